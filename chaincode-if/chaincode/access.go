@@ -0,0 +1,306 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// roleAttribute is the enrollment certificate attribute holding the caller's role
+const roleAttribute = "role"
+
+// Roles a caller can be enrolled with
+const (
+	RoleAdmin       = "admin"
+	RoleFarmer      = "farmer"
+	RoleCollector   = "collector"
+	RoleProcessor   = "processor"
+	RoleTransporter = "transporter"
+)
+
+// anyRole allows every enrolled role, used by read-only transactions
+var anyRole = []string{RoleAdmin, RoleFarmer, RoleCollector, RoleProcessor, RoleTransporter}
+
+// defaultMSPRoles are the roles the channel's MSPs may issue until their
+// admins set them with SetMSPRoles. Seeding another MSP changes the chaincode,
+// so it needs the approval of the channel's lifecycle endorsement policy.
+var defaultMSPRoles = map[string][]string{
+	"Org1MSP": anyRole,
+	"Org2MSP": anyRole,
+}
+
+// unlistedMSPRoles are the roles of MSPs that are neither seeded nor
+// configured, which may not act as admins
+var unlistedMSPRoles = []string{RoleFarmer, RoleCollector, RoleProcessor, RoleTransporter}
+
+// transactionRoles declares which roles may call each contract function.
+// Functions missing from this table cannot be called by anyone.
+var transactionRoles = map[string][]string{
+	"AddFarmer":                   {RoleAdmin, RoleFarmer},
+	"AddFarmerFromJSON":           {RoleAdmin, RoleFarmer},
+	"UpdateFarmer":                {RoleAdmin, RoleFarmer},
+	"UpdateFarmerFromJSON":        {RoleAdmin, RoleFarmer},
+	"PatchFarmer":                 {RoleAdmin, RoleFarmer},
+	"AddFarm":                     {RoleAdmin, RoleFarmer},
+	"AddFarmFromJSON":             {RoleAdmin, RoleFarmer},
+	"UpdateFarm":                  {RoleAdmin, RoleFarmer},
+	"UpdateFarmFromJSON":          {RoleAdmin, RoleFarmer},
+	"PatchFarm":                   {RoleAdmin, RoleFarmer},
+	"TransferFarmOwnership":       {RoleAdmin, RoleFarmer},
+	"AcceptFarmOwnership":         {RoleAdmin, RoleFarmer},
+	"RejectFarmOwnership":         {RoleAdmin, RoleFarmer},
+	"CancelFarmOwnershipTransfer": {RoleAdmin, RoleFarmer},
+	"AddCollector":                {RoleAdmin, RoleCollector},
+	"AddCollectorFromJSON":        {RoleAdmin, RoleCollector},
+	"UpdateCollector":             {RoleAdmin, RoleCollector},
+	"UpdateCollectorFromJSON":     {RoleAdmin, RoleCollector},
+	"PatchCollector":              {RoleAdmin, RoleCollector},
+	"ProposePartnership":          {RoleAdmin, RoleCollector, RoleFarmer},
+	"AcceptPartnership":           {RoleAdmin, RoleCollector, RoleFarmer},
+	"RejectPartnership":           {RoleAdmin, RoleCollector, RoleFarmer},
+	"EndPartnership":              {RoleAdmin, RoleCollector, RoleFarmer},
+	"AddProcessor":                {RoleAdmin, RoleProcessor},
+	"AddProcessorFromJSON":        {RoleAdmin, RoleProcessor},
+	"UpdateProcessor":             {RoleAdmin, RoleProcessor},
+	"UpdateProcessorFromJSON":     {RoleAdmin, RoleProcessor},
+	"PatchProcessor":              {RoleAdmin, RoleProcessor},
+	"AddTransporter":              {RoleAdmin, RoleTransporter},
+	"AddTransporterFromJSON":      {RoleAdmin, RoleTransporter},
+	"UpdateTransporter":           {RoleAdmin, RoleTransporter},
+	"UpdateTransporterFromJSON":   {RoleAdmin, RoleTransporter},
+	"PatchTransporter":            {RoleAdmin, RoleTransporter},
+
+	"Harvest":          {RoleAdmin, RoleFarmer},
+	"Collect":          {RoleAdmin, RoleCollector},
+	"Transport":        {RoleAdmin, RoleTransporter},
+	"Transported":      {RoleAdmin, RoleTransporter},
+	"Process":          {RoleAdmin, RoleProcessor},
+	"HoldCommodity":    {RoleAdmin, RoleCollector, RoleProcessor},
+	"ReleaseCommodity": {RoleAdmin, RoleCollector, RoleProcessor},
+	"RejectCommodity":  {RoleAdmin, RoleCollector, RoleProcessor},
+	"RecallBatch":      {RoleAdmin},
+
+	"SetExtractionRateRange": {RoleAdmin},
+	"SetMSPRoles":            {RoleAdmin},
+	"MigrateTraceability":    {RoleAdmin},
+	"MigrateEntityKeys":      {RoleAdmin},
+	"MigratePersonalData":    {RoleAdmin},
+	"QueryErasureRecordByID": {RoleAdmin},
+	"QueryAllErasureRecords": {RoleAdmin},
+	"ResolveIdentityNumber":  {RoleAdmin},
+
+	"QueryFarmerByID":                         anyRole,
+	"QueryAllFarmers":                         anyRole,
+	"QueryFarmByID":                           anyRole,
+	"QueryAllFarms":                           anyRole,
+	"QueryFarmTransfer":                       anyRole,
+	"QueryCollectorByID":                      anyRole,
+	"QueryAllCollectors":                      anyRole,
+	"QueryPartnership":                        anyRole,
+	"QueryPartnershipsByCollector":            anyRole,
+	"QueryPartnershipsByPartner":              anyRole,
+	"QueryProcessorByID":                      anyRole,
+	"QueryAllProcessors":                      anyRole,
+	"QueryTransporterByID":                    anyRole,
+	"QueryAllTransporters":                    anyRole,
+	"QueryCommodityByID":                      anyRole,
+	"QueryAllCommodities":                     anyRole,
+	"QueryProcessedCommodityByID":             anyRole,
+	"QueryAllProcessedCommodities":            anyRole,
+	"QueryRecallByID":                         anyRole,
+	"QueryExtractionRateRange":                anyRole,
+	"QueryMSPRoles":                           anyRole,
+	"QueryFarmersWithPagination":              anyRole,
+	"QueryFarmsWithPagination":                anyRole,
+	"QueryCollectorsWithPagination":           anyRole,
+	"QueryProcessorsWithPagination":           anyRole,
+	"QueryTransportersWithPagination":         anyRole,
+	"QueryCommoditiesWithPagination":          anyRole,
+	"QueryProcessedCommoditiesWithPagination": anyRole,
+	"QueryFarmersByNIK":                       anyRole,
+	"QueryCollectorsByNIB":                    anyRole,
+	"QueryProcessorsByNIB":                    anyRole,
+	"QueryFarmsByAttributes":                  anyRole,
+	"QueryCommoditiesByState":                 anyRole,
+	"QueryCommoditiesByStateWithPagination":   anyRole,
+	"QueryCommoditiesByHarvestDate":           anyRole,
+	"TraceProcessedCommodity":                 anyRole,
+	"TraceFarmForward":                        anyRole,
+	"TraceCommodityForward":                   anyRole,
+	"GetFarmerHistory":                        anyRole,
+	"GetFarmHistory":                          anyRole,
+	"GetCollectorHistory":                     anyRole,
+	"GetProcessorHistory":                     anyRole,
+	"GetTransporterHistory":                   anyRole,
+	"GetCommodityHistory":                     anyRole,
+	"GetEntityAsOf":                           anyRole,
+
+	// Further restricted to the enrolling identity and admins of its MSP
+	"QueryFarmerDetails":      anyRole,
+	"QueryCollectorDetails":   anyRole,
+	"QueryProcessorDetails":   anyRole,
+	"QueryTransporterDetails": anyRole,
+	"PurgePersonalData":       anyRole,
+}
+
+// PermissionError is returned when the caller may not invoke a contract function
+type PermissionError struct {
+	Function string
+	Role     string
+	MSPID    string
+	Reason   string
+}
+
+func (e *PermissionError) Error() string {
+	return fmt.Sprintf("permission denied: %s cannot be called by role %q of %s: %s", e.Function, e.Role, e.MSPID, e.Reason)
+}
+
+// callerRole returns the MSP ID and role attribute of the calling identity
+func callerRole(ctx contractapi.TransactionContextInterface) (string, string, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read client MSP ID: %v", err)
+	}
+
+	role, _, err := ctx.GetClientIdentity().GetAttributeValue(roleAttribute)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read client %s attribute: %v", roleAttribute, err)
+	}
+
+	return mspID, role, nil
+}
+
+// authorize checks that the caller's role may invoke the given contract
+// function and that the caller's MSP may issue that role
+func authorize(ctx contractapi.TransactionContextInterface, function string) error {
+	mspID, role, err := callerRole(ctx)
+	if err != nil {
+		return err
+	}
+
+	allowed, ok := transactionRoles[function]
+	if !ok {
+		return &PermissionError{Function: function, Role: role, MSPID: mspID, Reason: "no roles are declared for this function"}
+	}
+	if role == "" {
+		return &PermissionError{Function: function, Role: role, MSPID: mspID, Reason: "the certificate has no " + roleAttribute + " attribute"}
+	}
+	if !containsString(allowed, role) {
+		return &PermissionError{Function: function, Role: role, MSPID: mspID, Reason: "allowed roles are " + strings.Join(allowed, ", ")}
+	}
+
+	mspRoles, err := readMSPRoles(ctx, mspID)
+	if err != nil {
+		return err
+	}
+	if !containsString(mspRoles, role) {
+		return &PermissionError{Function: function, Role: role, MSPID: mspID, Reason: "the MSP may not issue this role"}
+	}
+
+	return nil
+}
+
+// SetMSPRoles restricts the roles an MSP's identities may act with. Only
+// admins of the MSP itself may set its roles, and the roles must keep admin so
+// they can be changed again. Until it is called for an MSP, the MSP's roles
+// are those seeded in defaultMSPRoles.
+func (pc *PalmOilContract) SetMSPRoles(ctx contractapi.TransactionContextInterface, mspID string, rolesInput string) error {
+	err := authorize(ctx, "SetMSPRoles")
+	if err != nil {
+		return err
+	}
+
+	callerMSPID, role, err := callerRole(ctx)
+	if err != nil {
+		return err
+	}
+	if mspID != callerMSPID {
+		return &PermissionError{Function: "SetMSPRoles", Role: role, MSPID: callerMSPID, Reason: "only admins of an MSP may set its roles"}
+	}
+
+	var roles []string
+	err = json.Unmarshal([]byte(rolesInput), &roles)
+	if err != nil {
+		return fmt.Errorf("failed to parse roles attribute: %v", err)
+	}
+	for _, role := range roles {
+		if !containsString(anyRole, role) {
+			return fmt.Errorf("unknown role %q", role)
+		}
+	}
+	if !containsString(roles, RoleAdmin) {
+		return fmt.Errorf("the roles of MSP %s must include %s, so its admins can change them again", mspID, RoleAdmin)
+	}
+
+	mspRoles := MSPRoles{MSPID: mspID, Roles: roles}
+	rolesJSON, err := json.Marshal(mspRoles)
+	if err != nil {
+		return err
+	}
+
+	err = putEntityState(ctx, mspRolesObjectType, mspID, rolesJSON)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventMSPRolesSet, mspRoles)
+}
+
+// MSPRoles lists the roles an MSP's identities may act with
+type MSPRoles struct {
+	MSPID string   `json:"mspId"`
+	Roles []string `json:"roles"`
+}
+
+// QueryMSPRoles retrieves the roles an MSP may issue
+func (pc *PalmOilContract) QueryMSPRoles(ctx contractapi.TransactionContextInterface, mspID string) (*MSPRoles, error) {
+	err := authorize(ctx, "QueryMSPRoles")
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := readMSPRoles(ctx, mspID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MSPRoles{MSPID: mspID, Roles: roles}, nil
+}
+
+// readMSPRoles returns the roles configured for an MSP, or its default roles
+// if none are
+func readMSPRoles(ctx contractapi.TransactionContextInterface, mspID string) ([]string, error) {
+	rolesJSON, err := getEntityState(ctx, mspRolesObjectType, mspID)
+	if err != nil {
+		return nil, err
+	}
+	if rolesJSON == nil {
+		if roles, ok := defaultMSPRoles[mspID]; ok {
+			return roles, nil
+		}
+		return unlistedMSPRoles, nil
+	}
+
+	var mspRoles MSPRoles
+	err = json.Unmarshal(rolesJSON, &mspRoles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal MSP roles JSON: %v", err)
+	}
+	if mspRoles.Roles == nil {
+		mspRoles.Roles = []string{}
+	}
+
+	return mspRoles.Roles, nil
+}
+
+// containsString reports whether a slice contains a value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package chaincode
+
+import (
+	"reflect"
+	"testing"
+
+	"chaincode-if/memledger"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestTransactionRolesCoverContract(t *testing.T) {
+	// Methods promoted from contractapi.Contract are not transactions of ours
+	inherited := make(map[string]bool)
+	contractType := reflect.TypeOf(&contractapi.Contract{})
+	for i := 0; i < contractType.NumMethod(); i++ {
+		inherited[contractType.Method(i).Name] = true
+	}
+
+	palmOilType := reflect.TypeOf(&PalmOilContract{})
+	for i := 0; i < palmOilType.NumMethod(); i++ {
+		name := palmOilType.Method(i).Name
+		if inherited[name] {
+			continue
+		}
+		if _, ok := transactionRoles[name]; !ok {
+			t.Errorf("%s has no entry in transactionRoles and cannot be called", name)
+		}
+	}
+
+	for name := range transactionRoles {
+		if _, ok := palmOilType.MethodByName(name); !ok {
+			t.Errorf("transactionRoles declares %s, which is not a contract method", name)
+		}
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	n := newTestNetwork(t)
+
+	tests := []struct {
+		name     string
+		caller   *memledger.Identity
+		function string
+		wantErr  string
+	}{
+		{name: "allowed role", caller: farmerUser, function: "Harvest"},
+		{name: "admin", caller: org1Admin, function: "RecallBatch"},
+		{name: "read by any role", caller: transporterUser, function: "QueryAllFarms"},
+		{name: "role not allowed", caller: transporterUser, function: "Harvest", wantErr: "allowed roles are admin, farmer"},
+		{name: "no role attribute", caller: noRoleUser, function: "QueryAllFarms", wantErr: "has no role attribute"},
+		{name: "undeclared function", caller: org1Admin, function: "DeleteEverything", wantErr: "no roles are declared"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := n.ledger.Evaluate(tt.caller, func(ctx contractapi.TransactionContextInterface) error {
+				return authorize(ctx, tt.function)
+			})
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr != "" && !isPermissionError(err) {
+				t.Errorf("expected a PermissionError, got %T", err)
+			}
+		})
+	}
+}
+
+func TestSetMSPRoles(t *testing.T) {
+	tests := []struct {
+		name      string
+		caller    *memledger.Identity
+		mspID     string
+		roles     string
+		wantRoles []string
+		wantErr   string
+	}{
+		{name: "restrict roles", caller: org2Admin, mspID: "Org2MSP", roles: `["admin","processor"]`, wantRoles: []string{"admin", "processor"}},
+		{name: "only admins", caller: org1Admin, mspID: "Org1MSP", roles: `["admin"]`, wantRoles: []string{"admin"}},
+		{name: "another MSP", caller: org2Admin, mspID: "Org1MSP", roles: `["admin"]`, wantErr: "only admins of an MSP may set its roles"},
+		{name: "without admin", caller: org1Admin, mspID: "Org1MSP", roles: `[]`, wantErr: "must include admin"},
+		{name: "unknown role", caller: org1Admin, mspID: "Org1MSP", roles: `["auditor"]`, wantErr: `unknown role "auditor"`},
+		{name: "invalid roles", caller: org1Admin, mspID: "Org1MSP", roles: `admin`, wantErr: "failed to parse roles attribute"},
+		{name: "empty MSP ID", caller: org1Admin, mspID: "", roles: `["admin"]`, wantErr: "permission denied"},
+		{name: "farmer role", caller: farmerUser, mspID: "Org1MSP", roles: `["admin"]`, wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(t)
+
+			err := n.submit(tt.caller, func(ctx contractapi.TransactionContextInterface) error {
+				return n.contract.SetMSPRoles(ctx, tt.mspID, tt.roles)
+			})
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+
+			roles := mustEvaluate(n, tt.caller, func(ctx contractapi.TransactionContextInterface) (*MSPRoles, error) {
+				return n.contract.QueryMSPRoles(ctx, tt.mspID)
+			})
+			if roles.MSPID != tt.mspID || !reflect.DeepEqual(roles.Roles, tt.wantRoles) {
+				t.Errorf("expected roles %v, got %+v", tt.wantRoles, roles)
+			}
+		})
+	}
+}
+
+func TestMSPRolesRestrictCallers(t *testing.T) {
+	org3Admin := memledger.MustNewIdentity("Org3MSP", "admin", map[string]string{roleAttribute: RoleAdmin})
+	org3Farmer := memledger.MustNewIdentity("Org3MSP", "farmer1", map[string]string{roleAttribute: RoleFarmer})
+	n := newTestNetwork(t)
+	n.mustSubmit(org1Admin, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.SetMSPRoles(ctx, "Org1MSP", `["admin","collector"]`)
+	})
+
+	tests := []struct {
+		name    string
+		caller  *memledger.Identity
+		wantErr string
+	}{
+		{name: "issued role", caller: collectorUser},
+		{name: "admin", caller: org1Admin},
+		{name: "role the MSP may not issue", caller: farmerUser, wantErr: "the MSP may not issue this role"},
+		{name: "seeded MSP", caller: org2Admin},
+		{name: "role of an unlisted MSP", caller: org3Farmer},
+		{name: "admin of an unlisted MSP", caller: org3Admin, wantErr: "the MSP may not issue this role"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := evaluate(n, tt.caller, n.contract.QueryAllFarms)
+			checkError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestQueryMSPRoles(t *testing.T) {
+	n := newTestNetwork(t)
+	n.mustSubmit(org1Admin, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.SetMSPRoles(ctx, "Org1MSP", `["admin","farmer","collector"]`)
+	})
+
+	tests := []struct {
+		name      string
+		caller    *memledger.Identity
+		mspID     string
+		wantRoles []string
+		wantErr   string
+	}{
+		{name: "configured", caller: collectorUser, mspID: "Org1MSP", wantRoles: []string{"admin", "farmer", "collector"}},
+		{name: "seeded", caller: collectorUser, mspID: "Org2MSP", wantRoles: anyRole},
+		{name: "unlisted", caller: collectorUser, mspID: "Org3MSP", wantRoles: unlistedMSPRoles},
+		{name: "no role", caller: noRoleUser, mspID: "Org2MSP", wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roles, err := evaluate(n, tt.caller, func(ctx contractapi.TransactionContextInterface) (*MSPRoles, error) {
+				return n.contract.QueryMSPRoles(ctx, tt.mspID)
+			})
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr == "" && !reflect.DeepEqual(roles.Roles, tt.wantRoles) {
+				t.Errorf("unexpected roles %+v", roles)
+			}
+		})
+	}
+}
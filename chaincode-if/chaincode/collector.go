@@ -7,28 +7,25 @@ import (
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
-// Collector represents the structure for a collector
+// Collector represents the structure for a collector. Partner lists the
+// farmers and farms it has an active partnership with and is kept by the
+// partnership transactions.
 type Collector struct {
-	ID       string   `json:"id"`
-	Name     string   `json:"name"`
-	NIB      string   `json:"nib"`
-	NIK      string   `json:"nik"`
-	NoHP     string   `json:"noHP"`
-	Email    string   `json:"email"`
-	Address  string   `json:"address"`
-	Capacity float64  `json:"capacity"`
-	Partner  []string `json:"partner"`
+	ID         string      `json:"id"`
+	Name       string      `json:"name"`
+	NIB        string      `json:"nib"`
+	NIKHash    string      `json:"nikHash"`
+	Address    string      `json:"address"`
+	Capacity   float64     `json:"capacity"`
+	Partner    []string    `json:"partner"`
+	EnrolledBy *Enrollment `json:"enrolledBy,omitempty" metadata:",optional"`
 }
 
 // AddCollector adds a new collector to the ledger
-func (pc *PalmOilContract) AddCollector(ctx contractapi.TransactionContextInterface, id string, name string, nib string, nik string, noHP string, email string, address string, capacity float64, partnersInput string) error {
-	// Check if a collector with the given ID already exists
-	existingCollectorJSON, err := ctx.GetStub().GetState(id)
+func (pc *PalmOilContract) AddCollector(ctx contractapi.TransactionContextInterface, id string, name string, nib string, address string, capacity float64, partnersInput string) error {
+	err := authorize(ctx, "AddCollector")
 	if err != nil {
-		return fmt.Errorf("failed to read from world state: %v", err)
-	}
-	if existingCollectorJSON != nil {
-		return fmt.Errorf("a collector with ID %s already exists", id)
+		return err
 	}
 
 	// Parse the partnersInput into a []string
@@ -38,16 +35,59 @@ func (pc *PalmOilContract) AddCollector(ctx contractapi.TransactionContextInterf
 		return fmt.Errorf("failed to parse partner attribute: %v", err)
 	}
 
-	collector := Collector{
-		ID:       id,
-		Name:     name,
-		NIB:      nib,
-		NIK:      nik,
-		NoHP:     noHP,
-		Email:    email,
-		Address:  address,
-		Capacity: capacity,
-		Partner:  partners,
+	return addCollector(ctx, Collector{ID: id, Name: name, NIB: nib, Address: address, Capacity: capacity, Partner: partners})
+}
+
+// AddCollectorFromJSON adds a new collector to the ledger from a JSON collector document
+func (pc *PalmOilContract) AddCollectorFromJSON(ctx contractapi.TransactionContextInterface, collectorJSON string) error {
+	err := authorize(ctx, "AddCollectorFromJSON")
+	if err != nil {
+		return err
+	}
+
+	var collector Collector
+	err = decodeDocument(collectorObjectType, collectorJSON, &collector)
+	if err != nil {
+		return err
+	}
+
+	return addCollector(ctx, collector)
+}
+
+// addCollector stores a new collector, enrolled by the caller
+func addCollector(ctx contractapi.TransactionContextInterface, collector Collector) error {
+	// Check if a collector with the given ID already exists
+	existingCollectorJSON, err := getEntityState(ctx, collectorObjectType, collector.ID)
+	if err != nil {
+		return err
+	}
+	if existingCollectorJSON != nil {
+		return fmt.Errorf("a collector with ID %s already exists", collector.ID)
+	}
+
+	collector.EnrolledBy, err = callerEnrollment(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = validateCollector(ctx, &collector)
+	if err != nil {
+		return err
+	}
+
+	err = keepPartnerList(&collector, []string{})
+	if err != nil {
+		return err
+	}
+
+	err = claimIdentity(ctx, IdentityNIB, collector.NIB, collectorObjectType, collector.ID)
+	if err != nil {
+		return err
+	}
+
+	collector.NIKHash, err = addPersonalData(ctx, collectorObjectType, collector.ID, collector.EnrolledBy)
+	if err != nil {
+		return err
 	}
 
 	collectorJSON, err := json.Marshal(collector)
@@ -55,17 +95,19 @@ func (pc *PalmOilContract) AddCollector(ctx contractapi.TransactionContextInterf
 		return err
 	}
 
-	return ctx.GetStub().PutState(id, collectorJSON)
+	err = putEntityState(ctx, collectorObjectType, collector.ID, collectorJSON)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventCollectorAdded, collectorPayload(&collector))
 }
 
 // UpdateCollector updates an existing collector on the ledger
-func (pc *PalmOilContract) UpdateCollector(ctx contractapi.TransactionContextInterface, id string, name string, nib string, nik string, noHP string, email string, address string, capacity float64, partnersInput string) error {
-	collectorJSON, err := ctx.GetStub().GetState(id)
+func (pc *PalmOilContract) UpdateCollector(ctx contractapi.TransactionContextInterface, id string, name string, nib string, address string, capacity float64, partnersInput string) error {
+	err := authorize(ctx, "UpdateCollector")
 	if err != nil {
-		return fmt.Errorf("failed to read from world state: %v", err)
-	}
-	if collectorJSON == nil {
-		return fmt.Errorf("the collector with ID %s does not exist", id)
+		return err
 	}
 
 	// Parse the partnersInput into a []string
@@ -75,33 +117,126 @@ func (pc *PalmOilContract) UpdateCollector(ctx contractapi.TransactionContextInt
 		return fmt.Errorf("failed to parse partner attribute: %v", err)
 	}
 
+	return updateCollector(ctx, "UpdateCollector", id, func(collector *Collector) error {
+		collector.Name = name
+		collector.NIB = nib
+		collector.Address = address
+		collector.Capacity = capacity
+		collector.Partner = partners
+		return nil
+	})
+}
+
+// UpdateCollectorFromJSON replaces every attribute of an existing collector with those
+// of a JSON collector document
+func (pc *PalmOilContract) UpdateCollectorFromJSON(ctx contractapi.TransactionContextInterface, collectorJSON string) error {
+	err := authorize(ctx, "UpdateCollectorFromJSON")
+	if err != nil {
+		return err
+	}
+
+	var update Collector
+	err = decodeDocument(collectorObjectType, collectorJSON, &update)
+	if err != nil {
+		return err
+	}
+
+	return updateCollector(ctx, "UpdateCollectorFromJSON", update.ID, func(collector *Collector) error {
+		collector.Name = update.Name
+		collector.NIB = update.NIB
+		collector.Address = update.Address
+		collector.Capacity = update.Capacity
+		collector.Partner = update.Partner
+		return nil
+	})
+}
+
+// PatchCollector changes the attributes of an existing collector named in a JSON
+// patch document and leaves the others unchanged
+func (pc *PalmOilContract) PatchCollector(ctx contractapi.TransactionContextInterface, id string, patchJSON string) error {
+	err := authorize(ctx, "PatchCollector")
+	if err != nil {
+		return err
+	}
+
+	return updateCollector(ctx, "PatchCollector", id, func(collector *Collector) error {
+		return applyPatch(collectorObjectType, collector, patchJSON)
+	})
+}
+
+// updateCollector changes the attributes of an existing collector with update,
+// after checking the caller may change it
+func updateCollector(ctx contractapi.TransactionContextInterface, function string, id string, update func(collector *Collector) error) error {
+	collectorJSON, err := getEntityState(ctx, collectorObjectType, id)
+	if err != nil {
+		return err
+	}
+	if collectorJSON == nil {
+		return fmt.Errorf("the collector with ID %s does not exist", id)
+	}
+
 	var collector Collector
 	json.Unmarshal(collectorJSON, &collector)
 
+	err = authorizeOwner(ctx, function, collector.EnrolledBy)
+	if err != nil {
+		return err
+	}
+
 	// Update the collector's attributes
-	collector.Name = name
-	collector.NIB = nib
-	collector.NIK = nik
-	collector.NoHP = noHP
-	collector.Email = email
-	collector.Address = address
-	collector.Capacity = capacity
-	collector.Partner = partners
+	previousNIB := collector.NIB
+	previousPartners := collector.Partner
+	if previousPartners == nil {
+		previousPartners = []string{}
+	}
+	err = update(&collector)
+	if err != nil {
+		return err
+	}
+
+	err = validateCollector(ctx, &collector)
+	if err != nil {
+		return err
+	}
+
+	err = keepPartnerList(&collector, previousPartners)
+	if err != nil {
+		return err
+	}
+
+	err = reindexIdentity(ctx, IdentityNIB, previousNIB, collector.NIB, collectorObjectType, id)
+	if err != nil {
+		return err
+	}
+
+	collector.NIKHash, err = updatePersonalData(ctx, collectorObjectType, id, collector.EnrolledBy, collector.NIKHash)
+	if err != nil {
+		return err
+	}
 
 	collectorJSON, err = json.Marshal(collector)
 	if err != nil {
 		return err
 	}
 
-	return ctx.GetStub().PutState(id, collectorJSON)
-}
+	err = putEntityState(ctx, collectorObjectType, id, collectorJSON)
+	if err != nil {
+		return err
+	}
 
+	return emitEvent(ctx, EventCollectorUpdated, collectorPayload(&collector))
+}
 
 // QueryCollectorByID retrieves a collector by its ID from the ledger
 func (pc *PalmOilContract) QueryCollectorByID(ctx contractapi.TransactionContextInterface, id string) (*Collector, error) {
-	collectorJSON, err := ctx.GetStub().GetState(id)
+	err := authorize(ctx, "QueryCollectorByID")
+	if err != nil {
+		return nil, err
+	}
+
+	collectorJSON, err := getEntityState(ctx, collectorObjectType, id)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read from world state: %v", err)
+		return nil, err
 	}
 	if collectorJSON == nil {
 		return nil, fmt.Errorf("the collector with ID %s does not exist", id)
@@ -115,23 +250,10 @@ func (pc *PalmOilContract) QueryCollectorByID(ctx contractapi.TransactionContext
 
 // QueryAllCollectors retrieves all collectors from the ledger
 func (pc *PalmOilContract) QueryAllCollectors(ctx contractapi.TransactionContextInterface) ([]*Collector, error) {
-	resultsIterator, err := ctx.GetStub().GetStateByRange("COL_", "COL_zzzzzzzzzz")
+	err := authorize(ctx, "QueryAllCollectors")
 	if err != nil {
 		return nil, err
 	}
-	defer resultsIterator.Close()
-
-	var collectors []*Collector
-	for resultsIterator.HasNext() {
-		queryResponse, err := resultsIterator.Next()
-		if err != nil {
-			return nil, err
-		}
-
-		var collector Collector
-		json.Unmarshal(queryResponse.Value, &collector)
-		collectors = append(collectors, &collector)
-	}
 
-	return collectors, nil
+	return queryAllEntities[Collector](ctx, collectorObjectType)
 }
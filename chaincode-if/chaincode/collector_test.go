@@ -0,0 +1,141 @@
+package chaincode
+
+import (
+	"reflect"
+	"testing"
+
+	"chaincode-if/memledger"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestAddCollector(t *testing.T) {
+	tests := []struct {
+		name     string
+		caller   *memledger.Identity
+		id       string
+		partners string
+		opts     []memledger.TxOption
+		wantErr  string
+	}{
+		{name: "collector", caller: collectorUser, id: "COL_002", partners: `[]`, opts: []memledger.TxOption{personal("1471010101900010")}},
+		{name: "admin", caller: org1Admin, id: "COL_002", partners: `[]`, opts: []memledger.TxOption{personal("1471010101900010")}},
+		{name: "duplicate ID", caller: collectorUser, id: "COL_001", partners: `[]`, opts: []memledger.TxOption{personal("1471010101900010")}, wantErr: "already exists"},
+		{name: "no personal data", caller: collectorUser, id: "COL_002", partners: `[]`, wantErr: "transient data"},
+		{name: "short salt", caller: collectorUser, id: "COL_002", partners: `[]`, opts: []memledger.TxOption{memledger.WithTransient(map[string][]byte{personalTransientKey: []byte(`{"nik":"1471010101900010","salt":"short"}`)})}, wantErr: "salt of at least"},
+		{name: "invalid partners", caller: collectorUser, id: "COL_002", partners: `FRM_001`, opts: []memledger.TxOption{personal("1471010101900010")}, wantErr: "failed to parse partner attribute"},
+		{name: "partners without partnerships", caller: collectorUser, id: "COL_002", partners: `["FRM_001"]`, opts: []memledger.TxOption{personal("1471010101900010")}, wantErr: "follow its partnerships"},
+		{name: "farmer role", caller: farmerUser, id: "COL_002", partners: `[]`, opts: []memledger.TxOption{personal("1471010101900010")}, wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(t)
+			n.addCollector(collectorUser, "COL_001", "1234567890123")
+
+			err := n.submit(tt.caller, func(ctx contractapi.TransactionContextInterface) error {
+				return n.contract.AddCollector(ctx, tt.id, "KUD Makmur", "9876543210987", "Siak", 250, tt.partners)
+			}, tt.opts...)
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+
+			collector := mustEvaluate(n, tt.caller, func(ctx contractapi.TransactionContextInterface) (*Collector, error) {
+				return n.contract.QueryCollectorByID(ctx, tt.id)
+			})
+			if collector.NIB != "9876543210987" || collector.Capacity != 250 {
+				t.Errorf("unexpected collector %+v", collector)
+			}
+			if collector.NIKHash != hashNIK(testSalt, "1471010101900010") {
+				t.Errorf("expected the salted NIK hash, got %q", collector.NIKHash)
+			}
+		})
+	}
+}
+
+func TestUpdateCollector(t *testing.T) {
+	tests := []struct {
+		name     string
+		caller   *memledger.Identity
+		id       string
+		partners string
+		wantErr  string
+	}{
+		{name: "enrolling collector", caller: collectorUser, id: "COL_001", partners: `["FRM_001"]`},
+		{name: "admin", caller: org1Admin, id: "COL_001", partners: `["FRM_001"]`},
+		{name: "partner without a partnership", caller: collectorUser, id: "COL_001", partners: `["FRM_001","FRM_002"]`, wantErr: "follow its partnerships"},
+		{name: "partner removed", caller: collectorUser, id: "COL_001", partners: `[]`, wantErr: "follow its partnerships"},
+		{name: "missing collector", caller: collectorUser, id: "COL_404", partners: `[]`, wantErr: "does not exist"},
+		{name: "invalid partners", caller: collectorUser, id: "COL_001", partners: `[1]`, wantErr: "failed to parse partner attribute"},
+		{name: "admin of another org", caller: org2Admin, id: "COL_001", partners: `[]`, wantErr: "permission denied"},
+		{name: "processor role", caller: processorUser, id: "COL_001", partners: `[]`, wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(t)
+			n.addCollector(collectorUser, "COL_001", "1234567890123")
+			n.addFarmer(farmerUser, "FRM_001")
+			n.addPartnership("PRT_001", "COL_001", PartnerFarmer, "FRM_001")
+
+			err := n.submit(tt.caller, func(ctx contractapi.TransactionContextInterface) error {
+				return n.contract.UpdateCollector(ctx, tt.id, "KUD Sejahtera", "1234567890123", "Siak", 750, tt.partners)
+			})
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+
+			collector := mustEvaluate(n, tt.caller, func(ctx contractapi.TransactionContextInterface) (*Collector, error) {
+				return n.contract.QueryCollectorByID(ctx, tt.id)
+			})
+			if collector.Name != "KUD Sejahtera" || collector.Capacity != 750 || !reflect.DeepEqual(collector.Partner, []string{"FRM_001"}) {
+				t.Errorf("the collector was not updated: %+v", collector)
+			}
+			if collector.NIKHash != hashNIK(testSalt, "1471010101900001") {
+				t.Errorf("the NIK hash changed without new personal data: %q", collector.NIKHash)
+			}
+		})
+	}
+}
+
+func TestQueryCollectorByID(t *testing.T) {
+	n := newTestNetwork(t)
+	n.addCollector(collectorUser, "COL_001", "1234567890123")
+
+	tests := []struct {
+		name    string
+		caller  *memledger.Identity
+		id      string
+		wantErr string
+	}{
+		{name: "existing", caller: farmerUser, id: "COL_001"},
+		{name: "missing", caller: farmerUser, id: "COL_404", wantErr: "does not exist"},
+		{name: "no role", caller: noRoleUser, id: "COL_001", wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector, err := evaluate(n, tt.caller, func(ctx contractapi.TransactionContextInterface) (*Collector, error) {
+				return n.contract.QueryCollectorByID(ctx, tt.id)
+			})
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr == "" && collector.ID != tt.id {
+				t.Errorf("expected %s, got %+v", tt.id, collector)
+			}
+		})
+	}
+}
+
+func TestQueryAllCollectors(t *testing.T) {
+	n := newTestNetwork(t)
+	n.addCollector(collectorUser, "COL_001", "1234567890123")
+	n.addCollector(org1Admin, "COL_002", "1234567890124")
+	n.addProcessor(processorUser, "COL_003", "1234567890125")
+
+	collectors := mustEvaluate(n, farmerUser, n.contract.QueryAllCollectors)
+	if len(collectors) != 2 || collectors[0].ID != "COL_001" || collectors[1].ID != "COL_002" {
+		t.Fatalf("expected COL_001 and COL_002, got %+v", collectors)
+	}
+}
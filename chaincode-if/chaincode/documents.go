@@ -0,0 +1,90 @@
+package chaincode
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// managedFields are the entity fields set by the contract itself, which a
+// document or patch cannot set
+var managedFields = []string{"nikHash", "enrolledBy", "docType"}
+
+// decodeDocument decodes a JSON document into an entity. Fields the entity
+// does not have, trailing data and fields managed by the contract are rejected.
+func decodeDocument(objectType string, document string, entity interface{}) error {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal([]byte(document), &fields)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s document: %v", objectType, err)
+	}
+	for _, field := range managedFields {
+		if _, ok := fields[field]; ok {
+			return fmt.Errorf("the %s document must not set %s, which is managed by the contract", objectType, field)
+		}
+	}
+
+	return decodeStrict(objectType+" document", []byte(document), entity)
+}
+
+// decodeStrict decodes JSON into an entity, rejecting fields the entity does not have
+func decodeStrict(what string, data []byte, entity interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(entity)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %v", what, err)
+	}
+	if decoder.More() {
+		return fmt.Errorf("failed to parse %s: unexpected data at the end", what)
+	}
+
+	return nil
+}
+
+// applyPatch changes the fields of an entity named in a JSON patch document
+// and leaves the others unchanged. The ID, contract-managed fields and null
+// values cannot be patched.
+func applyPatch(objectType string, entity interface{}, patch string) error {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal([]byte(patch), &fields)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s patch: %v", objectType, err)
+	}
+	if len(fields) == 0 {
+		return fmt.Errorf("the %s patch does not change any field", objectType)
+	}
+
+	immutable := append([]string{"id"}, managedFields...)
+	for name, value := range fields {
+		for _, field := range immutable {
+			if name == field {
+				return fmt.Errorf("the %s patch must not change %s", objectType, name)
+			}
+		}
+		if strings.TrimSpace(string(value)) == "null" {
+			return fmt.Errorf("the %s patch must not set %s to null", objectType, name)
+		}
+	}
+
+	entityJSON, err := json.Marshal(entity)
+	if err != nil {
+		return err
+	}
+	var current map[string]json.RawMessage
+	err = json.Unmarshal(entityJSON, &current)
+	if err != nil {
+		return err
+	}
+	for name, value := range fields {
+		current[name] = value
+	}
+
+	patchedJSON, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+
+	return decodeStrict(objectType+" patch", patchedJSON, entity)
+}
@@ -0,0 +1,272 @@
+package chaincode
+
+import (
+	"reflect"
+	"testing"
+
+	"chaincode-if/memledger"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestAddFromJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		caller   *memledger.Identity
+		document string
+		opts     []memledger.TxOption
+		wantErr  string
+	}{
+		{name: "document", caller: farmerUser, document: `{"id":"FRM_002","name":"Slamet","address":"Riau","farm":[]}`, opts: []memledger.TxOption{personal("1471010101900009")}},
+		{name: "missing list", caller: farmerUser, document: `{"id":"FRM_002","name":"Slamet"}`, opts: []memledger.TxOption{personal("1471010101900009")}},
+		{name: "unknown field", caller: farmerUser, document: `{"id":"FRM_002","nama":"Slamet"}`, opts: []memledger.TxOption{personal("1471010101900009")}, wantErr: `unknown field "nama"`},
+		{name: "wrong type", caller: farmerUser, document: `{"id":"FRM_002","farm":"FARM_002"}`, opts: []memledger.TxOption{personal("1471010101900009")}, wantErr: "failed to parse farmer document"},
+		{name: "managed field", caller: farmerUser, document: `{"id":"FRM_002","nikHash":"abc"}`, opts: []memledger.TxOption{personal("1471010101900009")}, wantErr: "must not set nikHash"},
+		{name: "trailing data", caller: farmerUser, document: `{"id":"FRM_002"} {}`, opts: []memledger.TxOption{personal("1471010101900009")}, wantErr: "failed to parse farmer document"},
+		{name: "not an object", caller: farmerUser, document: `["FRM_002"]`, opts: []memledger.TxOption{personal("1471010101900009")}, wantErr: "failed to parse farmer document"},
+		{name: "empty ID", caller: farmerUser, document: `{"name":"Slamet"}`, opts: []memledger.TxOption{personal("1471010101900009")}, wantErr: "must not be empty"},
+		{name: "duplicate ID", caller: farmerUser, document: `{"id":"FRM_001"}`, opts: []memledger.TxOption{personal("1471010101900009")}, wantErr: "already exists"},
+		{name: "no personal data", caller: farmerUser, document: `{"id":"FRM_002"}`, wantErr: "transient data"},
+		{name: "collector role", caller: collectorUser, document: `{"id":"FRM_002"}`, opts: []memledger.TxOption{personal("1471010101900009")}, wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(t)
+			n.addFarmer(farmerUser, "FRM_001")
+			n.addFarm(org1Admin, "FARM_002", "")
+
+			err := n.submit(tt.caller, func(ctx contractapi.TransactionContextInterface) error {
+				return n.contract.AddFarmerFromJSON(ctx, tt.document)
+			}, tt.opts...)
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+
+			farmer := mustEvaluate(n, tt.caller, func(ctx contractapi.TransactionContextInterface) (*Farmer, error) {
+				return n.contract.QueryFarmerByID(ctx, "FRM_002")
+			})
+			if farmer.NIKHash != hashNIK(testSalt, "1471010101900009") {
+				t.Errorf("expected the salted NIK hash, got %q", farmer.NIKHash)
+			}
+			if farmer.EnrolledBy == nil || farmer.EnrolledBy.MSPID != tt.caller.MSPID {
+				t.Errorf("expected the farmer to be enrolled by %s, got %+v", tt.caller.MSPID, farmer.EnrolledBy)
+			}
+			if farmer.Farm == nil {
+				t.Error("expected the farms to be an empty list, got nil")
+			}
+			if event := n.lastEvent(); event.Name != EventFarmerAdded {
+				t.Errorf("expected a %s event, got %s", EventFarmerAdded, event.Name)
+			}
+		})
+	}
+}
+
+func TestAddEntitiesFromJSON(t *testing.T) {
+	n := newTestNetwork(t)
+	n.addFarmer(farmerUser, "FRM_001")
+
+	n.mustSubmit(farmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.AddFarmFromJSON(ctx, `{"id":"FARM_001","owner":"FRM_001","plantedYear":2010,"area":2.5}`)
+	})
+	n.mustSubmit(collectorUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.AddCollectorFromJSON(ctx, `{"id":"COL_001","nib":"1234567890123","capacity":500,"partner":[]}`)
+	}, personal("1471010101900002"))
+	n.mustSubmit(processorUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.AddProcessorFromJSON(ctx, `{"id":"PRC_001","nib":"1234567890124","capacity":1000}`)
+	}, personal("1471010101900003"))
+	n.mustSubmit(transporterUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.AddTransporterFromJSON(ctx, `{"id":"TRP_001","numShip":3}`)
+	}, personal("1471010101900004"))
+
+	farm := mustEvaluate(n, farmerUser, func(ctx contractapi.TransactionContextInterface) (*Farm, error) {
+		return n.contract.QueryFarmByID(ctx, "FARM_001")
+	})
+	if farm.Owner != "FRM_001" || farm.PlantedYear != 2010 || farm.Area != 2.5 {
+		t.Errorf("unexpected farm %+v", farm)
+	}
+	collector := mustEvaluate(n, collectorUser, func(ctx contractapi.TransactionContextInterface) (*Collector, error) {
+		return n.contract.QueryCollectorByID(ctx, "COL_001")
+	})
+	if !reflect.DeepEqual(collector.Partner, []string{}) || collector.Capacity != 500 {
+		t.Errorf("unexpected collector %+v", collector)
+	}
+	processor := mustEvaluate(n, processorUser, func(ctx contractapi.TransactionContextInterface) (*Processor, error) {
+		return n.contract.QueryProcessorByID(ctx, "PRC_001")
+	})
+	if processor.NIB != "1234567890124" || processor.EnrolledBy == nil {
+		t.Errorf("unexpected processor %+v", processor)
+	}
+	transporter := mustEvaluate(n, transporterUser, func(ctx contractapi.TransactionContextInterface) (*Transporter, error) {
+		return n.contract.QueryTransporterByID(ctx, "TRP_001")
+	})
+	if transporter.NumShip != 3 || transporter.NIKHash == "" {
+		t.Errorf("unexpected transporter %+v", transporter)
+	}
+
+	err := n.submit(otherFarmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.AddFarmFromJSON(ctx, `{"id":"FARM_002","owner":"FRM_001"}`)
+	})
+	checkError(t, err, "permission denied")
+}
+
+func TestUpdateFromJSON(t *testing.T) {
+	n := newTestNetwork(t)
+	n.addCollector(collectorUser, "COL_001", "1234567890123")
+
+	n.mustSubmit(collectorUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.UpdateCollectorFromJSON(ctx, `{"id":"COL_001","name":"Koperasi Sawit","capacity":750}`)
+	})
+
+	collector := mustEvaluate(n, collectorUser, func(ctx contractapi.TransactionContextInterface) (*Collector, error) {
+		return n.contract.QueryCollectorByID(ctx, "COL_001")
+	})
+	// A document replaces every attribute, so omitted ones are cleared
+	want := Collector{
+		ID:         "COL_001",
+		Name:       "Koperasi Sawit",
+		NIKHash:    hashNIK(testSalt, "1471010101900001"),
+		Capacity:   750,
+		Partner:    []string{},
+		EnrolledBy: collector.EnrolledBy,
+	}
+	if !reflect.DeepEqual(*collector, want) {
+		t.Errorf("expected %+v, got %+v", want, *collector)
+	}
+	if event := n.lastEvent(); event.Name != EventCollectorUpdated {
+		t.Errorf("expected a %s event, got %s", EventCollectorUpdated, event.Name)
+	}
+
+	tests := []struct {
+		name     string
+		caller   *memledger.Identity
+		document string
+		wantErr  string
+	}{
+		{name: "missing collector", caller: collectorUser, document: `{"id":"COL_404"}`, wantErr: "does not exist"},
+		{name: "unknown field", caller: collectorUser, document: `{"id":"COL_001","partners":[]}`, wantErr: `unknown field "partners"`},
+		{name: "managed field", caller: collectorUser, document: `{"id":"COL_001","enrolledBy":null}`, wantErr: "must not set enrolledBy"},
+		{name: "admin of another org", caller: org2Admin, document: `{"id":"COL_001"}`, wantErr: "permission denied"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := n.submit(tt.caller, func(ctx contractapi.TransactionContextInterface) error {
+				return n.contract.UpdateCollectorFromJSON(ctx, tt.document)
+			})
+			checkError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestPatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		caller  *memledger.Identity
+		id      string
+		patch   string
+		opts    []memledger.TxOption
+		wantErr string
+		want    func(farmer *Farmer)
+	}{
+		{name: "one field", caller: farmerUser, id: "FRM_001", patch: `{"address":"Kampar"}`, want: func(farmer *Farmer) { farmer.Address = "Kampar" }},
+		{name: "list", caller: org1Admin, id: "FRM_001", patch: `{"farm":["FARM_001","FARM_002"]}`, want: func(farmer *Farmer) { farmer.Farm = []string{"FARM_001", "FARM_002"} }},
+		{name: "empty list", caller: farmerUser, id: "FRM_001", patch: `{"farm":[]}`, want: func(farmer *Farmer) { farmer.Farm = []string{} }},
+		{name: "new personal data", caller: farmerUser, id: "FRM_001", patch: `{"name":"Slamet"}`, opts: []memledger.TxOption{personal("1471010101900099")}, want: func(farmer *Farmer) {
+			farmer.Name = "Slamet"
+			farmer.NIKHash = hashNIK(testSalt, "1471010101900099")
+		}},
+		{name: "ID", caller: farmerUser, id: "FRM_001", patch: `{"id":"FRM_002"}`, wantErr: "must not change id"},
+		{name: "managed field", caller: farmerUser, id: "FRM_001", patch: `{"nikHash":""}`, wantErr: "must not change nikHash"},
+		{name: "null", caller: farmerUser, id: "FRM_001", patch: `{"farm":null}`, wantErr: "must not set farm to null"},
+		{name: "empty patch", caller: farmerUser, id: "FRM_001", patch: `{}`, wantErr: "does not change any field"},
+		{name: "unknown field", caller: farmerUser, id: "FRM_001", patch: `{"farms":[]}`, wantErr: `unknown field "farms"`},
+		{name: "wrong type", caller: farmerUser, id: "FRM_001", patch: `{"name":7}`, wantErr: "failed to parse farmer patch"},
+		{name: "missing farmer", caller: farmerUser, id: "FRM_404", patch: `{"name":"Slamet"}`, wantErr: "does not exist"},
+		{name: "another farmer", caller: otherFarmerUser, id: "FRM_001", patch: `{"name":"Slamet"}`, wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(t)
+			n.addFarmer(farmerUser, "FRM_001")
+			n.addFarm(org1Admin, "FARM_001", "")
+			n.addFarm(org1Admin, "FARM_002", "")
+			before := mustEvaluate(n, farmerUser, func(ctx contractapi.TransactionContextInterface) (*Farmer, error) {
+				return n.contract.QueryFarmerByID(ctx, "FRM_001")
+			})
+
+			err := n.submit(tt.caller, func(ctx contractapi.TransactionContextInterface) error {
+				return n.contract.PatchFarmer(ctx, tt.id, tt.patch)
+			}, tt.opts...)
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+
+			farmer := mustEvaluate(n, farmerUser, func(ctx contractapi.TransactionContextInterface) (*Farmer, error) {
+				return n.contract.QueryFarmerByID(ctx, "FRM_001")
+			})
+			want := *before
+			tt.want(&want)
+			if !reflect.DeepEqual(*farmer, want) {
+				t.Errorf("expected %+v, got %+v", want, *farmer)
+			}
+			if event := n.lastEvent(); event.Name != EventFarmerUpdated {
+				t.Errorf("expected a %s event, got %s", EventFarmerUpdated, event.Name)
+			}
+		})
+	}
+}
+
+func TestPatchEntities(t *testing.T) {
+	n := newTestNetwork(t)
+	n.addFarmer(farmerUser, "FRM_001")
+	n.addFarm(farmerUser, "FARM_001", "FRM_001")
+	n.addCollector(collectorUser, "COL_001", "1234567890123")
+	n.addProcessor(processorUser, "PRC_001", "1234567890124")
+	n.addTransporter(transporterUser, "TRP_001")
+
+	n.mustSubmit(farmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.PatchFarm(ctx, "FARM_001", `{"certificate":"ISPO"}`)
+	})
+	n.mustSubmit(collectorUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.PatchCollector(ctx, "COL_001", `{"address":"Siak"}`)
+	})
+	n.mustSubmit(processorUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.PatchProcessor(ctx, "PRC_001", `{"capacity":1500}`)
+	})
+	n.mustSubmit(transporterUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.PatchTransporter(ctx, "TRP_001", `{"numShip":5}`)
+	})
+
+	farm := mustEvaluate(n, farmerUser, func(ctx contractapi.TransactionContextInterface) (*Farm, error) {
+		return n.contract.QueryFarmByID(ctx, "FARM_001")
+	})
+	if farm.Certificate != "ISPO" || farm.Legality != "SHM" || farm.Owner != "FRM_001" {
+		t.Errorf("unexpected farm %+v", farm)
+	}
+	collector := mustEvaluate(n, collectorUser, func(ctx contractapi.TransactionContextInterface) (*Collector, error) {
+		return n.contract.QueryCollectorByID(ctx, "COL_001")
+	})
+	if collector.Address != "Siak" || collector.Capacity != 500 {
+		t.Errorf("unexpected collector %+v", collector)
+	}
+	processor := mustEvaluate(n, processorUser, func(ctx contractapi.TransactionContextInterface) (*Processor, error) {
+		return n.contract.QueryProcessorByID(ctx, "PRC_001")
+	})
+	if processor.Capacity != 1500 || processor.NIB != "1234567890124" {
+		t.Errorf("unexpected processor %+v", processor)
+	}
+	transporter := mustEvaluate(n, transporterUser, func(ctx contractapi.TransactionContextInterface) (*Transporter, error) {
+		return n.contract.QueryTransporterByID(ctx, "TRP_001")
+	})
+	if transporter.NumShip != 5 || transporter.Name != "Transporter TRP_001" {
+		t.Errorf("unexpected transporter %+v", transporter)
+	}
+
+	err := n.submit(otherFarmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.PatchFarm(ctx, "FARM_001", `{"certificate":""}`)
+	})
+	checkError(t, err, "permission denied")
+}
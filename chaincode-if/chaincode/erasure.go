@@ -0,0 +1,198 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// personalObjectTypes are the entity types that hold personal data
+var personalObjectTypes = []string{farmerObjectType, collectorObjectType, processorObjectType, transporterObjectType}
+
+// ErasureRecord documents the purge of an entity's personal data. ID is the
+// ID of the purging transaction.
+type ErasureRecord struct {
+	ID          string     `json:"id"`
+	EntityType  string     `json:"entityType"`
+	EntityID    string     `json:"entityId"`
+	NIKHash     string     `json:"nikHash"`
+	Collection  string     `json:"collection"`
+	RequestedBy Enrollment `json:"requestedBy"`
+	Timestamp   string     `json:"timestamp"`
+}
+
+// PurgePersonalData permanently removes the personal data of a farmer,
+// collector, processor or transporter from its organization's private data
+// collection, including its past versions. The public record keeps its NIK
+// hash and traceability links, so lineage still verifies. The purge is
+// documented by an erasure record.
+func (pc *PalmOilContract) PurgePersonalData(ctx contractapi.TransactionContextInterface, entityID string) (*ErasureRecord, error) {
+	err := authorize(ctx, "PurgePersonalData")
+	if err != nil {
+		return nil, err
+	}
+
+	objectType, entityJSON, err := findPersonalEntity(ctx, entityID)
+	if err != nil {
+		return nil, err
+	}
+
+	var entity struct {
+		NIKHash    string      `json:"nikHash"`
+		EnrolledBy *Enrollment `json:"enrolledBy"`
+	}
+	err = json.Unmarshal(entityJSON, &entity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s JSON: %v", objectType, err)
+	}
+
+	err = authorizeOwner(ctx, "PurgePersonalData", entity.EnrolledBy)
+	if err != nil {
+		return nil, err
+	}
+
+	mspID, err := ownerMSPID(ctx, entity.EnrolledBy)
+	if err != nil {
+		return nil, err
+	}
+	err = verifyPeerOrg(mspID)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := entityKey(ctx, objectType, entityID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The NIK is erased from the uniqueness index too, so it can no longer be
+	// resolved to the entity
+	personal, err := getPersonalData(ctx, objectType, entityID, mspID)
+	if err != nil {
+		return nil, err
+	}
+	if personal != nil {
+		err = releaseIdentity(ctx, IdentityNIK, personal.NIK, objectType, entityID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	collection := personalDataCollection(mspID)
+	err = ctx.GetStub().PurgePrivateData(collection, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to purge personal data: %v", err)
+	}
+
+	// Entities not yet moved by MigratePersonalData still hold their
+	// personal data in the world state
+	redactedJSON, err := redactPersonalFields(string(entityJSON))
+	if err != nil {
+		return nil, fmt.Errorf("failed to redact %s JSON: %v", objectType, err)
+	}
+	if redactedJSON != string(entityJSON) {
+		err = putEntityState(ctx, objectType, entityID, []byte(redactedJSON))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	requestedBy, err := callerEnrollment(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transaction timestamp: %v", err)
+	}
+
+	record := ErasureRecord{
+		ID:          ctx.GetStub().GetTxID(),
+		EntityType:  objectType,
+		EntityID:    entityID,
+		NIKHash:     entity.NIKHash,
+		Collection:  collection,
+		RequestedBy: *requestedBy,
+		Timestamp:   time.Unix(timestamp.GetSeconds(), int64(timestamp.GetNanos())).UTC().Format(time.RFC3339Nano),
+	}
+
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+
+	err = putEntityState(ctx, erasureObjectType, record.ID, recordJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitEvent(ctx, EventPersonalDataPurged, ErasurePayload{ID: record.ID, EntityType: objectType, EntityID: entityID})
+	if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// findPersonalEntity looks up the entity holding personal data with the given
+// ID. IDs are only unique per entity type, so an ID shared by several types
+// is rejected.
+func findPersonalEntity(ctx contractapi.TransactionContextInterface, entityID string) (string, []byte, error) {
+	var foundType string
+	var foundJSON []byte
+	for _, objectType := range personalObjectTypes {
+		entityJSON, err := getEntityState(ctx, objectType, entityID)
+		if err != nil {
+			return "", nil, err
+		}
+		if entityJSON == nil {
+			continue
+		}
+		if foundJSON != nil {
+			return "", nil, fmt.Errorf("the ID %s is used by both a %s and a %s", entityID, foundType, objectType)
+		}
+		foundType, foundJSON = objectType, entityJSON
+	}
+	if foundJSON == nil {
+		return "", nil, fmt.Errorf("no farmer, collector, processor or transporter with ID %s exists", entityID)
+	}
+
+	return foundType, foundJSON, nil
+}
+
+// QueryErasureRecordByID retrieves an erasure record by the ID of its transaction
+func (pc *PalmOilContract) QueryErasureRecordByID(ctx contractapi.TransactionContextInterface, id string) (*ErasureRecord, error) {
+	err := authorize(ctx, "QueryErasureRecordByID")
+	if err != nil {
+		return nil, err
+	}
+
+	recordJSON, err := getEntityState(ctx, erasureObjectType, id)
+	if err != nil {
+		return nil, err
+	}
+	if recordJSON == nil {
+		return nil, fmt.Errorf("the erasure record with ID %s does not exist", id)
+	}
+
+	var record ErasureRecord
+	err = json.Unmarshal(recordJSON, &record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal erasure record JSON: %v", err)
+	}
+
+	return &record, nil
+}
+
+// QueryAllErasureRecords retrieves every erasure record from the ledger
+func (pc *PalmOilContract) QueryAllErasureRecords(ctx contractapi.TransactionContextInterface) ([]*ErasureRecord, error) {
+	err := authorize(ctx, "QueryAllErasureRecords")
+	if err != nil {
+		return nil, err
+	}
+
+	return queryAllEntities[ErasureRecord](ctx, erasureObjectType)
+}
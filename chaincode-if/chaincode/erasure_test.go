@@ -0,0 +1,162 @@
+package chaincode
+
+import (
+	"strings"
+	"testing"
+
+	"chaincode-if/memledger"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestPurgePersonalData(t *testing.T) {
+	tests := []struct {
+		name           string
+		caller         *memledger.Identity
+		entityID       string
+		opts           []memledger.TxOption
+		wantEntityType string
+		wantErr        string
+	}{
+		{name: "farmer by itself", caller: farmerUser, entityID: "FRM_001", wantEntityType: farmerObjectType},
+		{name: "collector by admin", caller: org1Admin, entityID: "COL_001", wantEntityType: collectorObjectType},
+		{name: "legacy entity by admin", caller: org1Admin, entityID: "PRC_LEGACY", wantEntityType: processorObjectType},
+		{name: "by another farmer", caller: otherFarmerUser, entityID: "FRM_001", wantErr: "permission denied"},
+		{name: "by admin of another org", caller: org2Admin, entityID: "FRM_001", wantErr: "permission denied"},
+		{name: "on peer of another org", caller: farmerUser, entityID: "FRM_001", opts: []memledger.TxOption{memledger.WithPeerMSPID("Org2MSP")}, wantErr: "cannot be handled by a peer of Org2MSP"},
+		{name: "missing entity", caller: org1Admin, entityID: "FRM_404", wantErr: "no farmer, collector, processor or transporter"},
+		{name: "ambiguous ID", caller: org1Admin, entityID: "SHARED_001", wantErr: "is used by both a farmer and a transporter"},
+		{name: "no role", caller: noRoleUser, entityID: "FRM_001", wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(t)
+			n.addFarmer(farmerUser, "FRM_001")
+			n.addCollector(collectorUser, "COL_001", "1234567890123")
+			n.addFarmer(farmerUser, "SHARED_001")
+			n.addTransporter(transporterUser, "SHARED_001")
+			putLegacyPersonalEntity(n, processorObjectType, "PRC_LEGACY", nil)
+
+			var record *ErasureRecord
+			err := n.submit(tt.caller, func(ctx contractapi.TransactionContextInterface) error {
+				var err error
+				record, err = n.contract.PurgePersonalData(ctx, tt.entityID)
+				return err
+			}, append([]memledger.TxOption{indexKey}, tt.opts...)...)
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+
+			if record.EntityType != tt.wantEntityType || record.EntityID != tt.entityID || record.Collection != "Org1MSPPersonalData" || record.RequestedBy.MSPID != "Org1MSP" {
+				t.Errorf("unexpected erasure record %+v", record)
+			}
+			for _, kv := range n.ledger.PrivateData(record.Collection) {
+				if strings.Contains(kv.Key, tt.entityID) && !strings.Contains(kv.Key, "SHARED") {
+					t.Errorf("the personal data of %s was not purged", tt.entityID)
+				}
+			}
+			for _, kv := range n.ledger.WorldState() {
+				if strings.Contains(kv.Key, tt.entityID) && strings.Contains(string(kv.Value), `"nik"`) {
+					t.Errorf("the world state still holds the NIK of %s", tt.entityID)
+				}
+			}
+
+			stored := mustEvaluate(n, org1Admin, func(ctx contractapi.TransactionContextInterface) (*ErasureRecord, error) {
+				return n.contract.QueryErasureRecordByID(ctx, record.ID)
+			})
+			if *stored != *record {
+				t.Errorf("expected the stored record %+v, got %+v", record, stored)
+			}
+		})
+	}
+}
+
+func TestPurgeKeepsPublicRecord(t *testing.T) {
+	n := newTestNetwork(t)
+	n.seedSupplyChain()
+	n.mustSubmit(farmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := n.contract.PurgePersonalData(ctx, "FRM_001")
+		return err
+	}, indexKey)
+
+	farmer := mustEvaluate(n, farmerUser, func(ctx contractapi.TransactionContextInterface) (*Farmer, error) {
+		return n.contract.QueryFarmerByID(ctx, "FRM_001")
+	})
+	if farmer.NIKHash != hashNIK(testSalt, "1471010101900001") {
+		t.Errorf("the NIK hash was lost: %+v", farmer)
+	}
+
+	_, err := evaluate(n, farmerUser, func(ctx contractapi.TransactionContextInterface) (*FarmerDetails, error) {
+		return n.contract.QueryFarmerDetails(ctx, "FRM_001")
+	})
+	checkError(t, err, "no personal data is stored")
+
+	lineage := mustEvaluate(n, farmerUser, func(ctx contractapi.TransactionContextInterface) (*ForwardTrace, error) {
+		return n.contract.TraceFarmForward(ctx, "FARM_001")
+	})
+	if len(lineage.Commodities) != 2 {
+		t.Errorf("the lineage of the farm changed: %+v", lineage)
+	}
+}
+
+func TestQueryErasureRecordByID(t *testing.T) {
+	n := newTestNetwork(t)
+	n.addFarmer(farmerUser, "FRM_001")
+	var record *ErasureRecord
+	n.mustSubmit(farmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		record, err = n.contract.PurgePersonalData(ctx, "FRM_001")
+		return err
+	}, indexKey)
+
+	tests := []struct {
+		name    string
+		caller  *memledger.Identity
+		id      string
+		wantErr string
+	}{
+		{name: "existing", caller: org1Admin, id: record.ID},
+		{name: "missing", caller: org1Admin, id: "tx999999", wantErr: "does not exist"},
+		{name: "farmer role", caller: farmerUser, id: record.ID, wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stored, err := evaluate(n, tt.caller, func(ctx contractapi.TransactionContextInterface) (*ErasureRecord, error) {
+				return n.contract.QueryErasureRecordByID(ctx, tt.id)
+			})
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr == "" && stored.EntityID != "FRM_001" {
+				t.Errorf("unexpected erasure record %+v", stored)
+			}
+		})
+	}
+}
+
+func TestQueryAllErasureRecords(t *testing.T) {
+	n := newTestNetwork(t)
+	n.addFarmer(farmerUser, "FRM_001")
+	n.addTransporter(transporterUser, "TRP_001")
+
+	records := mustEvaluate(n, org1Admin, n.contract.QueryAllErasureRecords)
+	if len(records) != 0 {
+		t.Fatalf("expected no erasure records, got %d", len(records))
+	}
+
+	for _, id := range []string{"FRM_001", "TRP_001"} {
+		n.mustSubmit(org1Admin, func(ctx contractapi.TransactionContextInterface) error {
+			_, err := n.contract.PurgePersonalData(ctx, id)
+			return err
+		}, indexKey)
+	}
+
+	records = mustEvaluate(n, org1Admin, n.contract.QueryAllErasureRecords)
+	if len(records) != 2 || records[0].EntityID != "FRM_001" || records[1].EntityID != "TRP_001" {
+		t.Fatalf("expected the erasures of FRM_001 and TRP_001, got %+v", records)
+	}
+
+	_, err := evaluate(n, transporterUser, n.contract.QueryAllErasureRecords)
+	checkError(t, err, "permission denied")
+}
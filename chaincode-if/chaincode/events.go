@@ -0,0 +1,365 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// EventSchemaVersion is the version of the event envelope and payload schemas.
+// It is increased whenever a field is removed or changes meaning; new fields
+// may be added without a version change.
+const EventSchemaVersion = 1
+
+// Names of the chaincode events. Every state-changing transaction emits
+// exactly one event, since Fabric keeps only the last event set in a
+// transaction.
+const (
+	EventFarmerAdded              = "FarmerAdded"
+	EventFarmerUpdated            = "FarmerUpdated"
+	EventFarmAdded                = "FarmAdded"
+	EventFarmUpdated              = "FarmUpdated"
+	EventFarmTransferProposed     = "FarmTransferProposed"
+	EventFarmOwnershipTransferred = "FarmOwnershipTransferred"
+	EventFarmTransferRejected     = "FarmTransferRejected"
+	EventFarmTransferCancelled    = "FarmTransferCancelled"
+	EventPartnershipProposed      = "PartnershipProposed"
+	EventPartnershipAccepted      = "PartnershipAccepted"
+	EventPartnershipRejected      = "PartnershipRejected"
+	EventPartnershipEnded         = "PartnershipEnded"
+	EventCollectorAdded           = "CollectorAdded"
+	EventCollectorUpdated         = "CollectorUpdated"
+	EventProcessorAdded           = "ProcessorAdded"
+	EventProcessorUpdated         = "ProcessorUpdated"
+	EventTransporterAdded         = "TransporterAdded"
+	EventTransporterUpdated       = "TransporterUpdated"
+	EventCommodityHarvested       = "CommodityHarvested"
+	EventCommodityCollected       = "CommodityCollected"
+	EventCommodityInTransport     = "CommodityInTransport"
+	EventCommodityDelivered       = "CommodityDelivered"
+	EventCommodityHeld            = "CommodityHeld"
+	EventCommodityReleased        = "CommodityReleased"
+	EventCommodityRejected        = "CommodityRejected"
+	EventCommodityProcessed       = "CommodityProcessed"
+	EventBatchRecalled            = "BatchRecalled"
+	EventExtractionRateSet        = "ExtractionRateRangeSet"
+	EventMSPRolesSet              = "MSPRolesSet"
+	EventPersonalDataPurged       = "PersonalDataPurged"
+	EventTraceabilityMigrated     = "TraceabilityMigrated"
+	EventEntityKeysMigrated       = "EntityKeysMigrated"
+	EventPersonalDataMigrated     = "PersonalDataMigrated"
+)
+
+// Event is the envelope of every chaincode event. Payload holds one of the
+// payload types below, selected by Name. Payloads never carry personal data:
+// names, addresses, identity numbers and the persons in charge of trace steps
+// are left out.
+type Event struct {
+	Name          string          `json:"name"`
+	SchemaVersion int             `json:"schemaVersion"`
+	TxID          string          `json:"txId"`
+	Timestamp     string          `json:"timestamp"`
+	MSPID         string          `json:"mspId"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// FarmerPayload is the payload of the farmer events
+type FarmerPayload struct {
+	ID    string   `json:"id"`
+	Farms []string `json:"farms"`
+	MSPID string   `json:"mspId"`
+}
+
+// FarmPayload is the payload of the FarmAdded and FarmUpdated events. The
+// address and coordinate are left out, since they locate the owner's land.
+// EndedPartnerships lists the partnerships an owner change ended, and
+// CancelledTransfer the pending transfer it cancelled.
+type FarmPayload struct {
+	ID                string        `json:"id"`
+	Owner             string        `json:"owner"`
+	PlantedYear       int           `json:"plantedYear"`
+	SeedVarieties     string        `json:"seedVarieties"`
+	Area              float64       `json:"area"`
+	Capacity          float64       `json:"capacity"`
+	Legality          string        `json:"legality"`
+	Certificate       string        `json:"certificate"`
+	EndedPartnerships []string      `json:"endedPartnerships,omitempty"`
+	CancelledTransfer *FarmTransfer `json:"cancelledTransfer,omitempty"`
+}
+
+// CollectorPayload is the payload of the collector events
+type CollectorPayload struct {
+	ID       string   `json:"id"`
+	NIB      string   `json:"nib"`
+	Capacity float64  `json:"capacity"`
+	Partners []string `json:"partners"`
+	MSPID    string   `json:"mspId"`
+}
+
+// ConsentPayload is a consent without the certificate subject of the
+// identity that gave it
+type ConsentPayload struct {
+	MSPID string `json:"mspId"`
+	At    string `json:"at"`
+	TxID  string `json:"txId"`
+}
+
+// PartnershipPayload is the payload of the partnership events. The consents
+// name the organization that gave them but not the identity.
+type PartnershipPayload struct {
+	ID               string            `json:"id"`
+	CollectorID      string            `json:"collectorId"`
+	PartnerType      string            `json:"partnerType"`
+	PartnerID        string            `json:"partnerId"`
+	StartDate        string            `json:"startDate"`
+	EndDate          string            `json:"endDate,omitempty"`
+	Status           PartnershipStatus `json:"status"`
+	ProposedBy       string            `json:"proposedBy"`
+	CollectorConsent *ConsentPayload   `json:"collectorConsent,omitempty"`
+	PartnerConsent   *ConsentPayload   `json:"partnerConsent,omitempty"`
+	ClosedAt         string            `json:"closedAt,omitempty"`
+	ClosedTxID       string            `json:"closedTxId,omitempty"`
+}
+
+// ProcessorPayload is the payload of the processor events
+type ProcessorPayload struct {
+	ID       string  `json:"id"`
+	NIB      string  `json:"nib"`
+	Capacity float64 `json:"capacity"`
+	MSPID    string  `json:"mspId"`
+}
+
+// TransporterPayload is the payload of the transporter events
+type TransporterPayload struct {
+	ID      string `json:"id"`
+	NumShip int    `json:"numShip"`
+	MSPID   string `json:"mspId"`
+}
+
+// StepPayload is a trace event without the person in charge
+type StepPayload struct {
+	Status    string `json:"status"`
+	Location  string `json:"location"`
+	Timestamp string `json:"timestamp"`
+	TxID      string `json:"txId"`
+	MSPID     string `json:"mspId"`
+}
+
+// CommodityPayload is the payload of the commodity events. Step is the trace
+// step recorded by the transaction.
+type CommodityPayload struct {
+	ID            string         `json:"id"`
+	Name          string         `json:"name"`
+	Quantity      float64        `json:"quantity"`
+	DateHarvested string         `json:"dateHarvested"`
+	FarmID        string         `json:"farmId"`
+	FarmerID      string         `json:"farmerId"`
+	CollectorID   string         `json:"collectorId,omitempty"`
+	TransporterID string         `json:"transporterId,omitempty"`
+	State         CommodityState `json:"state"`
+	HeldFrom      CommodityState `json:"heldFrom,omitempty"`
+	Step          StepPayload    `json:"step"`
+}
+
+// BatchPayload is the payload of the CommodityProcessed event. Every material
+// has moved to the processed state.
+type BatchPayload struct {
+	ID             string      `json:"id"`
+	Processor      string      `json:"processor"`
+	Quantity       float64     `json:"quantity"`
+	InputQuantity  float64     `json:"inputQuantity"`
+	ExtractionRate float64     `json:"extractionRate"`
+	Materials      []string    `json:"materials"`
+	BatchNumber    string      `json:"batchNumber"`
+	Quality        string      `json:"quality"`
+	Status         BatchStatus `json:"status"`
+	Step           StepPayload `json:"step"`
+}
+
+// ErasurePayload is the payload of the PersonalDataPurged event
+type ErasurePayload struct {
+	ID         string `json:"id"`
+	EntityType string `json:"entityType"`
+	EntityID   string `json:"entityId"`
+}
+
+// MigrationPayload is the payload of the migration events
+type MigrationPayload struct {
+	Migrated int `json:"migrated"`
+	Skipped  int `json:"skipped"`
+}
+
+// The payloads of the farm transfer events, BatchRecalled,
+// ExtractionRateRangeSet and MSPRolesSet are FarmTransfer, Recall,
+// ExtractionRateRange and MSPRoles.
+
+// emitEvent sets the chaincode event of the transaction
+func emitEvent(ctx contractapi.TransactionContextInterface, name string, payload interface{}) error {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s payload: %v", name, err)
+	}
+
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to read transaction timestamp: %v", err)
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to read client MSP ID: %v", err)
+	}
+
+	eventJSON, err := json.Marshal(Event{
+		Name:          name,
+		SchemaVersion: EventSchemaVersion,
+		TxID:          ctx.GetStub().GetTxID(),
+		Timestamp:     time.Unix(timestamp.GetSeconds(), int64(timestamp.GetNanos())).UTC().Format(time.RFC3339Nano),
+		MSPID:         mspID,
+		Payload:       payloadJSON,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %v", name, err)
+	}
+
+	return ctx.GetStub().SetEvent(name, eventJSON)
+}
+
+// enrolledMSPID returns the MSP of an enrollment, or an empty string for
+// entities registered before enrollments were recorded
+func enrolledMSPID(enrolledBy *Enrollment) string {
+	if enrolledBy == nil {
+		return ""
+	}
+	return enrolledBy.MSPID
+}
+
+// farmerPayload builds the event payload of a farmer
+func farmerPayload(farmer *Farmer) FarmerPayload {
+	farms := farmer.Farm
+	if farms == nil {
+		farms = []string{}
+	}
+	return FarmerPayload{ID: farmer.ID, Farms: farms, MSPID: enrolledMSPID(farmer.EnrolledBy)}
+}
+
+// farmPayload builds the event payload of a farm
+func farmPayload(farm *Farm, endedPartnerships []string, cancelledTransfer *FarmTransfer) FarmPayload {
+	return FarmPayload{
+		ID:                farm.ID,
+		Owner:             farm.Owner,
+		PlantedYear:       farm.PlantedYear,
+		SeedVarieties:     farm.SeedVarieties,
+		Area:              farm.Area,
+		Capacity:          farm.Capacity,
+		Legality:          farm.Legality,
+		Certificate:       farm.Certificate,
+		EndedPartnerships: endedPartnerships,
+		CancelledTransfer: cancelledTransfer,
+	}
+}
+
+// collectorPayload builds the event payload of a collector
+func collectorPayload(collector *Collector) CollectorPayload {
+	partners := collector.Partner
+	if partners == nil {
+		partners = []string{}
+	}
+	return CollectorPayload{
+		ID:       collector.ID,
+		NIB:      collector.NIB,
+		Capacity: collector.Capacity,
+		Partners: partners,
+		MSPID:    enrolledMSPID(collector.EnrolledBy),
+	}
+}
+
+// partnershipPayload builds the event payload of a partnership
+func partnershipPayload(partnership *Partnership) PartnershipPayload {
+	return PartnershipPayload{
+		ID:               partnership.ID,
+		CollectorID:      partnership.CollectorID,
+		PartnerType:      partnership.PartnerType,
+		PartnerID:        partnership.PartnerID,
+		StartDate:        partnership.StartDate,
+		EndDate:          partnership.EndDate,
+		Status:           partnership.Status,
+		ProposedBy:       partnership.ProposedBy,
+		CollectorConsent: consentPayload(partnership.CollectorConsent),
+		PartnerConsent:   consentPayload(partnership.PartnerConsent),
+		ClosedAt:         partnership.ClosedAt,
+		ClosedTxID:       partnership.ClosedTxID,
+	}
+}
+
+// consentPayload builds the event payload of a consent, if any
+func consentPayload(consent *Consent) *ConsentPayload {
+	if consent == nil {
+		return nil
+	}
+	return &ConsentPayload{MSPID: consent.MSPID, At: consent.At, TxID: consent.TxID}
+}
+
+// processorPayload builds the event payload of a processor
+func processorPayload(processor *Processor) ProcessorPayload {
+	return ProcessorPayload{
+		ID:       processor.ID,
+		NIB:      processor.NIB,
+		Capacity: processor.Capacity,
+		MSPID:    enrolledMSPID(processor.EnrolledBy),
+	}
+}
+
+// transporterPayload builds the event payload of a transporter
+func transporterPayload(transporter *Transporter) TransporterPayload {
+	return TransporterPayload{ID: transporter.ID, NumShip: transporter.NumShip, MSPID: enrolledMSPID(transporter.EnrolledBy)}
+}
+
+// stepPayload strips the person in charge from a trace event
+func stepPayload(event TraceEvent) StepPayload {
+	return StepPayload{
+		Status:    event.Status,
+		Location:  event.Location,
+		Timestamp: event.Timestamp,
+		TxID:      event.TxID,
+		MSPID:     event.MSPID,
+	}
+}
+
+// commodityPayload builds the event payload of a commodity from its last trace step
+func commodityPayload(commodity *Commodity) CommodityPayload {
+	payload := CommodityPayload{
+		ID:            commodity.ID,
+		Name:          commodity.Name,
+		Quantity:      commodity.Quantity,
+		DateHarvested: commodity.DateHarvested,
+		FarmID:        commodity.FarmID,
+		FarmerID:      commodity.FarmerID,
+		CollectorID:   commodity.CollectorID,
+		TransporterID: commodity.TransporterID,
+		State:         commodity.State,
+		HeldFrom:      commodity.HeldFrom,
+	}
+	if events := commodity.Traceability.Events; len(events) > 0 {
+		payload.Step = stepPayload(events[len(events)-1])
+	}
+	return payload
+}
+
+// batchPayload builds the event payload of a processed commodity
+func batchPayload(processed *ProcessedCommodity, event TraceEvent) BatchPayload {
+	return BatchPayload{
+		ID:             processed.ID,
+		Processor:      processed.Processor,
+		Quantity:       processed.Quantity,
+		InputQuantity:  processed.InputQuantity,
+		ExtractionRate: processed.ExtractionRate,
+		Materials:      processed.Material,
+		BatchNumber:    processed.BatchNumber,
+		Quality:        processed.Quality,
+		Status:         processed.Status,
+		Step:           stepPayload(event),
+	}
+}
@@ -0,0 +1,196 @@
+package chaincode
+
+import (
+	"strings"
+	"testing"
+
+	"chaincode-if/memledger"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestEvents(t *testing.T) {
+	n := newTestNetwork(t)
+	migrationSalt := memledger.WithTransient(map[string][]byte{"salt": []byte("migration-salt-0001")})
+
+	// Every step runs on the ledger left by the steps before it
+	steps := []struct {
+		name      string
+		caller    *memledger.Identity
+		tx        func(ctx contractapi.TransactionContextInterface) error
+		opts      []memledger.TxOption
+		wantEvent string
+		wantID    string
+	}{
+		{name: "add farmer", caller: farmerUser, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.AddFarmer(ctx, "FRM_001", "Slamet", "Riau", `[]`)
+		}, opts: []memledger.TxOption{personal("1471010101900001")}, wantEvent: EventFarmerAdded, wantID: "FRM_001"},
+		{name: "update farmer", caller: farmerUser, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.UpdateFarmer(ctx, "FRM_001", "Slamet Riyadi", "Riau", `[]`)
+		}, wantEvent: EventFarmerUpdated, wantID: "FRM_001"},
+		{name: "add farm", caller: farmerUser, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.AddFarm(ctx, "FARM_001", "FRM_001", 2010, "Tenera", 2, "Jl. Kebun Sawit 7", "0.5071,101.4478", 20, "SHM", "RSPO")
+		}, wantEvent: EventFarmAdded, wantID: "FARM_001"},
+		{name: "update farm", caller: farmerUser, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.UpdateFarm(ctx, "FARM_001", "FRM_001", 2010, "Tenera", 3, "Jl. Kebun Sawit 7", "0.5071,101.4478", 20, "SHM", "RSPO")
+		}, wantEvent: EventFarmUpdated, wantID: "FARM_001"},
+		{name: "add collector", caller: collectorUser, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.AddCollector(ctx, "COL_001", "KUD Makmur", "1234567890123", "Siak", 250, `[]`)
+		}, opts: []memledger.TxOption{personal("1471010101900002")}, wantEvent: EventCollectorAdded, wantID: "COL_001"},
+		{name: "update collector", caller: collectorUser, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.UpdateCollector(ctx, "COL_001", "KUD Makmur", "1234567890123", "Siak", 300, `[]`)
+		}, wantEvent: EventCollectorUpdated, wantID: "COL_001"},
+		{name: "propose partnership", caller: collectorUser, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.ProposePartnership(ctx, "PRT_001", "COL_001", PartnerFarmer, "FRM_001", "2024-01-01", "")
+		}, wantEvent: EventPartnershipProposed, wantID: "PRT_001"},
+		{name: "reject partnership", caller: farmerUser, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.RejectPartnership(ctx, "PRT_001")
+		}, wantEvent: EventPartnershipRejected, wantID: "PRT_001"},
+		{name: "propose farm partnership", caller: farmerUser, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.ProposePartnership(ctx, "PRT_002", "COL_001", PartnerFarm, "FARM_001", "2024-01-01", "2025-12-31")
+		}, wantEvent: EventPartnershipProposed, wantID: "PRT_002"},
+		{name: "withdraw partnership", caller: farmerUser, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.EndPartnership(ctx, "PRT_002")
+		}, wantEvent: EventPartnershipEnded, wantID: "PRT_002"},
+		{name: "propose partnership again", caller: collectorUser, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.ProposePartnership(ctx, "PRT_003", "COL_001", PartnerFarmer, "FRM_001", "2024-01-01", "")
+		}, wantEvent: EventPartnershipProposed, wantID: "PRT_003"},
+		{name: "accept partnership", caller: farmerUser, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.AcceptPartnership(ctx, "PRT_003")
+		}, wantEvent: EventPartnershipAccepted, wantID: "PRT_003"},
+		{name: "add processor", caller: processorUser, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.AddProcessor(ctx, "PRC_001", "PKS Dumai", "1234567890124", "Dumai", 60)
+		}, opts: []memledger.TxOption{personal("1471010101900003")}, wantEvent: EventProcessorAdded, wantID: "PRC_001"},
+		{name: "update processor", caller: processorUser, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.UpdateProcessor(ctx, "PRC_001", "PKS Dumai", "1234567890124", "Dumai", 90)
+		}, wantEvent: EventProcessorUpdated, wantID: "PRC_001"},
+		{name: "add transporter", caller: transporterUser, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.AddTransporter(ctx, "TRP_001", "CV Angkut", 3)
+		}, opts: []memledger.TxOption{personal("1471010101900004")}, wantEvent: EventTransporterAdded, wantID: "TRP_001"},
+		{name: "update transporter", caller: transporterUser, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.UpdateTransporter(ctx, "TRP_001", "CV Angkut", 4)
+		}, wantEvent: EventTransporterUpdated, wantID: "TRP_001"},
+		{name: "harvest", caller: farmerUser, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.Harvest(ctx, "COM_001", "FARM_001", "FFB", 100, "2024-01-10", "TR_001", "Budi", "Kampar")
+		}, wantEvent: EventCommodityHarvested, wantID: "COM_001"},
+		{name: "hold", caller: collectorUser, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.HoldCommodity(ctx, "COM_001", "Sari", "Pekanbaru")
+		}, wantEvent: EventCommodityHeld, wantID: "COM_001"},
+		{name: "release", caller: collectorUser, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.ReleaseCommodity(ctx, "COM_001", "Sari", "Pekanbaru")
+		}, wantEvent: EventCommodityReleased, wantID: "COM_001"},
+		{name: "collect", caller: collectorUser, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.Collect(ctx, "COM_001", "COL_001", "Sari", "Pekanbaru")
+		}, wantEvent: EventCommodityCollected, wantID: "COM_001"},
+		{name: "transport", caller: transporterUser, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.Transport(ctx, "COM_001", "TRP_001", "Agus", "Pekanbaru")
+		}, wantEvent: EventCommodityInTransport, wantID: "COM_001"},
+		{name: "transported", caller: transporterUser, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.Transported(ctx, "COM_001", "TRP_001", "Agus", "Dumai")
+		}, wantEvent: EventCommodityDelivered, wantID: "COM_001"},
+		{name: "process", caller: processorUser, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.Process(ctx, "PCD_001", "PRC_001", 25, `["COM_001"]`, "B-001", "A", "Rina", "Dumai")
+		}, wantEvent: EventCommodityProcessed, wantID: "PCD_001"},
+		{name: "harvest for rejection", caller: farmerUser, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.Harvest(ctx, "COM_002", "FARM_001", "FFB", 100, "2024-01-11", "TR_002", "Budi", "Kampar")
+		}, wantEvent: EventCommodityHarvested, wantID: "COM_002"},
+		{name: "reject", caller: processorUser, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.RejectCommodity(ctx, "COM_002", "Rina", "Dumai")
+		}, wantEvent: EventCommodityRejected, wantID: "COM_002"},
+		{name: "recall", caller: org1Admin, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.RecallBatch(ctx, "RCL_001", OriginFarm, "FARM_001", "illegal clearing", "Dewi")
+		}, wantEvent: EventBatchRecalled, wantID: "RCL_001"},
+		{name: "set extraction rate range", caller: org1Admin, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.SetExtractionRateRange(ctx, 0.2, 0.25)
+		}, wantEvent: EventExtractionRateSet},
+		{name: "set MSP roles", caller: org2Admin, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.SetMSPRoles(ctx, "Org2MSP", `["admin"]`)
+		}, wantEvent: EventMSPRolesSet},
+		{name: "purge personal data", caller: farmerUser, tx: func(ctx contractapi.TransactionContextInterface) error {
+			_, err := n.contract.PurgePersonalData(ctx, "FRM_001")
+			return err
+		}, opts: []memledger.TxOption{indexKey}, wantEvent: EventPersonalDataPurged},
+		{name: "migrate traceability", caller: org1Admin, tx: func(ctx contractapi.TransactionContextInterface) error {
+			_, err := n.contract.MigrateTraceability(ctx)
+			return err
+		}, wantEvent: EventTraceabilityMigrated},
+		{name: "migrate entity keys", caller: org1Admin, tx: func(ctx contractapi.TransactionContextInterface) error {
+			_, err := n.contract.MigrateEntityKeys(ctx)
+			return err
+		}, wantEvent: EventEntityKeysMigrated},
+		{name: "migrate personal data", caller: org1Admin, tx: func(ctx contractapi.TransactionContextInterface) error {
+			_, err := n.contract.MigratePersonalData(ctx)
+			return err
+		}, opts: []memledger.TxOption{migrationSalt}, wantEvent: EventPersonalDataMigrated},
+		{name: "add new owner", caller: otherFarmerUser, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.AddFarmer(ctx, "FRM_002", "Joko", "Jambi", `[]`)
+		}, opts: []memledger.TxOption{personal("1471010101900005")}, wantEvent: EventFarmerAdded, wantID: "FRM_002"},
+		{name: "propose farm transfer", caller: farmerUser, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.TransferFarmOwnership(ctx, "FARM_001", "FRM_002")
+		}, wantEvent: EventFarmTransferProposed},
+		{name: "reject farm transfer", caller: otherFarmerUser, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.RejectFarmOwnership(ctx, "FARM_001")
+		}, wantEvent: EventFarmTransferRejected},
+		{name: "propose farm transfer again", caller: farmerUser, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.TransferFarmOwnership(ctx, "FARM_001", "FRM_002")
+		}, wantEvent: EventFarmTransferProposed},
+		{name: "cancel farm transfer", caller: farmerUser, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.CancelFarmOwnershipTransfer(ctx, "FARM_001")
+		}, wantEvent: EventFarmTransferCancelled},
+		{name: "propose farm transfer once more", caller: farmerUser, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.TransferFarmOwnership(ctx, "FARM_001", "FRM_002")
+		}, wantEvent: EventFarmTransferProposed},
+		{name: "accept farm transfer", caller: otherFarmerUser, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.AcceptFarmOwnership(ctx, "FARM_001")
+		}, wantEvent: EventFarmOwnershipTransferred},
+		{name: "end partnership", caller: collectorUser, tx: func(ctx contractapi.TransactionContextInterface) error {
+			return n.contract.EndPartnership(ctx, "PRT_003")
+		}, wantEvent: EventPartnershipEnded, wantID: "PRT_003"},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			blocks := len(n.ledger.Events())
+			n.mustSubmit(step.caller, step.tx, step.opts...)
+
+			events := n.ledger.Events()
+			if len(events) != blocks+1 {
+				t.Fatalf("expected one event, got %d", len(events)-blocks)
+			}
+			if events[blocks].EventName != step.wantEvent {
+				t.Fatalf("expected event %s, got %s", step.wantEvent, events[blocks].EventName)
+			}
+
+			event := n.lastEvent()
+			if event.Name != step.wantEvent || event.SchemaVersion != EventSchemaVersion || event.TxID != events[blocks].TransactionID || event.MSPID != step.caller.MSPID || event.Timestamp == "" {
+				t.Errorf("unexpected envelope %+v", event)
+			}
+			if step.wantID != "" && !strings.Contains(string(event.Payload), `"id":"`+step.wantID+`"`) {
+				t.Errorf("expected the payload of %s, got %s", step.wantID, event.Payload)
+			}
+
+			// Payloads never carry personal data, persons in charge, farm
+			// locations or the certificate subjects of callers
+			for _, personal := range []string{"Slamet", "KUD Makmur", "PKS Dumai", "CV Angkut", "Budi", "Sari", "Agus", "Rina", "Dewi", "14710101019", "+62", "example.com", "Riau", "Siak", "Kebun Sawit", "101.4478", "clientId", "CN="} {
+				if strings.Contains(string(event.Payload), personal) {
+					t.Errorf("the payload holds %q: %s", personal, event.Payload)
+				}
+			}
+		})
+	}
+}
+
+func TestFailedTransactionEmitsNoEvent(t *testing.T) {
+	n := newTestNetwork(t)
+	n.addFarmer(farmerUser, "FRM_001")
+	events := len(n.ledger.Events())
+
+	err := n.submit(farmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.AddFarmer(ctx, "FRM_001", "Slamet", "Riau", `[]`)
+	}, personal("1471010101900001"))
+	checkError(t, err, "already exists")
+
+	if len(n.ledger.Events()) != events {
+		t.Fatal("a failed transaction emitted an event")
+	}
+}
@@ -7,15 +7,15 @@ import (
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
-// Farmer represents the structure for a farmer
+// Farmer represents the structure for a farmer. Farm lists the farms the
+// farmer owns and is kept in step with the owner of each farm.
 type Farmer struct {
-	ID      string   `json:"id"`
-	Name    string   `json:"name"`
-	NIK     string   `json:"nik"`
-	Address string   `json:"address"`
-	Email   string   `json:"email"`
-	NoHP    string   `json:"noHP"`
-	Farm    []string `json:"farm"`
+	ID         string      `json:"id"`
+	Name       string      `json:"name"`
+	NIKHash    string      `json:"nikHash"`
+	Address    string      `json:"address"`
+	Farm       []string    `json:"farm"`
+	EnrolledBy *Enrollment `json:"enrolledBy,omitempty" metadata:",optional"`
 }
 
 // Farm represents the structure for a farm
@@ -44,14 +44,10 @@ type Farm struct {
 // }
 
 // AddFarmer adds a new farmer to the ledger
-func (pc *PalmOilContract) AddFarmer(ctx contractapi.TransactionContextInterface, id string, name string, nik string, address string, email string, noHP string, farmsInput string) error {
-	// Check if a farmer with the given ID already exists
-	existingFarmerJSON, err := ctx.GetStub().GetState(id)
+func (pc *PalmOilContract) AddFarmer(ctx contractapi.TransactionContextInterface, id string, name string, address string, farmsInput string) error {
+	err := authorize(ctx, "AddFarmer")
 	if err != nil {
-		return fmt.Errorf("failed to read from world state: %v", err)
-	}
-	if existingFarmerJSON != nil {
-		return fmt.Errorf("a farmer with ID %s already exists", id)
+		return err
 	}
 
 	// Parse the farmsInput into a []string
@@ -61,14 +57,58 @@ func (pc *PalmOilContract) AddFarmer(ctx contractapi.TransactionContextInterface
 		return fmt.Errorf("failed to parse farm attribute: %v", err)
 	}
 
-	farmer := Farmer{
-		ID:      id,
-		Name:    name,
-		NIK:     nik,
-		Address: address,
-		Email:   email,
-		NoHP:    noHP,
-		Farm:    farms,
+	return addFarmer(ctx, "AddFarmer", Farmer{ID: id, Name: name, Address: address, Farm: farms})
+}
+
+// AddFarmerFromJSON adds a new farmer to the ledger from a JSON farmer document
+func (pc *PalmOilContract) AddFarmerFromJSON(ctx contractapi.TransactionContextInterface, farmerJSON string) error {
+	err := authorize(ctx, "AddFarmerFromJSON")
+	if err != nil {
+		return err
+	}
+
+	var farmer Farmer
+	err = decodeDocument(farmerObjectType, farmerJSON, &farmer)
+	if err != nil {
+		return err
+	}
+
+	return addFarmer(ctx, "AddFarmerFromJSON", farmer)
+}
+
+// addFarmer stores a new farmer, enrolled by the caller
+func addFarmer(ctx contractapi.TransactionContextInterface, function string, farmer Farmer) error {
+	// Check if a farmer with the given ID already exists
+	existingFarmerJSON, err := getEntityState(ctx, farmerObjectType, farmer.ID)
+	if err != nil {
+		return err
+	}
+	if existingFarmerJSON != nil {
+		return fmt.Errorf("a farmer with ID %s already exists", farmer.ID)
+	}
+
+	farmer.EnrolledBy, err = callerEnrollment(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = validateFarmer(ctx, &farmer)
+	if err != nil {
+		return err
+	}
+
+	err = claimListedFarms(ctx, function, &farmer, []string{})
+	if err != nil {
+		return err
+	}
+
+	farmer.NIKHash, err = addPersonalData(ctx, farmerObjectType, farmer.ID, farmer.EnrolledBy)
+	if err != nil {
+		return err
+	}
+
+	if farmer.Farm == nil {
+		farmer.Farm = []string{}
 	}
 
 	farmerJSON, err := json.Marshal(farmer)
@@ -76,17 +116,19 @@ func (pc *PalmOilContract) AddFarmer(ctx contractapi.TransactionContextInterface
 		return err
 	}
 
-	return ctx.GetStub().PutState(id, farmerJSON)
+	err = putEntityState(ctx, farmerObjectType, farmer.ID, farmerJSON)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventFarmerAdded, farmerPayload(&farmer))
 }
 
 // UpdateFarmer updates an existing farmer on the ledger
-func (pc *PalmOilContract) UpdateFarmer(ctx contractapi.TransactionContextInterface, id string, name string, nik string, address string, email string, noHP string, farmsInput string) error {
-	farmerJSON, err := ctx.GetStub().GetState(id)
+func (pc *PalmOilContract) UpdateFarmer(ctx contractapi.TransactionContextInterface, id string, name string, address string, farmsInput string) error {
+	err := authorize(ctx, "UpdateFarmer")
 	if err != nil {
-		return fmt.Errorf("failed to read from world state: %v", err)
-	}
-	if farmerJSON == nil {
-		return fmt.Errorf("the farmer with ID %s does not exist", id)
+		return err
 	}
 
 	// Parse the farmsInput into a []string
@@ -96,30 +138,116 @@ func (pc *PalmOilContract) UpdateFarmer(ctx contractapi.TransactionContextInterf
 		return fmt.Errorf("failed to parse farm attribute: %v", err)
 	}
 
+	return updateFarmer(ctx, "UpdateFarmer", id, func(farmer *Farmer) error {
+		farmer.Name = name
+		farmer.Address = address
+		farmer.Farm = farms
+		return nil
+	})
+}
+
+// UpdateFarmerFromJSON replaces every attribute of an existing farmer with
+// those of a JSON farmer document
+func (pc *PalmOilContract) UpdateFarmerFromJSON(ctx contractapi.TransactionContextInterface, farmerJSON string) error {
+	err := authorize(ctx, "UpdateFarmerFromJSON")
+	if err != nil {
+		return err
+	}
+
+	var update Farmer
+	err = decodeDocument(farmerObjectType, farmerJSON, &update)
+	if err != nil {
+		return err
+	}
+
+	return updateFarmer(ctx, "UpdateFarmerFromJSON", update.ID, func(farmer *Farmer) error {
+		farmer.Name = update.Name
+		farmer.Address = update.Address
+		farmer.Farm = update.Farm
+		return nil
+	})
+}
+
+// PatchFarmer changes the attributes of an existing farmer named in a JSON
+// patch document and leaves the others unchanged
+func (pc *PalmOilContract) PatchFarmer(ctx contractapi.TransactionContextInterface, id string, patchJSON string) error {
+	err := authorize(ctx, "PatchFarmer")
+	if err != nil {
+		return err
+	}
+
+	return updateFarmer(ctx, "PatchFarmer", id, func(farmer *Farmer) error {
+		return applyPatch(farmerObjectType, farmer, patchJSON)
+	})
+}
+
+// updateFarmer changes the attributes of an existing farmer with update,
+// after checking the caller may change it
+func updateFarmer(ctx contractapi.TransactionContextInterface, function string, id string, update func(farmer *Farmer) error) error {
+	farmerJSON, err := getEntityState(ctx, farmerObjectType, id)
+	if err != nil {
+		return err
+	}
+	if farmerJSON == nil {
+		return fmt.Errorf("the farmer with ID %s does not exist", id)
+	}
+
 	var farmer Farmer
 	json.Unmarshal(farmerJSON, &farmer)
 
+	err = authorizeOwner(ctx, function, farmer.EnrolledBy)
+	if err != nil {
+		return err
+	}
+
 	// Update the farmer's attributes
-	farmer.Name = name
-	farmer.NIK = nik
-	farmer.Address = address
-	farmer.Email = email
-	farmer.NoHP = noHP
-	farmer.Farm = farms
+	previousFarms := farmer.Farm
+	err = update(&farmer)
+	if err != nil {
+		return err
+	}
+
+	err = validateFarmer(ctx, &farmer)
+	if err != nil {
+		return err
+	}
+
+	err = claimListedFarms(ctx, function, &farmer, previousFarms)
+	if err != nil {
+		return err
+	}
+
+	farmer.NIKHash, err = updatePersonalData(ctx, farmerObjectType, id, farmer.EnrolledBy, farmer.NIKHash)
+	if err != nil {
+		return err
+	}
+	if farmer.Farm == nil {
+		farmer.Farm = []string{}
+	}
 
 	farmerJSON, err = json.Marshal(farmer)
 	if err != nil {
 		return err
 	}
 
-	return ctx.GetStub().PutState(id, farmerJSON)
+	err = putEntityState(ctx, farmerObjectType, id, farmerJSON)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventFarmerUpdated, farmerPayload(&farmer))
 }
 
 // QueryFarmerByID retrieves a farmer by its ID from the ledger
 func (pc *PalmOilContract) QueryFarmerByID(ctx contractapi.TransactionContextInterface, id string) (*Farmer, error) {
-	farmerJSON, err := ctx.GetStub().GetState(id)
+	err := authorize(ctx, "QueryFarmerByID")
 	if err != nil {
-		return nil, fmt.Errorf("failed to read from world state: %v", err)
+		return nil, err
+	}
+
+	farmerJSON, err := getEntityState(ctx, farmerObjectType, id)
+	if err != nil {
+		return nil, err
 	}
 	if farmerJSON == nil {
 		return nil, fmt.Errorf("the farmer with ID %s does not exist", id)
@@ -133,38 +261,22 @@ func (pc *PalmOilContract) QueryFarmerByID(ctx contractapi.TransactionContextInt
 
 // QueryAllFarmers retrieves all farmers from the ledger
 func (pc *PalmOilContract) QueryAllFarmers(ctx contractapi.TransactionContextInterface) ([]*Farmer, error) {
-	resultsIterator, err := ctx.GetStub().GetStateByRange("FRR_", "FRR_zzzzzzzzzz")
+	err := authorize(ctx, "QueryAllFarmers")
 	if err != nil {
 		return nil, err
 	}
-	defer resultsIterator.Close()
-
-	var farmers []*Farmer
-	for resultsIterator.HasNext() {
-		queryResponse, err := resultsIterator.Next()
-		if err != nil {
-			return nil, err
-		}
 
-		var farmer Farmer
-		json.Unmarshal(queryResponse.Value, &farmer)
-		farmers = append(farmers, &farmer)
-	}
-
-	return farmers, nil
+	return queryAllEntities[Farmer](ctx, farmerObjectType)
 }
 
 // AddFarm adds a new farm to the ledger
 func (pc *PalmOilContract) AddFarm(ctx contractapi.TransactionContextInterface, id string, owner string, plantedYear int, seedVarieties string, area float64, address string, coordinate string, capacity float64, legality string, certificate string) error {
-	existingFarmJSON, err := ctx.GetStub().GetState(id)
+	err := authorize(ctx, "AddFarm")
 	if err != nil {
-		return fmt.Errorf("failed to read from world state: %v", err)
-	}
-	if existingFarmJSON != nil {
-		return fmt.Errorf("a farm with ID %s already exists", id)
+		return err
 	}
 
-	farm := Farm{
+	return addFarm(ctx, "AddFarm", Farm{
 		ID:            id,
 		Owner:         owner,
 		PlantedYear:   plantedYear,
@@ -175,6 +287,53 @@ func (pc *PalmOilContract) AddFarm(ctx contractapi.TransactionContextInterface,
 		Capacity:      capacity,
 		Legality:      legality,
 		Certificate:   certificate,
+	})
+}
+
+// AddFarmFromJSON adds a new farm to the ledger from a JSON farm document
+func (pc *PalmOilContract) AddFarmFromJSON(ctx contractapi.TransactionContextInterface, farmJSON string) error {
+	err := authorize(ctx, "AddFarmFromJSON")
+	if err != nil {
+		return err
+	}
+
+	var farm Farm
+	err = decodeDocument(farmObjectType, farmJSON, &farm)
+	if err != nil {
+		return err
+	}
+
+	return addFarm(ctx, "AddFarmFromJSON", farm)
+}
+
+// addFarm stores a new farm
+func addFarm(ctx contractapi.TransactionContextInterface, function string, farm Farm) error {
+	existingFarmJSON, err := getEntityState(ctx, farmObjectType, farm.ID)
+	if err != nil {
+		return err
+	}
+	if existingFarmJSON != nil {
+		return fmt.Errorf("a farm with ID %s already exists", farm.ID)
+	}
+
+	// Only the owning farmer or an admin may register a farm for them
+	if farm.Owner == "" {
+		err = authorizeOwner(ctx, function, nil)
+	} else {
+		err = authorizeFarmOwner(ctx, function, farm.Owner)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = validateFarm(ctx, &farm)
+	if err != nil {
+		return err
+	}
+
+	err = moveFarm(ctx, farm.ID, "", farm.Owner)
+	if err != nil {
+		return err
 	}
 
 	farmJSON, err := json.Marshal(farm)
@@ -182,14 +341,74 @@ func (pc *PalmOilContract) AddFarm(ctx contractapi.TransactionContextInterface,
 		return err
 	}
 
-	return ctx.GetStub().PutState(id, farmJSON)
+	err = putEntityState(ctx, farmObjectType, farm.ID, farmJSON)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventFarmAdded, farmPayload(&farm, nil, nil))
 }
 
 // UpdateFarm updates an existing farm on the ledger
 func (pc *PalmOilContract) UpdateFarm(ctx contractapi.TransactionContextInterface, id string, owner string, plantedYear int, seedVarieties string, area float64, address string, coordinate string, capacity float64, legality string, certificate string) error {
-	farmJSON, err := ctx.GetStub().GetState(id)
+	err := authorize(ctx, "UpdateFarm")
+	if err != nil {
+		return err
+	}
+
+	return updateFarm(ctx, "UpdateFarm", id, func(farm *Farm) error {
+		farm.Owner = owner
+		farm.PlantedYear = plantedYear
+		farm.SeedVarieties = seedVarieties
+		farm.Area = area
+		farm.Address = address
+		farm.Coordinate = coordinate
+		farm.Capacity = capacity
+		farm.Legality = legality
+		farm.Certificate = certificate
+		return nil
+	})
+}
+
+// UpdateFarmFromJSON replaces every attribute of an existing farm with those
+// of a JSON farm document
+func (pc *PalmOilContract) UpdateFarmFromJSON(ctx contractapi.TransactionContextInterface, farmJSON string) error {
+	err := authorize(ctx, "UpdateFarmFromJSON")
+	if err != nil {
+		return err
+	}
+
+	var update Farm
+	err = decodeDocument(farmObjectType, farmJSON, &update)
+	if err != nil {
+		return err
+	}
+
+	return updateFarm(ctx, "UpdateFarmFromJSON", update.ID, func(farm *Farm) error {
+		*farm = update
+		return nil
+	})
+}
+
+// PatchFarm changes the attributes of an existing farm named in a JSON patch
+// document and leaves the others unchanged
+func (pc *PalmOilContract) PatchFarm(ctx contractapi.TransactionContextInterface, id string, patchJSON string) error {
+	err := authorize(ctx, "PatchFarm")
 	if err != nil {
-		return fmt.Errorf("failed to read from world state: %v", err)
+		return err
+	}
+
+	return updateFarm(ctx, "PatchFarm", id, func(farm *Farm) error {
+		return applyPatch(farmObjectType, farm, patchJSON)
+	})
+}
+
+// updateFarm changes the attributes of an existing farm with update, after
+// checking the caller may change it
+func updateFarm(ctx contractapi.TransactionContextInterface, function string, id string, update func(farm *Farm) error) error {
+	farmJSON, err := getEntityState(ctx, farmObjectType, id)
+	if err != nil {
+		return err
 	}
 	if farmJSON == nil {
 		return fmt.Errorf("the farm with ID %s does not exist", id)
@@ -197,31 +416,85 @@ func (pc *PalmOilContract) UpdateFarm(ctx contractapi.TransactionContextInterfac
 
 	var farm Farm
 	json.Unmarshal(farmJSON, &farm)
+	previousOwner := farm.Owner
 
 	// Update the farm's attributes
-	farm.Owner = owner
-	farm.PlantedYear = plantedYear
-	farm.SeedVarieties = seedVarieties
-	farm.Area = area
-	farm.Address = address
-	farm.Coordinate = coordinate
-	farm.Capacity = capacity
-	farm.Legality = legality
-	farm.Certificate = certificate
+	err = update(&farm)
+	if err != nil {
+		return err
+	}
+
+	// Only the owning farmer or an admin may change a farm, and only an admin
+	// one with no owner
+	if previousOwner != "" {
+		err = authorizeFarmOwner(ctx, function, previousOwner)
+	} else {
+		err = authorizeOwner(ctx, function, nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Farmers hand a farm over with TransferFarmOwnership, so that the new
+	// owner accepts it; only admins reassign or release it directly
+	if previousOwner != "" && farm.Owner != previousOwner {
+		mspID, role, err := callerRole(ctx)
+		if err != nil {
+			return err
+		}
+		if role != RoleAdmin {
+			return &PermissionError{Function: function, Role: role, MSPID: mspID, Reason: "only admins may change the owner of a farm; farmers hand it over with TransferFarmOwnership"}
+		}
+	}
+
+	err = validateFarm(ctx, &farm)
+	if err != nil {
+		return err
+	}
+
+	err = moveFarm(ctx, id, previousOwner, farm.Owner)
+	if err != nil {
+		return err
+	}
+
+	// The new owner did not consent to the farm's partnerships, and a pending
+	// transfer was proposed by the previous owner
+	var endedPartnerships []string
+	var cancelledTransfer *FarmTransfer
+	if farm.Owner != previousOwner {
+		endedPartnerships, err = endFarmPartnerships(ctx, id)
+		if err != nil {
+			return err
+		}
+		cancelledTransfer, err = cancelFarmTransfer(ctx, id)
+		if err != nil {
+			return err
+		}
+	}
 
 	farmJSON, err = json.Marshal(farm)
 	if err != nil {
 		return err
 	}
 
-	return ctx.GetStub().PutState(id, farmJSON)
+	err = putEntityState(ctx, farmObjectType, id, farmJSON)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventFarmUpdated, farmPayload(&farm, endedPartnerships, cancelledTransfer))
 }
 
 // QueryFarmByID retrieves a farm by its ID from the ledger
 func (pc *PalmOilContract) QueryFarmByID(ctx contractapi.TransactionContextInterface, id string) (*Farm, error) {
-	farmJSON, err := ctx.GetStub().GetState(id)
+	err := authorize(ctx, "QueryFarmByID")
 	if err != nil {
-		return nil, fmt.Errorf("failed to read from world state: %v", err)
+		return nil, err
+	}
+
+	farmJSON, err := getEntityState(ctx, farmObjectType, id)
+	if err != nil {
+		return nil, err
 	}
 	if farmJSON == nil {
 		return nil, fmt.Errorf("the farm with ID %s does not exist", id)
@@ -235,28 +508,14 @@ func (pc *PalmOilContract) QueryFarmByID(ctx contractapi.TransactionContextInter
 
 // QueryAllFarms retrieves all farms from the ledger
 func (pc *PalmOilContract) QueryAllFarms(ctx contractapi.TransactionContextInterface) ([]*Farm, error) {
-	resultsIterator, err := ctx.GetStub().GetStateByRange("FRM_", "FRM_zzzzzzzzzz")
+	err := authorize(ctx, "QueryAllFarms")
 	if err != nil {
 		return nil, err
 	}
-	defer resultsIterator.Close()
 
-	var farms []*Farm
-	for resultsIterator.HasNext() {
-		queryResponse, err := resultsIterator.Next()
-		if err != nil {
-			return nil, err
-		}
-
-		var farm Farm
-		json.Unmarshal(queryResponse.Value, &farm)
-		farms = append(farms, &farm)
-	}
-
-	return farms, nil
+	return queryAllEntities[Farm](ctx, farmObjectType)
 }
 
-
 // func main() {
 // 	chaincode, err := contractapi.NewChaincode(new(PalmOilContract))
 // 	if err != nil {
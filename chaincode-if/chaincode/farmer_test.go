@@ -0,0 +1,296 @@
+package chaincode
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"chaincode-if/memledger"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestAddFarmer(t *testing.T) {
+	tests := []struct {
+		name    string
+		caller  *memledger.Identity
+		id      string
+		farms   string
+		opts    []memledger.TxOption
+		wantErr string
+	}{
+		{name: "farmer", caller: farmerUser, id: "FRM_002", farms: `[]`, opts: []memledger.TxOption{personal("1471010101900009")}},
+		{name: "admin", caller: org1Admin, id: "FRM_003", farms: `["FARM_002"]`, opts: []memledger.TxOption{personal("1471010101900009")}},
+		{name: "farmer lists unowned farm", caller: farmerUser, id: "FRM_002", farms: `["FARM_002"]`, opts: []memledger.TxOption{personal("1471010101900009")}, wantErr: "only admins may assign it"},
+		{name: "duplicate ID", caller: farmerUser, id: "FRM_001", farms: `[]`, opts: []memledger.TxOption{personal("1471010101900009")}, wantErr: "already exists"},
+		{name: "empty ID", caller: farmerUser, id: "", farms: `[]`, opts: []memledger.TxOption{personal("1471010101900009")}, wantErr: "must not be empty"},
+		{name: "no personal data", caller: farmerUser, id: "FRM_002", farms: `[]`, wantErr: "transient data"},
+		{name: "invalid farms", caller: farmerUser, id: "FRM_002", farms: `FARM_002`, opts: []memledger.TxOption{personal("1471010101900009")}, wantErr: "failed to parse farm attribute"},
+		{name: "collector role", caller: collectorUser, id: "FRM_002", farms: `[]`, opts: []memledger.TxOption{personal("1471010101900009")}, wantErr: "permission denied"},
+		{name: "peer of another org", caller: farmerUser, id: "FRM_002", farms: `[]`, opts: []memledger.TxOption{personal("1471010101900009"), memledger.WithPeerMSPID("Org2MSP")}, wantErr: "cannot be handled by a peer of Org2MSP"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(t)
+			n.addFarmer(farmerUser, "FRM_001")
+			n.addFarm(org1Admin, "FARM_002", "")
+
+			err := n.submit(tt.caller, func(ctx contractapi.TransactionContextInterface) error {
+				return n.contract.AddFarmer(ctx, tt.id, "Slamet", "Riau", tt.farms)
+			}, tt.opts...)
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+
+			farmer := mustEvaluate(n, tt.caller, func(ctx contractapi.TransactionContextInterface) (*Farmer, error) {
+				return n.contract.QueryFarmerByID(ctx, tt.id)
+			})
+			if farmer.NIKHash != hashNIK(testSalt, "1471010101900009") {
+				t.Errorf("expected the salted NIK hash, got %q", farmer.NIKHash)
+			}
+			if farmer.EnrolledBy == nil || farmer.EnrolledBy.MSPID != tt.caller.MSPID {
+				t.Errorf("expected the farmer to be enrolled by %s, got %+v", tt.caller.MSPID, farmer.EnrolledBy)
+			}
+			for _, kv := range n.ledger.WorldState() {
+				if strings.Contains(string(kv.Value), "1471010101900009") {
+					t.Errorf("the NIK is stored in the world state under %q", kv.Key)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateFarmer(t *testing.T) {
+	tests := []struct {
+		name    string
+		caller  *memledger.Identity
+		id      string
+		farms   string
+		opts    []memledger.TxOption
+		wantErr string
+		wantNIK string
+	}{
+		{name: "enrolling farmer", caller: farmerUser, id: "FRM_001", farms: `["FARM_001"]`, wantNIK: "1471010101900001"},
+		{name: "admin of the same org", caller: org1Admin, id: "FRM_001", farms: `["FARM_001","FARM_002"]`, wantNIK: "1471010101900001"},
+		{name: "farmer lists unowned farm", caller: farmerUser, id: "FRM_001", farms: `["FARM_001","FARM_002"]`, wantErr: "only admins may assign it"},
+		{name: "new personal data", caller: farmerUser, id: "FRM_001", farms: `["FARM_001"]`, opts: []memledger.TxOption{personal("1471010101900099")}, wantNIK: "1471010101900099"},
+		{name: "missing farmer", caller: farmerUser, id: "FRM_404", farms: `[]`, wantErr: "does not exist"},
+		{name: "invalid farms", caller: farmerUser, id: "FRM_001", farms: `{`, wantErr: "failed to parse farm attribute"},
+		{name: "another farmer", caller: otherFarmerUser, id: "FRM_001", farms: `[]`, wantErr: "permission denied"},
+		{name: "admin of another org", caller: org2Admin, id: "FRM_001", farms: `[]`, wantErr: "permission denied"},
+		{name: "transporter role", caller: transporterUser, id: "FRM_001", farms: `[]`, wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(t)
+			n.addFarmer(farmerUser, "FRM_001")
+			n.addFarm(farmerUser, "FARM_001", "FRM_001")
+			n.addFarm(org1Admin, "FARM_002", "")
+
+			err := n.submit(tt.caller, func(ctx contractapi.TransactionContextInterface) error {
+				return n.contract.UpdateFarmer(ctx, tt.id, "Slamet Riyadi", "Jambi", tt.farms)
+			}, tt.opts...)
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+
+			farmer := mustEvaluate(n, farmerUser, func(ctx contractapi.TransactionContextInterface) (*Farmer, error) {
+				return n.contract.QueryFarmerByID(ctx, tt.id)
+			})
+			if farmer.Name != "Slamet Riyadi" || farmer.Address != "Jambi" {
+				t.Errorf("the farmer was not updated: %+v", farmer)
+			}
+			if farmer.NIKHash != hashNIK(testSalt, tt.wantNIK) {
+				t.Errorf("expected the hash of NIK %s, got %q", tt.wantNIK, farmer.NIKHash)
+			}
+			if farmer.EnrolledBy.ClientID == "" {
+				t.Error("the enrollment was lost on update")
+			}
+		})
+	}
+}
+
+func TestQueryFarmerByID(t *testing.T) {
+	n := newTestNetwork(t)
+	n.addFarmer(farmerUser, "FRM_001")
+	n.addFarm(farmerUser, "FARM_001", "FRM_001")
+
+	tests := []struct {
+		name    string
+		caller  *memledger.Identity
+		id      string
+		wantErr string
+	}{
+		{name: "existing", caller: collectorUser, id: "FRM_001"},
+		{name: "missing", caller: collectorUser, id: "FRM_404", wantErr: "does not exist"},
+		{name: "no role", caller: noRoleUser, id: "FRM_001", wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			farmer, err := evaluate(n, tt.caller, func(ctx contractapi.TransactionContextInterface) (*Farmer, error) {
+				return n.contract.QueryFarmerByID(ctx, tt.id)
+			})
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr == "" && !reflect.DeepEqual(farmer.Farm, []string{"FARM_001"}) {
+				t.Errorf("unexpected farms %v", farmer.Farm)
+			}
+		})
+	}
+}
+
+func TestQueryAllFarmers(t *testing.T) {
+	n := newTestNetwork(t)
+
+	farmers := mustEvaluate(n, org1Admin, n.contract.QueryAllFarmers)
+	if len(farmers) != 0 {
+		t.Fatalf("expected no farmers, got %d", len(farmers))
+	}
+
+	n.addFarmer(farmerUser, "FRM_001")
+	n.addFarmer(otherFarmerUser, "FRM_002")
+	n.addCollector(collectorUser, "FRM_003", "1234567890123")
+
+	farmers = mustEvaluate(n, org1Admin, n.contract.QueryAllFarmers)
+	if len(farmers) != 2 || farmers[0].ID != "FRM_001" || farmers[1].ID != "FRM_002" {
+		t.Fatalf("expected FRM_001 and FRM_002, got %+v", farmers)
+	}
+}
+
+func TestAddFarm(t *testing.T) {
+	tests := []struct {
+		name    string
+		caller  *memledger.Identity
+		id      string
+		owner   string
+		wantErr string
+	}{
+		{name: "owning farmer", caller: farmerUser, id: "FARM_002", owner: "FRM_001"},
+		{name: "admin", caller: org1Admin, id: "FARM_002", owner: "FRM_001"},
+		{name: "admin without owner", caller: org1Admin, id: "FARM_002", owner: ""},
+		{name: "farmer without owner", caller: farmerUser, id: "FARM_002", owner: "", wantErr: "permission denied"},
+		{name: "duplicate ID", caller: farmerUser, id: "FARM_001", owner: "FRM_001", wantErr: "already exists"},
+		{name: "missing owner", caller: org1Admin, id: "FARM_002", owner: "FRM_404", wantErr: "does not exist"},
+		{name: "another farmer", caller: otherFarmerUser, id: "FARM_002", owner: "FRM_001", wantErr: "permission denied"},
+		{name: "admin of another org", caller: org2Admin, id: "FARM_002", owner: "FRM_001", wantErr: "permission denied"},
+		{name: "processor role", caller: processorUser, id: "FARM_002", owner: "FRM_001", wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(t)
+			n.addFarmer(farmerUser, "FRM_001")
+			n.addFarm(farmerUser, "FARM_001", "FRM_001")
+
+			err := n.submit(tt.caller, func(ctx contractapi.TransactionContextInterface) error {
+				return n.contract.AddFarm(ctx, tt.id, tt.owner, 2015, "Dura", 1.5, "Siak", "0.7,102.0", 10, "SHM", "ISPO")
+			})
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+
+			farm := mustEvaluate(n, tt.caller, func(ctx contractapi.TransactionContextInterface) (*Farm, error) {
+				return n.contract.QueryFarmByID(ctx, tt.id)
+			})
+			want := Farm{ID: tt.id, Owner: tt.owner, PlantedYear: 2015, SeedVarieties: "Dura", Area: 1.5, Address: "Siak", Coordinate: "0.7,102.0", Capacity: 10, Legality: "SHM", Certificate: "ISPO"}
+			if *farm != want {
+				t.Errorf("expected %+v, got %+v", want, *farm)
+			}
+		})
+	}
+}
+
+func TestUpdateFarm(t *testing.T) {
+	tests := []struct {
+		name    string
+		caller  *memledger.Identity
+		id      string
+		owner   string
+		wantErr string
+	}{
+		{name: "owning farmer", caller: farmerUser, id: "FARM_001", owner: "FRM_001"},
+		{name: "admin", caller: org1Admin, id: "FARM_001", owner: "FRM_001"},
+		{name: "admin assigns unowned farm", caller: org1Admin, id: "FARM_002", owner: "FRM_002"},
+		{name: "farmer claims unowned farm", caller: otherFarmerUser, id: "FARM_002", owner: "FRM_002", wantErr: "permission denied"},
+		{name: "farmer claims unowned farm for another", caller: farmerUser, id: "FARM_002", owner: "FRM_002", wantErr: "permission denied"},
+		{name: "missing farm", caller: farmerUser, id: "FARM_404", owner: "FRM_001", wantErr: "does not exist"},
+		{name: "another farmer", caller: otherFarmerUser, id: "FARM_001", owner: "FRM_002", wantErr: "permission denied"},
+		{name: "collector role", caller: collectorUser, id: "FARM_001", owner: "FRM_001", wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(t)
+			n.addFarmer(farmerUser, "FRM_001")
+			n.addFarmer(otherFarmerUser, "FRM_002")
+			n.addFarm(farmerUser, "FARM_001", "FRM_001")
+			n.addFarm(org1Admin, "FARM_002", "")
+
+			err := n.submit(tt.caller, func(ctx contractapi.TransactionContextInterface) error {
+				return n.contract.UpdateFarm(ctx, tt.id, tt.owner, 2012, "Tenera", 3, "Kampar", "0.5,101.4", 30, "SHM", "RSPO")
+			})
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+
+			farm := mustEvaluate(n, tt.caller, func(ctx contractapi.TransactionContextInterface) (*Farm, error) {
+				return n.contract.QueryFarmByID(ctx, tt.id)
+			})
+			if farm.Owner != tt.owner || farm.Area != 3 || farm.Capacity != 30 {
+				t.Errorf("the farm was not updated: %+v", farm)
+			}
+		})
+	}
+}
+
+func TestQueryFarmByID(t *testing.T) {
+	n := newTestNetwork(t)
+	n.addFarmer(farmerUser, "FRM_001")
+	n.addFarm(farmerUser, "FARM_001", "FRM_001")
+
+	tests := []struct {
+		name    string
+		id      string
+		wantErr string
+	}{
+		{name: "existing", id: "FARM_001"},
+		{name: "missing", id: "FARM_404", wantErr: "does not exist"},
+		{name: "farmer ID", id: "FRM_001", wantErr: "does not exist"},
+		{name: "empty ID", id: "", wantErr: "must not be empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			farm, err := evaluate(n, transporterUser, func(ctx contractapi.TransactionContextInterface) (*Farm, error) {
+				return n.contract.QueryFarmByID(ctx, tt.id)
+			})
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr == "" && farm.Owner != "FRM_001" {
+				t.Errorf("expected owner FRM_001, got %q", farm.Owner)
+			}
+		})
+	}
+}
+
+func TestQueryAllFarms(t *testing.T) {
+	n := newTestNetwork(t)
+	n.addFarmer(farmerUser, "FRM_001")
+	n.addFarm(farmerUser, "FARM_001", "FRM_001")
+	n.addFarm(farmerUser, "FARM_002", "FRM_001")
+
+	farms := mustEvaluate(n, collectorUser, n.contract.QueryAllFarms)
+	if len(farms) != 2 || farms[0].ID != "FARM_001" || farms[1].ID != "FARM_002" {
+		t.Fatalf("expected FARM_001 and FARM_002, got %+v", farms)
+	}
+
+	_, err := evaluate(n, noRoleUser, n.contract.QueryAllFarms)
+	if !isPermissionError(err) {
+		t.Fatalf("expected a permission error, got %v", err)
+	}
+}
@@ -0,0 +1,459 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// TransferStatus is the status of a farm transfer
+type TransferStatus string
+
+// Statuses of a farm transfer. A pending transfer ends when the new owner
+// accepts or rejects it, when the current owner cancels it, or when an admin
+// changes the owner of the farm.
+const (
+	TransferPending   TransferStatus = "pending"
+	TransferAccepted  TransferStatus = "accepted"
+	TransferRejected  TransferStatus = "rejected"
+	TransferCancelled TransferStatus = "cancelled"
+)
+
+// FarmTransfer is the latest proposal to hand a farm over to another farmer.
+// The farm changes owner only once the new owner accepts it, which ends the
+// partnerships of the farm listed in EndedPartnerships.
+type FarmTransfer struct {
+	FarmID            string         `json:"farmId"`
+	From              string         `json:"from"`
+	To                string         `json:"to"`
+	Status            TransferStatus `json:"status"`
+	ProposedAt        string         `json:"proposedAt"`
+	ProposedTxID      string         `json:"proposedTxId"`
+	ClosedAt          string         `json:"closedAt,omitempty" metadata:",optional"`
+	ClosedTxID        string         `json:"closedTxId,omitempty" metadata:",optional"`
+	EndedPartnerships []string       `json:"endedPartnerships,omitempty" metadata:",optional"`
+}
+
+// txTime returns the transaction timestamp in RFC 3339 format
+func txTime(ctx contractapi.TransactionContextInterface) (string, error) {
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return "", fmt.Errorf("failed to read transaction timestamp: %v", err)
+	}
+	return time.Unix(timestamp.GetSeconds(), int64(timestamp.GetNanos())).UTC().Format(time.RFC3339Nano), nil
+}
+
+// readFarmer fetches a farmer from the ledger
+func readFarmer(ctx contractapi.TransactionContextInterface, id string) (*Farmer, error) {
+	farmerJSON, err := getEntityState(ctx, farmerObjectType, id)
+	if err != nil {
+		return nil, err
+	}
+	if farmerJSON == nil {
+		return nil, fmt.Errorf("the farmer with ID %s does not exist", id)
+	}
+
+	var farmer Farmer
+	err = json.Unmarshal(farmerJSON, &farmer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal farmer JSON: %v", err)
+	}
+
+	return &farmer, nil
+}
+
+// writeFarmer stores a farmer on the ledger
+func writeFarmer(ctx contractapi.TransactionContextInterface, farmer *Farmer) error {
+	if farmer.Farm == nil {
+		farmer.Farm = []string{}
+	}
+
+	farmerJSON, err := json.Marshal(farmer)
+	if err != nil {
+		return fmt.Errorf("failed to marshal farmer: %v", err)
+	}
+
+	return putEntityState(ctx, farmerObjectType, farmer.ID, farmerJSON)
+}
+
+// readFarm fetches a farm from the ledger
+func readFarm(ctx contractapi.TransactionContextInterface, id string) (*Farm, error) {
+	farmJSON, err := getEntityState(ctx, farmObjectType, id)
+	if err != nil {
+		return nil, err
+	}
+	if farmJSON == nil {
+		return nil, fmt.Errorf("the farm with ID %s does not exist", id)
+	}
+
+	var farm Farm
+	err = json.Unmarshal(farmJSON, &farm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal farm JSON: %v", err)
+	}
+
+	return &farm, nil
+}
+
+// writeFarm stores a farm on the ledger
+func writeFarm(ctx contractapi.TransactionContextInterface, farm *Farm) error {
+	farmJSON, err := json.Marshal(farm)
+	if err != nil {
+		return fmt.Errorf("failed to marshal farm: %v", err)
+	}
+
+	return putEntityState(ctx, farmObjectType, farm.ID, farmJSON)
+}
+
+// moveFarm keeps the farm lists of farmers in step with a farm's owner: the
+// farm leaves the list of its previous owner and joins that of its new owner.
+// The new owner must be a registered farmer.
+func moveFarm(ctx contractapi.TransactionContextInterface, farmID string, previousOwner string, owner string) error {
+	if previousOwner != "" && previousOwner != owner {
+		farmer, err := readFarmer(ctx, previousOwner)
+		if err != nil {
+			return err
+		}
+		farms := []string{}
+		for _, id := range farmer.Farm {
+			if id != farmID {
+				farms = append(farms, id)
+			}
+		}
+		if len(farms) != len(farmer.Farm) {
+			farmer.Farm = farms
+			err = writeFarmer(ctx, farmer)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if owner == "" {
+		return nil
+	}
+	farmer, err := readFarmer(ctx, owner)
+	if err != nil {
+		return err
+	}
+	if containsString(farmer.Farm, farmID) {
+		return nil
+	}
+	farmer.Farm = append(farmer.Farm, farmID)
+
+	return writeFarmer(ctx, farmer)
+}
+
+// claimListedFarms checks the farm list of a farmer against the owners of the
+// farms. Newly listed farms must exist and be owned by the farmer or by no
+// one, in which case an admin caller makes the farmer their owner. Farms the
+// farmer owns can only leave the list by being transferred.
+func claimListedFarms(ctx contractapi.TransactionContextInterface, function string, farmer *Farmer, previous []string) error {
+	listed := map[string]bool{}
+	for _, farmID := range farmer.Farm {
+		if listed[farmID] {
+			return fmt.Errorf("the farm with ID %s is listed more than once", farmID)
+		}
+		listed[farmID] = true
+
+		if containsString(previous, farmID) {
+			continue
+		}
+		farm, err := readFarm(ctx, farmID)
+		if err != nil {
+			return err
+		}
+		switch farm.Owner {
+		case farmer.ID:
+		case "":
+			// Only admins create ownerless farms, so only they hand them out
+			mspID, role, err := callerRole(ctx)
+			if err != nil {
+				return err
+			}
+			if role != RoleAdmin {
+				return &PermissionError{Function: function, Role: role, MSPID: mspID, Reason: fmt.Sprintf("the farm with ID %s has no owner and only admins may assign it", farmID)}
+			}
+			farm.Owner = farmer.ID
+			err = writeFarm(ctx, farm)
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("the farm with ID %s is owned by farmer %s and can only be handed over with TransferFarmOwnership", farmID, farm.Owner)
+		}
+	}
+
+	for _, farmID := range previous {
+		if listed[farmID] {
+			continue
+		}
+		farmJSON, err := getEntityState(ctx, farmObjectType, farmID)
+		if err != nil {
+			return err
+		}
+		if farmJSON == nil {
+			continue
+		}
+		var farm Farm
+		json.Unmarshal(farmJSON, &farm)
+		if farm.Owner == farmer.ID {
+			return fmt.Errorf("farmer %s owns the farm with ID %s and can only remove it with TransferFarmOwnership", farmer.ID, farmID)
+		}
+	}
+
+	return nil
+}
+
+// readFarmTransfer fetches the latest transfer of a farm, or nil if it has none
+func readFarmTransfer(ctx contractapi.TransactionContextInterface, farmID string) (*FarmTransfer, error) {
+	transferJSON, err := getEntityState(ctx, farmTransferObjectType, farmID)
+	if err != nil {
+		return nil, err
+	}
+	if transferJSON == nil {
+		return nil, nil
+	}
+
+	var transfer FarmTransfer
+	err = json.Unmarshal(transferJSON, &transfer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal farm transfer JSON: %v", err)
+	}
+
+	return &transfer, nil
+}
+
+// writeFarmTransfer stores a farm transfer and emits its event
+func writeFarmTransfer(ctx contractapi.TransactionContextInterface, transfer *FarmTransfer, eventName string) error {
+	err := putFarmTransfer(ctx, transfer)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, eventName, transfer)
+}
+
+// putFarmTransfer stores a farm transfer
+func putFarmTransfer(ctx contractapi.TransactionContextInterface, transfer *FarmTransfer) error {
+	transferJSON, err := json.Marshal(transfer)
+	if err != nil {
+		return fmt.Errorf("failed to marshal farm transfer: %v", err)
+	}
+
+	return putEntityState(ctx, farmTransferObjectType, transfer.FarmID, transferJSON)
+}
+
+// TransferFarmOwnership proposes to hand a farm over to another farmer. The
+// farm keeps its owner until the new owner accepts with AcceptFarmOwnership.
+func (pc *PalmOilContract) TransferFarmOwnership(ctx contractapi.TransactionContextInterface, farmID string, newOwner string) error {
+	err := authorize(ctx, "TransferFarmOwnership")
+	if err != nil {
+		return err
+	}
+
+	farm, err := readFarm(ctx, farmID)
+	if err != nil {
+		return err
+	}
+	if farm.Owner == "" {
+		return fmt.Errorf("the farm with ID %s has no owner", farmID)
+	}
+
+	// Only the owning farmer or an admin may give a farm away
+	err = authorizeFarmOwner(ctx, "TransferFarmOwnership", farm.Owner)
+	if err != nil {
+		return err
+	}
+
+	if newOwner == farm.Owner {
+		return fmt.Errorf("the farm with ID %s is already owned by farmer %s", farmID, newOwner)
+	}
+	_, err = readFarmer(ctx, newOwner)
+	if err != nil {
+		return err
+	}
+
+	existing, err := readFarmTransfer(ctx, farmID)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.Status == TransferPending {
+		return fmt.Errorf("the farm with ID %s already has a pending transfer to farmer %s", farmID, existing.To)
+	}
+
+	proposedAt, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	return writeFarmTransfer(ctx, &FarmTransfer{
+		FarmID:       farmID,
+		From:         farm.Owner,
+		To:           newOwner,
+		Status:       TransferPending,
+		ProposedAt:   proposedAt,
+		ProposedTxID: ctx.GetStub().GetTxID(),
+	}, EventFarmTransferProposed)
+}
+
+// AcceptFarmOwnership accepts the pending transfer of a farm, making the
+// caller's farmer its owner. The new owner did not consent to the farm's
+// partnerships, so those still proposed or active are ended.
+func (pc *PalmOilContract) AcceptFarmOwnership(ctx contractapi.TransactionContextInterface, farmID string) error {
+	err := authorize(ctx, "AcceptFarmOwnership")
+	if err != nil {
+		return err
+	}
+
+	transfer, err := pendingFarmTransfer(ctx, "AcceptFarmOwnership", farmID, false)
+	if err != nil {
+		return err
+	}
+
+	farm, err := readFarm(ctx, farmID)
+	if err != nil {
+		return err
+	}
+	if farm.Owner != transfer.From {
+		return fmt.Errorf("the farm with ID %s changed owner after the transfer was proposed", farmID)
+	}
+
+	farm.Owner = transfer.To
+	err = writeFarm(ctx, farm)
+	if err != nil {
+		return err
+	}
+	err = moveFarm(ctx, farmID, transfer.From, transfer.To)
+	if err != nil {
+		return err
+	}
+	transfer.EndedPartnerships, err = endFarmPartnerships(ctx, farmID)
+	if err != nil {
+		return err
+	}
+
+	return closeFarmTransfer(ctx, transfer, TransferAccepted, EventFarmOwnershipTransferred)
+}
+
+// RejectFarmOwnership turns down the pending transfer of a farm to the
+// caller's farmer
+func (pc *PalmOilContract) RejectFarmOwnership(ctx contractapi.TransactionContextInterface, farmID string) error {
+	err := authorize(ctx, "RejectFarmOwnership")
+	if err != nil {
+		return err
+	}
+
+	transfer, err := pendingFarmTransfer(ctx, "RejectFarmOwnership", farmID, false)
+	if err != nil {
+		return err
+	}
+
+	return closeFarmTransfer(ctx, transfer, TransferRejected, EventFarmTransferRejected)
+}
+
+// CancelFarmOwnershipTransfer withdraws the pending transfer of a farm before
+// the new owner answers it
+func (pc *PalmOilContract) CancelFarmOwnershipTransfer(ctx contractapi.TransactionContextInterface, farmID string) error {
+	err := authorize(ctx, "CancelFarmOwnershipTransfer")
+	if err != nil {
+		return err
+	}
+
+	transfer, err := pendingFarmTransfer(ctx, "CancelFarmOwnershipTransfer", farmID, true)
+	if err != nil {
+		return err
+	}
+
+	return closeFarmTransfer(ctx, transfer, TransferCancelled, EventFarmTransferCancelled)
+}
+
+// pendingFarmTransfer reads the pending transfer of a farm and checks that the
+// caller may act for its current owner, if byOwner, or for its new owner
+func pendingFarmTransfer(ctx contractapi.TransactionContextInterface, function string, farmID string, byOwner bool) (*FarmTransfer, error) {
+	transfer, err := readFarmTransfer(ctx, farmID)
+	if err != nil {
+		return nil, err
+	}
+	if transfer == nil || transfer.Status != TransferPending {
+		return nil, fmt.Errorf("the farm with ID %s has no pending transfer", farmID)
+	}
+
+	farmerID := transfer.To
+	if byOwner {
+		farmerID = transfer.From
+	}
+	err = authorizeFarmOwner(ctx, function, farmerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+// closeFarmTransfer ends a pending transfer with the given status
+func closeFarmTransfer(ctx contractapi.TransactionContextInterface, transfer *FarmTransfer, status TransferStatus, eventName string) error {
+	err := markTransferClosed(ctx, transfer, status)
+	if err != nil {
+		return err
+	}
+
+	return writeFarmTransfer(ctx, transfer, eventName)
+}
+
+// markTransferClosed sets the status of a farm transfer and records the
+// transaction that closed it
+func markTransferClosed(ctx contractapi.TransactionContextInterface, transfer *FarmTransfer, status TransferStatus) error {
+	closedAt, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	transfer.Status = status
+	transfer.ClosedAt = closedAt
+	transfer.ClosedTxID = ctx.GetStub().GetTxID()
+	return nil
+}
+
+// cancelFarmTransfer cancels the pending transfer of a farm and returns it,
+// or nil if the farm has none. It emits no event, so the caller announces it.
+func cancelFarmTransfer(ctx contractapi.TransactionContextInterface, farmID string) (*FarmTransfer, error) {
+	transfer, err := readFarmTransfer(ctx, farmID)
+	if err != nil {
+		return nil, err
+	}
+	if transfer == nil || transfer.Status != TransferPending {
+		return nil, nil
+	}
+
+	err = markTransferClosed(ctx, transfer, TransferCancelled)
+	if err != nil {
+		return nil, err
+	}
+	err = putFarmTransfer(ctx, transfer)
+	if err != nil {
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+// QueryFarmTransfer retrieves the latest transfer proposed for a farm
+func (pc *PalmOilContract) QueryFarmTransfer(ctx contractapi.TransactionContextInterface, farmID string) (*FarmTransfer, error) {
+	err := authorize(ctx, "QueryFarmTransfer")
+	if err != nil {
+		return nil, err
+	}
+
+	transfer, err := readFarmTransfer(ctx, farmID)
+	if err != nil {
+		return nil, err
+	}
+	if transfer == nil {
+		return nil, fmt.Errorf("no transfer was proposed for the farm with ID %s", farmID)
+	}
+
+	return transfer, nil
+}
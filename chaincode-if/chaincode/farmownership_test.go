@@ -0,0 +1,424 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"chaincode-if/memledger"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// farmsOf returns the farm list of a farmer
+func (n *testNetwork) farmsOf(farmerID string) []string {
+	n.t.Helper()
+	farmer := mustEvaluate(n, org1Admin, func(ctx contractapi.TransactionContextInterface) (*Farmer, error) {
+		return n.contract.QueryFarmerByID(ctx, farmerID)
+	})
+	return farmer.Farm
+}
+
+// ownerOf returns the owner of a farm
+func (n *testNetwork) ownerOf(farmID string) string {
+	n.t.Helper()
+	farm := mustEvaluate(n, org1Admin, func(ctx contractapi.TransactionContextInterface) (*Farm, error) {
+		return n.contract.QueryFarmByID(ctx, farmID)
+	})
+	return farm.Owner
+}
+
+func TestFarmLists(t *testing.T) {
+	tests := []struct {
+		name      string
+		caller    *memledger.Identity
+		tx        func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error
+		opts      []memledger.TxOption
+		wantErr   string
+		wantFarms map[string][]string
+		wantOwner map[string]string
+	}{
+		{
+			name:   "add farm",
+			caller: farmerUser,
+			tx: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+				return c.AddFarm(ctx, "FARM_003", "FRM_001", 2015, "Dura", 1.5, "Siak", "", 10, "SHM", "ISPO")
+			},
+			wantFarms: map[string][]string{"FRM_001": {"FARM_001", "FARM_003"}},
+		},
+		{
+			name:   "admin assigns unowned farm",
+			caller: org1Admin,
+			tx: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+				return c.PatchFarm(ctx, "FARM_002", `{"owner":"FRM_002"}`)
+			},
+			wantFarms: map[string][]string{"FRM_002": {"FARM_002"}},
+		},
+		{
+			name:   "farmer claims unowned farm",
+			caller: otherFarmerUser,
+			tx: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+				return c.PatchFarm(ctx, "FARM_002", `{"owner":"FRM_002"}`)
+			},
+			wantErr: "permission denied",
+		},
+		{
+			name:   "admin reassigns farm",
+			caller: org1Admin,
+			tx: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+				return c.PatchFarm(ctx, "FARM_001", `{"owner":"FRM_002"}`)
+			},
+			wantFarms: map[string][]string{"FRM_001": {}, "FRM_002": {"FARM_001"}},
+		},
+		{
+			name:   "admin releases farm",
+			caller: org1Admin,
+			tx: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+				return c.PatchFarm(ctx, "FARM_001", `{"owner":""}`)
+			},
+			wantFarms: map[string][]string{"FRM_001": {}},
+		},
+		{
+			name:   "admin reassigns farm to missing farmer",
+			caller: org1Admin,
+			tx: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+				return c.PatchFarm(ctx, "FARM_001", `{"owner":"FRM_404"}`)
+			},
+			wantErr: "the farmer with ID FRM_404 does not exist",
+		},
+		{
+			name:   "farmer reassigns farm",
+			caller: farmerUser,
+			tx: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+				return c.PatchFarm(ctx, "FARM_001", `{"owner":"FRM_002"}`)
+			},
+			wantErr: "farmers hand it over with TransferFarmOwnership",
+		},
+		{
+			name:   "admin registers farmer with unowned farm",
+			caller: org1Admin,
+			tx: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+				return c.AddFarmer(ctx, "FRM_003", "Wayan", "Bali", `["FARM_002"]`)
+			},
+			opts:      []memledger.TxOption{personal("1471010101900009")},
+			wantFarms: map[string][]string{"FRM_003": {"FARM_002"}},
+			wantOwner: map[string]string{"FARM_002": "FRM_003"},
+		},
+		{
+			name:   "new farmer claims unowned farm",
+			caller: farmerUser,
+			tx: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+				return c.AddFarmer(ctx, "FRM_003", "Wayan", "Bali", `["FARM_002"]`)
+			},
+			opts:    []memledger.TxOption{personal("1471010101900009")},
+			wantErr: "only admins may assign it",
+		},
+		{
+			name:   "new farmer lists farm of another",
+			caller: farmerUser,
+			tx: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+				return c.AddFarmer(ctx, "FRM_003", "Wayan", "Bali", `["FARM_001"]`)
+			},
+			opts:    []memledger.TxOption{personal("1471010101900009")},
+			wantErr: "owned by farmer FRM_001",
+		},
+		{
+			name:   "new farmer lists missing farm",
+			caller: farmerUser,
+			tx: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+				return c.AddFarmer(ctx, "FRM_003", "Wayan", "Bali", `["FARM_404"]`)
+			},
+			opts:    []memledger.TxOption{personal("1471010101900009")},
+			wantErr: "the farm with ID FARM_404 does not exist",
+		},
+		{
+			name:   "farm listed twice",
+			caller: farmerUser,
+			tx: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+				return c.PatchFarmer(ctx, "FRM_001", `{"farm":["FARM_001","FARM_001"]}`)
+			},
+			wantErr: "listed more than once",
+		},
+		{
+			name:   "admin lists unowned farm on update",
+			caller: org1Admin,
+			tx: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+				return c.PatchFarmer(ctx, "FRM_001", `{"farm":["FARM_001","FARM_002"]}`)
+			},
+			wantFarms: map[string][]string{"FRM_001": {"FARM_001", "FARM_002"}},
+			wantOwner: map[string]string{"FARM_002": "FRM_001"},
+		},
+		{
+			name:   "farmer claims unowned farm on update",
+			caller: farmerUser,
+			tx: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+				return c.PatchFarmer(ctx, "FRM_001", `{"farm":["FARM_001","FARM_002"]}`)
+			},
+			wantErr: "only admins may assign it",
+		},
+		{
+			name:   "farmer drops owned farm",
+			caller: farmerUser,
+			tx: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+				return c.PatchFarmer(ctx, "FRM_001", `{"farm":[]}`)
+			},
+			wantErr: "can only remove it with TransferFarmOwnership",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(t)
+			n.addFarmer(farmerUser, "FRM_001")
+			n.addFarmer(otherFarmerUser, "FRM_002")
+			n.addFarm(farmerUser, "FARM_001", "FRM_001")
+			n.addFarm(org1Admin, "FARM_002", "")
+
+			err := n.submit(tt.caller, func(ctx contractapi.TransactionContextInterface) error {
+				return tt.tx(n.contract, ctx)
+			}, tt.opts...)
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+
+			for farmerID, want := range tt.wantFarms {
+				if got := n.farmsOf(farmerID); !reflect.DeepEqual(got, want) {
+					t.Errorf("expected farms %v of %s, got %v", want, farmerID, got)
+				}
+			}
+			for farmID, want := range tt.wantOwner {
+				if got := n.ownerOf(farmID); got != want {
+					t.Errorf("expected owner %s of %s, got %s", want, farmID, got)
+				}
+			}
+		})
+	}
+}
+
+func TestTransferFarmOwnership(t *testing.T) {
+	n := newTestNetwork(t)
+	n.addFarmer(farmerUser, "FRM_001")
+	n.addFarmer(otherFarmerUser, "FRM_002")
+	n.addFarm(farmerUser, "FARM_001", "FRM_001")
+	n.addFarm(farmerUser, "FARM_002", "FRM_001")
+
+	transfer := func(caller *memledger.Identity, fn func(ctx contractapi.TransactionContextInterface) error) {
+		t.Helper()
+		n.mustSubmit(caller, fn)
+	}
+	status := func() TransferStatus {
+		t.Helper()
+		transfer := mustEvaluate(n, collectorUser, func(ctx contractapi.TransactionContextInterface) (*FarmTransfer, error) {
+			return n.contract.QueryFarmTransfer(ctx, "FARM_001")
+		})
+		return transfer.Status
+	}
+
+	// A proposal changes nothing until the new owner accepts it
+	transfer(farmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.TransferFarmOwnership(ctx, "FARM_001", "FRM_002")
+	})
+	if n.ownerOf("FARM_001") != "FRM_001" || status() != TransferPending || n.lastEvent().Name != EventFarmTransferProposed {
+		t.Fatal("the proposal changed the owner or was not recorded")
+	}
+	transfer(otherFarmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.RejectFarmOwnership(ctx, "FARM_001")
+	})
+	if n.ownerOf("FARM_001") != "FRM_001" || status() != TransferRejected || n.lastEvent().Name != EventFarmTransferRejected {
+		t.Fatal("the rejection was not recorded")
+	}
+
+	transfer(farmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.TransferFarmOwnership(ctx, "FARM_001", "FRM_002")
+	})
+	transfer(farmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.CancelFarmOwnershipTransfer(ctx, "FARM_001")
+	})
+	if status() != TransferCancelled || n.lastEvent().Name != EventFarmTransferCancelled {
+		t.Fatal("the cancellation was not recorded")
+	}
+
+	transfer(farmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.TransferFarmOwnership(ctx, "FARM_001", "FRM_002")
+	})
+	transfer(otherFarmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.AcceptFarmOwnership(ctx, "FARM_001")
+	})
+	if n.ownerOf("FARM_001") != "FRM_002" || status() != TransferAccepted || n.lastEvent().Name != EventFarmOwnershipTransferred {
+		t.Fatal("the accepted transfer did not change the owner")
+	}
+	if !reflect.DeepEqual(n.farmsOf("FRM_001"), []string{"FARM_002"}) || !reflect.DeepEqual(n.farmsOf("FRM_002"), []string{"FARM_001"}) {
+		t.Errorf("the farm lists were not updated: %v and %v", n.farmsOf("FRM_001"), n.farmsOf("FRM_002"))
+	}
+
+	// The new owner now acts for the farm
+	transfer(otherFarmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.PatchFarm(ctx, "FARM_001", `{"certificate":"ISPO"}`)
+	})
+}
+
+func TestTransferFarmOwnershipErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		caller  *memledger.Identity
+		tx      func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error
+		wantErr string
+	}{
+		{name: "by another farmer", caller: otherFarmerUser, tx: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+			return c.TransferFarmOwnership(ctx, "FARM_001", "FRM_002")
+		}, wantErr: "permission denied"},
+		{name: "to the owner", caller: farmerUser, tx: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+			return c.TransferFarmOwnership(ctx, "FARM_001", "FRM_001")
+		}, wantErr: "already owned by farmer FRM_001"},
+		{name: "to a missing farmer", caller: farmerUser, tx: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+			return c.TransferFarmOwnership(ctx, "FARM_001", "FRM_404")
+		}, wantErr: "the farmer with ID FRM_404 does not exist"},
+		{name: "missing farm", caller: farmerUser, tx: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+			return c.TransferFarmOwnership(ctx, "FARM_404", "FRM_002")
+		}, wantErr: "the farm with ID FARM_404 does not exist"},
+		{name: "unowned farm", caller: org1Admin, tx: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+			return c.TransferFarmOwnership(ctx, "FARM_003", "FRM_002")
+		}, wantErr: "has no owner"},
+		{name: "second proposal", caller: farmerUser, tx: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+			return c.TransferFarmOwnership(ctx, "FARM_002", "FRM_002")
+		}, wantErr: "already has a pending transfer to farmer FRM_002"},
+		{name: "accept by the owner", caller: farmerUser, tx: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+			return c.AcceptFarmOwnership(ctx, "FARM_002")
+		}, wantErr: "permission denied"},
+		{name: "reject by the owner", caller: farmerUser, tx: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+			return c.RejectFarmOwnership(ctx, "FARM_002")
+		}, wantErr: "permission denied"},
+		{name: "cancel by the new owner", caller: otherFarmerUser, tx: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+			return c.CancelFarmOwnershipTransfer(ctx, "FARM_002")
+		}, wantErr: "permission denied"},
+		{name: "accept without proposal", caller: otherFarmerUser, tx: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+			return c.AcceptFarmOwnership(ctx, "FARM_001")
+		}, wantErr: "has no pending transfer"},
+		{name: "collector role", caller: collectorUser, tx: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+			return c.AcceptFarmOwnership(ctx, "FARM_002")
+		}, wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(t)
+			n.addFarmer(farmerUser, "FRM_001")
+			n.addFarmer(otherFarmerUser, "FRM_002")
+			n.addFarm(farmerUser, "FARM_001", "FRM_001")
+			n.addFarm(farmerUser, "FARM_002", "FRM_001")
+			n.addFarm(org1Admin, "FARM_003", "")
+			n.mustSubmit(farmerUser, func(ctx contractapi.TransactionContextInterface) error {
+				return n.contract.TransferFarmOwnership(ctx, "FARM_002", "FRM_002")
+			})
+
+			err := n.submit(tt.caller, func(ctx contractapi.TransactionContextInterface) error {
+				return tt.tx(n.contract, ctx)
+			})
+			checkError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestOwnerChangeCancelsTransfer(t *testing.T) {
+	n := newTestNetwork(t)
+	n.addFarmer(farmerUser, "FRM_001")
+	n.addFarmer(otherFarmerUser, "FRM_002")
+	n.addFarmer(org1Admin, "FRM_003")
+	n.addFarm(farmerUser, "FARM_001", "FRM_001")
+	n.mustSubmit(farmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.TransferFarmOwnership(ctx, "FARM_001", "FRM_002")
+	})
+
+	// An admin reassigns the farm while the transfer is pending
+	n.mustSubmit(org1Admin, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.PatchFarm(ctx, "FARM_001", `{"owner":"FRM_003"}`)
+	})
+
+	var payload FarmPayload
+	json.Unmarshal(n.lastEvent().Payload, &payload)
+	if payload.CancelledTransfer == nil || payload.CancelledTransfer.To != "FRM_002" || payload.CancelledTransfer.Status != TransferCancelled {
+		t.Errorf("expected the FarmUpdated event to announce the cancelled transfer, got %+v", payload.CancelledTransfer)
+	}
+	transfer := mustEvaluate(n, org1Admin, func(ctx contractapi.TransactionContextInterface) (*FarmTransfer, error) {
+		return n.contract.QueryFarmTransfer(ctx, "FARM_001")
+	})
+	if transfer.Status != TransferCancelled {
+		t.Errorf("expected the transfer to be cancelled, got %s", transfer.Status)
+	}
+
+	err := n.submit(otherFarmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.AcceptFarmOwnership(ctx, "FARM_001")
+	})
+	checkError(t, err, "has no pending transfer")
+
+	// The new owner can hand the farm over in turn
+	n.mustSubmit(org1Admin, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.TransferFarmOwnership(ctx, "FARM_001", "FRM_002")
+	})
+	n.mustSubmit(otherFarmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.AcceptFarmOwnership(ctx, "FARM_001")
+	})
+	if owner := n.ownerOf("FARM_001"); owner != "FRM_002" {
+		t.Errorf("expected owner FRM_002, got %s", owner)
+	}
+}
+
+func TestTransferEndsFarmPartnerships(t *testing.T) {
+	n := newTestNetwork(t)
+	n.addFarmer(farmerUser, "FRM_001")
+	n.addFarmer(otherFarmerUser, "FRM_002")
+	n.addFarm(farmerUser, "FARM_001", "FRM_001")
+	n.addCollector(collectorUser, "COL_001", "1234567890123")
+	n.addPartnership("PRT_001", "COL_001", PartnerFarm, "FARM_001")
+	n.addPartnership("PRT_002", "COL_001", PartnerFarmer, "FRM_001")
+
+	n.mustSubmit(farmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.TransferFarmOwnership(ctx, "FARM_001", "FRM_002")
+	})
+	n.mustSubmit(otherFarmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.AcceptFarmOwnership(ctx, "FARM_001")
+	})
+
+	var transfer FarmTransfer
+	json.Unmarshal(n.lastEvent().Payload, &transfer)
+	if !reflect.DeepEqual(transfer.EndedPartnerships, []string{"PRT_001"}) {
+		t.Errorf("expected the transfer to announce PRT_001, got %+v", transfer)
+	}
+	if partnership := n.partnership("PRT_001"); partnership.Status != PartnershipEnded || partnership.ClosedTxID == "" {
+		t.Errorf("expected the farm partnership to end, got %+v", partnership)
+	}
+	if partnership := n.partnership("PRT_002"); partnership.Status != PartnershipActive {
+		t.Errorf("expected the partnership with the previous owner to stay active, got %+v", partnership)
+	}
+	if partners := n.partnersOf("COL_001"); !reflect.DeepEqual(partners, []string{"FRM_001"}) {
+		t.Errorf("expected partners FRM_001, got %v", partners)
+	}
+
+	// The new owner's harvest needs the new owner's consent
+	n.harvest("COM_001", "FARM_001", 100, "2024-01-10")
+	err := n.submit(collectorUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.Collect(ctx, "COM_001", "COL_001", "Sari", "Pekanbaru")
+	})
+	checkError(t, err, "is not an active partner of collector COL_001")
+}
+
+func TestReassignEndsFarmPartnerships(t *testing.T) {
+	n := newTestNetwork(t)
+	n.addFarmer(farmerUser, "FRM_001")
+	n.addFarmer(otherFarmerUser, "FRM_002")
+	n.addFarm(farmerUser, "FARM_001", "FRM_001")
+	n.addCollector(collectorUser, "COL_001", "1234567890123")
+	n.addPartnership("PRT_001", "COL_001", PartnerFarm, "FARM_001")
+
+	n.mustSubmit(org1Admin, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.PatchFarm(ctx, "FARM_001", `{"owner":"FRM_002"}`)
+	})
+
+	if partnership := n.partnership("PRT_001"); partnership.Status != PartnershipEnded {
+		t.Errorf("expected the farm partnership to end, got %+v", partnership)
+	}
+	if partners := n.partnersOf("COL_001"); !reflect.DeepEqual(partners, []string{}) {
+		t.Errorf("expected no partners, got %v", partners)
+	}
+}
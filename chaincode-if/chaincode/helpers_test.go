@@ -0,0 +1,238 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"chaincode-if/memledger"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// testSalt is the NIK salt passed with the personal data of test entities
+const testSalt = "0123456789abcdef"
+
+// testIndexKey is the identity index key passed with transactions that
+// register, release or resolve a NIK
+const testIndexKey = "test-identity-index-key-0123456789"
+
+// indexKey passes the identity index key as transient data
+var indexKey = memledger.WithTransient(map[string][]byte{indexKeyTransientKey: []byte(testIndexKey)})
+
+// Identities enrolled with the test network
+var (
+	org1Admin       = memledger.MustNewIdentity("Org1MSP", "admin", map[string]string{roleAttribute: RoleAdmin})
+	org2Admin       = memledger.MustNewIdentity("Org2MSP", "admin", map[string]string{roleAttribute: RoleAdmin})
+	farmerUser      = memledger.MustNewIdentity("Org1MSP", "farmer1", map[string]string{roleAttribute: RoleFarmer})
+	otherFarmerUser = memledger.MustNewIdentity("Org1MSP", "farmer2", map[string]string{roleAttribute: RoleFarmer})
+	collectorUser   = memledger.MustNewIdentity("Org1MSP", "collector1", map[string]string{roleAttribute: RoleCollector})
+	processorUser   = memledger.MustNewIdentity("Org1MSP", "processor1", map[string]string{roleAttribute: RoleProcessor})
+	transporterUser = memledger.MustNewIdentity("Org1MSP", "transporter1", map[string]string{roleAttribute: RoleTransporter})
+	noRoleUser      = memledger.MustNewIdentity("Org1MSP", "guest", nil)
+)
+
+// testNetwork is a channel with the palmoil contract deployed
+type testNetwork struct {
+	t        *testing.T
+	ledger   *memledger.Ledger
+	contract *PalmOilContract
+
+	// registered counts the entities added by the helpers, which get distinct
+	// NIKs since a NIK can only be registered once
+	registered int
+}
+
+func newTestNetwork(t *testing.T) *testNetwork {
+	t.Helper()
+	return &testNetwork{t: t, ledger: memledger.New(), contract: &PalmOilContract{}}
+}
+
+// submit runs a transaction and commits it if it succeeds
+func (n *testNetwork) submit(id *memledger.Identity, fn func(ctx contractapi.TransactionContextInterface) error, opts ...memledger.TxOption) error {
+	return n.ledger.Transact(id, fn, opts...)
+}
+
+// mustSubmit runs a transaction and fails the test if it does not succeed
+func (n *testNetwork) mustSubmit(id *memledger.Identity, fn func(ctx contractapi.TransactionContextInterface) error, opts ...memledger.TxOption) {
+	n.t.Helper()
+	err := n.submit(id, fn, opts...)
+	if err != nil {
+		n.t.Fatalf("transaction failed: %v", err)
+	}
+}
+
+// evaluate runs a query without committing it
+func evaluate[T any](n *testNetwork, id *memledger.Identity, fn func(ctx contractapi.TransactionContextInterface) (T, error), opts ...memledger.TxOption) (T, error) {
+	var result T
+	err := n.ledger.Evaluate(id, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		result, err = fn(ctx)
+		return err
+	}, opts...)
+	return result, err
+}
+
+// mustEvaluate runs a query and fails the test if it does not succeed
+func mustEvaluate[T any](n *testNetwork, id *memledger.Identity, fn func(ctx contractapi.TransactionContextInterface) (T, error), opts ...memledger.TxOption) T {
+	n.t.Helper()
+	result, err := evaluate(n, id, fn, opts...)
+	if err != nil {
+		n.t.Fatalf("query failed: %v", err)
+	}
+	return result
+}
+
+// personal passes personal data with a NIK as transient data
+func personal(nik string) memledger.TxOption {
+	personalJSON, _ := json.Marshal(PersonalData{NIK: nik, NoHP: "+6281234567890", Email: "user@example.com", Salt: testSalt})
+	return memledger.WithTransient(map[string][]byte{personalTransientKey: personalJSON, indexKeyTransientKey: []byte(testIndexKey)})
+}
+
+// nextNIK returns a NIK not yet registered by the helpers
+func (n *testNetwork) nextNIK() string {
+	n.registered++
+	return fmt.Sprintf("147101010190%04d", n.registered)
+}
+
+// jsonList encodes string arguments as a JSON array
+func jsonList(values ...string) string {
+	if values == nil {
+		values = []string{}
+	}
+	listJSON, _ := json.Marshal(values)
+	return string(listJSON)
+}
+
+func (n *testNetwork) addFarmer(by *memledger.Identity, id string) {
+	n.t.Helper()
+	n.mustSubmit(by, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.AddFarmer(ctx, id, "Farmer "+id, "Riau", jsonList())
+	}, personal(n.nextNIK()))
+}
+
+func (n *testNetwork) addFarm(by *memledger.Identity, id string, owner string) {
+	n.t.Helper()
+	n.mustSubmit(by, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.AddFarm(ctx, id, owner, 2010, "Tenera", 2.5, "Kampar", "0.5,101.4", 20, "SHM", "RSPO")
+	})
+}
+
+func (n *testNetwork) addCollector(by *memledger.Identity, id string, nib string) {
+	n.t.Helper()
+	n.mustSubmit(by, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.AddCollector(ctx, id, "Collector "+id, nib, "Pekanbaru", 500, jsonList())
+	}, personal(n.nextNIK()))
+}
+
+// addPartnership makes a partnership between a collector enrolled by
+// collectorUser and a farmer, or a farm owned by a farmer, enrolled by
+// farmerUser, starting on the first day of the ledger clock
+func (n *testNetwork) addPartnership(id string, collectorID string, partnerType string, partnerID string) {
+	n.t.Helper()
+	n.mustSubmit(collectorUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.ProposePartnership(ctx, id, collectorID, partnerType, partnerID, "2024-01-01", "")
+	})
+	n.mustSubmit(farmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.AcceptPartnership(ctx, id)
+	})
+}
+
+func (n *testNetwork) addProcessor(by *memledger.Identity, id string, nib string) {
+	n.t.Helper()
+	n.mustSubmit(by, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.AddProcessor(ctx, id, "Mill "+id, nib, "Dumai", 1000)
+	}, personal(n.nextNIK()))
+}
+
+func (n *testNetwork) addTransporter(by *memledger.Identity, id string) {
+	n.t.Helper()
+	n.mustSubmit(by, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.AddTransporter(ctx, id, "Transporter "+id, 3)
+	}, personal(n.nextNIK()))
+}
+
+func (n *testNetwork) harvest(commodityID string, farmID string, quantity float64, dateHarvested string) {
+	n.t.Helper()
+	n.mustSubmit(org1Admin, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.Harvest(ctx, commodityID, farmID, "FFB", quantity, dateHarvested, "TR_"+commodityID, "Budi", "Kampar")
+	})
+}
+
+// deliver moves a harvested commodity to the processor through collector
+// COL_001 and transporter TRP_001
+func (n *testNetwork) deliver(commodityID string) {
+	n.t.Helper()
+	n.mustSubmit(collectorUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.Collect(ctx, commodityID, "COL_001", "Sari", "Pekanbaru")
+	})
+	n.mustSubmit(transporterUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.Transport(ctx, commodityID, "TRP_001", "Agus", "Pekanbaru")
+	})
+	n.mustSubmit(transporterUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.Transported(ctx, commodityID, "TRP_001", "Agus", "Dumai")
+	})
+}
+
+func (n *testNetwork) process(processedID string, quantity float64, materials ...string) {
+	n.t.Helper()
+	n.mustSubmit(processorUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.Process(ctx, processedID, "PRC_001", quantity, jsonList(materials...), "B-"+processedID, "A", "Rina", "Dumai")
+	})
+}
+
+// seedSupplyChain registers farmer FRM_001 with farm FARM_001, enrolled by
+// farmerUser, processor PRC_001, enrolled by processorUser, and collector
+// COL_001, enrolled by collectorUser and partnered with FRM_001, and delivers
+// commodities COM_001 and COM_002 of 100 each to the processor
+func (n *testNetwork) seedSupplyChain() {
+	n.t.Helper()
+	n.addFarmer(farmerUser, "FRM_001")
+	n.addFarm(farmerUser, "FARM_001", "FRM_001")
+	n.addProcessor(processorUser, "PRC_001", "1234567890123")
+	n.addCollector(collectorUser, "COL_001", "1234567890120")
+	n.addPartnership("PRT_001", "COL_001", PartnerFarmer, "FRM_001")
+	n.addTransporter(transporterUser, "TRP_001")
+	n.harvest("COM_001", "FARM_001", 100, "2024-01-10")
+	n.harvest("COM_002", "FARM_001", 100, "2024-01-11")
+	n.deliver("COM_001")
+	n.deliver("COM_002")
+}
+
+// lastEvent returns the chaincode event of the last committed transaction
+func (n *testNetwork) lastEvent() Event {
+	n.t.Helper()
+	events := n.ledger.Events()
+	if len(events) == 0 {
+		n.t.Fatal("no events were emitted")
+	}
+
+	var event Event
+	err := json.Unmarshal(events[len(events)-1].Payload, &event)
+	if err != nil {
+		n.t.Fatalf("failed to unmarshal event: %v", err)
+	}
+	return event
+}
+
+// checkError fails the test unless err contains wantErr, or is nil if wantErr is empty
+func checkError(t *testing.T, err error, wantErr string) {
+	t.Helper()
+	if wantErr == "" {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+	if err == nil || !strings.Contains(err.Error(), wantErr) {
+		t.Fatalf("expected error containing %q, got %v", wantErr, err)
+	}
+}
+
+// isPermissionError reports whether err is a PermissionError
+func isPermissionError(err error) bool {
+	var permissionErr *PermissionError
+	return errors.As(err, &permissionErr)
+}
@@ -0,0 +1,328 @@
+package chaincode
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// FieldChange is the change of one top-level field between two versions of an
+// entity. Old and New hold the JSON encoding of the value and are empty when
+// the field was added or removed.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// HistoryEntry is one version of an entity as recorded on the ledger. Value is
+// the JSON of the entity after the transaction and is empty for a delete.
+type HistoryEntry struct {
+	TxID      string        `json:"txId"`
+	Timestamp string        `json:"timestamp"`
+	IsDelete  bool          `json:"isDelete"`
+	Value     string        `json:"value"`
+	Changes   []FieldChange `json:"changes"`
+}
+
+// historyObjectTypes are the entity types whose history can be queried
+var historyObjectTypes = map[string]bool{
+	farmerObjectType:      true,
+	farmObjectType:        true,
+	collectorObjectType:   true,
+	processorObjectType:   true,
+	transporterObjectType: true,
+	commodityObjectType:   true,
+}
+
+// GetFarmerHistory retrieves every version of a farmer, oldest first
+func (pc *PalmOilContract) GetFarmerHistory(ctx contractapi.TransactionContextInterface, id string) ([]*HistoryEntry, error) {
+	err := authorize(ctx, "GetFarmerHistory")
+	if err != nil {
+		return nil, err
+	}
+
+	return entityHistory(ctx, farmerObjectType, id)
+}
+
+// GetFarmHistory retrieves every version of a farm, oldest first
+func (pc *PalmOilContract) GetFarmHistory(ctx contractapi.TransactionContextInterface, id string) ([]*HistoryEntry, error) {
+	err := authorize(ctx, "GetFarmHistory")
+	if err != nil {
+		return nil, err
+	}
+
+	return entityHistory(ctx, farmObjectType, id)
+}
+
+// GetCollectorHistory retrieves every version of a collector, oldest first
+func (pc *PalmOilContract) GetCollectorHistory(ctx contractapi.TransactionContextInterface, id string) ([]*HistoryEntry, error) {
+	err := authorize(ctx, "GetCollectorHistory")
+	if err != nil {
+		return nil, err
+	}
+
+	return entityHistory(ctx, collectorObjectType, id)
+}
+
+// GetProcessorHistory retrieves every version of a processor, oldest first
+func (pc *PalmOilContract) GetProcessorHistory(ctx contractapi.TransactionContextInterface, id string) ([]*HistoryEntry, error) {
+	err := authorize(ctx, "GetProcessorHistory")
+	if err != nil {
+		return nil, err
+	}
+
+	return entityHistory(ctx, processorObjectType, id)
+}
+
+// GetTransporterHistory retrieves every version of a transporter, oldest first
+func (pc *PalmOilContract) GetTransporterHistory(ctx contractapi.TransactionContextInterface, id string) ([]*HistoryEntry, error) {
+	err := authorize(ctx, "GetTransporterHistory")
+	if err != nil {
+		return nil, err
+	}
+
+	return entityHistory(ctx, transporterObjectType, id)
+}
+
+// GetCommodityHistory retrieves every version of a commodity, oldest first
+func (pc *PalmOilContract) GetCommodityHistory(ctx contractapi.TransactionContextInterface, id string) ([]*HistoryEntry, error) {
+	err := authorize(ctx, "GetCommodityHistory")
+	if err != nil {
+		return nil, err
+	}
+
+	return entityHistory(ctx, commodityObjectType, id)
+}
+
+// GetEntityAsOf rebuilds an entity as it was on the ledger at an RFC 3339
+// timestamp. The returned entry is the latest version written at or before
+// that time; its IsDelete flag is set if the entity had been deleted.
+func (pc *PalmOilContract) GetEntityAsOf(ctx contractapi.TransactionContextInterface, entityType string, id string, timestamp string) (*HistoryEntry, error) {
+	err := authorize(ctx, "GetEntityAsOf")
+	if err != nil {
+		return nil, err
+	}
+
+	if !historyObjectTypes[entityType] {
+		return nil, fmt.Errorf("unknown entity type %q", entityType)
+	}
+
+	asOf, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp %q: %v", timestamp, err)
+	}
+
+	history, err := entityHistory(ctx, entityType, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var found *HistoryEntry
+	for _, entry := range history {
+		written, err := time.Parse(time.RFC3339Nano, entry.Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid history timestamp %q: %v", entry.Timestamp, err)
+		}
+		if written.After(asOf) {
+			break
+		}
+		found = entry
+	}
+	if found == nil {
+		return nil, fmt.Errorf("the %s with ID %s did not exist at %s", entityType, id, timestamp)
+	}
+
+	return found, nil
+}
+
+// historyVersion is one version of a key read from the ledger history
+type historyVersion struct {
+	entry   *HistoryEntry
+	value   []byte
+	written time.Time
+}
+
+// entityHistory reads the ledger history of an entity and diffs each version
+// against the one before it. Entities stored before MigrateEntityKeys moved
+// them to composite keys also have versions under their plain ID, which are
+// merged in.
+func entityHistory(ctx contractapi.TransactionContextInterface, objectType string, id string) ([]*HistoryEntry, error) {
+	key, err := entityKey(ctx, objectType, id)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := keyHistory(ctx, key, objectType, id)
+	if err != nil {
+		return nil, err
+	}
+	legacy, err := legacyHistory(ctx, objectType, id, versions)
+	if err != nil {
+		return nil, err
+	}
+	versions = append(legacy, versions...)
+
+	// The order of the history iterator differs between peer versions
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].written.Before(versions[j].written)
+	})
+
+	history := make([]*HistoryEntry, len(versions))
+	previous := ""
+	for i, v := range versions {
+		if !v.entry.IsDelete {
+			// Versions written before personal data moved to private
+			// collections still hold it
+			v.entry.Value, err = redactPersonalFields(string(v.value))
+			if err != nil {
+				return nil, fmt.Errorf("failed to redact %s %s at %s: %v", objectType, id, v.entry.TxID, err)
+			}
+		}
+		v.entry.Changes, err = diffFields(previous, v.entry.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to diff %s %s at %s: %v", objectType, id, v.entry.TxID, err)
+		}
+		history[i] = v.entry
+		previous = v.entry.Value
+	}
+
+	return history, nil
+}
+
+// legacyHistory reads the versions an entity had under its plain ID. Every
+// entity type shared that key space, so only the versions recognised as the
+// entity's type are kept, along with the deletes that followed them. The
+// delete by which MigrateEntityKeys moved the entity is left out, since the
+// same transaction wrote its first version under the composite key.
+func legacyHistory(ctx contractapi.TransactionContextInterface, objectType string, id string, versions []historyVersion) ([]historyVersion, error) {
+	legacy, err := keyHistory(ctx, id, objectType, id)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(legacy, func(i, j int) bool {
+		return legacy[i].written.Before(legacy[j].written)
+	})
+
+	moved := make(map[string]bool)
+	for _, v := range versions {
+		moved[v.entry.TxID] = true
+	}
+
+	var kept []historyVersion
+	belongs := false
+	for _, v := range legacy {
+		if v.entry.IsDelete {
+			if belongs && !moved[v.entry.TxID] {
+				kept = append(kept, v)
+			}
+			belongs = false
+			continue
+		}
+
+		legacyType, _ := classifyLegacyEntity(id, v.value)
+		belongs = legacyType == objectType
+		if belongs {
+			kept = append(kept, v)
+		}
+	}
+
+	return kept, nil
+}
+
+// keyHistory reads every version of a key from the ledger history
+func keyHistory(ctx contractapi.TransactionContextInterface, key string, objectType string, id string) ([]historyVersion, error) {
+	resultsIterator, err := ctx.GetStub().GetHistoryForKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history of %s %s: %v", objectType, id, err)
+	}
+	defer resultsIterator.Close()
+
+	var versions []historyVersion
+	for resultsIterator.HasNext() {
+		modification, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		written := time.Unix(modification.GetTimestamp().GetSeconds(), int64(modification.GetTimestamp().GetNanos())).UTC()
+		versions = append(versions, historyVersion{
+			entry: &HistoryEntry{
+				TxID:      modification.GetTxId(),
+				Timestamp: written.Format(time.RFC3339Nano),
+				IsDelete:  modification.GetIsDelete(),
+			},
+			value:   modification.GetValue(),
+			written: written,
+		})
+	}
+
+	return versions, nil
+}
+
+// diffFields lists the top-level fields that differ between two JSON objects.
+// An empty string stands for an absent entity. The docType field is ignored.
+func diffFields(before string, after string) ([]FieldChange, error) {
+	oldFields, err := topLevelFields(before)
+	if err != nil {
+		return nil, err
+	}
+	newFields, err := topLevelFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for name := range oldFields {
+		names[name] = true
+	}
+	for name := range newFields {
+		names[name] = true
+	}
+	delete(names, "docType")
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	changes := []FieldChange{}
+	for _, name := range sorted {
+		oldValue, newValue := oldFields[name], newFields[name]
+		if oldValue != newValue {
+			changes = append(changes, FieldChange{Field: name, Old: oldValue, New: newValue})
+		}
+	}
+
+	return changes, nil
+}
+
+// topLevelFields returns the compacted JSON of every top-level field of a JSON object
+func topLevelFields(value string) (map[string]string, error) {
+	fields := make(map[string]string)
+	if value == "" {
+		return fields, nil
+	}
+
+	var raw map[string]json.RawMessage
+	err := json.Unmarshal([]byte(value), &raw)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, fieldJSON := range raw {
+		var compact bytes.Buffer
+		err = json.Compact(&compact, fieldJSON)
+		if err != nil {
+			return nil, err
+		}
+		fields[name] = compact.String()
+	}
+
+	return fields, nil
+}
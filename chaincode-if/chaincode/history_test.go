@@ -0,0 +1,195 @@
+package chaincode
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"chaincode-if/memledger"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestEntityHistory(t *testing.T) {
+	n := newTestNetwork(t)
+	n.seedSupplyChain()
+	n.mustSubmit(farmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.UpdateFarmer(ctx, "FRM_001", "Slamet Riyadi", "Riau", `["FARM_001"]`)
+	})
+
+	tests := []struct {
+		name        string
+		caller      *memledger.Identity
+		query       func(*PalmOilContract, contractapi.TransactionContextInterface, string) ([]*HistoryEntry, error)
+		id          string
+		wantEntries int
+		wantErr     string
+	}{
+		{name: "farmer", caller: collectorUser, query: (*PalmOilContract).GetFarmerHistory, id: "FRM_001", wantEntries: 3},
+		{name: "farm", caller: collectorUser, query: (*PalmOilContract).GetFarmHistory, id: "FARM_001", wantEntries: 1},
+		{name: "collector", caller: collectorUser, query: (*PalmOilContract).GetCollectorHistory, id: "COL_001", wantEntries: 2},
+		{name: "processor", caller: collectorUser, query: (*PalmOilContract).GetProcessorHistory, id: "PRC_001", wantEntries: 1},
+		{name: "transporter", caller: collectorUser, query: (*PalmOilContract).GetTransporterHistory, id: "TRP_001", wantEntries: 1},
+		{name: "commodity", caller: collectorUser, query: (*PalmOilContract).GetCommodityHistory, id: "COM_001", wantEntries: 4},
+		{name: "missing entity", caller: collectorUser, query: (*PalmOilContract).GetFarmerHistory, id: "FRM_404", wantEntries: 0},
+		{name: "empty ID", caller: collectorUser, query: (*PalmOilContract).GetCommodityHistory, id: "", wantErr: "must not be empty"},
+		{name: "no role", caller: noRoleUser, query: (*PalmOilContract).GetFarmerHistory, id: "FRM_001", wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := evaluate(n, tt.caller, func(ctx contractapi.TransactionContextInterface) ([]*HistoryEntry, error) {
+				return tt.query(n.contract, ctx, tt.id)
+			})
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+
+			if len(entries) != tt.wantEntries {
+				t.Fatalf("expected %d entries, got %d", tt.wantEntries, len(entries))
+			}
+			for i, entry := range entries {
+				if entry.TxID == "" || entry.IsDelete || len(entry.Changes) == 0 {
+					t.Errorf("unexpected entry %d: %+v", i, entry)
+				}
+				if i > 0 && entry.Timestamp <= entries[i-1].Timestamp {
+					t.Errorf("entries are not ordered oldest first: %s after %s", entry.Timestamp, entries[i-1].Timestamp)
+				}
+			}
+		})
+	}
+}
+
+func TestFarmerHistoryChanges(t *testing.T) {
+	n := newTestNetwork(t)
+	n.addFarmer(farmerUser, "FRM_001")
+	n.mustSubmit(farmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.UpdateFarmer(ctx, "FRM_001", "Slamet Riyadi", "Riau", `[]`)
+	})
+
+	entries := mustEvaluate(n, farmerUser, func(ctx contractapi.TransactionContextInterface) ([]*HistoryEntry, error) {
+		return n.contract.GetFarmerHistory(ctx, "FRM_001")
+	})
+	changes := entries[1].Changes
+	if len(changes) != 1 || changes[0].Field != "name" || changes[0].Old != `"Farmer FRM_001"` || changes[0].New != `"Slamet Riyadi"` {
+		t.Fatalf("expected only the name to change, got %+v", changes)
+	}
+}
+
+func TestHistoryUnderLegacyKeys(t *testing.T) {
+	n := newTestNetwork(t)
+	n.putRawState("FRM_001", `{"id":"FRM_001","name":"Slamet","nik":"1471010101900001","farm":[]}`)
+	n.putRawState("FRM_001", `{"id":"FRM_001","name":"Slamet Riyadi","nik":"1471010101900001","farm":[]}`)
+	n.putRawState("TRP_001", `{"id":"TRP_001","numShip":2}`)
+	n.mustSubmit(org1Admin, func(ctx contractapi.TransactionContextInterface) error {
+		return ctx.GetStub().DelState("TRP_001")
+	})
+	n.mustSubmit(org1Admin, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := n.contract.MigrateEntityKeys(ctx)
+		return err
+	})
+
+	tests := []struct {
+		name        string
+		query       func(*PalmOilContract, contractapi.TransactionContextInterface, string) ([]*HistoryEntry, error)
+		id          string
+		wantDeletes []bool
+	}{
+		{name: "moved entity", query: (*PalmOilContract).GetFarmerHistory, id: "FRM_001", wantDeletes: []bool{false, false, false}},
+		{name: "deleted entity", query: (*PalmOilContract).GetTransporterHistory, id: "TRP_001", wantDeletes: []bool{false, true}},
+		{name: "other entity type", query: (*PalmOilContract).GetCollectorHistory, id: "FRM_001", wantDeletes: []bool{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries := mustEvaluate(n, collectorUser, func(ctx contractapi.TransactionContextInterface) ([]*HistoryEntry, error) {
+				return tt.query(n.contract, ctx, tt.id)
+			})
+			deletes := []bool{}
+			for _, entry := range entries {
+				deletes = append(deletes, entry.IsDelete)
+				if strings.Contains(entry.Value, "1471010101900001") {
+					t.Errorf("the history still holds the NIK: %s", entry.Value)
+				}
+			}
+			if !reflect.DeepEqual(deletes, tt.wantDeletes) {
+				t.Fatalf("expected versions with deletes %v, got %v", tt.wantDeletes, deletes)
+			}
+		})
+	}
+
+	entries := mustEvaluate(n, collectorUser, func(ctx contractapi.TransactionContextInterface) ([]*HistoryEntry, error) {
+		return n.contract.GetFarmerHistory(ctx, "FRM_001")
+	})
+	changes := entries[1].Changes
+	if len(changes) != 1 || changes[0].Field != "name" || changes[0].New != `"Slamet Riyadi"` {
+		t.Errorf("expected the legacy versions to be diffed, got %+v", changes)
+	}
+	if len(entries[2].Changes) != 0 {
+		t.Errorf("expected the move to change nothing, got %+v", entries[2].Changes)
+	}
+}
+
+func TestHistoryRedactsPersonalData(t *testing.T) {
+	n := newTestNetwork(t)
+	n.mustSubmit(org1Admin, func(ctx contractapi.TransactionContextInterface) error {
+		return putEntityState(ctx, farmerObjectType, "FRM_001", []byte(`{"id":"FRM_001","name":"Slamet","nik":"1471010101900001","noHP":"+6281234567890","email":"slamet@example.com","address":"Riau","farm":[]}`))
+	})
+
+	entries := mustEvaluate(n, farmerUser, func(ctx contractapi.TransactionContextInterface) ([]*HistoryEntry, error) {
+		return n.contract.GetFarmerHistory(ctx, "FRM_001")
+	})
+	if len(entries) != 1 {
+		t.Fatalf("expected one entry, got %d", len(entries))
+	}
+	for _, field := range []string{"1471010101900001", "+6281234567890", "slamet@example.com"} {
+		if strings.Contains(entries[0].Value, field) {
+			t.Errorf("the history still holds %s: %s", field, entries[0].Value)
+		}
+	}
+}
+
+func TestGetEntityAsOf(t *testing.T) {
+	n := newTestNetwork(t)
+	n.ledger.SetClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Hour)
+	n.addFarmer(farmerUser, "FRM_001")
+	n.mustSubmit(farmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.UpdateFarmer(ctx, "FRM_001", "Slamet Riyadi", "Riau", `[]`)
+	})
+
+	tests := []struct {
+		name       string
+		entityType string
+		id         string
+		timestamp  string
+		wantName   string
+		wantErr    string
+	}{
+		{name: "first version", entityType: farmerObjectType, id: "FRM_001", timestamp: "2024-01-01T00:30:00Z", wantName: "Farmer FRM_001"},
+		{name: "at the update", entityType: farmerObjectType, id: "FRM_001", timestamp: "2024-01-01T01:00:00Z", wantName: "Slamet Riyadi"},
+		{name: "after the update", entityType: farmerObjectType, id: "FRM_001", timestamp: "2024-06-01T00:00:00+07:00", wantName: "Slamet Riyadi"},
+		{name: "before registration", entityType: farmerObjectType, id: "FRM_001", timestamp: "2023-12-31T23:59:59Z", wantErr: "did not exist"},
+		{name: "missing entity", entityType: farmerObjectType, id: "FRM_404", timestamp: "2024-06-01T00:00:00Z", wantErr: "did not exist"},
+		{name: "unknown entity type", entityType: "recall", id: "FRM_001", timestamp: "2024-06-01T00:00:00Z", wantErr: "unknown entity type"},
+		{name: "invalid timestamp", entityType: farmerObjectType, id: "FRM_001", timestamp: "2024-06-01", wantErr: "invalid timestamp"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := evaluate(n, processorUser, func(ctx contractapi.TransactionContextInterface) (*HistoryEntry, error) {
+				return n.contract.GetEntityAsOf(ctx, tt.entityType, tt.id, tt.timestamp)
+			})
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr == "" && !strings.Contains(entry.Value, `"name":"`+tt.wantName+`"`) {
+				t.Errorf("expected the version named %s, got %s", tt.wantName, entry.Value)
+			}
+		})
+	}
+
+	_, err := evaluate(n, noRoleUser, func(ctx contractapi.TransactionContextInterface) (*HistoryEntry, error) {
+		return n.contract.GetEntityAsOf(ctx, farmerObjectType, "FRM_001", "2024-06-01T00:00:00Z")
+	})
+	checkError(t, err, "permission denied")
+}
@@ -0,0 +1,206 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// CommodityState is a step in the lifecycle of a harvested commodity
+type CommodityState string
+
+const (
+	StateHarvested   CommodityState = "harvested"
+	StateCollected   CommodityState = "collected"
+	StateInTransport CommodityState = "in transport"
+	StateDelivered   CommodityState = "delivered"
+	StateProcessed   CommodityState = "processed"
+	StateOnHold      CommodityState = "on hold"
+	StateRejected    CommodityState = "rejected"
+	StateRecalled    CommodityState = "recalled"
+)
+
+// commodityTransitions declares, for every state, the states a commodity may
+// move to next. States without an entry are terminal. Leaving StateOnHold is
+// handled by ReleaseCommodity, which returns to the state held from.
+var commodityTransitions = map[CommodityState][]CommodityState{
+	StateHarvested:   {StateCollected, StateOnHold, StateRejected, StateRecalled},
+	StateCollected:   {StateInTransport, StateOnHold, StateRejected, StateRecalled},
+	StateInTransport: {StateDelivered, StateOnHold, StateRejected, StateRecalled},
+	StateDelivered:   {StateProcessed, StateOnHold, StateRejected, StateRecalled},
+	StateProcessed:   {StateRecalled},
+	StateOnHold:      {StateRejected, StateRecalled},
+}
+
+// TransitionError is returned when a transaction would move a commodity
+// through a transition the lifecycle does not allow
+type TransitionError struct {
+	CommodityID string
+	From        CommodityState
+	To          CommodityState
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("commodity %s cannot move from %q to %q", e.CommodityID, e.From, e.To)
+}
+
+// canTransition reports whether the lifecycle allows moving from one state to another
+func canTransition(from CommodityState, to CommodityState) bool {
+	for _, next := range commodityTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// currentState returns the lifecycle state of a commodity. Commodities written
+// before the state was stored fall back to their latest trace event.
+func currentState(commodity *Commodity) CommodityState {
+	if commodity.State != "" {
+		return commodity.State
+	}
+	if n := len(commodity.Traceability.Events); n > 0 {
+		return CommodityState(commodity.Traceability.Events[n-1].Status)
+	}
+	return StateHarvested
+}
+
+// readCommodity fetches a commodity from the ledger
+func readCommodity(ctx contractapi.TransactionContextInterface, commodityID string) (*Commodity, error) {
+	commodityJSON, err := getEntityState(ctx, commodityObjectType, commodityID)
+	if err != nil {
+		return nil, err
+	}
+	if commodityJSON == nil {
+		return nil, fmt.Errorf("the commodity with ID %s does not exist", commodityID)
+	}
+
+	var commodity Commodity
+	err = json.Unmarshal(commodityJSON, &commodity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal commodity JSON: %v", err)
+	}
+
+	return &commodity, nil
+}
+
+// writeCommodity stores a commodity on the ledger
+func writeCommodity(ctx contractapi.TransactionContextInterface, commodity *Commodity) error {
+	commodityJSON, err := json.Marshal(commodity)
+	if err != nil {
+		return fmt.Errorf("failed to marshal commodity: %v", err)
+	}
+
+	return putEntityState(ctx, commodityObjectType, commodity.ID, commodityJSON)
+}
+
+// moveCommodity checks that the commodity may enter the given state and, if so,
+// records the step in its traceability
+func moveCommodity(commodity *Commodity, to CommodityState, event TraceEvent) error {
+	from := currentState(commodity)
+	if !canTransition(from, to) {
+		return &TransitionError{CommodityID: commodity.ID, From: from, To: to}
+	}
+
+	if to == StateOnHold {
+		commodity.HeldFrom = from
+	} else {
+		commodity.HeldFrom = ""
+	}
+	recordStep(commodity, to, event)
+
+	return nil
+}
+
+// recordStep sets the commodity state and appends the event to the traceability
+func recordStep(commodity *Commodity, state CommodityState, event TraceEvent) {
+	event.Status = string(state)
+	commodity.State = state
+	commodity.Traceability.Events = append(commodity.Traceability.Events, event)
+}
+
+// advanceCommodity reads a commodity, moves it to the given state, writes it
+// back and emits the named event
+func advanceCommodity(ctx contractapi.TransactionContextInterface, commodityID string, to CommodityState, event TraceEvent, eventName string) error {
+	commodity, err := readCommodity(ctx, commodityID)
+	if err != nil {
+		return err
+	}
+
+	err = moveCommodity(commodity, to, event)
+	if err != nil {
+		return err
+	}
+
+	err = writeCommodity(ctx, commodity)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, eventName, commodityPayload(commodity))
+}
+
+// HoldCommodity suspends a commodity until it is released or rejected
+func (pc *PalmOilContract) HoldCommodity(ctx contractapi.TransactionContextInterface, commodityID string, pic string, location string) error {
+	err := authorize(ctx, "HoldCommodity")
+	if err != nil {
+		return err
+	}
+
+	event, err := newTraceEvent(ctx, string(StateOnHold), pic, location)
+	if err != nil {
+		return err
+	}
+
+	return advanceCommodity(ctx, commodityID, StateOnHold, event, EventCommodityHeld)
+}
+
+// ReleaseCommodity returns a commodity on hold to the state it was held from
+func (pc *PalmOilContract) ReleaseCommodity(ctx contractapi.TransactionContextInterface, commodityID string, pic string, location string) error {
+	err := authorize(ctx, "ReleaseCommodity")
+	if err != nil {
+		return err
+	}
+
+	commodity, err := readCommodity(ctx, commodityID)
+	if err != nil {
+		return err
+	}
+
+	from := currentState(commodity)
+	if from != StateOnHold || commodity.HeldFrom == "" {
+		return &TransitionError{CommodityID: commodityID, From: from, To: commodity.HeldFrom}
+	}
+
+	event, err := newTraceEvent(ctx, string(commodity.HeldFrom), pic, location)
+	if err != nil {
+		return err
+	}
+
+	recordStep(commodity, commodity.HeldFrom, event)
+	commodity.HeldFrom = ""
+
+	err = writeCommodity(ctx, commodity)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventCommodityReleased, commodityPayload(commodity))
+}
+
+// RejectCommodity permanently removes a commodity from the supply chain
+func (pc *PalmOilContract) RejectCommodity(ctx contractapi.TransactionContextInterface, commodityID string, pic string, location string) error {
+	err := authorize(ctx, "RejectCommodity")
+	if err != nil {
+		return err
+	}
+
+	event, err := newTraceEvent(ctx, string(StateRejected), pic, location)
+	if err != nil {
+		return err
+	}
+
+	return advanceCommodity(ctx, commodityID, StateRejected, event, EventCommodityRejected)
+}
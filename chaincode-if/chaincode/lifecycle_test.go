@@ -0,0 +1,87 @@
+package chaincode
+
+import (
+	"testing"
+
+	"chaincode-if/memledger"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func holdStep(n *testNetwork, ctx contractapi.TransactionContextInterface, commodityID string) error {
+	return n.contract.HoldCommodity(ctx, commodityID, "Sari", "Pekanbaru")
+}
+
+func releaseStep(n *testNetwork, ctx contractapi.TransactionContextInterface, commodityID string) error {
+	return n.contract.ReleaseCommodity(ctx, commodityID, "Sari", "Pekanbaru")
+}
+
+func rejectStep(n *testNetwork, ctx contractapi.TransactionContextInterface, commodityID string) error {
+	return n.contract.RejectCommodity(ctx, commodityID, "Sari", "Pekanbaru")
+}
+
+func TestCommodityLifecycle(t *testing.T) {
+	tests := []struct {
+		name         string
+		caller       *memledger.Identity
+		before       []movement
+		move         movement
+		commodity    string
+		wantState    CommodityState
+		wantHeldFrom CommodityState
+		wantErr      string
+	}{
+		{name: "hold harvested", caller: collectorUser, move: holdStep, commodity: "COM_001", wantState: StateOnHold, wantHeldFrom: StateHarvested},
+		{name: "hold in transport", caller: processorUser, before: []movement{collectStep, transportStep}, move: holdStep, commodity: "COM_001", wantState: StateOnHold, wantHeldFrom: StateInTransport},
+		{name: "release", caller: collectorUser, before: []movement{collectStep, holdStep}, move: releaseStep, commodity: "COM_001", wantState: StateCollected},
+		{name: "continue after release", caller: transporterUser, before: []movement{collectStep, holdStep, releaseStep}, move: transportStep, commodity: "COM_001", wantState: StateInTransport},
+		{name: "reject", caller: processorUser, before: []movement{collectStep}, move: rejectStep, commodity: "COM_001", wantState: StateRejected},
+		{name: "reject on hold", caller: processorUser, before: []movement{holdStep}, move: rejectStep, commodity: "COM_001", wantState: StateRejected},
+		{name: "hold twice", caller: collectorUser, before: []movement{holdStep}, move: holdStep, commodity: "COM_001", wantErr: `cannot move from "on hold" to "on hold"`},
+		{name: "move on hold", caller: collectorUser, before: []movement{holdStep}, move: collectStep, commodity: "COM_001", wantErr: `cannot move from "on hold" to "collected"`},
+		{name: "release not on hold", caller: collectorUser, move: releaseStep, commodity: "COM_001", wantErr: `cannot move from "harvested"`},
+		{name: "collect rejected", caller: collectorUser, before: []movement{rejectStep}, move: collectStep, commodity: "COM_001", wantErr: `cannot move from "rejected" to "collected"`},
+		{name: "hold missing", caller: collectorUser, move: holdStep, commodity: "COM_404", wantErr: "does not exist"},
+		{name: "release missing", caller: collectorUser, move: releaseStep, commodity: "COM_404", wantErr: "does not exist"},
+		{name: "reject missing", caller: collectorUser, move: rejectStep, commodity: "COM_404", wantErr: "does not exist"},
+		{name: "hold by farmer", caller: farmerUser, move: holdStep, commodity: "COM_001", wantErr: "permission denied"},
+		{name: "release by transporter", caller: transporterUser, before: []movement{holdStep}, move: releaseStep, commodity: "COM_001", wantErr: "permission denied"},
+		{name: "reject by farmer", caller: farmerUser, move: rejectStep, commodity: "COM_001", wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(t)
+			n.addFarmer(farmerUser, "FRM_001")
+			n.addFarm(farmerUser, "FARM_001", "FRM_001")
+			n.addCollector(collectorUser, "COL_001", "1234567890123")
+			n.addPartnership("PRT_001", "COL_001", PartnerFarm, "FARM_001")
+			n.addTransporter(transporterUser, "TRP_001")
+			n.harvest("COM_001", "FARM_001", 100, "2024-01-10")
+			for _, step := range tt.before {
+				n.mustSubmit(org1Admin, func(ctx contractapi.TransactionContextInterface) error {
+					return step(n, ctx, "COM_001")
+				})
+			}
+
+			err := n.submit(tt.caller, func(ctx contractapi.TransactionContextInterface) error {
+				return tt.move(n, ctx, tt.commodity)
+			})
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+
+			commodity := mustEvaluate(n, tt.caller, func(ctx contractapi.TransactionContextInterface) (*Commodity, error) {
+				return n.contract.QueryCommodityByID(ctx, tt.commodity)
+			})
+			if commodity.State != tt.wantState || commodity.HeldFrom != tt.wantHeldFrom {
+				t.Errorf("expected state %q held from %q, got %q held from %q", tt.wantState, tt.wantHeldFrom, commodity.State, commodity.HeldFrom)
+			}
+			events := commodity.Traceability.Events
+			if len(events) != len(tt.before)+2 || events[len(events)-1].Status != string(tt.wantState) {
+				t.Errorf("expected %d trace events ending in %q, got %+v", len(tt.before)+2, tt.wantState, events)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package chaincode
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// BatchLineage is the backward lineage of a processed commodity down to the
+// farms and farmers its material was harvested from
+type BatchLineage struct {
+	Batch     ProcessedCommodity `json:"batch"`
+	Processor Processor          `json:"processor"`
+	Materials []CommodityLineage `json:"materials"`
+}
+
+// CommodityLineage links a material commodity to its origin farm and farmer.
+// Farm and Farmer are empty for commodities harvested before origins were recorded.
+type CommodityLineage struct {
+	Commodity Commodity `json:"commodity"`
+	Farm      *Farm     `json:"farm,omitempty" metadata:",optional"`
+	Farmer    *Farmer   `json:"farmer,omitempty" metadata:",optional"`
+}
+
+// TraceProcessedCommodity retrieves the full backward lineage of a processed commodity
+func (pc *PalmOilContract) TraceProcessedCommodity(ctx contractapi.TransactionContextInterface, processedID string) (*BatchLineage, error) {
+	err := authorize(ctx, "TraceProcessedCommodity")
+	if err != nil {
+		return nil, err
+	}
+
+	batch, err := pc.QueryProcessedCommodityByID(ctx, processedID)
+	if err != nil {
+		return nil, err
+	}
+
+	processor, err := pc.QueryProcessorByID(ctx, batch.Processor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve processor of %s: %v", processedID, err)
+	}
+
+	lineage := BatchLineage{
+		Batch:     *batch,
+		Processor: *processor,
+		Materials: []CommodityLineage{},
+	}
+
+	for _, materialID := range batch.Material {
+		commodity, err := readCommodity(ctx, materialID)
+		if err != nil {
+			return nil, err
+		}
+
+		material := CommodityLineage{Commodity: *commodity}
+		if commodity.FarmID != "" {
+			material.Farm, err = pc.QueryFarmByID(ctx, commodity.FarmID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve origin of commodity %s: %v", materialID, err)
+			}
+		}
+		if commodity.FarmerID != "" {
+			material.Farmer, err = pc.QueryFarmerByID(ctx, commodity.FarmerID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve origin of commodity %s: %v", materialID, err)
+			}
+		}
+
+		lineage.Materials = append(lineage.Materials, material)
+	}
+
+	return &lineage, nil
+}
+
+// Reverse indexes from an origin to what was made from it. Entries are
+// composite keys with an empty value, e.g. farm~commodity/<farmID>/<commodityID>.
+const (
+	farmCommodityIndex      = "farm~commodity"
+	commodityProcessedIndex = "commodity~processed"
+)
+
+// Origin types accepted by forward traces and recalls
+const (
+	OriginFarm      = "farm"
+	OriginCommodity = "commodity"
+	OriginBatch     = "batch"
+)
+
+// ForwardTrace lists everything downstream of a farm, commodity or batch
+type ForwardTrace struct {
+	OriginType  string               `json:"originType"`
+	OriginID    string               `json:"originId"`
+	Commodities []Commodity          `json:"commodities"`
+	Batches     []ProcessedCommodity `json:"batches"`
+}
+
+// putIndex records that target was made from source in the given index
+func putIndex(ctx contractapi.TransactionContextInterface, index string, source string, target string) error {
+	indexKey, err := ctx.GetStub().CreateCompositeKey(index, []string{source, target})
+	if err != nil {
+		return fmt.Errorf("failed to create index key: %v", err)
+	}
+
+	return ctx.GetStub().PutState(indexKey, []byte{0x00})
+}
+
+// readIndex returns every target recorded for source in the given index
+func readIndex(ctx contractapi.TransactionContextInterface, index string, source string) ([]string, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(index, []string{source})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	var targets []string
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split index key: %v", err)
+		}
+		if len(attributes) == 2 {
+			targets = append(targets, attributes[1])
+		}
+	}
+
+	return targets, nil
+}
+
+// TraceFarmForward retrieves every commodity harvested from a farm and every
+// processed commodity containing them
+func (pc *PalmOilContract) TraceFarmForward(ctx contractapi.TransactionContextInterface, farmID string) (*ForwardTrace, error) {
+	err := authorize(ctx, "TraceFarmForward")
+	if err != nil {
+		return nil, err
+	}
+
+	return pc.traceForward(ctx, OriginFarm, farmID)
+}
+
+// TraceCommodityForward retrieves every processed commodity containing a commodity
+func (pc *PalmOilContract) TraceCommodityForward(ctx contractapi.TransactionContextInterface, commodityID string) (*ForwardTrace, error) {
+	err := authorize(ctx, "TraceCommodityForward")
+	if err != nil {
+		return nil, err
+	}
+
+	return pc.traceForward(ctx, OriginCommodity, commodityID)
+}
+
+// traceForward follows the reverse indexes from an origin down to the
+// processed commodities made from it
+func (pc *PalmOilContract) traceForward(ctx contractapi.TransactionContextInterface, originType string, originID string) (*ForwardTrace, error) {
+	trace := ForwardTrace{
+		OriginType:  originType,
+		OriginID:    originID,
+		Commodities: []Commodity{},
+		Batches:     []ProcessedCommodity{},
+	}
+
+	var commodityIDs []string
+	switch originType {
+	case OriginFarm:
+		_, err := pc.QueryFarmByID(ctx, originID)
+		if err != nil {
+			return nil, err
+		}
+		commodityIDs, err = readIndex(ctx, farmCommodityIndex, originID)
+		if err != nil {
+			return nil, err
+		}
+	case OriginCommodity:
+		commodityIDs = []string{originID}
+	case OriginBatch:
+		batch, err := pc.QueryProcessedCommodityByID(ctx, originID)
+		if err != nil {
+			return nil, err
+		}
+		trace.Batches = append(trace.Batches, *batch)
+		return &trace, nil
+	default:
+		return nil, fmt.Errorf("unknown origin type %q: expected %s, %s or %s", originType, OriginFarm, OriginCommodity, OriginBatch)
+	}
+
+	seen := make(map[string]bool)
+	for _, commodityID := range commodityIDs {
+		commodity, err := readCommodity(ctx, commodityID)
+		if err != nil {
+			return nil, err
+		}
+		trace.Commodities = append(trace.Commodities, *commodity)
+
+		processedIDs, err := readIndex(ctx, commodityProcessedIndex, commodityID)
+		if err != nil {
+			return nil, err
+		}
+		for _, processedID := range processedIDs {
+			if seen[processedID] {
+				continue
+			}
+			seen[processedID] = true
+
+			batch, err := pc.QueryProcessedCommodityByID(ctx, processedID)
+			if err != nil {
+				return nil, err
+			}
+			trace.Batches = append(trace.Batches, *batch)
+		}
+	}
+
+	return &trace, nil
+}
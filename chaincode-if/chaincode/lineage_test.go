@@ -0,0 +1,135 @@
+package chaincode
+
+import (
+	"testing"
+
+	"chaincode-if/memledger"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestTraceProcessedCommodity(t *testing.T) {
+	n := newTestNetwork(t)
+	n.seedSupplyChain()
+	n.process("PCD_001", 50, "COM_001", "COM_002")
+
+	tests := []struct {
+		name    string
+		caller  *memledger.Identity
+		id      string
+		wantErr string
+	}{
+		{name: "existing", caller: transporterUser, id: "PCD_001"},
+		{name: "missing", caller: transporterUser, id: "PCD_404", wantErr: "does not exist"},
+		{name: "no role", caller: noRoleUser, id: "PCD_001", wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lineage, err := evaluate(n, tt.caller, func(ctx contractapi.TransactionContextInterface) (*BatchLineage, error) {
+				return n.contract.TraceProcessedCommodity(ctx, tt.id)
+			})
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+
+			if lineage.Batch.ID != "PCD_001" || lineage.Processor.ID != "PRC_001" || len(lineage.Materials) != 2 {
+				t.Fatalf("unexpected lineage %+v", lineage)
+			}
+			for _, material := range lineage.Materials {
+				if material.Farm == nil || material.Farm.ID != "FARM_001" || material.Farmer == nil || material.Farmer.ID != "FRM_001" {
+					t.Errorf("material %s has no origin: %+v", material.Commodity.ID, material)
+				}
+			}
+		})
+	}
+}
+
+func TestTraceProcessedCommodityWithoutOrigin(t *testing.T) {
+	n := newTestNetwork(t)
+	n.seedSupplyChain()
+	n.process("PCD_001", 25, "COM_001")
+
+	// Commodities harvested before origins were recorded have no farm
+	n.mustSubmit(org1Admin, func(ctx contractapi.TransactionContextInterface) error {
+		commodity, err := readCommodity(ctx, "COM_001")
+		if err != nil {
+			return err
+		}
+		commodity.FarmID, commodity.FarmerID = "", ""
+		return writeCommodity(ctx, commodity)
+	})
+
+	lineage := mustEvaluate(n, org1Admin, func(ctx contractapi.TransactionContextInterface) (*BatchLineage, error) {
+		return n.contract.TraceProcessedCommodity(ctx, "PCD_001")
+	})
+	if len(lineage.Materials) != 1 || lineage.Materials[0].Farm != nil || lineage.Materials[0].Farmer != nil {
+		t.Fatalf("expected a material without origin, got %+v", lineage.Materials)
+	}
+}
+
+func TestTraceFarmForward(t *testing.T) {
+	n := newTestNetwork(t)
+	n.seedSupplyChain()
+	n.addFarm(farmerUser, "FARM_002", "FRM_001")
+	n.process("PCD_001", 25, "COM_001")
+	n.process("PCD_002", 25, "COM_002")
+
+	tests := []struct {
+		name            string
+		caller          *memledger.Identity
+		farmID          string
+		wantCommodities int
+		wantBatches     int
+		wantErr         string
+	}{
+		{name: "farm with harvests", caller: collectorUser, farmID: "FARM_001", wantCommodities: 2, wantBatches: 2},
+		{name: "farm without harvests", caller: collectorUser, farmID: "FARM_002"},
+		{name: "missing farm", caller: collectorUser, farmID: "FARM_404", wantErr: "does not exist"},
+		{name: "no role", caller: noRoleUser, farmID: "FARM_001", wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trace, err := evaluate(n, tt.caller, func(ctx contractapi.TransactionContextInterface) (*ForwardTrace, error) {
+				return n.contract.TraceFarmForward(ctx, tt.farmID)
+			})
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr == "" && (len(trace.Commodities) != tt.wantCommodities || len(trace.Batches) != tt.wantBatches) {
+				t.Errorf("expected %d commodities and %d batches, got %+v", tt.wantCommodities, tt.wantBatches, trace)
+			}
+		})
+	}
+}
+
+func TestTraceCommodityForward(t *testing.T) {
+	n := newTestNetwork(t)
+	n.seedSupplyChain()
+	n.process("PCD_001", 25, "COM_001")
+
+	tests := []struct {
+		name        string
+		caller      *memledger.Identity
+		commodityID string
+		wantBatches int
+		wantErr     string
+	}{
+		{name: "processed commodity", caller: farmerUser, commodityID: "COM_001", wantBatches: 1},
+		{name: "unprocessed commodity", caller: farmerUser, commodityID: "COM_002"},
+		{name: "missing commodity", caller: farmerUser, commodityID: "COM_404", wantErr: "does not exist"},
+		{name: "no role", caller: noRoleUser, commodityID: "COM_001", wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trace, err := evaluate(n, tt.caller, func(ctx contractapi.TransactionContextInterface) (*ForwardTrace, error) {
+				return n.contract.TraceCommodityForward(ctx, tt.commodityID)
+			})
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr == "" && (len(trace.Commodities) != 1 || len(trace.Batches) != tt.wantBatches) {
+				t.Errorf("expected the commodity and %d batches, got %+v", tt.wantBatches, trace)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// extractionRateKey is the config ID of the extraction rate range. Before
+// entities were stored under composite keys it lived at legacyExtractionRateKey.
+const (
+	extractionRateKey       = "extractionRate"
+	legacyExtractionRateKey = "CFG_EXTRACTION_RATE"
+)
+
+// defaultExtractionRate applies until SetExtractionRateRange is called
+var defaultExtractionRate = ExtractionRateRange{Min: 0.15, Max: 0.30}
+
+// ExtractionRateRange is the accepted ratio between the output quantity of a
+// processed commodity and the FFB weight of its materials
+type ExtractionRateRange struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// MassBalanceError is returned when the output of Process falls outside the
+// configured extraction rate range
+type MassBalanceError struct {
+	ProcessedID string
+	Input       float64
+	Output      float64
+	Range       ExtractionRateRange
+}
+
+func (e *MassBalanceError) Error() string {
+	return fmt.Sprintf("processed commodity %s yields %.4f from %.4f of material, outside the extraction rate range %.4f-%.4f", e.ProcessedID, e.Output, e.Input, e.Range.Min, e.Range.Max)
+}
+
+// MaterialConsumedError is returned when a material has already gone into a
+// processed commodity
+type MaterialConsumedError struct {
+	CommodityID string
+	ProcessedID string
+}
+
+func (e *MaterialConsumedError) Error() string {
+	return fmt.Sprintf("commodity %s has already been consumed by processed commodity %s", e.CommodityID, e.ProcessedID)
+}
+
+// SetExtractionRateRange configures the accepted oil extraction rate range
+func (pc *PalmOilContract) SetExtractionRateRange(ctx contractapi.TransactionContextInterface, min float64, max float64) error {
+	err := authorize(ctx, "SetExtractionRateRange")
+	if err != nil {
+		return err
+	}
+
+	if min <= 0 || max > 1 || min > max {
+		return fmt.Errorf("invalid extraction rate range %.4f-%.4f: expected 0 < min <= max <= 1", min, max)
+	}
+
+	rateRange := ExtractionRateRange{Min: min, Max: max}
+	rangeJSON, err := json.Marshal(rateRange)
+	if err != nil {
+		return err
+	}
+
+	err = putEntityState(ctx, configObjectType, extractionRateKey, rangeJSON)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventExtractionRateSet, rateRange)
+}
+
+// QueryExtractionRateRange retrieves the accepted oil extraction rate range
+func (pc *PalmOilContract) QueryExtractionRateRange(ctx contractapi.TransactionContextInterface) (*ExtractionRateRange, error) {
+	err := authorize(ctx, "QueryExtractionRateRange")
+	if err != nil {
+		return nil, err
+	}
+
+	rangeJSON, err := getEntityState(ctx, configObjectType, extractionRateKey)
+	if err != nil {
+		return nil, err
+	}
+	if rangeJSON == nil {
+		rate := defaultExtractionRate
+		return &rate, nil
+	}
+
+	var rate ExtractionRateRange
+	err = json.Unmarshal(rangeJSON, &rate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal extraction rate range: %v", err)
+	}
+
+	return &rate, nil
+}
+
+// checkMassBalance verifies that the output quantity is within the extraction
+// rate range for the given material input
+func (pc *PalmOilContract) checkMassBalance(ctx contractapi.TransactionContextInterface, processedID string, input float64, output float64) error {
+	rate, err := pc.QueryExtractionRateRange(ctx)
+	if err != nil {
+		return err
+	}
+
+	if input <= 0 || output < input*rate.Min || output > input*rate.Max {
+		return &MassBalanceError{ProcessedID: processedID, Input: input, Output: output, Range: *rate}
+	}
+
+	return nil
+}
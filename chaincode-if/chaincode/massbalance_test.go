@@ -0,0 +1,104 @@
+package chaincode
+
+import (
+	"errors"
+	"testing"
+
+	"chaincode-if/memledger"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestSetExtractionRateRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		caller  *memledger.Identity
+		min     float64
+		max     float64
+		wantErr string
+	}{
+		{name: "valid", caller: org1Admin, min: 0.2, max: 0.25},
+		{name: "single rate", caller: org1Admin, min: 0.22, max: 0.22},
+		{name: "zero min", caller: org1Admin, min: 0, max: 0.25, wantErr: "invalid extraction rate range"},
+		{name: "min above max", caller: org1Admin, min: 0.3, max: 0.2, wantErr: "invalid extraction rate range"},
+		{name: "max above one", caller: org1Admin, min: 0.2, max: 1.5, wantErr: "invalid extraction rate range"},
+		{name: "processor role", caller: processorUser, min: 0.2, max: 0.25, wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(t)
+
+			err := n.submit(tt.caller, func(ctx contractapi.TransactionContextInterface) error {
+				return n.contract.SetExtractionRateRange(ctx, tt.min, tt.max)
+			})
+			checkError(t, err, tt.wantErr)
+
+			want := defaultExtractionRate
+			if tt.wantErr == "" {
+				want = ExtractionRateRange{Min: tt.min, Max: tt.max}
+			}
+			rate := mustEvaluate(n, processorUser, n.contract.QueryExtractionRateRange)
+			if *rate != want {
+				t.Errorf("expected %+v, got %+v", want, *rate)
+			}
+		})
+	}
+}
+
+func TestQueryExtractionRateRange(t *testing.T) {
+	n := newTestNetwork(t)
+
+	rate := mustEvaluate(n, farmerUser, n.contract.QueryExtractionRateRange)
+	if *rate != defaultExtractionRate {
+		t.Fatalf("expected the default range %+v, got %+v", defaultExtractionRate, *rate)
+	}
+
+	_, err := evaluate(n, noRoleUser, n.contract.QueryExtractionRateRange)
+	checkError(t, err, "permission denied")
+}
+
+func TestMassBalance(t *testing.T) {
+	tests := []struct {
+		name     string
+		min      float64
+		max      float64
+		quantity float64
+		wantErr  bool
+	}{
+		{name: "within default range", quantity: 40},
+		{name: "at default minimum", quantity: 30},
+		{name: "at default maximum", quantity: 60},
+		{name: "below default range", quantity: 29, wantErr: true},
+		{name: "above default range", quantity: 61, wantErr: true},
+		{name: "within configured range", min: 0.1, max: 0.12, quantity: 22},
+		{name: "outside configured range", min: 0.1, max: 0.12, quantity: 40, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(t)
+			n.seedSupplyChain()
+			if tt.min != 0 {
+				n.mustSubmit(org1Admin, func(ctx contractapi.TransactionContextInterface) error {
+					return n.contract.SetExtractionRateRange(ctx, tt.min, tt.max)
+				})
+			}
+
+			err := n.submit(processorUser, func(ctx contractapi.TransactionContextInterface) error {
+				return n.contract.Process(ctx, "PCD_001", "PRC_001", tt.quantity, `["COM_001","COM_002"]`, "B-001", "A", "Rina", "Dumai")
+			})
+
+			var massBalanceErr *MassBalanceError
+			if tt.wantErr != errors.As(err, &massBalanceErr) {
+				t.Fatalf("expected a mass balance error: %v, got %v", tt.wantErr, err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr && (massBalanceErr.Input != 200 || massBalanceErr.Output != tt.quantity) {
+				t.Errorf("unexpected mass balance error %+v", massBalanceErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// legacyTraceability is the traceability format written before trace events,
+// with status, location and PIC kept as parallel arrays
+type legacyTraceability struct {
+	ID       string       `json:"id"`
+	Events   []TraceEvent `json:"events"`
+	Status   []string     `json:"status"`
+	Location []string     `json:"location"`
+	PIC      []string     `json:"pic"`
+}
+
+// UnmarshalJSON reads both the current and the legacy traceability format, so
+// commodities that have not been migrated yet keep their history
+func (t *Traceability) UnmarshalJSON(data []byte) error {
+	var legacy legacyTraceability
+	err := json.Unmarshal(data, &legacy)
+	if err != nil {
+		return err
+	}
+
+	t.ID = legacy.ID
+	t.Events = legacy.Events
+	if len(t.Events) == 0 && len(legacy.Status) > 0 {
+		t.Events = legacyEvents(legacy)
+	}
+
+	return nil
+}
+
+// legacyEvents converts parallel status, location and PIC arrays into trace
+// events. The original transactions are unknown, so the events carry no
+// timestamp or transaction ID and are flagged as migrated.
+func legacyEvents(legacy legacyTraceability) []TraceEvent {
+	events := make([]TraceEvent, len(legacy.Status))
+	for i, status := range legacy.Status {
+		events[i] = TraceEvent{Status: status, Migrated: true}
+		if i < len(legacy.Location) {
+			events[i].Location = legacy.Location[i]
+		}
+		if i < len(legacy.PIC) {
+			events[i].Actor = legacy.PIC[i]
+		}
+	}
+	return events
+}
+
+// MigrateTraceability rewrites every commodity still stored in the legacy
+// traceability format using trace events and returns how many were migrated
+func (pc *PalmOilContract) MigrateTraceability(ctx contractapi.TransactionContextInterface) (int, error) {
+	err := authorize(ctx, "MigrateTraceability")
+	if err != nil {
+		return 0, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(commodityObjectType, []string{})
+	if err != nil {
+		return 0, err
+	}
+	defer resultsIterator.Close()
+
+	migrated := 0
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return migrated, err
+		}
+
+		var stored struct {
+			Traceability *legacyTraceability `json:"traceability"`
+		}
+		if json.Unmarshal(queryResponse.Value, &stored) != nil || stored.Traceability == nil {
+			continue
+		}
+		if len(stored.Traceability.Status) == 0 {
+			continue
+		}
+
+		var commodity Commodity
+		err = json.Unmarshal(queryResponse.Value, &commodity)
+		if err != nil {
+			return migrated, fmt.Errorf("failed to unmarshal commodity %s: %v", queryResponse.Key, err)
+		}
+		if commodity.State == "" {
+			commodity.State = currentState(&commodity)
+		}
+
+		commodityJSON, err := json.Marshal(commodity)
+		if err != nil {
+			return migrated, fmt.Errorf("failed to marshal commodity: %v", err)
+		}
+		commodityJSON, err = withDocType(commodityObjectType, commodityJSON)
+		if err != nil {
+			return migrated, err
+		}
+		err = ctx.GetStub().PutState(queryResponse.Key, commodityJSON)
+		if err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+
+	err = emitEvent(ctx, EventTraceabilityMigrated, MigrationPayload{Migrated: migrated})
+	if err != nil {
+		return migrated, err
+	}
+
+	return migrated, nil
+}
+
+// KeyMigration reports the outcome of MigrateEntityKeys
+type KeyMigration struct {
+	Migrated int      `json:"migrated"`
+	Skipped  []string `json:"skipped"`
+}
+
+// MigrateEntityKeys moves every entity stored under a plain ID to the
+// composite key of its entity type. The type is recognised from the fields of
+// the stored JSON; keys that cannot be recognised are left in place and reported.
+func (pc *PalmOilContract) MigrateEntityKeys(ctx contractapi.TransactionContextInterface) (*KeyMigration, error) {
+	err := authorize(ctx, "MigrateEntityKeys")
+	if err != nil {
+		return nil, err
+	}
+
+	// An empty range covers every simple key but never a composite one
+	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	migration := KeyMigration{Skipped: []string{}}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		objectType, id := classifyLegacyEntity(queryResponse.Key, queryResponse.Value)
+		if objectType == "" {
+			migration.Skipped = append(migration.Skipped, queryResponse.Key)
+			continue
+		}
+
+		existingJSON, err := getEntityState(ctx, objectType, id)
+		if err != nil {
+			return nil, err
+		}
+		if existingJSON != nil {
+			return nil, fmt.Errorf("cannot migrate %s: a %s with ID %s already exists", queryResponse.Key, objectType, id)
+		}
+
+		err = putEntityState(ctx, objectType, id, queryResponse.Value)
+		if err != nil {
+			return nil, err
+		}
+		err = ctx.GetStub().DelState(queryResponse.Key)
+		if err != nil {
+			return nil, err
+		}
+		migration.Migrated++
+	}
+
+	err = emitEvent(ctx, EventEntityKeysMigrated, MigrationPayload{Migrated: migration.Migrated, Skipped: len(migration.Skipped)})
+	if err != nil {
+		return nil, err
+	}
+
+	return &migration, nil
+}
+
+// classifyLegacyEntity recognises the entity type of a value stored under a
+// plain key from the fields only that type has. It returns an empty object
+// type if the value is not a known entity.
+func classifyLegacyEntity(key string, value []byte) (string, string) {
+	if key == legacyExtractionRateKey {
+		return configObjectType, extractionRateKey
+	}
+
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(value, &fields) != nil {
+		return "", ""
+	}
+	has := func(name string) bool {
+		_, ok := fields[name]
+		return ok
+	}
+
+	switch {
+	case has("traceability"):
+		return commodityObjectType, key
+	case has("material"):
+		return processedObjectType, key
+	case has("originType") && has("reason"):
+		return recallObjectType, key
+	case has("plantedYear"):
+		return farmObjectType, key
+	case has("numShip"):
+		return transporterObjectType, key
+	case has("partner"):
+		return collectorObjectType, key
+	case has("nib"):
+		return processorObjectType, key
+	case has("farm") && has("nik"):
+		return farmerObjectType, key
+	}
+
+	return "", ""
+}
@@ -0,0 +1,184 @@
+package chaincode
+
+import (
+	"reflect"
+	"testing"
+
+	"chaincode-if/memledger"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// putRawState stores a value under a key as is, the way earlier versions of
+// the chaincode did
+func (n *testNetwork) putRawState(key string, value string) {
+	n.t.Helper()
+	n.mustSubmit(org1Admin, func(ctx contractapi.TransactionContextInterface) error {
+		return ctx.GetStub().PutState(key, []byte(value))
+	})
+}
+
+// putRawEntity stores a value under the composite key of an entity without
+// adding a docType
+func (n *testNetwork) putRawEntity(objectType string, id string, value string) {
+	n.t.Helper()
+	n.mustSubmit(org1Admin, func(ctx contractapi.TransactionContextInterface) error {
+		key, err := entityKey(ctx, objectType, id)
+		if err != nil {
+			return err
+		}
+		return ctx.GetStub().PutState(key, []byte(value))
+	})
+}
+
+func TestMigrateTraceability(t *testing.T) {
+	tests := []struct {
+		name         string
+		caller       *memledger.Identity
+		wantMigrated int
+		wantErr      string
+	}{
+		{name: "admin", caller: org1Admin, wantMigrated: 2},
+		{name: "collector role", caller: collectorUser, wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(t)
+			n.putRawEntity(commodityObjectType, "COM_001", `{"id":"COM_001","name":"FFB","quantity":100,"traceability":{"id":"TR_001","status":["harvested","collected"],"location":["Kampar","Pekanbaru"],"pic":["Budi","Sari"]}}`)
+			n.putRawEntity(commodityObjectType, "COM_002", `{"id":"COM_002","name":"FFB","quantity":100,"traceability":{"id":"TR_002","status":["harvested"],"location":["Kampar"],"pic":["Budi"]}}`)
+			n.addFarmer(farmerUser, "FRM_001")
+			n.addFarm(farmerUser, "FARM_001", "FRM_001")
+			n.harvest("COM_003", "FARM_001", 100, "2024-01-10")
+
+			var migrated int
+			err := n.submit(tt.caller, func(ctx contractapi.TransactionContextInterface) error {
+				var err error
+				migrated, err = n.contract.MigrateTraceability(ctx)
+				return err
+			})
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+			if migrated != tt.wantMigrated {
+				t.Fatalf("expected %d migrated commodities, got %d", tt.wantMigrated, migrated)
+			}
+
+			commodity := mustEvaluate(n, org1Admin, func(ctx contractapi.TransactionContextInterface) (*Commodity, error) {
+				return n.contract.QueryCommodityByID(ctx, "COM_001")
+			})
+			want := []TraceEvent{
+				{Status: "harvested", Location: "Kampar", Actor: "Budi", Migrated: true},
+				{Status: "collected", Location: "Pekanbaru", Actor: "Sari", Migrated: true},
+			}
+			if commodity.State != StateCollected || !reflect.DeepEqual(commodity.Traceability.Events, want) {
+				t.Fatalf("unexpected migrated commodity %+v", commodity)
+			}
+
+			// A second run finds nothing left to migrate
+			n.mustSubmit(org1Admin, func(ctx contractapi.TransactionContextInterface) error {
+				migrated, err = n.contract.MigrateTraceability(ctx)
+				return err
+			})
+			if migrated != 0 {
+				t.Fatalf("expected nothing to migrate on the second run, got %d", migrated)
+			}
+		})
+	}
+}
+
+func TestLegacyTraceabilityIsReadable(t *testing.T) {
+	n := newTestNetwork(t)
+	n.addTransporter(transporterUser, "TRP_001")
+	n.putRawEntity(commodityObjectType, "COM_001", `{"id":"COM_001","traceability":{"id":"TR_001","status":["harvested","collected"],"location":["Kampar"],"pic":[]}}`)
+
+	// Unmigrated commodities take their state from the last trace event
+	n.mustSubmit(transporterUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.Transport(ctx, "COM_001", "TRP_001", "Agus", "Pekanbaru")
+	})
+
+	commodity := mustEvaluate(n, org1Admin, func(ctx contractapi.TransactionContextInterface) (*Commodity, error) {
+		return n.contract.QueryCommodityByID(ctx, "COM_001")
+	})
+	events := commodity.Traceability.Events
+	if commodity.State != StateInTransport || len(events) != 3 || events[1].Location != "" || events[2].Migrated {
+		t.Fatalf("unexpected commodity %+v", commodity)
+	}
+}
+
+func TestMigrateEntityKeys(t *testing.T) {
+	tests := []struct {
+		name         string
+		caller       *memledger.Identity
+		conflict     bool
+		wantMigrated int
+		wantSkipped  []string
+		wantErr      string
+	}{
+		{name: "admin", caller: org1Admin, wantMigrated: 9, wantSkipped: []string{"UNKNOWN_001"}},
+		{name: "conflicting entity", caller: org1Admin, conflict: true, wantErr: "a farm with ID FARM_001 already exists"},
+		{name: "farmer role", caller: farmerUser, wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(t)
+			n.putRawState("FRM_001", `{"id":"FRM_001","name":"Slamet","nik":"1471010101900001","farm":["FARM_001"]}`)
+			n.putRawState("FARM_001", `{"id":"FARM_001","owner":"FRM_001","plantedYear":2010}`)
+			n.putRawState("COL_001", `{"id":"COL_001","nib":"1234567890123","partner":[]}`)
+			n.putRawState("PRC_001", `{"id":"PRC_001","nib":"1234567890124"}`)
+			n.putRawState("TRP_001", `{"id":"TRP_001","numShip":2}`)
+			n.putRawState("COM_001", `{"id":"COM_001","traceability":{"id":"TR_001","events":[]}}`)
+			n.putRawState("PCD_001", `{"id":"PCD_001","material":["COM_001"]}`)
+			n.putRawState("RCL_001", `{"id":"RCL_001","originType":"farm","reason":"illegal clearing"}`)
+			n.putRawState(legacyExtractionRateKey, `{"min":0.2,"max":0.25}`)
+			n.putRawState("UNKNOWN_001", `{"id":"UNKNOWN_001"}`)
+			if tt.conflict {
+				n.putRawEntity(farmObjectType, "FARM_001", `{"id":"FARM_001"}`)
+			}
+
+			var result *KeyMigration
+			err := n.submit(tt.caller, func(ctx contractapi.TransactionContextInterface) error {
+				var err error
+				result, err = n.contract.MigrateEntityKeys(ctx)
+				return err
+			})
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+
+			if result.Migrated != tt.wantMigrated || !reflect.DeepEqual(result.Skipped, tt.wantSkipped) {
+				t.Fatalf("expected %d migrated and %v skipped, got %+v", tt.wantMigrated, tt.wantSkipped, result)
+			}
+
+			simpleKeys := []string{}
+			for _, kv := range n.ledger.WorldState() {
+				if kv.Key[0] != 0 {
+					simpleKeys = append(simpleKeys, kv.Key)
+				}
+			}
+			if !reflect.DeepEqual(simpleKeys, tt.wantSkipped) {
+				t.Errorf("expected only %v under simple keys, got %v", tt.wantSkipped, simpleKeys)
+			}
+
+			farm := mustEvaluate(n, org1Admin, func(ctx contractapi.TransactionContextInterface) (*Farm, error) {
+				return n.contract.QueryFarmByID(ctx, "FARM_001")
+			})
+			if farm.Owner != "FRM_001" || farm.PlantedYear != 2010 {
+				t.Errorf("unexpected migrated farm %+v", farm)
+			}
+			rate := mustEvaluate(n, org1Admin, n.contract.QueryExtractionRateRange)
+			if *rate != (ExtractionRateRange{Min: 0.2, Max: 0.25}) {
+				t.Errorf("the extraction rate range was not migrated: %+v", rate)
+			}
+			recall := mustEvaluate(n, org1Admin, func(ctx contractapi.TransactionContextInterface) (*Recall, error) {
+				return n.contract.QueryRecallByID(ctx, "RCL_001")
+			})
+			if recall.Reason != "illegal clearing" {
+				t.Errorf("unexpected migrated recall %+v", recall)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Enrollment identifies the certificate identity that registered an entity
+type Enrollment struct {
+	ClientID string `json:"clientId"`
+	MSPID    string `json:"mspId"`
+}
+
+// callerEnrollment returns the enrollment of the calling identity
+func callerEnrollment(ctx contractapi.TransactionContextInterface) (*Enrollment, error) {
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client ID: %v", err)
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client MSP ID: %v", err)
+	}
+
+	return &Enrollment{ClientID: clientID, MSPID: mspID}, nil
+}
+
+// authorizeOwner checks that the caller may change an entity registered by
+// the given enrollment: either the caller is that identity, or it is an admin
+// of the same MSP. Entities registered before enrollments were recorded can
+// only be changed by admins.
+func authorizeOwner(ctx contractapi.TransactionContextInterface, function string, enrolledBy *Enrollment) error {
+	caller, err := callerEnrollment(ctx)
+	if err != nil {
+		return err
+	}
+
+	if enrolledBy != nil && *caller == *enrolledBy {
+		return nil
+	}
+
+	_, role, err := callerRole(ctx)
+	if err != nil {
+		return err
+	}
+	if role == RoleAdmin && (enrolledBy == nil || caller.MSPID == enrolledBy.MSPID) {
+		return nil
+	}
+
+	return &PermissionError{Function: function, Role: role, MSPID: caller.MSPID, Reason: "only the enrolling identity or an admin of its MSP may change this entity"}
+}
+
+// authorizeFarmOwner checks that the caller may act on behalf of the farmer
+// owning a farm
+func authorizeFarmOwner(ctx contractapi.TransactionContextInterface, function string, farmerID string) error {
+	farmerJSON, err := getEntityState(ctx, farmerObjectType, farmerID)
+	if err != nil {
+		return err
+	}
+	if farmerJSON == nil {
+		return fmt.Errorf("the farmer with ID %s does not exist", farmerID)
+	}
+
+	var farmer Farmer
+	err = json.Unmarshal(farmerJSON, &farmer)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal farmer JSON: %v", err)
+	}
+
+	return authorizeOwner(ctx, function, farmer.EnrolledBy)
+}
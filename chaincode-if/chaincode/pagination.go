@@ -0,0 +1,190 @@
+package chaincode
+
+import (
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// The page types below are the response envelope of the paginated queries.
+// Bookmark is passed back to fetch the next page and is empty after the last one.
+
+// FarmerPage is one page of farmers
+type FarmerPage struct {
+	Records             []*Farmer `json:"records"`
+	FetchedRecordsCount int32     `json:"fetchedRecordsCount"`
+	Bookmark            string    `json:"bookmark"`
+}
+
+// FarmPage is one page of farms
+type FarmPage struct {
+	Records             []*Farm `json:"records"`
+	FetchedRecordsCount int32   `json:"fetchedRecordsCount"`
+	Bookmark            string  `json:"bookmark"`
+}
+
+// CollectorPage is one page of collectors
+type CollectorPage struct {
+	Records             []*Collector `json:"records"`
+	FetchedRecordsCount int32        `json:"fetchedRecordsCount"`
+	Bookmark            string       `json:"bookmark"`
+}
+
+// ProcessorPage is one page of processors
+type ProcessorPage struct {
+	Records             []*Processor `json:"records"`
+	FetchedRecordsCount int32        `json:"fetchedRecordsCount"`
+	Bookmark            string       `json:"bookmark"`
+}
+
+// TransporterPage is one page of transporters
+type TransporterPage struct {
+	Records             []*Transporter `json:"records"`
+	FetchedRecordsCount int32          `json:"fetchedRecordsCount"`
+	Bookmark            string         `json:"bookmark"`
+}
+
+// CommodityPage is one page of commodities
+type CommodityPage struct {
+	Records             []*Commodity `json:"records"`
+	FetchedRecordsCount int32        `json:"fetchedRecordsCount"`
+	Bookmark            string       `json:"bookmark"`
+}
+
+// ProcessedCommodityPage is one page of processed commodities
+type ProcessedCommodityPage struct {
+	Records             []*ProcessedCommodity `json:"records"`
+	FetchedRecordsCount int32                 `json:"fetchedRecordsCount"`
+	Bookmark            string                `json:"bookmark"`
+}
+
+// QueryFarmersWithPagination retrieves one page of farmers from the ledger
+func (pc *PalmOilContract) QueryFarmersWithPagination(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*FarmerPage, error) {
+	err := authorize(ctx, "QueryFarmersWithPagination")
+	if err != nil {
+		return nil, err
+	}
+
+	records, metadata, err := queryEntityPage[Farmer](ctx, farmerObjectType, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FarmerPage{
+		Records:             records,
+		FetchedRecordsCount: metadata.FetchedRecordsCount,
+		Bookmark:            metadata.Bookmark,
+	}, nil
+}
+
+// QueryFarmsWithPagination retrieves one page of farms from the ledger
+func (pc *PalmOilContract) QueryFarmsWithPagination(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*FarmPage, error) {
+	err := authorize(ctx, "QueryFarmsWithPagination")
+	if err != nil {
+		return nil, err
+	}
+
+	records, metadata, err := queryEntityPage[Farm](ctx, farmObjectType, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FarmPage{
+		Records:             records,
+		FetchedRecordsCount: metadata.FetchedRecordsCount,
+		Bookmark:            metadata.Bookmark,
+	}, nil
+}
+
+// QueryCollectorsWithPagination retrieves one page of collectors from the ledger
+func (pc *PalmOilContract) QueryCollectorsWithPagination(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*CollectorPage, error) {
+	err := authorize(ctx, "QueryCollectorsWithPagination")
+	if err != nil {
+		return nil, err
+	}
+
+	records, metadata, err := queryEntityPage[Collector](ctx, collectorObjectType, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CollectorPage{
+		Records:             records,
+		FetchedRecordsCount: metadata.FetchedRecordsCount,
+		Bookmark:            metadata.Bookmark,
+	}, nil
+}
+
+// QueryProcessorsWithPagination retrieves one page of processors from the ledger
+func (pc *PalmOilContract) QueryProcessorsWithPagination(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*ProcessorPage, error) {
+	err := authorize(ctx, "QueryProcessorsWithPagination")
+	if err != nil {
+		return nil, err
+	}
+
+	records, metadata, err := queryEntityPage[Processor](ctx, processorObjectType, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProcessorPage{
+		Records:             records,
+		FetchedRecordsCount: metadata.FetchedRecordsCount,
+		Bookmark:            metadata.Bookmark,
+	}, nil
+}
+
+// QueryTransportersWithPagination retrieves one page of transporters from the ledger
+func (pc *PalmOilContract) QueryTransportersWithPagination(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*TransporterPage, error) {
+	err := authorize(ctx, "QueryTransportersWithPagination")
+	if err != nil {
+		return nil, err
+	}
+
+	records, metadata, err := queryEntityPage[Transporter](ctx, transporterObjectType, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TransporterPage{
+		Records:             records,
+		FetchedRecordsCount: metadata.FetchedRecordsCount,
+		Bookmark:            metadata.Bookmark,
+	}, nil
+}
+
+// QueryCommoditiesWithPagination retrieves one page of commodities from the ledger
+func (pc *PalmOilContract) QueryCommoditiesWithPagination(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*CommodityPage, error) {
+	err := authorize(ctx, "QueryCommoditiesWithPagination")
+	if err != nil {
+		return nil, err
+	}
+
+	records, metadata, err := queryEntityPage[Commodity](ctx, commodityObjectType, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CommodityPage{
+		Records:             records,
+		FetchedRecordsCount: metadata.FetchedRecordsCount,
+		Bookmark:            metadata.Bookmark,
+	}, nil
+}
+
+// QueryProcessedCommoditiesWithPagination retrieves one page of processed commodities from the ledger
+func (pc *PalmOilContract) QueryProcessedCommoditiesWithPagination(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*ProcessedCommodityPage, error) {
+	err := authorize(ctx, "QueryProcessedCommoditiesWithPagination")
+	if err != nil {
+		return nil, err
+	}
+
+	records, metadata, err := queryEntityPage[ProcessedCommodity](ctx, processedObjectType, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProcessedCommodityPage{
+		Records:             records,
+		FetchedRecordsCount: metadata.FetchedRecordsCount,
+		Bookmark:            metadata.Bookmark,
+	}, nil
+}
@@ -0,0 +1,189 @@
+package chaincode
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// pageQuery runs a paginated query and returns the IDs on the page and the
+// bookmark of the next page
+type pageQuery func(n *testNetwork, ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) ([]string, string, error)
+
+func pageIDs[T any](records []*T, id func(*T) string) []string {
+	ids := make([]string, len(records))
+	for i, record := range records {
+		ids[i] = id(record)
+	}
+	return ids
+}
+
+func TestPagination(t *testing.T) {
+	n := newTestNetwork(t)
+	n.seedSupplyChain()
+	n.addFarmer(otherFarmerUser, "FRM_002")
+	n.addFarmer(otherFarmerUser, "FRM_003")
+	n.addFarm(farmerUser, "FARM_002", "FRM_001")
+	n.addFarm(farmerUser, "FARM_003", "FRM_001")
+	n.addCollector(collectorUser, "COL_002", "1234567890122")
+	n.addCollector(collectorUser, "COL_003", "1234567890126")
+	n.addProcessor(processorUser, "PRC_002", "1234567890124")
+	n.addProcessor(processorUser, "PRC_003", "1234567890125")
+	n.addTransporter(transporterUser, "TRP_002")
+	n.addTransporter(transporterUser, "TRP_003")
+	n.harvest("COM_003", "FARM_001", 100, "2024-01-12")
+	n.harvest("COM_004", "FARM_001", 100, "2024-01-12")
+	n.deliver("COM_003")
+	n.process("PCD_001", 25, "COM_001")
+	n.process("PCD_002", 25, "COM_002")
+	n.process("PCD_003", 25, "COM_003")
+
+	tests := []struct {
+		name  string
+		query pageQuery
+		want  []string
+	}{
+		{
+			name: "farmers",
+			query: func(n *testNetwork, ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) ([]string, string, error) {
+				page, err := n.contract.QueryFarmersWithPagination(ctx, pageSize, bookmark)
+				if err != nil {
+					return nil, "", err
+				}
+				return pageIDs(page.Records, func(f *Farmer) string { return f.ID }), page.Bookmark, nil
+			},
+			want: []string{"FRM_001", "FRM_002", "FRM_003"},
+		},
+		{
+			name: "farms",
+			query: func(n *testNetwork, ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) ([]string, string, error) {
+				page, err := n.contract.QueryFarmsWithPagination(ctx, pageSize, bookmark)
+				if err != nil {
+					return nil, "", err
+				}
+				return pageIDs(page.Records, func(f *Farm) string { return f.ID }), page.Bookmark, nil
+			},
+			want: []string{"FARM_001", "FARM_002", "FARM_003"},
+		},
+		{
+			name: "collectors",
+			query: func(n *testNetwork, ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) ([]string, string, error) {
+				page, err := n.contract.QueryCollectorsWithPagination(ctx, pageSize, bookmark)
+				if err != nil {
+					return nil, "", err
+				}
+				return pageIDs(page.Records, func(c *Collector) string { return c.ID }), page.Bookmark, nil
+			},
+			want: []string{"COL_001", "COL_002", "COL_003"},
+		},
+		{
+			name: "processors",
+			query: func(n *testNetwork, ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) ([]string, string, error) {
+				page, err := n.contract.QueryProcessorsWithPagination(ctx, pageSize, bookmark)
+				if err != nil {
+					return nil, "", err
+				}
+				return pageIDs(page.Records, func(p *Processor) string { return p.ID }), page.Bookmark, nil
+			},
+			want: []string{"PRC_001", "PRC_002", "PRC_003"},
+		},
+		{
+			name: "transporters",
+			query: func(n *testNetwork, ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) ([]string, string, error) {
+				page, err := n.contract.QueryTransportersWithPagination(ctx, pageSize, bookmark)
+				if err != nil {
+					return nil, "", err
+				}
+				return pageIDs(page.Records, func(tr *Transporter) string { return tr.ID }), page.Bookmark, nil
+			},
+			want: []string{"TRP_001", "TRP_002", "TRP_003"},
+		},
+		{
+			name: "commodities",
+			query: func(n *testNetwork, ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) ([]string, string, error) {
+				page, err := n.contract.QueryCommoditiesWithPagination(ctx, pageSize, bookmark)
+				if err != nil {
+					return nil, "", err
+				}
+				return pageIDs(page.Records, func(c *Commodity) string { return c.ID }), page.Bookmark, nil
+			},
+			want: []string{"COM_001", "COM_002", "COM_003", "COM_004"},
+		},
+		{
+			name: "processed commodities",
+			query: func(n *testNetwork, ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) ([]string, string, error) {
+				page, err := n.contract.QueryProcessedCommoditiesWithPagination(ctx, pageSize, bookmark)
+				if err != nil {
+					return nil, "", err
+				}
+				return pageIDs(page.Records, func(p *ProcessedCommodity) string { return p.ID }), page.Bookmark, nil
+			},
+			want: []string{"PCD_001", "PCD_002", "PCD_003"},
+		},
+		{
+			name: "commodities by state",
+			query: func(n *testNetwork, ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) ([]string, string, error) {
+				page, err := n.contract.QueryCommoditiesByStateWithPagination(ctx, string(StateProcessed), pageSize, bookmark)
+				if err != nil {
+					return nil, "", err
+				}
+				return pageIDs(page.Records, func(c *Commodity) string { return c.ID }), page.Bookmark, nil
+			},
+			want: []string{"COM_001", "COM_002", "COM_003"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Walk every page of two records
+			var got []string
+			bookmark := ""
+			for pages := 0; ; pages++ {
+				if pages > len(tt.want) {
+					t.Fatalf("pagination does not end, got %v", got)
+				}
+				ids, next, err := evaluatePage(n, tt.query, 2, bookmark)
+				if err != nil {
+					t.Fatalf("query failed: %v", err)
+				}
+				if len(ids) > 2 {
+					t.Fatalf("expected at most 2 records per page, got %v", ids)
+				}
+				got = append(got, ids...)
+				if next == "" {
+					break
+				}
+				bookmark = next
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("expected %v, got %v", tt.want, got)
+				}
+			}
+
+			_, _, err := evaluatePage(n, tt.query, 0, "")
+			checkError(t, err, "page size must be positive")
+
+			err = n.ledger.Evaluate(noRoleUser, func(ctx contractapi.TransactionContextInterface) error {
+				_, _, err := tt.query(n, ctx, 2, "")
+				return err
+			})
+			checkError(t, err, "permission denied")
+		})
+	}
+}
+
+// evaluatePage runs a page query as an admin
+func evaluatePage(n *testNetwork, query pageQuery, pageSize int32, bookmark string) ([]string, string, error) {
+	var ids []string
+	var next string
+	err := n.ledger.Evaluate(org1Admin, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		ids, next, err = query(n, ctx, pageSize, bookmark)
+		return err
+	})
+	return ids, next, err
+}
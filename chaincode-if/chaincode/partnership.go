@@ -0,0 +1,708 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// PartnershipStatus is the status of a partnership
+type PartnershipStatus string
+
+// Statuses of a partnership. A proposed partnership becomes active once the
+// other side accepts it and ends when either side ends it.
+const (
+	PartnershipProposed PartnershipStatus = "proposed"
+	PartnershipActive   PartnershipStatus = "active"
+	PartnershipRejected PartnershipStatus = "rejected"
+	PartnershipEnded    PartnershipStatus = "ended"
+)
+
+// Kinds of partners a collector can have
+const (
+	PartnerFarmer = "farmer"
+	PartnerFarm   = "farm"
+)
+
+// Sides of a partnership. The partner side of a farm partnership is the
+// farmer owning the farm.
+const (
+	SideCollector = "collector"
+	SidePartner   = "partner"
+)
+
+// collectorPartnershipIndex indexes partnerships by collector and partner,
+// e.g. collector~partnership/<collectorID>/farm/<farmID>/<partnershipID>
+const collectorPartnershipIndex = "collector~partnership"
+
+// partnerPartnershipIndex indexes partnerships by partner and collector,
+// e.g. partner~partnership/farm/<farmID>/<collectorID>/<partnershipID>
+const partnerPartnershipIndex = "partner~partnership"
+
+// Consent records the identity and transaction with which one side agreed to
+// a partnership
+type Consent struct {
+	ClientID string `json:"clientId"`
+	MSPID    string `json:"mspId"`
+	At       string `json:"at"`
+	TxID     string `json:"txId"`
+}
+
+// Partnership is an agreement that a collector collects the harvests of a
+// farmer's farms, or of one farm, from its start date until its end date, if
+// any. One side proposes it and it becomes active once the other accepts.
+type Partnership struct {
+	ID               string            `json:"id"`
+	CollectorID      string            `json:"collectorId"`
+	PartnerType      string            `json:"partnerType"`
+	PartnerID        string            `json:"partnerId"`
+	StartDate        string            `json:"startDate"`
+	EndDate          string            `json:"endDate,omitempty" metadata:",optional"`
+	Status           PartnershipStatus `json:"status"`
+	ProposedBy       string            `json:"proposedBy"`
+	CollectorConsent *Consent          `json:"collectorConsent,omitempty" metadata:",optional"`
+	PartnerConsent   *Consent          `json:"partnerConsent,omitempty" metadata:",optional"`
+	ClosedAt         string            `json:"closedAt,omitempty" metadata:",optional"`
+	ClosedTxID       string            `json:"closedTxId,omitempty" metadata:",optional"`
+}
+
+// activeOn reports whether a partnership is active on a date
+func (p *Partnership) activeOn(date string) bool {
+	return p.Status == PartnershipActive && p.StartDate <= date && (p.EndDate == "" || date <= p.EndDate)
+}
+
+// txDate returns the date of the transaction in YYYY-MM-DD format
+func txDate(ctx contractapi.TransactionContextInterface) (string, error) {
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return "", fmt.Errorf("failed to read transaction timestamp: %v", err)
+	}
+	return time.Unix(timestamp.GetSeconds(), 0).UTC().Format(time.DateOnly), nil
+}
+
+// readCollector fetches a collector from the ledger
+func readCollector(ctx contractapi.TransactionContextInterface, id string) (*Collector, error) {
+	collectorJSON, err := getEntityState(ctx, collectorObjectType, id)
+	if err != nil {
+		return nil, err
+	}
+	if collectorJSON == nil {
+		return nil, fmt.Errorf("the collector with ID %s does not exist", id)
+	}
+
+	var collector Collector
+	err = json.Unmarshal(collectorJSON, &collector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal collector JSON: %v", err)
+	}
+
+	return &collector, nil
+}
+
+// readPartnership fetches a partnership from the ledger
+func readPartnership(ctx contractapi.TransactionContextInterface, id string) (*Partnership, error) {
+	partnershipJSON, err := getEntityState(ctx, partnershipObjectType, id)
+	if err != nil {
+		return nil, err
+	}
+	if partnershipJSON == nil {
+		return nil, fmt.Errorf("the partnership with ID %s does not exist", id)
+	}
+
+	var partnership Partnership
+	err = json.Unmarshal(partnershipJSON, &partnership)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal partnership JSON: %v", err)
+	}
+
+	return &partnership, nil
+}
+
+// writePartnership stores a partnership and emits its event
+func writePartnership(ctx contractapi.TransactionContextInterface, partnership *Partnership, eventName string) error {
+	err := putPartnership(ctx, partnership)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, eventName, partnershipPayload(partnership))
+}
+
+// putPartnership stores a partnership
+func putPartnership(ctx contractapi.TransactionContextInterface, partnership *Partnership) error {
+	partnershipJSON, err := json.Marshal(partnership)
+	if err != nil {
+		return fmt.Errorf("failed to marshal partnership: %v", err)
+	}
+
+	return putEntityState(ctx, partnershipObjectType, partnership.ID, partnershipJSON)
+}
+
+// collectorPartnerships returns the partnerships of a collector, optionally
+// narrowed to one partner by passing its type and ID as keys
+func collectorPartnerships(ctx contractapi.TransactionContextInterface, collectorID string, keys ...string) ([]*Partnership, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(collectorPartnershipIndex, append([]string{collectorID}, keys...))
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	partnerships := []*Partnership{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split index key: %v", err)
+		}
+		if len(attributes) != 4 {
+			continue
+		}
+
+		partnership, err := readPartnership(ctx, attributes[3])
+		if err != nil {
+			return nil, err
+		}
+		partnerships = append(partnerships, partnership)
+	}
+
+	return partnerships, nil
+}
+
+// partnerFarmer returns the farmer who consents for the partner side of a
+// partnership: the partner itself, or the owner of the partner farm
+func partnerFarmer(ctx contractapi.TransactionContextInterface, partnership *Partnership) (string, error) {
+	if partnership.PartnerType == PartnerFarmer {
+		_, err := readFarmer(ctx, partnership.PartnerID)
+		if err != nil {
+			return "", err
+		}
+		return partnership.PartnerID, nil
+	}
+
+	farm, err := readFarm(ctx, partnership.PartnerID)
+	if err != nil {
+		return "", err
+	}
+	if farm.Owner == "" {
+		return "", fmt.Errorf("the farm with ID %s has no owner", farm.ID)
+	}
+
+	return farm.Owner, nil
+}
+
+// authorizeSide checks that the caller may act for one side of a partnership
+func authorizeSide(ctx contractapi.TransactionContextInterface, function string, partnership *Partnership, side string) error {
+	if side == SideCollector {
+		collector, err := readCollector(ctx, partnership.CollectorID)
+		if err != nil {
+			return err
+		}
+		return authorizeOwner(ctx, function, collector.EnrolledBy)
+	}
+
+	farmerID, err := partnerFarmer(ctx, partnership)
+	if err != nil {
+		return err
+	}
+	return authorizeFarmOwner(ctx, function, farmerID)
+}
+
+// callerSide returns the side of a partnership the caller may act for,
+// trying the collector side first
+func callerSide(ctx contractapi.TransactionContextInterface, function string, partnership *Partnership) (string, error) {
+	err := authorizeSide(ctx, function, partnership, SideCollector)
+	var permissionErr *PermissionError
+	if !errors.As(err, &permissionErr) {
+		return SideCollector, err
+	}
+
+	err = authorizeSide(ctx, function, partnership, SidePartner)
+	if err != nil {
+		return "", err
+	}
+	return SidePartner, nil
+}
+
+// sideEnrollment returns the enrollment of the entity on one side of a
+// partnership: the collector, or the farmer partnered or owning the farm
+func sideEnrollment(ctx contractapi.TransactionContextInterface, partnership *Partnership, side string) (*Enrollment, error) {
+	if side == SideCollector {
+		collector, err := readCollector(ctx, partnership.CollectorID)
+		if err != nil {
+			return nil, err
+		}
+		return collector.EnrolledBy, nil
+	}
+
+	farmerID, err := partnerFarmer(ctx, partnership)
+	if err != nil {
+		return nil, err
+	}
+	farmer, err := readFarmer(ctx, farmerID)
+	if err != nil {
+		return nil, err
+	}
+	return farmer.EnrolledBy, nil
+}
+
+// boundToSide reports whether the caller enrolled the entity on one side of a
+// partnership. Only that identity may consent for the side, so admins cannot
+// give the consent of both sides.
+func boundToSide(ctx contractapi.TransactionContextInterface, partnership *Partnership, side string) (bool, error) {
+	caller, err := callerEnrollment(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	enrolledBy, err := sideEnrollment(ctx, partnership, side)
+	if err != nil {
+		return false, err
+	}
+
+	return enrolledBy != nil && *enrolledBy == *caller, nil
+}
+
+// consentDenied is returned when the caller may not consent for a side
+func consentDenied(ctx contractapi.TransactionContextInterface, function string, reason string) error {
+	mspID, role, err := callerRole(ctx)
+	if err != nil {
+		return err
+	}
+	return &PermissionError{Function: function, Role: role, MSPID: mspID, Reason: reason}
+}
+
+// otherSide returns the opposite side of a partnership
+func otherSide(side string) string {
+	if side == SideCollector {
+		return SidePartner
+	}
+	return SideCollector
+}
+
+// newConsent records the consent of the caller in the current transaction
+func newConsent(ctx contractapi.TransactionContextInterface) (*Consent, error) {
+	at, err := txTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	caller, err := callerEnrollment(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Consent{ClientID: caller.ClientID, MSPID: caller.MSPID, At: at, TxID: ctx.GetStub().GetTxID()}, nil
+}
+
+// giveConsent records the consent of one side of a partnership
+func giveConsent(ctx contractapi.TransactionContextInterface, partnership *Partnership, side string) error {
+	consent, err := newConsent(ctx)
+	if err != nil {
+		return err
+	}
+
+	if side == SideCollector {
+		partnership.CollectorConsent = consent
+	} else {
+		partnership.PartnerConsent = consent
+	}
+	return nil
+}
+
+// syncCollectorPartners rewrites the partner list of a collector from its
+// active partnerships after one of them changed. Reads do not see the writes
+// of the transaction, so the changed partnership replaces its stored copy.
+func syncCollectorPartners(ctx contractapi.TransactionContextInterface, changed *Partnership) error {
+	collectorID := changed.CollectorID
+	collector, err := readCollector(ctx, collectorID)
+	if err != nil {
+		return err
+	}
+
+	partnerships, err := collectorPartnerships(ctx, collectorID)
+	if err != nil {
+		return err
+	}
+
+	partners := []string{}
+	for _, partnership := range partnerships {
+		if partnership.ID == changed.ID {
+			partnership = changed
+		}
+		if partnership.Status == PartnershipActive && !containsString(partners, partnership.PartnerID) {
+			partners = append(partners, partnership.PartnerID)
+		}
+	}
+	collector.Partner = partners
+
+	collectorJSON, err := json.Marshal(collector)
+	if err != nil {
+		return fmt.Errorf("failed to marshal collector: %v", err)
+	}
+
+	return putEntityState(ctx, collectorObjectType, collectorID, collectorJSON)
+}
+
+// keepPartnerList checks that an add or update leaves the partner list of a
+// collector as its partnerships made it
+func keepPartnerList(collector *Collector, previous []string) error {
+	same := len(collector.Partner) == len(previous)
+	for _, id := range collector.Partner {
+		same = same && containsString(previous, id)
+	}
+	if !same {
+		return fmt.Errorf("the partners of collector %s follow its partnerships and cannot be set directly; use ProposePartnership", collector.ID)
+	}
+
+	collector.Partner = previous
+	return nil
+}
+
+// isActivePartner reports whether a collector has a partnership active on
+// the transaction date with a farm or with the farmer owning it
+func isActivePartner(ctx contractapi.TransactionContextInterface, collectorID string, farmID string, farmerID string) (bool, error) {
+	date, err := txDate(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	farmPartnerships, err := collectorPartnerships(ctx, collectorID, PartnerFarm, farmID)
+	if err != nil {
+		return false, err
+	}
+	farmerPartnerships, err := collectorPartnerships(ctx, collectorID, PartnerFarmer, farmerID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, partnership := range append(farmPartnerships, farmerPartnerships...) {
+		if partnership.activeOn(date) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ProposePartnership proposes a partnership between a collector and a farmer
+// or farm, with dates in YYYY-MM-DD format and an optional end date. The
+// caller consents for its side and must be the identity that enrolled the
+// collector or the farmer. The partnership becomes active once the identity
+// of the other side calls AcceptPartnership.
+func (pc *PalmOilContract) ProposePartnership(ctx contractapi.TransactionContextInterface, id string, collectorID string, partnerType string, partnerID string, startDate string, endDate string) error {
+	err := authorize(ctx, "ProposePartnership")
+	if err != nil {
+		return err
+	}
+
+	existingJSON, err := getEntityState(ctx, partnershipObjectType, id)
+	if err != nil {
+		return err
+	}
+	if existingJSON != nil {
+		return fmt.Errorf("a partnership with ID %s already exists", id)
+	}
+
+	partnership := Partnership{
+		ID:          id,
+		CollectorID: collectorID,
+		PartnerType: partnerType,
+		PartnerID:   partnerID,
+		StartDate:   startDate,
+		EndDate:     endDate,
+		Status:      PartnershipProposed,
+	}
+	err = validatePartnership(&partnership)
+	if err != nil {
+		return err
+	}
+
+	// The partner must exist whichever side proposes
+	_, err = partnerFarmer(ctx, &partnership)
+	if err != nil {
+		return err
+	}
+	side := SideCollector
+	bound, err := boundToSide(ctx, &partnership, side)
+	if err != nil {
+		return err
+	}
+	if !bound {
+		side = SidePartner
+		bound, err = boundToSide(ctx, &partnership, side)
+		if err != nil {
+			return err
+		}
+	}
+	if !bound {
+		return consentDenied(ctx, "ProposePartnership", "only the identity that enrolled the collector or the partner may propose a partnership")
+	}
+
+	// A collector has at most one open partnership with each partner
+	partnerships, err := collectorPartnerships(ctx, collectorID, partnerType, partnerID)
+	if err != nil {
+		return err
+	}
+	for _, open := range partnerships {
+		if open.Status == PartnershipProposed || open.Status == PartnershipActive {
+			return fmt.Errorf("collector %s already has the %s partnership %s with %s %s", collectorID, open.Status, open.ID, partnerType, partnerID)
+		}
+	}
+
+	partnership.ProposedBy = side
+	err = giveConsent(ctx, &partnership, side)
+	if err != nil {
+		return err
+	}
+
+	indexKeys := map[string][]string{
+		collectorPartnershipIndex: {collectorID, partnerType, partnerID, id},
+		partnerPartnershipIndex:   {partnerType, partnerID, collectorID, id},
+	}
+	for index, attributes := range indexKeys {
+		indexKey, err := ctx.GetStub().CreateCompositeKey(index, attributes)
+		if err != nil {
+			return fmt.Errorf("failed to create index key: %v", err)
+		}
+		err = ctx.GetStub().PutState(indexKey, []byte{0x00})
+		if err != nil {
+			return err
+		}
+	}
+
+	return writePartnership(ctx, &partnership, EventPartnershipProposed)
+}
+
+// AcceptPartnership gives the consent of the side that did not propose a
+// partnership, which makes it active. The caller must be the identity that
+// enrolled that side's collector or farmer, and not the proposing identity.
+func (pc *PalmOilContract) AcceptPartnership(ctx contractapi.TransactionContextInterface, id string) error {
+	err := authorize(ctx, "AcceptPartnership")
+	if err != nil {
+		return err
+	}
+
+	partnership, err := proposedPartnership(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	caller, err := callerEnrollment(ctx)
+	if err != nil {
+		return err
+	}
+	proposal := partnership.CollectorConsent
+	if partnership.ProposedBy == SidePartner {
+		proposal = partnership.PartnerConsent
+	}
+	if proposal != nil && proposal.ClientID == caller.ClientID && proposal.MSPID == caller.MSPID {
+		return consentDenied(ctx, "AcceptPartnership", "the identity that proposed a partnership cannot also accept it")
+	}
+
+	side := otherSide(partnership.ProposedBy)
+	bound, err := boundToSide(ctx, partnership, side)
+	if err != nil {
+		return err
+	}
+	if !bound {
+		return consentDenied(ctx, "AcceptPartnership", "only the identity that enrolled the "+side+" side may accept a partnership")
+	}
+
+	err = giveConsent(ctx, partnership, side)
+	if err != nil {
+		return err
+	}
+	partnership.Status = PartnershipActive
+
+	err = writePartnership(ctx, partnership, EventPartnershipAccepted)
+	if err != nil {
+		return err
+	}
+
+	return syncCollectorPartners(ctx, partnership)
+}
+
+// RejectPartnership declines a partnership proposed by the other side
+func (pc *PalmOilContract) RejectPartnership(ctx contractapi.TransactionContextInterface, id string) error {
+	err := authorize(ctx, "RejectPartnership")
+	if err != nil {
+		return err
+	}
+
+	partnership, err := proposedPartnership(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = authorizeSide(ctx, "RejectPartnership", partnership, otherSide(partnership.ProposedBy))
+	if err != nil {
+		return err
+	}
+
+	return closePartnership(ctx, partnership, PartnershipRejected, EventPartnershipRejected)
+}
+
+// EndPartnership ends an active partnership, on behalf of either side, or
+// withdraws a proposal on behalf of the side that made it
+func (pc *PalmOilContract) EndPartnership(ctx contractapi.TransactionContextInterface, id string) error {
+	err := authorize(ctx, "EndPartnership")
+	if err != nil {
+		return err
+	}
+
+	partnership, err := readPartnership(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	switch partnership.Status {
+	case PartnershipActive:
+		_, err = callerSide(ctx, "EndPartnership", partnership)
+	case PartnershipProposed:
+		err = authorizeSide(ctx, "EndPartnership", partnership, partnership.ProposedBy)
+	default:
+		err = fmt.Errorf("the partnership with ID %s is already %s", id, partnership.Status)
+	}
+	if err != nil {
+		return err
+	}
+
+	wasActive := partnership.Status == PartnershipActive
+	err = closePartnership(ctx, partnership, PartnershipEnded, EventPartnershipEnded)
+	if err != nil {
+		return err
+	}
+	if !wasActive {
+		return nil
+	}
+
+	return syncCollectorPartners(ctx, partnership)
+}
+
+// proposedPartnership reads a partnership awaiting consent
+func proposedPartnership(ctx contractapi.TransactionContextInterface, id string) (*Partnership, error) {
+	partnership, err := readPartnership(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if partnership.Status != PartnershipProposed {
+		return nil, fmt.Errorf("the partnership with ID %s is %s, not proposed", id, partnership.Status)
+	}
+
+	return partnership, nil
+}
+
+// closePartnership ends a partnership with the given status
+func closePartnership(ctx contractapi.TransactionContextInterface, partnership *Partnership, status PartnershipStatus, eventName string) error {
+	err := markClosed(ctx, partnership, status)
+	if err != nil {
+		return err
+	}
+
+	return writePartnership(ctx, partnership, eventName)
+}
+
+// markClosed sets the status of a partnership and records the transaction
+// that closed it
+func markClosed(ctx contractapi.TransactionContextInterface, partnership *Partnership, status PartnershipStatus) error {
+	closedAt, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	partnership.Status = status
+	partnership.ClosedAt = closedAt
+	partnership.ClosedTxID = ctx.GetStub().GetTxID()
+	return nil
+}
+
+// endFarmPartnerships ends the proposed and active partnerships of a farm and
+// returns their IDs. It emits no event, so the caller announces them.
+func endFarmPartnerships(ctx contractapi.TransactionContextInterface, farmID string) ([]string, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(partnerPartnershipIndex, []string{PartnerFarm, farmID})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	ended := []string{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split index key: %v", err)
+		}
+		if len(attributes) != 4 {
+			continue
+		}
+
+		partnership, err := readPartnership(ctx, attributes[3])
+		if err != nil {
+			return nil, err
+		}
+		if partnership.Status != PartnershipProposed && partnership.Status != PartnershipActive {
+			continue
+		}
+
+		wasActive := partnership.Status == PartnershipActive
+		err = markClosed(ctx, partnership, PartnershipEnded)
+		if err != nil {
+			return nil, err
+		}
+		err = putPartnership(ctx, partnership)
+		if err != nil {
+			return nil, err
+		}
+
+		// A collector has one open partnership with the farm, so each sync
+		// changes a different collector
+		if wasActive {
+			err = syncCollectorPartners(ctx, partnership)
+			if err != nil {
+				return nil, err
+			}
+		}
+		ended = append(ended, partnership.ID)
+	}
+
+	return ended, nil
+}
+
+// QueryPartnership retrieves a partnership by its ID
+func (pc *PalmOilContract) QueryPartnership(ctx contractapi.TransactionContextInterface, id string) (*Partnership, error) {
+	err := authorize(ctx, "QueryPartnership")
+	if err != nil {
+		return nil, err
+	}
+
+	return readPartnership(ctx, id)
+}
+
+// QueryPartnershipsByCollector retrieves every partnership of a collector
+func (pc *PalmOilContract) QueryPartnershipsByCollector(ctx contractapi.TransactionContextInterface, collectorID string) ([]*Partnership, error) {
+	err := authorize(ctx, "QueryPartnershipsByCollector")
+	if err != nil {
+		return nil, err
+	}
+
+	return collectorPartnerships(ctx, collectorID)
+}
+
+// QueryPartnershipsByPartner retrieves every partnership of a farmer or farm
+func (pc *PalmOilContract) QueryPartnershipsByPartner(ctx contractapi.TransactionContextInterface, partnerType string, partnerID string) ([]*Partnership, error) {
+	err := authorize(ctx, "QueryPartnershipsByPartner")
+	if err != nil {
+		return nil, err
+	}
+
+	return queryEntities[Partnership](ctx, partnershipObjectType, map[string]interface{}{"partnerType": partnerType, "partnerId": partnerID}, "indexPartnershipPartnerDoc")
+}
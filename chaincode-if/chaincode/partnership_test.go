@@ -0,0 +1,384 @@
+package chaincode
+
+import (
+	"reflect"
+	"testing"
+
+	"chaincode-if/memledger"
+	"chaincode-if/validation"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// partnershipNetwork registers farmer FRM_001, enrolled by farmerUser, with
+// farm FARM_001, farmer FRM_002, enrolled by otherFarmerUser, unowned farm
+// FARM_002 and collector COL_001, enrolled by collectorUser
+func partnershipNetwork(t *testing.T) *testNetwork {
+	n := newTestNetwork(t)
+	n.addFarmer(farmerUser, "FRM_001")
+	n.addFarmer(otherFarmerUser, "FRM_002")
+	n.addFarm(farmerUser, "FARM_001", "FRM_001")
+	n.addFarm(org1Admin, "FARM_002", "")
+	n.addCollector(collectorUser, "COL_001", "1234567890123")
+	return n
+}
+
+// partnership returns a partnership by its ID
+func (n *testNetwork) partnership(id string) *Partnership {
+	n.t.Helper()
+	return mustEvaluate(n, org1Admin, func(ctx contractapi.TransactionContextInterface) (*Partnership, error) {
+		return n.contract.QueryPartnership(ctx, id)
+	})
+}
+
+// partnersOf returns the partner list of a collector
+func (n *testNetwork) partnersOf(collectorID string) []string {
+	n.t.Helper()
+	collector := mustEvaluate(n, org1Admin, func(ctx contractapi.TransactionContextInterface) (*Collector, error) {
+		return n.contract.QueryCollectorByID(ctx, collectorID)
+	})
+	return collector.Partner
+}
+
+func TestProposePartnership(t *testing.T) {
+	tests := []struct {
+		name           string
+		caller         *memledger.Identity
+		id             string
+		collectorID    string
+		partnerType    string
+		partnerID      string
+		startDate      string
+		endDate        string
+		wantProposedBy string
+		wantErr        string
+	}{
+		{name: "by collector", caller: collectorUser, id: "PRT_002", partnerType: PartnerFarmer, partnerID: "FRM_001", startDate: "2024-01-01", wantProposedBy: SideCollector},
+		{name: "by farmer", caller: farmerUser, id: "PRT_002", partnerType: PartnerFarmer, partnerID: "FRM_001", startDate: "2024-01-01", endDate: "2024-12-31", wantProposedBy: SidePartner},
+		{name: "by admin", caller: org1Admin, id: "PRT_002", partnerType: PartnerFarmer, partnerID: "FRM_002", startDate: "2024-01-01", wantErr: "only the identity that enrolled the collector or the partner"},
+		{name: "duplicate ID", caller: collectorUser, id: "PRT_001", partnerType: PartnerFarmer, partnerID: "FRM_002", startDate: "2024-01-01", wantErr: "already exists"},
+		{name: "open partnership", caller: collectorUser, id: "PRT_002", partnerType: PartnerFarm, partnerID: "FARM_001", startDate: "2024-01-01", wantErr: "already has the proposed partnership PRT_001"},
+		{name: "missing collector", caller: collectorUser, id: "PRT_002", collectorID: "COL_404", partnerType: PartnerFarmer, partnerID: "FRM_001", startDate: "2024-01-01", wantErr: "the collector with ID COL_404 does not exist"},
+		{name: "missing farmer", caller: collectorUser, id: "PRT_002", partnerType: PartnerFarmer, partnerID: "FRM_404", startDate: "2024-01-01", wantErr: "the farmer with ID FRM_404 does not exist"},
+		{name: "unowned farm", caller: collectorUser, id: "PRT_002", partnerType: PartnerFarm, partnerID: "FARM_002", startDate: "2024-01-01", wantErr: "has no owner"},
+		{name: "farmer of another partner", caller: otherFarmerUser, id: "PRT_002", partnerType: PartnerFarmer, partnerID: "FRM_001", startDate: "2024-01-01", wantErr: "permission denied"},
+		{name: "transporter role", caller: transporterUser, id: "PRT_002", partnerType: PartnerFarmer, partnerID: "FRM_001", startDate: "2024-01-01", wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := partnershipNetwork(t)
+			n.mustSubmit(collectorUser, func(ctx contractapi.TransactionContextInterface) error {
+				return n.contract.ProposePartnership(ctx, "PRT_001", "COL_001", PartnerFarm, "FARM_001", "2024-01-01", "")
+			})
+
+			collectorID := tt.collectorID
+			if collectorID == "" {
+				collectorID = "COL_001"
+			}
+			err := n.submit(tt.caller, func(ctx contractapi.TransactionContextInterface) error {
+				return n.contract.ProposePartnership(ctx, tt.id, collectorID, tt.partnerType, tt.partnerID, tt.startDate, tt.endDate)
+			})
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+
+			partnership := n.partnership(tt.id)
+			if partnership.Status != PartnershipProposed || partnership.ProposedBy != tt.wantProposedBy || partnership.EndDate != tt.endDate {
+				t.Errorf("unexpected partnership %+v", partnership)
+			}
+			consent, missing := partnership.CollectorConsent, partnership.PartnerConsent
+			if tt.wantProposedBy == SidePartner {
+				consent, missing = missing, consent
+			}
+			if consent == nil || consent.MSPID != "Org1MSP" || consent.TxID == "" || missing != nil {
+				t.Errorf("expected the consent of the %s side only, got %+v", tt.wantProposedBy, partnership)
+			}
+			if !reflect.DeepEqual(n.partnersOf("COL_001"), []string{}) {
+				t.Errorf("a proposal changed the partners of COL_001: %v", n.partnersOf("COL_001"))
+			}
+		})
+	}
+}
+
+func TestPartnershipValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		partnerType string
+		startDate   string
+		endDate     string
+		want        []validation.FieldError
+	}{
+		{name: "partner type", partnerType: "processor", startDate: "2024-01-01", want: []validation.FieldError{{Field: "partnerType", Code: validation.CodeFormat, Message: "must be farmer or farm"}}},
+		{name: "no start date", partnerType: PartnerFarmer, want: []validation.FieldError{{Field: "startDate", Code: validation.CodeRequired, Message: "is required"}}},
+		{name: "date format", partnerType: PartnerFarmer, startDate: "01/01/2024", endDate: "2024-13-01", want: []validation.FieldError{
+			{Field: "startDate", Code: validation.CodeFormat, Message: "must be a date such as 2024-03-01"},
+			{Field: "endDate", Code: validation.CodeFormat, Message: "must be a date such as 2024-03-01"},
+		}},
+		{name: "end before start", partnerType: PartnerFarmer, startDate: "2024-06-01", endDate: "2024-05-31", want: []validation.FieldError{{Field: "endDate", Code: validation.CodeRange, Message: "must not be before startDate"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := partnershipNetwork(t)
+			err := n.submit(collectorUser, func(ctx contractapi.TransactionContextInterface) error {
+				return n.contract.ProposePartnership(ctx, "PRT_001", "COL_001", tt.partnerType, "FRM_001", tt.startDate, tt.endDate)
+			})
+
+			validationErr, ok := validation.Parse(err)
+			if !ok {
+				t.Fatalf("expected a validation error, got %v", err)
+			}
+			if !reflect.DeepEqual(validationErr.Fields, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, validationErr.Fields)
+			}
+		})
+	}
+}
+
+func TestPartnershipConsent(t *testing.T) {
+	tests := []struct {
+		name         string
+		proposer     *memledger.Identity
+		caller       *memledger.Identity
+		tx           func(c *PalmOilContract, ctx contractapi.TransactionContextInterface, id string) error
+		wantStatus   PartnershipStatus
+		wantPartners []string
+		wantErr      string
+	}{
+		{name: "farmer accepts", proposer: collectorUser, caller: farmerUser, tx: (*PalmOilContract).AcceptPartnership, wantStatus: PartnershipActive, wantPartners: []string{"FRM_001"}},
+		{name: "collector accepts", proposer: farmerUser, caller: collectorUser, tx: (*PalmOilContract).AcceptPartnership, wantStatus: PartnershipActive, wantPartners: []string{"FRM_001"}},
+		{name: "farmer rejects", proposer: collectorUser, caller: farmerUser, tx: (*PalmOilContract).RejectPartnership, wantStatus: PartnershipRejected, wantPartners: []string{}},
+		{name: "proposer withdraws", proposer: collectorUser, caller: collectorUser, tx: (*PalmOilContract).EndPartnership, wantStatus: PartnershipEnded, wantPartners: []string{}},
+		{name: "proposer accepts", proposer: collectorUser, caller: collectorUser, tx: (*PalmOilContract).AcceptPartnership, wantErr: "the identity that proposed a partnership cannot also accept it"},
+		{name: "proposer rejects", proposer: farmerUser, caller: farmerUser, tx: (*PalmOilContract).RejectPartnership, wantErr: "permission denied"},
+		{name: "other side withdraws", proposer: collectorUser, caller: farmerUser, tx: (*PalmOilContract).EndPartnership, wantErr: "permission denied"},
+		{name: "another farmer accepts", proposer: collectorUser, caller: otherFarmerUser, tx: (*PalmOilContract).AcceptPartnership, wantErr: "permission denied"},
+		{name: "admin of the same org accepts", proposer: collectorUser, caller: org1Admin, tx: (*PalmOilContract).AcceptPartnership, wantErr: "only the identity that enrolled the partner side"},
+		{name: "admin of another org accepts", proposer: collectorUser, caller: org2Admin, tx: (*PalmOilContract).AcceptPartnership, wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := partnershipNetwork(t)
+			n.mustSubmit(tt.proposer, func(ctx contractapi.TransactionContextInterface) error {
+				return n.contract.ProposePartnership(ctx, "PRT_001", "COL_001", PartnerFarmer, "FRM_001", "2024-01-01", "")
+			})
+
+			err := n.submit(tt.caller, func(ctx contractapi.TransactionContextInterface) error {
+				return tt.tx(n.contract, ctx, "PRT_001")
+			})
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+
+			partnership := n.partnership("PRT_001")
+			if partnership.Status != tt.wantStatus {
+				t.Errorf("expected status %s, got %+v", tt.wantStatus, partnership)
+			}
+			if tt.wantStatus == PartnershipActive && (partnership.CollectorConsent == nil || partnership.PartnerConsent == nil) {
+				t.Errorf("expected the consent of both sides, got %+v", partnership)
+			}
+			if tt.wantStatus != PartnershipActive && (partnership.ClosedAt == "" || partnership.ClosedTxID == "") {
+				t.Errorf("expected the partnership to be closed, got %+v", partnership)
+			}
+			if partners := n.partnersOf("COL_001"); !reflect.DeepEqual(partners, tt.wantPartners) {
+				t.Errorf("expected partners %v, got %v", tt.wantPartners, partners)
+			}
+		})
+	}
+}
+
+func TestPartnershipNeedsTwoIdentities(t *testing.T) {
+	n := partnershipNetwork(t)
+	n.addFarmer(org1Admin, "FRM_003")
+	n.addCollector(org1Admin, "COL_002", "1234567890124")
+
+	// The admin enrolled both sides, so it may propose but not also accept
+	n.mustSubmit(org1Admin, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.ProposePartnership(ctx, "PRT_001", "COL_002", PartnerFarmer, "FRM_003", "2024-01-01", "")
+	})
+	err := n.submit(org1Admin, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.AcceptPartnership(ctx, "PRT_001")
+	})
+	checkError(t, err, "the identity that proposed a partnership cannot also accept it")
+	if !isPermissionError(err) {
+		t.Errorf("expected a PermissionError, got %T", err)
+	}
+
+	if partnership := n.partnership("PRT_001"); partnership.Status != PartnershipProposed || partnership.PartnerConsent != nil {
+		t.Errorf("expected the partnership to await the partner's consent, got %+v", partnership)
+	}
+}
+
+func TestEndPartnership(t *testing.T) {
+	n := partnershipNetwork(t)
+	n.addPartnership("PRT_001", "COL_001", PartnerFarmer, "FRM_001")
+	n.addPartnership("PRT_002", "COL_001", PartnerFarm, "FARM_001")
+	if partners := n.partnersOf("COL_001"); !reflect.DeepEqual(partners, []string{"FARM_001", "FRM_001"}) {
+		t.Fatalf("expected partners FARM_001 and FRM_001, got %v", partners)
+	}
+
+	// Either side ends an active partnership
+	n.mustSubmit(farmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.EndPartnership(ctx, "PRT_002")
+	})
+	if partners := n.partnersOf("COL_001"); !reflect.DeepEqual(partners, []string{"FRM_001"}) {
+		t.Errorf("expected partner FRM_001, got %v", partners)
+	}
+
+	err := n.submit(collectorUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.EndPartnership(ctx, "PRT_002")
+	})
+	checkError(t, err, "the partnership with ID PRT_002 is already ended")
+	err = n.submit(farmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.AcceptPartnership(ctx, "PRT_002")
+	})
+	checkError(t, err, "is ended, not proposed")
+
+	// An ended partnership can be proposed again
+	n.addPartnership("PRT_003", "COL_001", PartnerFarm, "FARM_001")
+	n.mustSubmit(collectorUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.EndPartnership(ctx, "PRT_001")
+	})
+	if partners := n.partnersOf("COL_001"); !reflect.DeepEqual(partners, []string{"FARM_001"}) {
+		t.Errorf("expected partner FARM_001, got %v", partners)
+	}
+}
+
+func TestCollectRequiresPartnership(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(n *testNetwork)
+		caller  *memledger.Identity
+		wantErr string
+	}{
+		{name: "farmer partner", setup: func(n *testNetwork) {
+			n.addPartnership("PRT_001", "COL_001", PartnerFarmer, "FRM_001")
+		}},
+		{name: "farm partner", setup: func(n *testNetwork) {
+			n.addPartnership("PRT_001", "COL_001", PartnerFarm, "FARM_001")
+		}},
+		{name: "partnership with dates", setup: func(n *testNetwork) {
+			addDatedPartnership(n, "PRT_001", PartnerFarmer, "FRM_001", "2023-06-01", "2024-01-01")
+		}},
+		{name: "collected by admin", caller: org1Admin, setup: func(n *testNetwork) {
+			n.addPartnership("PRT_001", "COL_001", PartnerFarm, "FARM_001")
+		}},
+		{name: "no partnership", setup: func(n *testNetwork) {}, wantErr: "the farm with ID FARM_001 of commodity COM_001 is not an active partner of collector COL_001"},
+		{name: "proposed partnership", setup: func(n *testNetwork) {
+			n.mustSubmit(collectorUser, func(ctx contractapi.TransactionContextInterface) error {
+				return n.contract.ProposePartnership(ctx, "PRT_001", "COL_001", PartnerFarm, "FARM_001", "2024-01-01", "")
+			})
+		}, wantErr: "is not an active partner"},
+		{name: "partnership with another farmer", setup: func(n *testNetwork) {
+			n.mustSubmit(collectorUser, func(ctx contractapi.TransactionContextInterface) error {
+				return n.contract.ProposePartnership(ctx, "PRT_001", "COL_001", PartnerFarmer, "FRM_002", "2024-01-01", "")
+			})
+			n.mustSubmit(otherFarmerUser, func(ctx contractapi.TransactionContextInterface) error {
+				return n.contract.AcceptPartnership(ctx, "PRT_001")
+			})
+		}, wantErr: "is not an active partner"},
+		{name: "partnership not started", setup: func(n *testNetwork) {
+			addDatedPartnership(n, "PRT_001", PartnerFarm, "FARM_001", "2024-02-01", "")
+		}, wantErr: "is not an active partner"},
+		{name: "partnership expired", setup: func(n *testNetwork) {
+			addDatedPartnership(n, "PRT_001", PartnerFarm, "FARM_001", "2023-01-01", "2023-12-31")
+		}, wantErr: "is not an active partner"},
+		{name: "ended partnership", setup: func(n *testNetwork) {
+			n.addPartnership("PRT_001", "COL_001", PartnerFarmer, "FRM_001")
+			n.mustSubmit(farmerUser, func(ctx contractapi.TransactionContextInterface) error {
+				return n.contract.EndPartnership(ctx, "PRT_001")
+			})
+		}, wantErr: "is not an active partner"},
+		{name: "collector of another identity", caller: org2Admin, setup: func(n *testNetwork) {
+			n.addPartnership("PRT_001", "COL_001", PartnerFarmer, "FRM_001")
+		}, wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := partnershipNetwork(t)
+			tt.setup(n)
+			n.harvest("COM_001", "FARM_001", 100, "2024-01-01")
+
+			caller := tt.caller
+			if caller == nil {
+				caller = collectorUser
+			}
+			err := n.submit(caller, func(ctx contractapi.TransactionContextInterface) error {
+				return n.contract.Collect(ctx, "COM_001", "COL_001", "Sari", "Pekanbaru")
+			})
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+
+			commodity := mustEvaluate(n, collectorUser, func(ctx contractapi.TransactionContextInterface) (*Commodity, error) {
+				return n.contract.QueryCommodityByID(ctx, "COM_001")
+			})
+			if commodity.State != StateCollected || commodity.CollectorID != "COL_001" {
+				t.Errorf("unexpected commodity %+v", commodity)
+			}
+		})
+	}
+}
+
+// addDatedPartnership makes a partnership of COL_001 with the given dates
+func addDatedPartnership(n *testNetwork, id string, partnerType string, partnerID string, startDate string, endDate string) {
+	n.t.Helper()
+	n.mustSubmit(collectorUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.ProposePartnership(ctx, id, "COL_001", partnerType, partnerID, startDate, endDate)
+	})
+	n.mustSubmit(farmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.AcceptPartnership(ctx, id)
+	})
+}
+
+func TestQueryPartnerships(t *testing.T) {
+	n := partnershipNetwork(t)
+	n.addCollector(collectorUser, "COL_002", "1234567890124")
+	n.addPartnership("PRT_001", "COL_001", PartnerFarmer, "FRM_001")
+	n.addPartnership("PRT_002", "COL_001", PartnerFarm, "FARM_001")
+	n.addPartnership("PRT_003", "COL_002", PartnerFarmer, "FRM_001")
+
+	ids := func(partnerships []*Partnership) []string {
+		ids := []string{}
+		for _, partnership := range partnerships {
+			ids = append(ids, partnership.ID)
+		}
+		return ids
+	}
+
+	byCollector := mustEvaluate(n, farmerUser, func(ctx contractapi.TransactionContextInterface) ([]*Partnership, error) {
+		return n.contract.QueryPartnershipsByCollector(ctx, "COL_001")
+	})
+	if got := ids(byCollector); !reflect.DeepEqual(got, []string{"PRT_002", "PRT_001"}) {
+		t.Errorf("expected the partnerships of COL_001, got %v", got)
+	}
+
+	byPartner := mustEvaluate(n, collectorUser, func(ctx contractapi.TransactionContextInterface) ([]*Partnership, error) {
+		return n.contract.QueryPartnershipsByPartner(ctx, PartnerFarmer, "FRM_001")
+	})
+	if got := ids(byPartner); !reflect.DeepEqual(got, []string{"PRT_001", "PRT_003"}) {
+		t.Errorf("expected the partnerships of FRM_001, got %v", got)
+	}
+
+	none := mustEvaluate(n, collectorUser, func(ctx contractapi.TransactionContextInterface) ([]*Partnership, error) {
+		return n.contract.QueryPartnershipsByCollector(ctx, "COL_404")
+	})
+	if len(none) != 0 || none == nil {
+		t.Errorf("expected an empty list, got %v", none)
+	}
+
+	_, err := evaluate(n, noRoleUser, func(ctx contractapi.TransactionContextInterface) (*Partnership, error) {
+		return n.contract.QueryPartnership(ctx, "PRT_001")
+	})
+	checkError(t, err, "permission denied")
+	_, err = evaluate(n, farmerUser, func(ctx contractapi.TransactionContextInterface) (*Partnership, error) {
+		return n.contract.QueryPartnership(ctx, "PRT_404")
+	})
+	checkError(t, err, "the partnership with ID PRT_404 does not exist")
+}
@@ -0,0 +1,484 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Personal data of farmers, collectors, processors and transporters is kept
+// out of the world state. It is passed to the contract as transient data and
+// stored in a private data collection of the organization that registered the
+// entity; the public record only keeps a salted hash of the NIK. The
+// collections are defined in collections_config.json.
+
+// personalTransientKey is the transient data key holding a PersonalData JSON
+const personalTransientKey = "personal"
+
+// minSaltLength is the shortest salt accepted for hashing a NIK
+const minSaltLength = 16
+
+// personalFields are the JSON fields that held personal data in the public
+// record before it moved to private data collections
+var personalFields = []string{"nik", "noHP", "email"}
+
+// PersonalData holds the personal details of an entity
+type PersonalData struct {
+	EntityType string `json:"entityType"`
+	EntityID   string `json:"entityId"`
+	NIK        string `json:"nik"`
+	NoHP       string `json:"noHP"`
+	Email      string `json:"email"`
+	Salt       string `json:"salt"`
+}
+
+// FarmerDetails is a farmer together with its personal data
+type FarmerDetails struct {
+	Farmer       *Farmer       `json:"farmer"`
+	PersonalData *PersonalData `json:"personalData"`
+}
+
+// CollectorDetails is a collector together with its personal data
+type CollectorDetails struct {
+	Collector    *Collector    `json:"collector"`
+	PersonalData *PersonalData `json:"personalData"`
+}
+
+// ProcessorDetails is a processor together with its personal data
+type ProcessorDetails struct {
+	Processor    *Processor    `json:"processor"`
+	PersonalData *PersonalData `json:"personalData"`
+}
+
+// TransporterDetails is a transporter together with its personal data
+type TransporterDetails struct {
+	Transporter  *Transporter  `json:"transporter"`
+	PersonalData *PersonalData `json:"personalData"`
+}
+
+// personalDataCollection returns the name of the private data collection
+// holding the personal data registered by an organization
+func personalDataCollection(mspID string) string {
+	return mspID + "PersonalData"
+}
+
+// hashNIK returns the salted SHA-256 hash of a NIK
+func hashNIK(salt string, nik string) string {
+	sum := sha256.Sum256([]byte(salt + ":" + nik))
+	return hex.EncodeToString(sum[:])
+}
+
+// verifyPeerOrg checks that the endorsing peer belongs to the organization
+// whose personal data is being handled, so the data never reaches the peers
+// of other organizations
+func verifyPeerOrg(mspID string) error {
+	peerMSPID, err := shim.GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to read peer MSP ID: %v", err)
+	}
+	if peerMSPID != mspID {
+		return fmt.Errorf("personal data of %s cannot be handled by a peer of %s", mspID, peerMSPID)
+	}
+
+	return nil
+}
+
+// readTransientPersonalData parses the personal data passed as transient
+// data, or returns nil if none was passed
+func readTransientPersonalData(ctx contractapi.TransactionContextInterface) (*PersonalData, error) {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transient data: %v", err)
+	}
+
+	personalJSON, ok := transientMap[personalTransientKey]
+	if !ok {
+		return nil, nil
+	}
+
+	var personal PersonalData
+	err = json.Unmarshal(personalJSON, &personal)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s transient data: %v", personalTransientKey, err)
+	}
+	if personal.NIK == "" {
+		return nil, fmt.Errorf("the %s transient data must include a nik", personalTransientKey)
+	}
+	if len(personal.Salt) < minSaltLength {
+		return nil, fmt.Errorf("the %s transient data must include a salt of at least %d characters", personalTransientKey, minSaltLength)
+	}
+
+	return &personal, nil
+}
+
+// putPersonalData stores the personal data of an entity in the collection of
+// the organization that registered it and returns the salted NIK hash to keep
+// in the public record
+func putPersonalData(ctx contractapi.TransactionContextInterface, objectType string, id string, mspID string, personal *PersonalData) (string, error) {
+	err := verifyPeerOrg(mspID)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := entityKey(ctx, objectType, id)
+	if err != nil {
+		return "", err
+	}
+
+	personal.EntityType = objectType
+	personal.EntityID = id
+	personalJSON, err := json.Marshal(personal)
+	if err != nil {
+		return "", err
+	}
+
+	err = ctx.GetStub().PutPrivateData(personalDataCollection(mspID), key, personalJSON)
+	if err != nil {
+		return "", fmt.Errorf("failed to write personal data: %v", err)
+	}
+
+	return hashNIK(personal.Salt, personal.NIK), nil
+}
+
+// addPersonalData stores the personal data passed as transient data for a
+// newly registered entity, registers its NIK as unique and returns its salted
+// NIK hash
+func addPersonalData(ctx contractapi.TransactionContextInterface, objectType string, id string, enrolledBy *Enrollment) (string, error) {
+	personal, err := readTransientPersonalData(ctx)
+	if err != nil {
+		return "", err
+	}
+	if personal == nil {
+		return "", fmt.Errorf("the personal data of %s %s must be passed as %s transient data", objectType, id, personalTransientKey)
+	}
+
+	err = claimIdentity(ctx, IdentityNIK, personal.NIK, objectType, id)
+	if err != nil {
+		return "", err
+	}
+
+	return putPersonalData(ctx, objectType, id, enrolledBy.MSPID, personal)
+}
+
+// updatePersonalData replaces the personal data of an entity if new data was
+// passed as transient data, moving its NIK in the uniqueness index, and
+// returns the entity's salted NIK hash
+func updatePersonalData(ctx contractapi.TransactionContextInterface, objectType string, id string, enrolledBy *Enrollment, nikHash string) (string, error) {
+	personal, err := readTransientPersonalData(ctx)
+	if err != nil {
+		return "", err
+	}
+	if personal == nil {
+		return nikHash, nil
+	}
+
+	mspID, err := ownerMSPID(ctx, enrolledBy)
+	if err != nil {
+		return "", err
+	}
+
+	previous, err := getPersonalData(ctx, objectType, id, mspID)
+	if err != nil {
+		return "", err
+	}
+	previousNIK := ""
+	if previous != nil {
+		previousNIK = previous.NIK
+	}
+	err = reindexIdentity(ctx, IdentityNIK, previousNIK, personal.NIK, objectType, id)
+	if err != nil {
+		return "", err
+	}
+
+	return putPersonalData(ctx, objectType, id, mspID, personal)
+}
+
+// getPersonalData reads the personal data of an entity from the collection of
+// an organization, or returns nil if none is stored
+func getPersonalData(ctx contractapi.TransactionContextInterface, objectType string, id string, mspID string) (*PersonalData, error) {
+	err := verifyPeerOrg(mspID)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := entityKey(ctx, objectType, id)
+	if err != nil {
+		return nil, err
+	}
+
+	personalJSON, err := ctx.GetStub().GetPrivateData(personalDataCollection(mspID), key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read personal data: %v", err)
+	}
+	if personalJSON == nil {
+		return nil, nil
+	}
+
+	var personal PersonalData
+	err = json.Unmarshal(personalJSON, &personal)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal personal data JSON: %v", err)
+	}
+
+	return &personal, nil
+}
+
+// ownerMSPID returns the organization holding the personal data of an entity.
+// Entities registered before enrollments were recorded belong to the caller's
+// organization.
+func ownerMSPID(ctx contractapi.TransactionContextInterface, enrolledBy *Enrollment) (string, error) {
+	if enrolledBy != nil {
+		return enrolledBy.MSPID, nil
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", fmt.Errorf("failed to read client MSP ID: %v", err)
+	}
+
+	return mspID, nil
+}
+
+// authorizedPersonalData returns the personal data of an entity if the caller
+// is the identity that registered it or an admin of the same organization
+func authorizedPersonalData(ctx contractapi.TransactionContextInterface, function string, objectType string, id string, enrolledBy *Enrollment) (*PersonalData, error) {
+	err := authorizeOwner(ctx, function, enrolledBy)
+	if err != nil {
+		return nil, err
+	}
+
+	mspID, err := ownerMSPID(ctx, enrolledBy)
+	if err != nil {
+		return nil, err
+	}
+
+	personal, err := getPersonalData(ctx, objectType, id, mspID)
+	if err != nil {
+		return nil, err
+	}
+	if personal == nil {
+		return nil, fmt.Errorf("no personal data is stored for %s %s", objectType, id)
+	}
+
+	return personal, nil
+}
+
+// QueryFarmerDetails retrieves a farmer together with its personal data
+func (pc *PalmOilContract) QueryFarmerDetails(ctx contractapi.TransactionContextInterface, id string) (*FarmerDetails, error) {
+	err := authorize(ctx, "QueryFarmerDetails")
+	if err != nil {
+		return nil, err
+	}
+
+	farmer, err := pc.QueryFarmerByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	personal, err := authorizedPersonalData(ctx, "QueryFarmerDetails", farmerObjectType, id, farmer.EnrolledBy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FarmerDetails{Farmer: farmer, PersonalData: personal}, nil
+}
+
+// QueryCollectorDetails retrieves a collector together with its personal data
+func (pc *PalmOilContract) QueryCollectorDetails(ctx contractapi.TransactionContextInterface, id string) (*CollectorDetails, error) {
+	err := authorize(ctx, "QueryCollectorDetails")
+	if err != nil {
+		return nil, err
+	}
+
+	collector, err := pc.QueryCollectorByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	personal, err := authorizedPersonalData(ctx, "QueryCollectorDetails", collectorObjectType, id, collector.EnrolledBy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CollectorDetails{Collector: collector, PersonalData: personal}, nil
+}
+
+// QueryProcessorDetails retrieves a processor together with its personal data
+func (pc *PalmOilContract) QueryProcessorDetails(ctx contractapi.TransactionContextInterface, id string) (*ProcessorDetails, error) {
+	err := authorize(ctx, "QueryProcessorDetails")
+	if err != nil {
+		return nil, err
+	}
+
+	processor, err := pc.QueryProcessorByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	personal, err := authorizedPersonalData(ctx, "QueryProcessorDetails", processorObjectType, id, processor.EnrolledBy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProcessorDetails{Processor: processor, PersonalData: personal}, nil
+}
+
+// QueryTransporterDetails retrieves a transporter together with its personal data
+func (pc *PalmOilContract) QueryTransporterDetails(ctx contractapi.TransactionContextInterface, id string) (*TransporterDetails, error) {
+	err := authorize(ctx, "QueryTransporterDetails")
+	if err != nil {
+		return nil, err
+	}
+
+	transporter, err := pc.QueryTransporterByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	personal, err := authorizedPersonalData(ctx, "QueryTransporterDetails", transporterObjectType, id, transporter.EnrolledBy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TransporterDetails{Transporter: transporter, PersonalData: personal}, nil
+}
+
+// MigratePersonalData moves the personal data that entities registered by the
+// caller's organization still hold in the world state into the organization's
+// private data collection. The salt passed as transient data under "salt" is
+// combined with each entity's ID to derive that entity's NIK salt. Entities
+// registered by other organizations are skipped and must be migrated by them.
+// Earlier versions of the entities remain in the ledger history.
+func (pc *PalmOilContract) MigratePersonalData(ctx contractapi.TransactionContextInterface) (*KeyMigration, error) {
+	err := authorize(ctx, "MigratePersonalData")
+	if err != nil {
+		return nil, err
+	}
+
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transient data: %v", err)
+	}
+	migrationSalt := string(transientMap["salt"])
+	if len(migrationSalt) < minSaltLength {
+		return nil, fmt.Errorf("the salt transient data must be at least %d characters", minSaltLength)
+	}
+
+	callerMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client MSP ID: %v", err)
+	}
+
+	result := &KeyMigration{Skipped: []string{}}
+	objectTypes := []string{farmerObjectType, collectorObjectType, processorObjectType, transporterObjectType}
+	for _, objectType := range objectTypes {
+		resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(objectType, []string{})
+		if err != nil {
+			return nil, err
+		}
+
+		for resultsIterator.HasNext() {
+			queryResponse, err := resultsIterator.Next()
+			if err != nil {
+				resultsIterator.Close()
+				return nil, err
+			}
+
+			var fields map[string]json.RawMessage
+			err = json.Unmarshal(queryResponse.Value, &fields)
+			if err != nil {
+				resultsIterator.Close()
+				return nil, fmt.Errorf("failed to unmarshal %s JSON: %v", objectType, err)
+			}
+
+			var stored struct {
+				ID         string      `json:"id"`
+				NIK        string      `json:"nik"`
+				NoHP       string      `json:"noHP"`
+				Email      string      `json:"email"`
+				EnrolledBy *Enrollment `json:"enrolledBy"`
+			}
+			json.Unmarshal(queryResponse.Value, &stored)
+			if _, ok := fields["nik"]; !ok {
+				continue
+			}
+			if stored.EnrolledBy != nil && stored.EnrolledBy.MSPID != callerMSPID {
+				result.Skipped = append(result.Skipped, queryResponse.Key)
+				continue
+			}
+
+			saltSum := sha256.Sum256([]byte(migrationSalt + ":" + objectType + ":" + stored.ID))
+			nikHash, err := putPersonalData(ctx, objectType, stored.ID, callerMSPID, &PersonalData{
+				NIK:   stored.NIK,
+				NoHP:  stored.NoHP,
+				Email: stored.Email,
+				Salt:  hex.EncodeToString(saltSum[:]),
+			})
+			if err != nil {
+				resultsIterator.Close()
+				return nil, err
+			}
+
+			for _, field := range personalFields {
+				delete(fields, field)
+			}
+			fields["nikHash"], _ = json.Marshal(nikHash)
+			value, err := json.Marshal(fields)
+			if err != nil {
+				resultsIterator.Close()
+				return nil, err
+			}
+
+			err = ctx.GetStub().PutState(queryResponse.Key, value)
+			if err != nil {
+				resultsIterator.Close()
+				return nil, err
+			}
+			result.Migrated++
+		}
+		resultsIterator.Close()
+	}
+
+	err = emitEvent(ctx, EventPersonalDataMigrated, MigrationPayload{Migrated: result.Migrated, Skipped: len(result.Skipped)})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// redactPersonalFields removes the personal data fields from an entity JSON,
+// which older versions in the ledger history may still contain
+func redactPersonalFields(value string) (string, error) {
+	if value == "" {
+		return value, nil
+	}
+
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal([]byte(value), &fields)
+	if err != nil {
+		return "", err
+	}
+
+	redacted := false
+	for _, field := range personalFields {
+		if _, ok := fields[field]; ok {
+			delete(fields, field)
+			redacted = true
+		}
+	}
+	if !redacted {
+		return value, nil
+	}
+
+	redactedJSON, err := json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+
+	return string(redactedJSON), nil
+}
@@ -0,0 +1,200 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"chaincode-if/memledger"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestQueryDetails(t *testing.T) {
+	n := newTestNetwork(t)
+	n.addFarmer(farmerUser, "FRM_001")
+	n.addCollector(collectorUser, "COL_001", "1234567890123")
+	n.addProcessor(processorUser, "PRC_001", "1234567890124")
+	n.addTransporter(transporterUser, "TRP_001")
+
+	// details runs one of the detail queries and returns its personal data
+	type details func(n *testNetwork, ctx contractapi.TransactionContextInterface, id string) (*PersonalData, error)
+	farmerDetails := func(n *testNetwork, ctx contractapi.TransactionContextInterface, id string) (*PersonalData, error) {
+		d, err := n.contract.QueryFarmerDetails(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return d.PersonalData, nil
+	}
+	collectorDetails := func(n *testNetwork, ctx contractapi.TransactionContextInterface, id string) (*PersonalData, error) {
+		d, err := n.contract.QueryCollectorDetails(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return d.PersonalData, nil
+	}
+	processorDetails := func(n *testNetwork, ctx contractapi.TransactionContextInterface, id string) (*PersonalData, error) {
+		d, err := n.contract.QueryProcessorDetails(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return d.PersonalData, nil
+	}
+	transporterDetails := func(n *testNetwork, ctx contractapi.TransactionContextInterface, id string) (*PersonalData, error) {
+		d, err := n.contract.QueryTransporterDetails(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return d.PersonalData, nil
+	}
+
+	tests := []struct {
+		name    string
+		caller  *memledger.Identity
+		query   details
+		id      string
+		opts    []memledger.TxOption
+		wantNIK string
+		wantErr string
+	}{
+		{name: "farmer by itself", caller: farmerUser, query: farmerDetails, id: "FRM_001", wantNIK: "1471010101900001"},
+		{name: "farmer by admin", caller: org1Admin, query: farmerDetails, id: "FRM_001", wantNIK: "1471010101900001"},
+		{name: "farmer by another farmer", caller: otherFarmerUser, query: farmerDetails, id: "FRM_001", wantErr: "permission denied"},
+		{name: "farmer by admin of another org", caller: org2Admin, query: farmerDetails, id: "FRM_001", wantErr: "permission denied"},
+		{name: "farmer on peer of another org", caller: farmerUser, query: farmerDetails, id: "FRM_001", opts: []memledger.TxOption{memledger.WithPeerMSPID("Org2MSP")}, wantErr: "cannot be handled by a peer of Org2MSP"},
+		{name: "missing farmer", caller: org1Admin, query: farmerDetails, id: "FRM_404", wantErr: "does not exist"},
+		{name: "collector by itself", caller: collectorUser, query: collectorDetails, id: "COL_001", wantNIK: "1471010101900002"},
+		{name: "collector by farmer", caller: farmerUser, query: collectorDetails, id: "COL_001", wantErr: "permission denied"},
+		{name: "missing collector", caller: org1Admin, query: collectorDetails, id: "COL_404", wantErr: "does not exist"},
+		{name: "processor by itself", caller: processorUser, query: processorDetails, id: "PRC_001", wantNIK: "1471010101900003"},
+		{name: "processor by admin of another org", caller: org2Admin, query: processorDetails, id: "PRC_001", wantErr: "permission denied"},
+		{name: "missing processor", caller: org1Admin, query: processorDetails, id: "PRC_404", wantErr: "does not exist"},
+		{name: "transporter by itself", caller: transporterUser, query: transporterDetails, id: "TRP_001", wantNIK: "1471010101900004"},
+		{name: "transporter by collector", caller: collectorUser, query: transporterDetails, id: "TRP_001", wantErr: "permission denied"},
+		{name: "missing transporter", caller: org1Admin, query: transporterDetails, id: "TRP_404", wantErr: "does not exist"},
+		{name: "no role", caller: noRoleUser, query: farmerDetails, id: "FRM_001", wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			personal, err := evaluate(n, tt.caller, func(ctx contractapi.TransactionContextInterface) (*PersonalData, error) {
+				return tt.query(n, ctx, tt.id)
+			}, tt.opts...)
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr == "" && (personal.NIK != tt.wantNIK || personal.EntityID != tt.id || personal.Salt != testSalt) {
+				t.Errorf("unexpected personal data %+v", personal)
+			}
+		})
+	}
+}
+
+func TestQueryDetailsWithoutPersonalData(t *testing.T) {
+	n := newTestNetwork(t)
+	n.mustSubmit(org1Admin, func(ctx contractapi.TransactionContextInterface) error {
+		return putEntityState(ctx, farmerObjectType, "FRM_001", []byte(`{"id":"FRM_001","name":"Slamet","address":"Riau","farm":[]}`))
+	})
+
+	_, err := evaluate(n, org1Admin, func(ctx contractapi.TransactionContextInterface) (*FarmerDetails, error) {
+		return n.contract.QueryFarmerDetails(ctx, "FRM_001")
+	})
+	checkError(t, err, "no personal data is stored")
+}
+
+// putLegacyPersonalEntity stores an entity that still holds its personal data
+// in the world state
+func putLegacyPersonalEntity(n *testNetwork, objectType string, id string, enrolledBy *memledger.Identity) {
+	n.t.Helper()
+	entity := map[string]interface{}{"id": id, "name": "Legacy " + id, "nik": "1471010101900050", "noHP": "+6281200000000", "email": id + "@example.com"}
+	if enrolledBy != nil {
+		entity["enrolledBy"] = Enrollment{ClientID: "legacy", MSPID: enrolledBy.MSPID}
+	}
+	entityJSON, _ := json.Marshal(entity)
+	n.mustSubmit(org1Admin, func(ctx contractapi.TransactionContextInterface) error {
+		return putEntityState(ctx, objectType, id, entityJSON)
+	})
+}
+
+func TestMigratePersonalData(t *testing.T) {
+	salt := memledger.WithTransient(map[string][]byte{"salt": []byte("migration-salt-0001")})
+
+	tests := []struct {
+		name         string
+		caller       *memledger.Identity
+		opts         []memledger.TxOption
+		wantMigrated int
+		wantSkipped  []string
+		wantPrivate  int
+		wantErr      string
+	}{
+		{name: "own org", caller: org1Admin, opts: []memledger.TxOption{salt}, wantMigrated: 4, wantSkipped: []string{"\x00farmer\x00FRM_ORG2\x00"}, wantPrivate: 5},
+		{name: "other org", caller: org2Admin, opts: []memledger.TxOption{salt}, wantMigrated: 3, wantSkipped: []string{"\x00farmer\x00FRM_001\x00", "\x00collector\x00COL_001\x00"}, wantPrivate: 3},
+		{name: "no salt", caller: org1Admin, wantErr: "salt transient data must be at least"},
+		{name: "short salt", caller: org1Admin, opts: []memledger.TxOption{memledger.WithTransient(map[string][]byte{"salt": []byte("short")})}, wantErr: "salt transient data must be at least"},
+		{name: "farmer role", caller: farmerUser, opts: []memledger.TxOption{salt}, wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(t)
+			putLegacyPersonalEntity(n, farmerObjectType, "FRM_001", org1Admin)
+			putLegacyPersonalEntity(n, farmerObjectType, "FRM_ORG2", org2Admin)
+			putLegacyPersonalEntity(n, collectorObjectType, "COL_001", org1Admin)
+			putLegacyPersonalEntity(n, processorObjectType, "PRC_001", nil)
+			putLegacyPersonalEntity(n, transporterObjectType, "TRP_001", nil)
+			n.addFarmer(farmerUser, "FRM_002")
+
+			var result *KeyMigration
+			err := n.submit(tt.caller, func(ctx contractapi.TransactionContextInterface) error {
+				var err error
+				result, err = n.contract.MigratePersonalData(ctx)
+				return err
+			}, tt.opts...)
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+
+			if result.Migrated != tt.wantMigrated || strings.Join(result.Skipped, ",") != strings.Join(tt.wantSkipped, ",") {
+				t.Fatalf("expected %d migrated and %q skipped, got %+v", tt.wantMigrated, tt.wantSkipped, result)
+			}
+
+			collection := n.ledger.PrivateData(personalDataCollection(tt.caller.MSPID))
+			if len(collection) != tt.wantPrivate {
+				t.Errorf("expected %d entries in the collection of %s, got %d", tt.wantPrivate, tt.caller.MSPID, len(collection))
+			}
+			for _, kv := range n.ledger.WorldState() {
+				var fields map[string]json.RawMessage
+				json.Unmarshal(kv.Value, &fields)
+				if _, ok := fields["nik"]; !ok {
+					continue
+				}
+				owner := struct {
+					EnrolledBy *Enrollment `json:"enrolledBy"`
+				}{}
+				json.Unmarshal(kv.Value, &owner)
+				if owner.EnrolledBy == nil || owner.EnrolledBy.MSPID == tt.caller.MSPID {
+					t.Errorf("%q still holds a NIK after migration", kv.Key)
+				}
+			}
+		})
+	}
+}
+
+func TestMigratedPersonalDataIsReadable(t *testing.T) {
+	n := newTestNetwork(t)
+	putLegacyPersonalEntity(n, farmerObjectType, "FRM_001", org1Admin)
+	n.mustSubmit(org1Admin, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := n.contract.MigratePersonalData(ctx)
+		return err
+	}, memledger.WithTransient(map[string][]byte{"salt": []byte("migration-salt-0001")}))
+
+	details := mustEvaluate(n, org1Admin, func(ctx contractapi.TransactionContextInterface) (*FarmerDetails, error) {
+		return n.contract.QueryFarmerDetails(ctx, "FRM_001")
+	})
+	if details.PersonalData.NIK != "1471010101900050" || details.PersonalData.Email != "FRM_001@example.com" {
+		t.Fatalf("unexpected personal data %+v", details.PersonalData)
+	}
+	if details.Farmer.NIKHash != hashNIK(details.PersonalData.Salt, "1471010101900050") {
+		t.Fatalf("the NIK hash does not match the migrated salt: %+v", details.Farmer)
+	}
+}
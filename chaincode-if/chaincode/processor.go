@@ -9,36 +9,70 @@ import (
 
 // Processor represents the structure for a processor
 type Processor struct {
-	ID       string  `json:"id"`
-	Name     string  `json:"name"`
-	NIB      string  `json:"nib"`
-	NIK      string  `json:"nik"`
-	NoHP     string  `json:"noHP"`
-	Email    string  `json:"email"`
-	Address  string  `json:"address"`
-	Capacity float64 `json:"capacity"`
+	ID         string      `json:"id"`
+	Name       string      `json:"name"`
+	NIB        string      `json:"nib"`
+	NIKHash    string      `json:"nikHash"`
+	Address    string      `json:"address"`
+	Capacity   float64     `json:"capacity"`
+	EnrolledBy *Enrollment `json:"enrolledBy,omitempty" metadata:",optional"`
 }
 
 // AddProcessor adds a new processor to the ledger
-func (pc *PalmOilContract) AddProcessor(ctx contractapi.TransactionContextInterface, id string, name string, nib string, nik string, noHP string, email string, address string, capacity float64) error {
+func (pc *PalmOilContract) AddProcessor(ctx contractapi.TransactionContextInterface, id string, name string, nib string, address string, capacity float64) error {
+	err := authorize(ctx, "AddProcessor")
+	if err != nil {
+		return err
+	}
+
+	return addProcessor(ctx, Processor{ID: id, Name: name, NIB: nib, Address: address, Capacity: capacity})
+}
+
+// AddProcessorFromJSON adds a new processor to the ledger from a JSON processor document
+func (pc *PalmOilContract) AddProcessorFromJSON(ctx contractapi.TransactionContextInterface, processorJSON string) error {
+	err := authorize(ctx, "AddProcessorFromJSON")
+	if err != nil {
+		return err
+	}
+
+	var processor Processor
+	err = decodeDocument(processorObjectType, processorJSON, &processor)
+	if err != nil {
+		return err
+	}
+
+	return addProcessor(ctx, processor)
+}
+
+// addProcessor stores a new processor, enrolled by the caller
+func addProcessor(ctx contractapi.TransactionContextInterface, processor Processor) error {
 	// Check if a processor with the given ID already exists
-	existingProcessorJSON, err := ctx.GetStub().GetState(id)
+	existingProcessorJSON, err := getEntityState(ctx, processorObjectType, processor.ID)
 	if err != nil {
-		return fmt.Errorf("failed to read from world state: %v", err)
+		return err
 	}
 	if existingProcessorJSON != nil {
-		return fmt.Errorf("a processor with ID %s already exists", id)
+		return fmt.Errorf("a processor with ID %s already exists", processor.ID)
 	}
 
-	processor := Processor{
-		ID:       id,
-		Name:     name,
-		NIB:      nib,
-		NIK:      nik,
-		NoHP:     noHP,
-		Email:    email,
-		Address:  address,
-		Capacity: capacity,
+	processor.EnrolledBy, err = callerEnrollment(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = validateProcessor(ctx, &processor)
+	if err != nil {
+		return err
+	}
+
+	err = claimIdentity(ctx, IdentityNIB, processor.NIB, processorObjectType, processor.ID)
+	if err != nil {
+		return err
+	}
+
+	processor.NIKHash, err = addPersonalData(ctx, processorObjectType, processor.ID, processor.EnrolledBy)
+	if err != nil {
+		return err
 	}
 
 	processorJSON, err := json.Marshal(processor)
@@ -46,14 +80,72 @@ func (pc *PalmOilContract) AddProcessor(ctx contractapi.TransactionContextInterf
 		return err
 	}
 
-	return ctx.GetStub().PutState(id, processorJSON)
+	err = putEntityState(ctx, processorObjectType, processor.ID, processorJSON)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventProcessorAdded, processorPayload(&processor))
 }
 
 // UpdateProcessor updates an existing processor on the ledger
-func (pc *PalmOilContract) UpdateProcessor(ctx contractapi.TransactionContextInterface, id string, name string, nib string, nik string, noHP string, email string, address string, capacity float64) error {
-	processorJSON, err := ctx.GetStub().GetState(id)
+func (pc *PalmOilContract) UpdateProcessor(ctx contractapi.TransactionContextInterface, id string, name string, nib string, address string, capacity float64) error {
+	err := authorize(ctx, "UpdateProcessor")
+	if err != nil {
+		return err
+	}
+
+	return updateProcessor(ctx, "UpdateProcessor", id, func(processor *Processor) error {
+		processor.Name = name
+		processor.NIB = nib
+		processor.Address = address
+		processor.Capacity = capacity
+		return nil
+	})
+}
+
+// UpdateProcessorFromJSON replaces every attribute of an existing processor with those
+// of a JSON processor document
+func (pc *PalmOilContract) UpdateProcessorFromJSON(ctx contractapi.TransactionContextInterface, processorJSON string) error {
+	err := authorize(ctx, "UpdateProcessorFromJSON")
+	if err != nil {
+		return err
+	}
+
+	var update Processor
+	err = decodeDocument(processorObjectType, processorJSON, &update)
+	if err != nil {
+		return err
+	}
+
+	return updateProcessor(ctx, "UpdateProcessorFromJSON", update.ID, func(processor *Processor) error {
+		processor.Name = update.Name
+		processor.NIB = update.NIB
+		processor.Address = update.Address
+		processor.Capacity = update.Capacity
+		return nil
+	})
+}
+
+// PatchProcessor changes the attributes of an existing processor named in a JSON
+// patch document and leaves the others unchanged
+func (pc *PalmOilContract) PatchProcessor(ctx contractapi.TransactionContextInterface, id string, patchJSON string) error {
+	err := authorize(ctx, "PatchProcessor")
 	if err != nil {
-		return fmt.Errorf("failed to read from world state: %v", err)
+		return err
+	}
+
+	return updateProcessor(ctx, "PatchProcessor", id, func(processor *Processor) error {
+		return applyPatch(processorObjectType, processor, patchJSON)
+	})
+}
+
+// updateProcessor changes the attributes of an existing processor with update,
+// after checking the caller may change it
+func updateProcessor(ctx contractapi.TransactionContextInterface, function string, id string, update func(processor *Processor) error) error {
+	processorJSON, err := getEntityState(ctx, processorObjectType, id)
+	if err != nil {
+		return err
 	}
 	if processorJSON == nil {
 		return fmt.Errorf("the processor with ID %s does not exist", id)
@@ -62,28 +154,56 @@ func (pc *PalmOilContract) UpdateProcessor(ctx contractapi.TransactionContextInt
 	var processor Processor
 	json.Unmarshal(processorJSON, &processor)
 
+	err = authorizeOwner(ctx, function, processor.EnrolledBy)
+	if err != nil {
+		return err
+	}
+
 	// Update the processor's attributes
-	processor.Name = name
-	processor.NIB = nib
-	processor.NIK = nik
-	processor.NoHP = noHP
-	processor.Email = email
-	processor.Address = address
-	processor.Capacity = capacity
+	previousNIB := processor.NIB
+	err = update(&processor)
+	if err != nil {
+		return err
+	}
+
+	err = validateProcessor(ctx, &processor)
+	if err != nil {
+		return err
+	}
+
+	err = reindexIdentity(ctx, IdentityNIB, previousNIB, processor.NIB, processorObjectType, id)
+	if err != nil {
+		return err
+	}
+
+	processor.NIKHash, err = updatePersonalData(ctx, processorObjectType, id, processor.EnrolledBy, processor.NIKHash)
+	if err != nil {
+		return err
+	}
 
 	processorJSON, err = json.Marshal(processor)
 	if err != nil {
 		return err
 	}
 
-	return ctx.GetStub().PutState(id, processorJSON)
+	err = putEntityState(ctx, processorObjectType, id, processorJSON)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventProcessorUpdated, processorPayload(&processor))
 }
 
 // QueryProcessorByID retrieves a processor by its ID from the ledger
 func (pc *PalmOilContract) QueryProcessorByID(ctx contractapi.TransactionContextInterface, id string) (*Processor, error) {
-	processorJSON, err := ctx.GetStub().GetState(id)
+	err := authorize(ctx, "QueryProcessorByID")
 	if err != nil {
-		return nil, fmt.Errorf("failed to read from world state: %v", err)
+		return nil, err
+	}
+
+	processorJSON, err := getEntityState(ctx, processorObjectType, id)
+	if err != nil {
+		return nil, err
 	}
 	if processorJSON == nil {
 		return nil, fmt.Errorf("the processor with ID %s does not exist", id)
@@ -97,23 +217,10 @@ func (pc *PalmOilContract) QueryProcessorByID(ctx contractapi.TransactionContext
 
 // QueryAllProcessors retrieves all processors from the ledger
 func (pc *PalmOilContract) QueryAllProcessors(ctx contractapi.TransactionContextInterface) ([]*Processor, error) {
-	resultsIterator, err := ctx.GetStub().GetStateByRange("PRO_", "PRO_zzzzzzzzzz")
+	err := authorize(ctx, "QueryAllProcessors")
 	if err != nil {
 		return nil, err
 	}
-	defer resultsIterator.Close()
-
-	var processors []*Processor
-	for resultsIterator.HasNext() {
-		queryResponse, err := resultsIterator.Next()
-		if err != nil {
-			return nil, err
-		}
-
-		var processor Processor
-		json.Unmarshal(queryResponse.Value, &processor)
-		processors = append(processors, &processor)
-	}
 
-	return processors, nil
+	return queryAllEntities[Processor](ctx, processorObjectType)
 }
@@ -0,0 +1,124 @@
+package chaincode
+
+import (
+	"testing"
+
+	"chaincode-if/memledger"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestAddProcessor(t *testing.T) {
+	tests := []struct {
+		name    string
+		caller  *memledger.Identity
+		id      string
+		opts    []memledger.TxOption
+		wantErr string
+	}{
+		{name: "processor", caller: processorUser, id: "PRC_002", opts: []memledger.TxOption{personal("1471010101900011")}},
+		{name: "admin", caller: org1Admin, id: "PRC_002", opts: []memledger.TxOption{personal("1471010101900011")}},
+		{name: "duplicate ID", caller: processorUser, id: "PRC_001", opts: []memledger.TxOption{personal("1471010101900011")}, wantErr: "already exists"},
+		{name: "no personal data", caller: processorUser, id: "PRC_002", wantErr: "transient data"},
+		{name: "personal data without NIK", caller: processorUser, id: "PRC_002", opts: []memledger.TxOption{memledger.WithTransient(map[string][]byte{personalTransientKey: []byte(`{"salt":"0123456789abcdef"}`)})}, wantErr: "must include a nik"},
+		{name: "collector role", caller: collectorUser, id: "PRC_002", opts: []memledger.TxOption{personal("1471010101900011")}, wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(t)
+			n.addProcessor(processorUser, "PRC_001", "1234567890123")
+
+			err := n.submit(tt.caller, func(ctx contractapi.TransactionContextInterface) error {
+				return n.contract.AddProcessor(ctx, tt.id, "PKS Dumai", "9876543210987", "Dumai", 60)
+			}, tt.opts...)
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+
+			processor := mustEvaluate(n, tt.caller, func(ctx contractapi.TransactionContextInterface) (*Processor, error) {
+				return n.contract.QueryProcessorByID(ctx, tt.id)
+			})
+			if processor.NIB != "9876543210987" || processor.Capacity != 60 || processor.NIKHash != hashNIK(testSalt, "1471010101900011") {
+				t.Errorf("unexpected processor %+v", processor)
+			}
+		})
+	}
+}
+
+func TestUpdateProcessor(t *testing.T) {
+	tests := []struct {
+		name    string
+		caller  *memledger.Identity
+		id      string
+		wantErr string
+	}{
+		{name: "enrolling processor", caller: processorUser, id: "PRC_001"},
+		{name: "admin", caller: org1Admin, id: "PRC_001"},
+		{name: "missing processor", caller: processorUser, id: "PRC_404", wantErr: "does not exist"},
+		{name: "admin of another org", caller: org2Admin, id: "PRC_001", wantErr: "permission denied"},
+		{name: "transporter role", caller: transporterUser, id: "PRC_001", wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(t)
+			n.addProcessor(processorUser, "PRC_001", "1234567890123")
+
+			err := n.submit(tt.caller, func(ctx contractapi.TransactionContextInterface) error {
+				return n.contract.UpdateProcessor(ctx, tt.id, "PKS Dumai Baru", "1234567890123", "Dumai", 90)
+			})
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+
+			processor := mustEvaluate(n, tt.caller, func(ctx contractapi.TransactionContextInterface) (*Processor, error) {
+				return n.contract.QueryProcessorByID(ctx, tt.id)
+			})
+			if processor.Name != "PKS Dumai Baru" || processor.Capacity != 90 {
+				t.Errorf("the processor was not updated: %+v", processor)
+			}
+		})
+	}
+}
+
+func TestQueryProcessorByID(t *testing.T) {
+	n := newTestNetwork(t)
+	n.addProcessor(processorUser, "PRC_001", "1234567890123")
+
+	tests := []struct {
+		name    string
+		caller  *memledger.Identity
+		id      string
+		wantErr string
+	}{
+		{name: "existing", caller: farmerUser, id: "PRC_001"},
+		{name: "missing", caller: farmerUser, id: "PRC_404", wantErr: "does not exist"},
+		{name: "no role", caller: noRoleUser, id: "PRC_001", wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor, err := evaluate(n, tt.caller, func(ctx contractapi.TransactionContextInterface) (*Processor, error) {
+				return n.contract.QueryProcessorByID(ctx, tt.id)
+			})
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr == "" && processor.ID != tt.id {
+				t.Errorf("expected %s, got %+v", tt.id, processor)
+			}
+		})
+	}
+}
+
+func TestQueryAllProcessors(t *testing.T) {
+	n := newTestNetwork(t)
+	n.addProcessor(processorUser, "PRC_001", "1234567890123")
+	n.addProcessor(org1Admin, "PRC_002", "1234567890124")
+
+	processors := mustEvaluate(n, farmerUser, n.contract.QueryAllProcessors)
+	if len(processors) != 2 || processors[0].ID != "PRC_001" || processors[1].ID != "PRC_002" {
+		t.Fatalf("expected PRC_001 and PRC_002, got %+v", processors)
+	}
+}
@@ -10,9 +10,14 @@ import (
 // ... [Your Other Struct Definitions Here] ...
 
 func (pc *PalmOilContract) QueryCommodityByID(ctx contractapi.TransactionContextInterface, commodityID string) (*Commodity, error) {
-	commodityJSON, err := ctx.GetStub().GetState(commodityID)
+	err := authorize(ctx, "QueryCommodityByID")
 	if err != nil {
-		return nil, fmt.Errorf("failed to read from world state: %v", err)
+		return nil, err
+	}
+
+	commodityJSON, err := getEntityState(ctx, commodityObjectType, commodityID)
+	if err != nil {
+		return nil, err
 	}
 	if commodityJSON == nil {
 		return nil, fmt.Errorf("the commodity with ID %s does not exist", commodityID)
@@ -29,50 +34,44 @@ func (pc *PalmOilContract) QueryCommodityByID(ctx contractapi.TransactionContext
 
 // QueryAllCommodities retrieves all commodities from the ledger
 func (pc *PalmOilContract) QueryAllCommodities(ctx contractapi.TransactionContextInterface) ([]*Commodity, error) {
-	resultsIterator, err := ctx.GetStub().GetStateByRange("COM_", "COM_zzzzzzzzzz")
+	err := authorize(ctx, "QueryAllCommodities")
 	if err != nil {
 		return nil, err
 	}
-	defer resultsIterator.Close()
 
-	var commodities []*Commodity
-	for resultsIterator.HasNext() {
-		queryResponse, err := resultsIterator.Next()
-		if err != nil {
-			return nil, err
-		}
+	return queryAllEntities[Commodity](ctx, commodityObjectType)
+}
 
-		var commodity Commodity
-		err = json.Unmarshal(queryResponse.Value, &commodity)
-		if err != nil {
-			return nil, fmt.Errorf("failed to unmarshal commodity JSON: %v", err)
-		}
+// QueryProcessedCommodityByID retrieves a processed commodity by its ID from the ledger
+func (pc *PalmOilContract) QueryProcessedCommodityByID(ctx contractapi.TransactionContextInterface, processedID string) (*ProcessedCommodity, error) {
+	err := authorize(ctx, "QueryProcessedCommodityByID")
+	if err != nil {
+		return nil, err
+	}
 
-		commodities = append(commodities, &commodity)
+	processedJSON, err := getEntityState(ctx, processedObjectType, processedID)
+	if err != nil {
+		return nil, err
+	}
+	if processedJSON == nil {
+		return nil, fmt.Errorf("the processed commodity with ID %s does not exist", processedID)
+	}
+
+	var processedCommodity ProcessedCommodity
+	err = json.Unmarshal(processedJSON, &processedCommodity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal processed commodity JSON: %v", err)
 	}
 
-	return commodities, nil
+	return &processedCommodity, nil
 }
 
 // QueryAllProcessedCommodities retrieves all processed commodities from the ledger
 func (pc *PalmOilContract) QueryAllProcessedCommodities(ctx contractapi.TransactionContextInterface) ([]*ProcessedCommodity, error) {
-	resultsIterator, err := ctx.GetStub().GetStateByRange("PCD_", "PCD_zzzzzzzzzz")
+	err := authorize(ctx, "QueryAllProcessedCommodities")
 	if err != nil {
 		return nil, err
 	}
-	defer resultsIterator.Close()
 
-	var processedCommodities []*ProcessedCommodity
-	for resultsIterator.HasNext() {
-		queryResponse, err := resultsIterator.Next()
-		if err != nil {
-			return nil, err
-		}
-
-		var processedCommodity ProcessedCommodity
-		json.Unmarshal(queryResponse.Value, &processedCommodity)
-		processedCommodities = append(processedCommodities, &processedCommodity)
-	}
-
-	return processedCommodities, nil
-}
\ No newline at end of file
+	return queryAllEntities[ProcessedCommodity](ctx, processedObjectType)
+}
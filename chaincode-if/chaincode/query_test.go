@@ -0,0 +1,96 @@
+package chaincode
+
+import (
+	"testing"
+
+	"chaincode-if/memledger"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestQueryCommodityByID(t *testing.T) {
+	n := newTestNetwork(t)
+	n.seedSupplyChain()
+
+	tests := []struct {
+		name    string
+		caller  *memledger.Identity
+		id      string
+		wantErr string
+	}{
+		{name: "existing", caller: transporterUser, id: "COM_001"},
+		{name: "missing", caller: transporterUser, id: "COM_404", wantErr: "does not exist"},
+		{name: "empty ID", caller: transporterUser, id: "", wantErr: "must not be empty"},
+		{name: "no role", caller: noRoleUser, id: "COM_001", wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commodity, err := evaluate(n, tt.caller, func(ctx contractapi.TransactionContextInterface) (*Commodity, error) {
+				return n.contract.QueryCommodityByID(ctx, tt.id)
+			})
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr == "" && (commodity.State != StateDelivered || len(commodity.Traceability.Events) != 4) {
+				t.Errorf("unexpected commodity %+v", commodity)
+			}
+		})
+	}
+}
+
+func TestQueryAllCommodities(t *testing.T) {
+	n := newTestNetwork(t)
+	n.seedSupplyChain()
+
+	commodities := mustEvaluate(n, farmerUser, n.contract.QueryAllCommodities)
+	if len(commodities) != 2 || commodities[0].ID != "COM_001" || commodities[1].ID != "COM_002" {
+		t.Fatalf("expected COM_001 and COM_002, got %+v", commodities)
+	}
+}
+
+func TestQueryProcessedCommodityByID(t *testing.T) {
+	n := newTestNetwork(t)
+	n.seedSupplyChain()
+	n.process("PCD_001", 50, "COM_001", "COM_002")
+
+	tests := []struct {
+		name    string
+		caller  *memledger.Identity
+		id      string
+		wantErr string
+	}{
+		{name: "existing", caller: farmerUser, id: "PCD_001"},
+		{name: "missing", caller: farmerUser, id: "PCD_404", wantErr: "does not exist"},
+		{name: "commodity ID", caller: farmerUser, id: "COM_001", wantErr: "does not exist"},
+		{name: "no role", caller: noRoleUser, id: "PCD_001", wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batch, err := evaluate(n, tt.caller, func(ctx contractapi.TransactionContextInterface) (*ProcessedCommodity, error) {
+				return n.contract.QueryProcessedCommodityByID(ctx, tt.id)
+			})
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr == "" && (batch.Processor != "PRC_001" || len(batch.Material) != 2) {
+				t.Errorf("unexpected processed commodity %+v", batch)
+			}
+		})
+	}
+}
+
+func TestQueryAllProcessedCommodities(t *testing.T) {
+	n := newTestNetwork(t)
+	n.seedSupplyChain()
+
+	batches := mustEvaluate(n, farmerUser, n.contract.QueryAllProcessedCommodities)
+	if len(batches) != 0 {
+		t.Fatalf("expected no processed commodities, got %d", len(batches))
+	}
+
+	n.process("PCD_001", 25, "COM_001")
+	n.process("PCD_002", 25, "COM_002")
+
+	batches = mustEvaluate(n, farmerUser, n.contract.QueryAllProcessedCommodities)
+	if len(batches) != 2 || batches[0].ID != "PCD_001" || batches[1].ID != "PCD_002" {
+		t.Fatalf("expected PCD_001 and PCD_002, got %+v", batches)
+	}
+}
@@ -0,0 +1,140 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Recall records the commodities and processed commodities withdrawn because
+// of a non-compliant farm, commodity or batch
+type Recall struct {
+	ID          string   `json:"id"`
+	OriginType  string   `json:"originType"`
+	OriginID    string   `json:"originId"`
+	Reason      string   `json:"reason"`
+	Commodities []string `json:"commodities"`
+	Batches     []string `json:"batches"`
+	Timestamp   string   `json:"timestamp"`
+	TxID        string   `json:"txId"`
+	MSPID       string   `json:"mspId"`
+}
+
+// RecallBatch marks every commodity and processed commodity downstream of a
+// farm, commodity or batch as recalled. Recalled commodities cannot move
+// through the lifecycle any further, so they can no longer be processed.
+func (pc *PalmOilContract) RecallBatch(ctx contractapi.TransactionContextInterface, recallID string, originType string, originID string, reason string, pic string) error {
+	err := authorize(ctx, "RecallBatch")
+	if err != nil {
+		return err
+	}
+
+	// Check if a recall with the given ID already exists
+	existingRecallJSON, err := getEntityState(ctx, recallObjectType, recallID)
+	if err != nil {
+		return err
+	}
+	if existingRecallJSON != nil {
+		return fmt.Errorf("a recall with ID %s already exists", recallID)
+	}
+
+	trace, err := pc.traceForward(ctx, originType, originID)
+	if err != nil {
+		return err
+	}
+
+	event, err := newTraceEvent(ctx, string(StateRecalled), pic, "")
+	if err != nil {
+		return err
+	}
+
+	recall := Recall{
+		ID:          recallID,
+		OriginType:  originType,
+		OriginID:    originID,
+		Reason:      reason,
+		Commodities: []string{},
+		Batches:     []string{},
+		Timestamp:   event.Timestamp,
+		TxID:        event.TxID,
+		MSPID:       event.MSPID,
+	}
+
+	for i := range trace.Commodities {
+		commodity := &trace.Commodities[i]
+		state := currentState(commodity)
+		if state == StateRecalled || state == StateRejected {
+			continue
+		}
+
+		err = moveCommodity(commodity, StateRecalled, event)
+		if err != nil {
+			return err
+		}
+		commodity.RecallID = recallID
+
+		err = writeCommodity(ctx, commodity)
+		if err != nil {
+			return err
+		}
+		recall.Commodities = append(recall.Commodities, commodity.ID)
+	}
+
+	for i := range trace.Batches {
+		batch := &trace.Batches[i]
+		if batch.Status == BatchRecalled {
+			continue
+		}
+
+		batch.Status = BatchRecalled
+		batch.RecallID = recallID
+		batch.Events = append(batch.Events, event)
+
+		batchJSON, err := json.Marshal(batch)
+		if err != nil {
+			return fmt.Errorf("failed to marshal processed commodity: %v", err)
+		}
+		err = putEntityState(ctx, processedObjectType, batch.ID, batchJSON)
+		if err != nil {
+			return err
+		}
+		recall.Batches = append(recall.Batches, batch.ID)
+	}
+
+	recallJSON, err := json.Marshal(recall)
+	if err != nil {
+		return err
+	}
+
+	err = putEntityState(ctx, recallObjectType, recallID, recallJSON)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventBatchRecalled, recall)
+}
+
+// QueryRecallByID retrieves a recall by its ID from the ledger
+func (pc *PalmOilContract) QueryRecallByID(ctx contractapi.TransactionContextInterface, recallID string) (*Recall, error) {
+	err := authorize(ctx, "QueryRecallByID")
+	if err != nil {
+		return nil, err
+	}
+
+	recallJSON, err := getEntityState(ctx, recallObjectType, recallID)
+	if err != nil {
+		return nil, err
+	}
+	if recallJSON == nil {
+		return nil, fmt.Errorf("the recall with ID %s does not exist", recallID)
+	}
+
+	var recall Recall
+	err = json.Unmarshal(recallJSON, &recall)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal recall JSON: %v", err)
+	}
+
+	return &recall, nil
+}
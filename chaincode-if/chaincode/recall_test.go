@@ -0,0 +1,132 @@
+package chaincode
+
+import (
+	"reflect"
+	"testing"
+
+	"chaincode-if/memledger"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestRecallBatch(t *testing.T) {
+	tests := []struct {
+		name            string
+		caller          *memledger.Identity
+		recallID        string
+		originType      string
+		originID        string
+		wantCommodities []string
+		wantBatches     []string
+		wantErr         string
+	}{
+		{name: "farm", caller: org1Admin, recallID: "RCL_002", originType: OriginFarm, originID: "FARM_001", wantCommodities: []string{"COM_001", "COM_002"}, wantBatches: []string{"PCD_001"}},
+		{name: "commodity", caller: org1Admin, recallID: "RCL_002", originType: OriginCommodity, originID: "COM_002", wantCommodities: []string{"COM_002"}, wantBatches: []string{}},
+		{name: "batch", caller: org1Admin, recallID: "RCL_002", originType: OriginBatch, originID: "PCD_001", wantCommodities: []string{}, wantBatches: []string{"PCD_001"}},
+		{name: "duplicate ID", caller: org1Admin, recallID: "RCL_001", originType: OriginFarm, originID: "FARM_001", wantErr: "already exists"},
+		{name: "unknown origin type", caller: org1Admin, recallID: "RCL_002", originType: "farmer", originID: "FRM_001", wantErr: "unknown origin type"},
+		{name: "missing farm", caller: org1Admin, recallID: "RCL_002", originType: OriginFarm, originID: "FARM_404", wantErr: "does not exist"},
+		{name: "missing commodity", caller: org1Admin, recallID: "RCL_002", originType: OriginCommodity, originID: "COM_404", wantErr: "does not exist"},
+		{name: "missing batch", caller: org1Admin, recallID: "RCL_002", originType: OriginBatch, originID: "PCD_404", wantErr: "does not exist"},
+		{name: "processor role", caller: processorUser, recallID: "RCL_002", originType: OriginFarm, originID: "FARM_001", wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(t)
+			n.seedSupplyChain()
+			n.process("PCD_001", 25, "COM_001")
+			n.harvest("COM_003", "FARM_001", 100, "2024-01-12")
+			n.mustSubmit(org1Admin, func(ctx contractapi.TransactionContextInterface) error {
+				return n.contract.RecallBatch(ctx, "RCL_001", OriginCommodity, "COM_003", "contamination", "Dewi")
+			})
+
+			err := n.submit(tt.caller, func(ctx contractapi.TransactionContextInterface) error {
+				return n.contract.RecallBatch(ctx, tt.recallID, tt.originType, tt.originID, "illegal clearing", "Dewi")
+			})
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+
+			recall := mustEvaluate(n, farmerUser, func(ctx contractapi.TransactionContextInterface) (*Recall, error) {
+				return n.contract.QueryRecallByID(ctx, tt.recallID)
+			})
+			if !reflect.DeepEqual(recall.Commodities, tt.wantCommodities) || !reflect.DeepEqual(recall.Batches, tt.wantBatches) {
+				t.Errorf("expected commodities %v and batches %v, got %+v", tt.wantCommodities, tt.wantBatches, recall)
+			}
+
+			for _, commodityID := range tt.wantCommodities {
+				commodity := mustEvaluate(n, farmerUser, func(ctx contractapi.TransactionContextInterface) (*Commodity, error) {
+					return n.contract.QueryCommodityByID(ctx, commodityID)
+				})
+				if commodity.State != StateRecalled || commodity.RecallID != tt.recallID {
+					t.Errorf("commodity %s was not recalled: %+v", commodityID, commodity)
+				}
+			}
+			for _, batchID := range tt.wantBatches {
+				batch := mustEvaluate(n, farmerUser, func(ctx contractapi.TransactionContextInterface) (*ProcessedCommodity, error) {
+					return n.contract.QueryProcessedCommodityByID(ctx, batchID)
+				})
+				if batch.Status != BatchRecalled || batch.RecallID != tt.recallID {
+					t.Errorf("batch %s was not recalled: %+v", batchID, batch)
+				}
+			}
+		})
+	}
+}
+
+func TestRecallBlocksProcessing(t *testing.T) {
+	n := newTestNetwork(t)
+	n.seedSupplyChain()
+	n.mustSubmit(org1Admin, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.RecallBatch(ctx, "RCL_001", OriginCommodity, "COM_002", "contamination", "Dewi")
+	})
+
+	err := n.submit(processorUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.Process(ctx, "PCD_001", "PRC_001", 25, `["COM_002"]`, "B-001", "A", "Rina", "Dumai")
+	})
+	checkError(t, err, `cannot move from "recalled" to "processed"`)
+
+	// Recalling again skips what was already recalled
+	n.mustSubmit(org1Admin, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.RecallBatch(ctx, "RCL_002", OriginFarm, "FARM_001", "illegal clearing", "Dewi")
+	})
+	recall := mustEvaluate(n, org1Admin, func(ctx contractapi.TransactionContextInterface) (*Recall, error) {
+		return n.contract.QueryRecallByID(ctx, "RCL_002")
+	})
+	if !reflect.DeepEqual(recall.Commodities, []string{"COM_001"}) {
+		t.Fatalf("expected only COM_001 to be recalled again, got %v", recall.Commodities)
+	}
+}
+
+func TestQueryRecallByID(t *testing.T) {
+	n := newTestNetwork(t)
+	n.seedSupplyChain()
+	n.mustSubmit(org1Admin, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.RecallBatch(ctx, "RCL_001", OriginFarm, "FARM_001", "illegal clearing", "Dewi")
+	})
+
+	tests := []struct {
+		name    string
+		caller  *memledger.Identity
+		id      string
+		wantErr string
+	}{
+		{name: "existing", caller: collectorUser, id: "RCL_001"},
+		{name: "missing", caller: collectorUser, id: "RCL_404", wantErr: "does not exist"},
+		{name: "no role", caller: noRoleUser, id: "RCL_001", wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recall, err := evaluate(n, tt.caller, func(ctx contractapi.TransactionContextInterface) (*Recall, error) {
+				return n.contract.QueryRecallByID(ctx, tt.id)
+			})
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr == "" && (recall.Reason != "illegal clearing" || recall.MSPID != "Org1MSP" || recall.TxID == "") {
+				t.Errorf("unexpected recall %+v", recall)
+			}
+		})
+	}
+}
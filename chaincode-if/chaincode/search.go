@@ -0,0 +1,186 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// The queries below use CouchDB Mango selectors and need CouchDB as the state
+// database. Each is backed by an index in META-INF/statedb/couchdb/indexes, or
+// in META-INF/statedb/couchdb/collections for queries on private data.
+
+// mangoQuery builds a rich query for the given selector, optionally pinned to
+// an index design document
+func mangoQuery(selector map[string]interface{}, designDoc string) (string, error) {
+	query := map[string]interface{}{"selector": selector}
+	if designDoc != "" {
+		query["use_index"] = designDoc
+	}
+
+	queryJSON, err := json.Marshal(query)
+	if err != nil {
+		return "", fmt.Errorf("failed to build query: %v", err)
+	}
+
+	return string(queryJSON), nil
+}
+
+// queryEntities runs a rich query and decodes every matching entity
+func queryEntities[T any](ctx contractapi.TransactionContextInterface, objectType string, selector map[string]interface{}, designDoc string) ([]*T, error) {
+	selector["docType"] = objectType
+	query, err := mangoQuery(selector, designDoc)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetQueryResult(query)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	return decodeEntities[T](resultsIterator, objectType)
+}
+
+// QueryFarmersByNIK retrieves the farmers registered by the caller's
+// organization with a NIK. NIKs are private data, so farmers registered by
+// other organizations are not found.
+func (pc *PalmOilContract) QueryFarmersByNIK(ctx contractapi.TransactionContextInterface, nik string) ([]*Farmer, error) {
+	err := authorize(ctx, "QueryFarmersByNIK")
+	if err != nil {
+		return nil, err
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client MSP ID: %v", err)
+	}
+	err = verifyPeerOrg(mspID)
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := mangoQuery(map[string]interface{}{"entityType": farmerObjectType, "nik": nik}, "indexPersonalNIKDoc")
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetPrivateDataQueryResult(personalDataCollection(mspID), query)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	personalData, err := decodeEntities[PersonalData](resultsIterator, "personal data")
+	if err != nil {
+		return nil, err
+	}
+
+	farmers := []*Farmer{}
+	for _, personal := range personalData {
+		farmer, err := pc.QueryFarmerByID(ctx, personal.EntityID)
+		if err != nil {
+			return nil, err
+		}
+		farmers = append(farmers, farmer)
+	}
+
+	return farmers, nil
+}
+
+// QueryCollectorsByNIB retrieves the collectors registered with a NIB
+func (pc *PalmOilContract) QueryCollectorsByNIB(ctx contractapi.TransactionContextInterface, nib string) ([]*Collector, error) {
+	err := authorize(ctx, "QueryCollectorsByNIB")
+	if err != nil {
+		return nil, err
+	}
+
+	return queryEntities[Collector](ctx, collectorObjectType, map[string]interface{}{"nib": nib}, "indexNIBDoc")
+}
+
+// QueryProcessorsByNIB retrieves the processors registered with a NIB
+func (pc *PalmOilContract) QueryProcessorsByNIB(ctx contractapi.TransactionContextInterface, nib string) ([]*Processor, error) {
+	err := authorize(ctx, "QueryProcessorsByNIB")
+	if err != nil {
+		return nil, err
+	}
+
+	return queryEntities[Processor](ctx, processorObjectType, map[string]interface{}{"nib": nib}, "indexNIBDoc")
+}
+
+// QueryFarmsByAttributes retrieves the farms matching a legality, certificate
+// and seed variety. Empty arguments match any value.
+func (pc *PalmOilContract) QueryFarmsByAttributes(ctx contractapi.TransactionContextInterface, legality string, certificate string, seedVarieties string) ([]*Farm, error) {
+	err := authorize(ctx, "QueryFarmsByAttributes")
+	if err != nil {
+		return nil, err
+	}
+
+	selector := map[string]interface{}{}
+	if legality != "" {
+		selector["legality"] = legality
+	}
+	if certificate != "" {
+		selector["certificate"] = certificate
+	}
+	if seedVarieties != "" {
+		selector["seedVarieties"] = seedVarieties
+	}
+
+	return queryEntities[Farm](ctx, farmObjectType, selector, "")
+}
+
+// QueryCommoditiesByState retrieves the commodities currently in a lifecycle state
+func (pc *PalmOilContract) QueryCommoditiesByState(ctx contractapi.TransactionContextInterface, state string) ([]*Commodity, error) {
+	err := authorize(ctx, "QueryCommoditiesByState")
+	if err != nil {
+		return nil, err
+	}
+
+	return queryEntities[Commodity](ctx, commodityObjectType, map[string]interface{}{"state": state}, "indexCommodityStateDoc")
+}
+
+// QueryCommoditiesByStateWithPagination retrieves one page of the commodities
+// currently in a lifecycle state
+func (pc *PalmOilContract) QueryCommoditiesByStateWithPagination(ctx contractapi.TransactionContextInterface, state string, pageSize int32, bookmark string) (*CommodityPage, error) {
+	err := authorize(ctx, "QueryCommoditiesByStateWithPagination")
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := mangoQuery(map[string]interface{}{"docType": commodityObjectType, "state": state}, "indexCommodityStateDoc")
+	if err != nil {
+		return nil, err
+	}
+
+	records, metadata, err := queryResultPage[Commodity](ctx, commodityObjectType, query, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CommodityPage{
+		Records:             records,
+		FetchedRecordsCount: metadata.FetchedRecordsCount,
+		Bookmark:            metadata.Bookmark,
+	}, nil
+}
+
+// QueryCommoditiesByHarvestDate retrieves the commodities harvested between two
+// dates, inclusive. Dates are compared as strings, so they must use the same
+// sortable format as DateHarvested, e.g. YYYY-MM-DD. An empty bound is open.
+func (pc *PalmOilContract) QueryCommoditiesByHarvestDate(ctx contractapi.TransactionContextInterface, from string, to string) ([]*Commodity, error) {
+	err := authorize(ctx, "QueryCommoditiesByHarvestDate")
+	if err != nil {
+		return nil, err
+	}
+
+	// Every string sorts at or after the empty string in CouchDB collation
+	dateRange := map[string]interface{}{"$gte": from}
+	if to != "" {
+		dateRange["$lte"] = to
+	}
+
+	return queryEntities[Commodity](ctx, commodityObjectType, map[string]interface{}{"dateHarvested": dateRange}, "indexCommodityHarvestDateDoc")
+}
@@ -0,0 +1,216 @@
+package chaincode
+
+import (
+	"reflect"
+	"testing"
+
+	"chaincode-if/memledger"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestQueryFarmersByNIK(t *testing.T) {
+	n := newTestNetwork(t)
+	n.addFarmer(farmerUser, "FRM_001")
+	n.mustSubmit(otherFarmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.AddFarmer(ctx, "FRM_002", "Joko", "Jambi", `[]`)
+	}, personal("1471010101900077"))
+	n.mustSubmit(org2Admin, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.AddFarmer(ctx, "FRM_003", "Wayan", "Bali", `[]`)
+	}, personal("1471010101900078"))
+
+	tests := []struct {
+		name    string
+		caller  *memledger.Identity
+		nik     string
+		opts    []memledger.TxOption
+		want    []string
+		wantErr string
+	}{
+		{name: "registered by own org", caller: collectorUser, nik: "1471010101900001", want: []string{"FRM_001"}},
+		{name: "registered by another org", caller: org2Admin, nik: "1471010101900078", want: []string{"FRM_003"}},
+		{name: "hidden from another org", caller: org2Admin, nik: "1471010101900001", want: []string{}},
+		{name: "unknown NIK", caller: collectorUser, nik: "9999999999999999", want: []string{}},
+		{name: "peer of another org", caller: collectorUser, nik: "1471010101900001", opts: []memledger.TxOption{memledger.WithPeerMSPID("Org2MSP")}, wantErr: "cannot be handled by a peer of Org2MSP"},
+		{name: "no role", caller: noRoleUser, nik: "1471010101900001", wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			farmers, err := evaluate(n, tt.caller, func(ctx contractapi.TransactionContextInterface) ([]*Farmer, error) {
+				return n.contract.QueryFarmersByNIK(ctx, tt.nik)
+			}, tt.opts...)
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+
+			got := pageIDs(farmers, func(f *Farmer) string { return f.ID })
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestQueryByNIB(t *testing.T) {
+	n := newTestNetwork(t)
+	n.addCollector(collectorUser, "COL_001", "1111111111111")
+	n.addCollector(collectorUser, "COL_002", "2222222222222")
+	n.addProcessor(processorUser, "PRC_001", "3333333333333")
+	n.addProcessor(processorUser, "PRC_002", "4444444444444")
+
+	tests := []struct {
+		name           string
+		caller         *memledger.Identity
+		nib            string
+		wantCollectors []string
+		wantProcessors []string
+		wantErr        string
+	}{
+		{name: "collector NIB", caller: farmerUser, nib: "2222222222222", wantCollectors: []string{"COL_002"}, wantProcessors: []string{}},
+		{name: "processor NIB", caller: farmerUser, nib: "3333333333333", wantCollectors: []string{}, wantProcessors: []string{"PRC_001"}},
+		{name: "unknown NIB", caller: farmerUser, nib: "5555555555555", wantCollectors: []string{}, wantProcessors: []string{}},
+		{name: "no role", caller: noRoleUser, nib: "1111111111111", wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collectors, err := evaluate(n, tt.caller, func(ctx contractapi.TransactionContextInterface) ([]*Collector, error) {
+				return n.contract.QueryCollectorsByNIB(ctx, tt.nib)
+			})
+			checkError(t, err, tt.wantErr)
+			processors, err := evaluate(n, tt.caller, func(ctx contractapi.TransactionContextInterface) ([]*Processor, error) {
+				return n.contract.QueryProcessorsByNIB(ctx, tt.nib)
+			})
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+
+			if got := pageIDs(collectors, func(c *Collector) string { return c.ID }); !reflect.DeepEqual(got, tt.wantCollectors) {
+				t.Errorf("expected collectors %v, got %v", tt.wantCollectors, got)
+			}
+			if got := pageIDs(processors, func(p *Processor) string { return p.ID }); !reflect.DeepEqual(got, tt.wantProcessors) {
+				t.Errorf("expected processors %v, got %v", tt.wantProcessors, got)
+			}
+		})
+	}
+}
+
+func TestQueryFarmsByAttributes(t *testing.T) {
+	n := newTestNetwork(t)
+	n.addFarmer(farmerUser, "FRM_001")
+	n.mustSubmit(farmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.AddFarm(ctx, "FARM_001", "FRM_001", 2010, "Tenera", 2, "Kampar", "", 20, "SHM", "RSPO")
+	})
+	n.mustSubmit(farmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.AddFarm(ctx, "FARM_002", "FRM_001", 2012, "Dura", 2, "Kampar", "", 20, "SHM", "ISPO")
+	})
+	n.mustSubmit(farmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.AddFarm(ctx, "FARM_003", "FRM_001", 2014, "Tenera", 2, "Kampar", "", 20, "SKT", "RSPO")
+	})
+
+	tests := []struct {
+		name          string
+		legality      string
+		certificate   string
+		seedVarieties string
+		want          []string
+	}{
+		{name: "any", want: []string{"FARM_001", "FARM_002", "FARM_003"}},
+		{name: "legality", legality: "SHM", want: []string{"FARM_001", "FARM_002"}},
+		{name: "certificate", certificate: "RSPO", want: []string{"FARM_001", "FARM_003"}},
+		{name: "seed variety", seedVarieties: "Dura", want: []string{"FARM_002"}},
+		{name: "all attributes", legality: "SKT", certificate: "RSPO", seedVarieties: "Tenera", want: []string{"FARM_003"}},
+		{name: "no match", legality: "SKT", certificate: "ISPO", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			farms := mustEvaluate(n, collectorUser, func(ctx contractapi.TransactionContextInterface) ([]*Farm, error) {
+				return n.contract.QueryFarmsByAttributes(ctx, tt.legality, tt.certificate, tt.seedVarieties)
+			})
+			if got := pageIDs(farms, func(f *Farm) string { return f.ID }); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	_, err := evaluate(n, noRoleUser, func(ctx contractapi.TransactionContextInterface) ([]*Farm, error) {
+		return n.contract.QueryFarmsByAttributes(ctx, "", "", "")
+	})
+	checkError(t, err, "permission denied")
+}
+
+func TestQueryCommoditiesByState(t *testing.T) {
+	n := newTestNetwork(t)
+	n.seedSupplyChain()
+	n.harvest("COM_003", "FARM_001", 100, "2024-01-12")
+	n.process("PCD_001", 25, "COM_001")
+
+	tests := []struct {
+		name  string
+		state CommodityState
+		want  []string
+	}{
+		{name: "harvested", state: StateHarvested, want: []string{"COM_003"}},
+		{name: "delivered", state: StateDelivered, want: []string{"COM_002"}},
+		{name: "processed", state: StateProcessed, want: []string{"COM_001"}},
+		{name: "none in state", state: StateOnHold, want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commodities := mustEvaluate(n, transporterUser, func(ctx contractapi.TransactionContextInterface) ([]*Commodity, error) {
+				return n.contract.QueryCommoditiesByState(ctx, string(tt.state))
+			})
+			if got := pageIDs(commodities, func(c *Commodity) string { return c.ID }); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	_, err := evaluate(n, noRoleUser, func(ctx contractapi.TransactionContextInterface) ([]*Commodity, error) {
+		return n.contract.QueryCommoditiesByState(ctx, string(StateHarvested))
+	})
+	checkError(t, err, "permission denied")
+}
+
+func TestQueryCommoditiesByHarvestDate(t *testing.T) {
+	n := newTestNetwork(t)
+	n.addFarmer(farmerUser, "FRM_001")
+	n.addFarm(farmerUser, "FARM_001", "FRM_001")
+	n.harvest("COM_001", "FARM_001", 100, "2024-01-10")
+	n.harvest("COM_002", "FARM_001", 100, "2024-02-15")
+	n.harvest("COM_003", "FARM_001", 100, "2024-03-20")
+
+	tests := []struct {
+		name string
+		from string
+		to   string
+		want []string
+	}{
+		{name: "open range", want: []string{"COM_001", "COM_002", "COM_003"}},
+		{name: "inclusive bounds", from: "2024-01-10", to: "2024-02-15", want: []string{"COM_001", "COM_002"}},
+		{name: "open start", to: "2024-01-31", want: []string{"COM_001"}},
+		{name: "open end", from: "2024-02-01", want: []string{"COM_002", "COM_003"}},
+		{name: "empty range", from: "2024-04-01", to: "2024-04-30", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commodities := mustEvaluate(n, processorUser, func(ctx contractapi.TransactionContextInterface) ([]*Commodity, error) {
+				return n.contract.QueryCommoditiesByHarvestDate(ctx, tt.from, tt.to)
+			})
+			if got := pageIDs(commodities, func(c *Commodity) string { return c.ID }); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	_, err := evaluate(n, noRoleUser, func(ctx contractapi.TransactionContextInterface) ([]*Commodity, error) {
+		return n.contract.QueryCommoditiesByHarvestDate(ctx, "", "")
+	})
+	checkError(t, err, "permission denied")
+}
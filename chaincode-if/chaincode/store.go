@@ -0,0 +1,161 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// Object types under which entities are stored as composite keys. Each entity
+// type has its own key space, so an ID used by one type can never overwrite
+// an entity of another type.
+const (
+	farmerObjectType       = "farmer"
+	farmObjectType         = "farm"
+	collectorObjectType    = "collector"
+	processorObjectType    = "processor"
+	transporterObjectType  = "transporter"
+	commodityObjectType    = "commodity"
+	processedObjectType    = "processedCommodity"
+	recallObjectType       = "recall"
+	configObjectType       = "config"
+	mspRolesObjectType     = "mspRoles"
+	erasureObjectType      = "erasure"
+	farmTransferObjectType = "farmTransfer"
+	partnershipObjectType  = "partnership"
+)
+
+// entityKey builds the composite key of an entity
+func entityKey(ctx contractapi.TransactionContextInterface, objectType string, id string) (string, error) {
+	if id == "" {
+		return "", fmt.Errorf("the %s ID must not be empty", objectType)
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(objectType, []string{id})
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s key for %s: %v", objectType, id, err)
+	}
+
+	return key, nil
+}
+
+// getEntityState reads the stored value of an entity, or nil if it does not exist
+func getEntityState(ctx contractapi.TransactionContextInterface, objectType string, id string) ([]byte, error) {
+	key, err := entityKey(ctx, objectType, id)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+
+	return value, nil
+}
+
+// putEntityState stores the value of an entity. The object type is added to
+// the stored JSON as docType so rich queries can select on it.
+func putEntityState(ctx contractapi.TransactionContextInterface, objectType string, id string, value []byte) error {
+	key, err := entityKey(ctx, objectType, id)
+	if err != nil {
+		return err
+	}
+
+	value, err = withDocType(objectType, value)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, value)
+}
+
+// withDocType sets the docType field of a JSON object
+func withDocType(objectType string, value []byte) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(value, &fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s JSON: %v", objectType, err)
+	}
+
+	fields["docType"], err = json.Marshal(objectType)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(fields)
+}
+
+// queryAllEntities retrieves every entity of an object type from the ledger
+func queryAllEntities[T any](ctx contractapi.TransactionContextInterface, objectType string) ([]*T, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(objectType, []string{})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	return decodeEntities[T](resultsIterator, objectType)
+}
+
+// queryEntityPage retrieves one page of entities of an object type, starting at bookmark
+func queryEntityPage[T any](ctx contractapi.TransactionContextInterface, objectType string, pageSize int32, bookmark string) ([]*T, *peer.QueryResponseMetadata, error) {
+	if pageSize <= 0 {
+		return nil, nil, fmt.Errorf("page size must be positive, got %d", pageSize)
+	}
+
+	resultsIterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(objectType, []string{}, pageSize, bookmark)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resultsIterator.Close()
+
+	entities, err := decodeEntities[T](resultsIterator, objectType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return entities, metadata, nil
+}
+
+// queryResultPage retrieves one page of entities matching a rich query, starting at bookmark
+func queryResultPage[T any](ctx contractapi.TransactionContextInterface, objectType string, query string, pageSize int32, bookmark string) ([]*T, *peer.QueryResponseMetadata, error) {
+	if pageSize <= 0 {
+		return nil, nil, fmt.Errorf("page size must be positive, got %d", pageSize)
+	}
+
+	resultsIterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(query, pageSize, bookmark)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resultsIterator.Close()
+
+	entities, err := decodeEntities[T](resultsIterator, objectType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return entities, metadata, nil
+}
+
+// decodeEntities unmarshals every value of a query iterator
+func decodeEntities[T any](resultsIterator shim.StateQueryIteratorInterface, objectType string) ([]*T, error) {
+	entities := []*T{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var entity T
+		err = json.Unmarshal(queryResponse.Value, &entity)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal %s JSON: %v", objectType, err)
+		}
+		entities = append(entities, &entity)
+	}
+
+	return entities, nil
+}
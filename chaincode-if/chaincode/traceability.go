@@ -3,49 +3,149 @@ package chaincode
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
-// Commodity represents the structure for a commodity
+// TraceEvent records a single step in the life of a commodity
+type TraceEvent struct {
+	Status    string `json:"status"`
+	Location  string `json:"location"`
+	Actor     string `json:"actor"`
+	Timestamp string `json:"timestamp"`
+	TxID      string `json:"txId"`
+	MSPID     string `json:"mspId"`
+	Migrated  bool   `json:"migrated,omitempty" metadata:",optional"`
+}
+
+// Traceability holds the ordered trace events of a commodity
 type Traceability struct {
-	ID       string   `json:"id"`
-	Status   []string `json:"status"`
-	Location []string `json:"location"`
-	PIC      []string `json:"pic"`
+	ID     string       `json:"id"`
+	Events []TraceEvent `json:"events"`
 }
 
+// Commodity represents the structure for a commodity
 type Commodity struct {
-	ID           string       `json:"id"`
-	Name         string       `json:"name"`
-	Quantity     float64      `json:"quantity"`
-	DateHarvested string      `json:"dateHarvested"`
-	Traceability Traceability `json:"traceability"`
+	ID            string         `json:"id"`
+	Name          string         `json:"name"`
+	Quantity      float64        `json:"quantity"`
+	DateHarvested string         `json:"dateHarvested"`
+	FarmID        string         `json:"farmId"`
+	FarmerID      string         `json:"farmerId"`
+	CollectorID   string         `json:"collectorId,omitempty" metadata:",optional"`
+	TransporterID string         `json:"transporterId,omitempty" metadata:",optional"`
+	State         CommodityState `json:"state"`
+	HeldFrom      CommodityState `json:"heldFrom,omitempty" metadata:",optional"`
+	ProcessedInto string         `json:"processedInto,omitempty" metadata:",optional"`
+	RecallID      string         `json:"recallId,omitempty" metadata:",optional"`
+	Traceability  Traceability   `json:"traceability"`
 }
 
+// ProcessedCommodity represents the structure for a processed commodity
 type ProcessedCommodity struct {
-	ID         string   `json:"id"`
-	Processor  string	`json:"processor"`
-	Quantity   float64  `json:"quantity"`
-	Material   []string `json:"material"`
-	BatchNumber string  `json:"batchNumber"`
-	Quality    string   `json:"quality"`
+	ID             string       `json:"id"`
+	Processor      string       `json:"processor"`
+	Quantity       float64      `json:"quantity"`
+	InputQuantity  float64      `json:"inputQuantity"`
+	ExtractionRate float64      `json:"extractionRate"`
+	Material       []string     `json:"material"`
+	BatchNumber    string       `json:"batchNumber"`
+	Quality        string       `json:"quality"`
+	Status         BatchStatus  `json:"status,omitempty" metadata:",optional"`
+	RecallID       string       `json:"recallId,omitempty" metadata:",optional"`
+	Events         []TraceEvent `json:"events,omitempty" metadata:",optional"`
+}
+
+// BatchStatus is the status of a processed commodity
+type BatchStatus string
+
+const (
+	BatchActive   BatchStatus = "active"
+	BatchRecalled BatchStatus = "recalled"
+)
+
+// newTraceEvent builds a trace event stamped with the current transaction
+func newTraceEvent(ctx contractapi.TransactionContextInterface, status string, actor string, location string) (TraceEvent, error) {
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return TraceEvent{}, fmt.Errorf("failed to read transaction timestamp: %v", err)
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return TraceEvent{}, fmt.Errorf("failed to read client MSP ID: %v", err)
+	}
+
+	return TraceEvent{
+		Status:    status,
+		Location:  location,
+		Actor:     actor,
+		Timestamp: time.Unix(timestamp.GetSeconds(), int64(timestamp.GetNanos())).UTC().Format(time.RFC3339Nano),
+		TxID:      ctx.GetStub().GetTxID(),
+		MSPID:     mspID,
+	}, nil
 }
 
-func (pc *PalmOilContract) Harvest(ctx contractapi.TransactionContextInterface, commodityID string, name string, quantity float64, dateHarvested string, traceabilityID string, pic string, location string) error {
+// Harvest records a new commodity harvested from a registered farm. The farm
+// must exist and be owned by a registered farmer; both are stored on the
+// commodity as its origin.
+func (pc *PalmOilContract) Harvest(ctx contractapi.TransactionContextInterface, commodityID string, farmID string, name string, quantity float64, dateHarvested string, traceabilityID string, pic string, location string) error {
+	err := authorize(ctx, "Harvest")
+	if err != nil {
+		return err
+	}
+
+	// Check if a commodity with the given ID already exists
+	existingCommodityJSON, err := getEntityState(ctx, commodityObjectType, commodityID)
+	if err != nil {
+		return err
+	}
+	if existingCommodityJSON != nil {
+		return fmt.Errorf("a commodity with ID %s already exists", commodityID)
+	}
+
+	// Resolve the origin farm and its owner
+	farm, err := pc.QueryFarmByID(ctx, farmID)
+	if err != nil {
+		return err
+	}
+	if farm.Owner == "" {
+		return fmt.Errorf("the farm with ID %s has no owner", farmID)
+	}
+	farmer, err := pc.QueryFarmerByID(ctx, farm.Owner)
+	if err != nil {
+		return fmt.Errorf("the owner of farm %s is not a registered farmer: %v", farmID, err)
+	}
+	err = authorizeOwner(ctx, "Harvest", farmer.EnrolledBy)
+	if err != nil {
+		return err
+	}
+
+	event, err := newTraceEvent(ctx, string(StateHarvested), pic, location)
+	if err != nil {
+		return err
+	}
+
 	traceability := Traceability{
-		ID: traceabilityID,
-		Status: []string{"harvested"},
-		Location: []string{location},
-		PIC: []string{pic},
+		ID:     traceabilityID,
+		Events: []TraceEvent{event},
 	}
 
 	commodity := Commodity{
-		ID:           commodityID,
-		Name:         name,
-		Quantity:     quantity,
+		ID:            commodityID,
+		Name:          name,
+		Quantity:      quantity,
 		DateHarvested: dateHarvested,
-		Traceability: traceability,
+		FarmID:        farm.ID,
+		FarmerID:      farmer.ID,
+		State:         StateHarvested,
+		Traceability:  traceability,
+	}
+
+	err = validateCommodity(&commodity)
+	if err != nil {
+		return err
 	}
 
 	commodityJSON, err := json.Marshal(commodity)
@@ -53,129 +153,240 @@ func (pc *PalmOilContract) Harvest(ctx contractapi.TransactionContextInterface,
 		return err
 	}
 
-	return ctx.GetStub().PutState(commodityID, commodityJSON)
+	err = putEntityState(ctx, commodityObjectType, commodityID, commodityJSON)
+	if err != nil {
+		return err
+	}
+
+	err = putIndex(ctx, farmCommodityIndex, farm.ID, commodityID)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventCommodityHarvested, commodityPayload(&commodity))
 }
 
+// Collect records that a harvested commodity was picked up by a collector.
+// The collector must have an active partnership with the commodity's farm, or
+// with the farmer who harvested it.
+func (pc *PalmOilContract) Collect(ctx contractapi.TransactionContextInterface, commodityID string, collectorID string, pic string, location string) error {
+	err := authorize(ctx, "Collect")
+	if err != nil {
+		return err
+	}
 
-func (pc *PalmOilContract) Collect(ctx contractapi.TransactionContextInterface, commodityID string, pic string, location string) error {
-	// Fetch the commodity data from the ledger
-	commodityJSON, err := ctx.GetStub().GetState(commodityID)
+	collector, err := readCollector(ctx, collectorID)
 	if err != nil {
-		return fmt.Errorf("failed to read from world state: %v", err)
+		return err
 	}
-	if commodityJSON == nil {
-		return fmt.Errorf("the commodity with ID %s does not exist", commodityID)
+	err = authorizeOwner(ctx, "Collect", collector.EnrolledBy)
+	if err != nil {
+		return err
 	}
 
-	var commodity Commodity
-	err = json.Unmarshal(commodityJSON, &commodity)
+	event, err := newTraceEvent(ctx, string(StateCollected), pic, location)
 	if err != nil {
-		return fmt.Errorf("failed to unmarshal commodity JSON: %v", err)
+		return err
 	}
 
-	// Update the traceability's status, location, and PIC
-	commodity.Traceability.Status = append(commodity.Traceability.Status, "collected")
-	commodity.Traceability.PIC = append(commodity.Traceability.PIC, pic)
-	commodity.Traceability.Location = append(commodity.Traceability.Location, location)
-
-	// Marshal the updated commodity back to JSON
-	commodityJSON, err = json.Marshal(commodity)
+	commodity, err := readCommodity(ctx, commodityID)
 	if err != nil {
-		return fmt.Errorf("failed to marshal commodity: %v", err)
+		return err
+	}
+	err = moveCommodity(commodity, StateCollected, event)
+	if err != nil {
+		return err
 	}
 
-	// Update the commodity in the ledger
-	return ctx.GetStub().PutState(commodityID, commodityJSON)
-}
-
-
-func (pc *PalmOilContract) Transport(ctx contractapi.TransactionContextInterface, commodityID string, pic string, location string) error {
-	// Fetch the commodity data from the ledger
-	commodityJSON, err := ctx.GetStub().GetState(commodityID)
+	partner, err := isActivePartner(ctx, collectorID, commodity.FarmID, commodity.FarmerID)
 	if err != nil {
-		return fmt.Errorf("failed to read from world state: %v", err)
+		return err
 	}
-	if commodityJSON == nil {
-		return fmt.Errorf("the commodity with ID %s does not exist", commodityID)
+	if !partner {
+		return fmt.Errorf("the farm with ID %s of commodity %s is not an active partner of collector %s", commodity.FarmID, commodityID, collectorID)
 	}
+	commodity.CollectorID = collectorID
 
-	var commodity Commodity
-	err = json.Unmarshal(commodityJSON, &commodity)
+	err = writeCommodity(ctx, commodity)
 	if err != nil {
-		return fmt.Errorf("failed to unmarshal commodity JSON: %v", err)
+		return err
 	}
 
-	// Update the traceability's status, location, and PIC
-	commodity.Traceability.Status = append(commodity.Traceability.Status, "in transport")
-	commodity.Traceability.PIC = append(commodity.Traceability.PIC, pic)
-	commodity.Traceability.Location = append(commodity.Traceability.Location, location)
+	return emitEvent(ctx, EventCommodityCollected, commodityPayload(commodity))
+}
 
-	// Marshal the updated commodity back to JSON
-	commodityJSON, err = json.Marshal(commodity)
+// Transport records that a collected commodity left for the processor with a
+// transporter
+func (pc *PalmOilContract) Transport(ctx contractapi.TransactionContextInterface, commodityID string, transporterID string, pic string, location string) error {
+	err := authorize(ctx, "Transport")
 	if err != nil {
-		return fmt.Errorf("failed to marshal commodity: %v", err)
+		return err
 	}
 
-	// Update the commodity in the ledger
-	return ctx.GetStub().PutState(commodityID, commodityJSON)
+	return transportCommodity(ctx, "Transport", commodityID, transporterID, StateInTransport, pic, location, EventCommodityInTransport)
 }
 
+// Transported records that a commodity in transport was delivered by the
+// transporter that carried it
+func (pc *PalmOilContract) Transported(ctx contractapi.TransactionContextInterface, commodityID string, transporterID string, pic string, location string) error {
+	err := authorize(ctx, "Transported")
+	if err != nil {
+		return err
+	}
+
+	return transportCommodity(ctx, "Transported", commodityID, transporterID, StateDelivered, pic, location, EventCommodityDelivered)
+}
 
-func (pc *PalmOilContract) Transported(ctx contractapi.TransactionContextInterface, commodityID string, location string, pic string) error {
-	// Fetch the commodity data from the ledger
-	commodityJSON, err := ctx.GetStub().GetState(commodityID)
+// transportCommodity moves a commodity to a transport state on behalf of a
+// transporter, after checking the caller may act for the transporter. A
+// commodity put in transport before transporters were recorded may be
+// delivered by any transporter.
+func transportCommodity(ctx contractapi.TransactionContextInterface, function string, commodityID string, transporterID string, to CommodityState, pic string, location string, eventName string) error {
+	transporter, err := readTransporter(ctx, transporterID)
 	if err != nil {
-		return fmt.Errorf("failed to read from world state: %v", err)
+		return err
 	}
-	if commodityJSON == nil {
-		return fmt.Errorf("the commodity with ID %s does not exist", commodityID)
+	err = authorizeOwner(ctx, function, transporter.EnrolledBy)
+	if err != nil {
+		return err
 	}
 
-	var commodity Commodity
-	err = json.Unmarshal(commodityJSON, &commodity)
+	event, err := newTraceEvent(ctx, string(to), pic, location)
 	if err != nil {
-		return fmt.Errorf("failed to unmarshal commodity JSON: %v", err)
+		return err
 	}
 
-	// Update the traceability's status, location, and PIC
-	commodity.Traceability.Status = append(commodity.Traceability.Status, "delivered")
-	commodity.Traceability.PIC = append(commodity.Traceability.PIC, pic)
-	commodity.Traceability.Location = append(commodity.Traceability.Location, location)
+	commodity, err := readCommodity(ctx, commodityID)
+	if err != nil {
+		return err
+	}
+	err = moveCommodity(commodity, to, event)
+	if err != nil {
+		return err
+	}
+
+	if to == StateDelivered && commodity.TransporterID != "" && commodity.TransporterID != transporterID {
+		return fmt.Errorf("the commodity with ID %s is carried by transporter %s, not %s", commodityID, commodity.TransporterID, transporterID)
+	}
+	commodity.TransporterID = transporterID
 
-	// Marshal the updated commodity back to JSON
-	commodityJSON, err = json.Marshal(commodity)
+	err = writeCommodity(ctx, commodity)
 	if err != nil {
-		return fmt.Errorf("failed to marshal commodity: %v", err)
+		return err
 	}
 
-	// Update the commodity in the ledger
-	return ctx.GetStub().PutState(commodityID, commodityJSON)
+	return emitEvent(ctx, eventName, commodityPayload(commodity))
 }
 
-
+// Process turns delivered commodities into a processed commodity. Every
+// material must be in the delivered state and not yet consumed by another
+// processed commodity; it moves to processed and is linked to the new batch.
+// Reading the materials puts them in the transaction's read set, so two
+// concurrent Process calls on the same material cannot both commit. The output
+// quantity must be within the configured extraction rate of the FFB weight.
 func (pc *PalmOilContract) Process(ctx contractapi.TransactionContextInterface, processedID string, processor string, quantity float64, materialInput string, batchNumber string, quality string, pic string, location string) error {
-	processorJSON, err := ctx.GetStub().GetState(processor)
+	err := authorize(ctx, "Process")
+	if err != nil {
+		return err
+	}
+
+	// Check if a processed commodity with the given ID already exists
+	existingProcessedJSON, err := getEntityState(ctx, processedObjectType, processedID)
+	if err != nil {
+		return err
+	}
+	if existingProcessedJSON != nil {
+		return fmt.Errorf("a processed commodity with ID %s already exists", processedID)
+	}
+
+	processorJSON, err := getEntityState(ctx, processorObjectType, processor)
 	if err != nil {
-		return fmt.Errorf("failed to read from world state: %v", err)
+		return err
 	}
 	if processorJSON == nil {
-		return fmt.Errorf("the processir with ID %s does not exist", processor)
+		return fmt.Errorf("the processor with ID %s does not exist", processor)
+	}
+
+	var processorEntity Processor
+	err = json.Unmarshal(processorJSON, &processorEntity)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal processor JSON: %v", err)
+	}
+	err = authorizeOwner(ctx, "Process", processorEntity.EnrolledBy)
+	if err != nil {
+		return err
 	}
-	
+
 	var materials []string
 	err = json.Unmarshal([]byte(materialInput), &materials)
 	if err != nil {
-		return fmt.Errorf("failed to parse farm attribute: %v", err)
+		return fmt.Errorf("failed to parse material attribute: %v", err)
+	}
+	if len(materials) == 0 {
+		return fmt.Errorf("processed commodity %s has no material", processedID)
+	}
+
+	event, err := newTraceEvent(ctx, string(StateProcessed), pic, location)
+	if err != nil {
+		return err
+	}
+
+	// Consume every material, moving it into the processed state
+	var consumed []*Commodity
+	var inputQuantity float64
+	seen := make(map[string]bool)
+	for _, materialID := range materials {
+		if seen[materialID] {
+			return fmt.Errorf("commodity %s is listed more than once as material", materialID)
+		}
+		seen[materialID] = true
+
+		commodity, err := readCommodity(ctx, materialID)
+		if err != nil {
+			return err
+		}
+		if commodity.ProcessedInto != "" {
+			return &MaterialConsumedError{CommodityID: materialID, ProcessedID: commodity.ProcessedInto}
+		}
+
+		err = moveCommodity(commodity, StateProcessed, event)
+		if err != nil {
+			return err
+		}
+		commodity.ProcessedInto = processedID
+
+		consumed = append(consumed, commodity)
+		inputQuantity += commodity.Quantity
+	}
+
+	err = pc.checkMassBalance(ctx, processedID, inputQuantity, quantity)
+	if err != nil {
+		return err
+	}
+
+	for _, commodity := range consumed {
+		err = writeCommodity(ctx, commodity)
+		if err != nil {
+			return err
+		}
+		err = putIndex(ctx, commodityProcessedIndex, commodity.ID, processedID)
+		if err != nil {
+			return err
+		}
 	}
 
 	// Create a new processed commodity
 	processedCommodity := ProcessedCommodity{
-		ID:         processedID,
-		Processor:  processor,
-		Quantity:   quantity,
-		Material:   materials,
-		BatchNumber: batchNumber,
-		Quality:    quality,
+		ID:             processedID,
+		Processor:      processor,
+		Quantity:       quantity,
+		InputQuantity:  inputQuantity,
+		ExtractionRate: quantity / inputQuantity,
+		Material:       materials,
+		BatchNumber:    batchNumber,
+		Quality:        quality,
+		Status:         BatchActive,
+		Events:         []TraceEvent{event},
 	}
 
 	processedJSON, err := json.Marshal(processedCommodity)
@@ -184,8 +395,10 @@ func (pc *PalmOilContract) Process(ctx contractapi.TransactionContextInterface,
 	}
 
 	// Add the processed commodity to the ledger
-	return ctx.GetStub().PutState(processedID, processedJSON)
-}
-
-
+	err = putEntityState(ctx, processedObjectType, processedID, processedJSON)
+	if err != nil {
+		return err
+	}
 
+	return emitEvent(ctx, EventCommodityProcessed, batchPayload(&processedCommodity, event))
+}
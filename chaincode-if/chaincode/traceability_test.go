@@ -0,0 +1,244 @@
+package chaincode
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"chaincode-if/memledger"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestHarvest(t *testing.T) {
+	tests := []struct {
+		name        string
+		caller      *memledger.Identity
+		commodityID string
+		farmID      string
+		quantity    float64
+		wantErr     string
+	}{
+		{name: "owning farmer", caller: farmerUser, commodityID: "COM_002", farmID: "FARM_001", quantity: 120},
+		{name: "admin", caller: org1Admin, commodityID: "COM_002", farmID: "FARM_001", quantity: 120},
+		{name: "duplicate ID", caller: farmerUser, commodityID: "COM_001", farmID: "FARM_001", quantity: 120, wantErr: "already exists"},
+		{name: "missing farm", caller: farmerUser, commodityID: "COM_002", farmID: "FARM_404", quantity: 120, wantErr: "does not exist"},
+		{name: "farm without owner", caller: org1Admin, commodityID: "COM_002", farmID: "FARM_002", quantity: 120, wantErr: "has no owner"},
+		{name: "another farmer", caller: otherFarmerUser, commodityID: "COM_002", farmID: "FARM_001", quantity: 120, wantErr: "permission denied"},
+		{name: "collector role", caller: collectorUser, commodityID: "COM_002", farmID: "FARM_001", quantity: 120, wantErr: "permission denied"},
+		{name: "fractional quantity", caller: farmerUser, commodityID: "COM_002", farmID: "FARM_001", quantity: 0.5},
+		{name: "zero quantity", caller: farmerUser, commodityID: "COM_002", farmID: "FARM_001", quantity: 0, wantErr: "must be greater than 0"},
+		{name: "negative quantity", caller: farmerUser, commodityID: "COM_002", farmID: "FARM_001", quantity: -120, wantErr: "must be greater than 0"},
+		{name: "NaN quantity", caller: farmerUser, commodityID: "COM_002", farmID: "FARM_001", quantity: math.NaN(), wantErr: "must be greater than 0"},
+		{name: "infinite quantity", caller: farmerUser, commodityID: "COM_002", farmID: "FARM_001", quantity: math.Inf(1), wantErr: "must be greater than 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(t)
+			n.addFarmer(farmerUser, "FRM_001")
+			n.addFarm(farmerUser, "FARM_001", "FRM_001")
+			n.addFarm(org1Admin, "FARM_002", "")
+			n.harvest("COM_001", "FARM_001", 100, "2024-01-10")
+
+			err := n.submit(tt.caller, func(ctx contractapi.TransactionContextInterface) error {
+				return n.contract.Harvest(ctx, tt.commodityID, tt.farmID, "FFB", tt.quantity, "2024-01-12", "TR_002", "Budi", "Kampar")
+			})
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+
+			commodity := mustEvaluate(n, tt.caller, func(ctx contractapi.TransactionContextInterface) (*Commodity, error) {
+				return n.contract.QueryCommodityByID(ctx, tt.commodityID)
+			})
+			if commodity.State != StateHarvested || commodity.Quantity != tt.quantity || commodity.FarmID != "FARM_001" || commodity.FarmerID != "FRM_001" {
+				t.Errorf("unexpected commodity %+v", commodity)
+			}
+			events := commodity.Traceability.Events
+			if len(events) != 1 || events[0].Status != string(StateHarvested) || events[0].Actor != "Budi" || events[0].MSPID != "Org1MSP" || events[0].TxID == "" || events[0].Timestamp == "" {
+				t.Errorf("unexpected trace events %+v", events)
+			}
+		})
+	}
+}
+
+// movement moves a commodity one step through the lifecycle
+type movement func(n *testNetwork, ctx contractapi.TransactionContextInterface, commodityID string) error
+
+func collectStep(n *testNetwork, ctx contractapi.TransactionContextInterface, commodityID string) error {
+	return n.contract.Collect(ctx, commodityID, "COL_001", "Sari", "Pekanbaru")
+}
+
+// transportWith puts a commodity in transport with a transporter
+func transportWith(transporterID string) movement {
+	return func(n *testNetwork, ctx contractapi.TransactionContextInterface, commodityID string) error {
+		return n.contract.Transport(ctx, commodityID, transporterID, "Agus", "Pekanbaru")
+	}
+}
+
+// transportedWith delivers a commodity in transport with a transporter
+func transportedWith(transporterID string) movement {
+	return func(n *testNetwork, ctx contractapi.TransactionContextInterface, commodityID string) error {
+		return n.contract.Transported(ctx, commodityID, transporterID, "Agus", "Dumai")
+	}
+}
+
+var (
+	transportStep   = transportWith("TRP_001")
+	transportedStep = transportedWith("TRP_001")
+)
+
+func TestCommodityMovements(t *testing.T) {
+	otherTransporterUser := memledger.MustNewIdentity("Org1MSP", "transporter2", map[string]string{roleAttribute: RoleTransporter})
+
+	tests := []struct {
+		name      string
+		caller    *memledger.Identity
+		before    []movement
+		move      movement
+		commodity string
+		wantState CommodityState
+		wantErr   string
+	}{
+		{name: "collect", caller: collectorUser, move: collectStep, commodity: "COM_001", wantState: StateCollected},
+		{name: "transport by transporter", caller: transporterUser, before: []movement{collectStep}, move: transportStep, commodity: "COM_001", wantState: StateInTransport},
+		{name: "transport by admin", caller: org1Admin, before: []movement{collectStep}, move: transportStep, commodity: "COM_001", wantState: StateInTransport},
+		{name: "transported by transporter", caller: transporterUser, before: []movement{collectStep, transportStep}, move: transportedStep, commodity: "COM_001", wantState: StateDelivered},
+		{name: "collect twice", caller: collectorUser, before: []movement{collectStep}, move: collectStep, commodity: "COM_001", wantErr: `cannot move from "collected" to "collected"`},
+		{name: "transport before collection", caller: transporterUser, move: transportStep, commodity: "COM_001", wantErr: `cannot move from "harvested" to "in transport"`},
+		{name: "deliver before transport", caller: transporterUser, before: []movement{collectStep}, move: transportedStep, commodity: "COM_001", wantErr: `cannot move from "collected" to "delivered"`},
+		{name: "missing commodity", caller: collectorUser, move: collectStep, commodity: "COM_404", wantErr: "does not exist"},
+		{name: "collect by farmer", caller: farmerUser, move: collectStep, commodity: "COM_001", wantErr: "permission denied"},
+		{name: "transport by farmer", caller: farmerUser, before: []movement{collectStep}, move: transportStep, commodity: "COM_001", wantErr: "permission denied"},
+		{name: "transport by collector", caller: collectorUser, before: []movement{collectStep}, move: transportStep, commodity: "COM_001", wantErr: "permission denied"},
+		{name: "transported by collector", caller: collectorUser, before: []movement{collectStep, transportStep}, move: transportedStep, commodity: "COM_001", wantErr: "permission denied"},
+		{name: "transported by processor", caller: processorUser, before: []movement{collectStep, transportStep}, move: transportedStep, commodity: "COM_001", wantErr: "permission denied"},
+		{name: "transport with another's transporter", caller: transporterUser, before: []movement{collectStep}, move: transportWith("TRP_002"), commodity: "COM_001", wantErr: "permission denied"},
+		{name: "transported with another's transporter", caller: transporterUser, before: []movement{collectStep, transportStep}, move: transportedWith("TRP_002"), commodity: "COM_001", wantErr: "permission denied"},
+		{name: "delivered by another transporter", caller: otherTransporterUser, before: []movement{collectStep, transportStep}, move: transportedWith("TRP_002"), commodity: "COM_001", wantErr: "is carried by transporter TRP_001, not TRP_002"},
+		{name: "missing transporter", caller: transporterUser, before: []movement{collectStep}, move: transportWith("TRP_404"), commodity: "COM_001", wantErr: "the transporter with ID TRP_404 does not exist"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(t)
+			n.addFarmer(farmerUser, "FRM_001")
+			n.addFarm(farmerUser, "FARM_001", "FRM_001")
+			n.addCollector(collectorUser, "COL_001", "1234567890123")
+			n.addPartnership("PRT_001", "COL_001", PartnerFarm, "FARM_001")
+			n.addTransporter(transporterUser, "TRP_001")
+			n.addTransporter(otherTransporterUser, "TRP_002")
+			n.harvest("COM_001", "FARM_001", 100, "2024-01-10")
+			for _, step := range tt.before {
+				n.mustSubmit(org1Admin, func(ctx contractapi.TransactionContextInterface) error {
+					return step(n, ctx, "COM_001")
+				})
+			}
+
+			err := n.submit(tt.caller, func(ctx contractapi.TransactionContextInterface) error {
+				return tt.move(n, ctx, tt.commodity)
+			})
+			checkError(t, err, tt.wantErr)
+
+			var transitionErr *TransitionError
+			if errors.As(err, &transitionErr) && transitionErr.CommodityID != tt.commodity {
+				t.Errorf("unexpected transition error %+v", transitionErr)
+			}
+			if tt.wantErr != "" {
+				return
+			}
+
+			commodity := mustEvaluate(n, tt.caller, func(ctx contractapi.TransactionContextInterface) (*Commodity, error) {
+				return n.contract.QueryCommodityByID(ctx, tt.commodity)
+			})
+			events := commodity.Traceability.Events
+			if commodity.State != tt.wantState || len(events) != len(tt.before)+2 || events[len(events)-1].Status != string(tt.wantState) {
+				t.Errorf("expected state %q after %d steps, got %+v", tt.wantState, len(tt.before)+2, commodity)
+			}
+		})
+	}
+}
+
+func TestProcess(t *testing.T) {
+	tests := []struct {
+		name        string
+		caller      *memledger.Identity
+		processedID string
+		processor   string
+		quantity    float64
+		materials   string
+		wantErr     string
+	}{
+		{name: "processor", caller: processorUser, processedID: "PCD_002", processor: "PRC_001", quantity: 50, materials: `["COM_002","COM_003"]`},
+		{name: "admin", caller: org1Admin, processedID: "PCD_002", processor: "PRC_001", quantity: 50, materials: `["COM_002","COM_003"]`},
+		{name: "duplicate ID", caller: processorUser, processedID: "PCD_001", processor: "PRC_001", quantity: 25, materials: `["COM_002"]`, wantErr: "already exists"},
+		{name: "missing processor", caller: processorUser, processedID: "PCD_002", processor: "PRC_404", quantity: 25, materials: `["COM_002"]`, wantErr: "the processor with ID PRC_404 does not exist"},
+		{name: "invalid materials", caller: processorUser, processedID: "PCD_002", processor: "PRC_001", quantity: 25, materials: `COM_002`, wantErr: "failed to parse material attribute"},
+		{name: "no materials", caller: processorUser, processedID: "PCD_002", processor: "PRC_001", quantity: 25, materials: `[]`, wantErr: "has no material"},
+		{name: "material listed twice", caller: processorUser, processedID: "PCD_002", processor: "PRC_001", quantity: 50, materials: `["COM_002","COM_002"]`, wantErr: "listed more than once"},
+		{name: "missing material", caller: processorUser, processedID: "PCD_002", processor: "PRC_001", quantity: 25, materials: `["COM_404"]`, wantErr: "the commodity with ID COM_404 does not exist"},
+		{name: "material consumed", caller: processorUser, processedID: "PCD_002", processor: "PRC_001", quantity: 25, materials: `["COM_001"]`, wantErr: "already been consumed by processed commodity PCD_001"},
+		{name: "material not delivered", caller: processorUser, processedID: "PCD_002", processor: "PRC_001", quantity: 25, materials: `["COM_004"]`, wantErr: `cannot move from "harvested" to "processed"`},
+		{name: "extraction rate too high", caller: processorUser, processedID: "PCD_002", processor: "PRC_001", quantity: 90, materials: `["COM_002","COM_003"]`, wantErr: "outside the extraction rate range"},
+		{name: "extraction rate too low", caller: processorUser, processedID: "PCD_002", processor: "PRC_001", quantity: 10, materials: `["COM_002","COM_003"]`, wantErr: "outside the extraction rate range"},
+		{name: "another processor", caller: memledger.MustNewIdentity("Org1MSP", "processor2", map[string]string{roleAttribute: RoleProcessor}), processedID: "PCD_002", processor: "PRC_001", quantity: 25, materials: `["COM_002"]`, wantErr: "permission denied"},
+		{name: "collector role", caller: collectorUser, processedID: "PCD_002", processor: "PRC_001", quantity: 25, materials: `["COM_002"]`, wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(t)
+			n.seedSupplyChain()
+			n.harvest("COM_003", "FARM_001", 100, "2024-01-12")
+			n.harvest("COM_004", "FARM_001", 100, "2024-01-12")
+			n.deliver("COM_003")
+			n.process("PCD_001", 25, "COM_001")
+
+			err := n.submit(tt.caller, func(ctx contractapi.TransactionContextInterface) error {
+				return n.contract.Process(ctx, tt.processedID, tt.processor, tt.quantity, tt.materials, "B-002", "A", "Rina", "Dumai")
+			})
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				// A failed transaction must not consume any material
+				commodity := mustEvaluate(n, org1Admin, func(ctx contractapi.TransactionContextInterface) (*Commodity, error) {
+					return n.contract.QueryCommodityByID(ctx, "COM_002")
+				})
+				if commodity.State != StateDelivered || commodity.ProcessedInto != "" {
+					t.Errorf("COM_002 changed by a failed transaction: %+v", commodity)
+				}
+				return
+			}
+
+			batch := mustEvaluate(n, tt.caller, func(ctx contractapi.TransactionContextInterface) (*ProcessedCommodity, error) {
+				return n.contract.QueryProcessedCommodityByID(ctx, tt.processedID)
+			})
+			if batch.InputQuantity != 200 || batch.ExtractionRate != 0.25 || batch.Status != BatchActive || len(batch.Events) != 1 {
+				t.Errorf("unexpected processed commodity %+v", batch)
+			}
+			for _, materialID := range []string{"COM_002", "COM_003"} {
+				commodity := mustEvaluate(n, tt.caller, func(ctx contractapi.TransactionContextInterface) (*Commodity, error) {
+					return n.contract.QueryCommodityByID(ctx, materialID)
+				})
+				if commodity.State != StateProcessed || commodity.ProcessedInto != tt.processedID {
+					t.Errorf("material %s was not consumed: %+v", materialID, commodity)
+				}
+			}
+		})
+	}
+}
+
+func TestMaterialConsumedError(t *testing.T) {
+	n := newTestNetwork(t)
+	n.seedSupplyChain()
+	n.process("PCD_001", 25, "COM_001")
+
+	err := n.submit(processorUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.Process(ctx, "PCD_002", "PRC_001", 25, `["COM_001"]`, "B-002", "A", "Rina", "Dumai")
+	})
+
+	var consumedErr *MaterialConsumedError
+	if !errors.As(err, &consumedErr) || consumedErr.CommodityID != "COM_001" || consumedErr.ProcessedID != "PCD_001" {
+		t.Fatalf("expected a MaterialConsumedError for COM_001, got %v", err)
+	}
+}
@@ -9,30 +9,63 @@ import (
 
 // Transporter represents the structure for a transporter
 type Transporter struct {
-	ID      string `json:"id"`
-	Name    string `json:"name"`
-	NIK     string `json:"nik"`
-	NoHP    string `json:"noHP"`
-	NumShip int    `json:"numShip"`
+	ID         string      `json:"id"`
+	Name       string      `json:"name"`
+	NIKHash    string      `json:"nikHash"`
+	NumShip    int         `json:"numShip"`
+	EnrolledBy *Enrollment `json:"enrolledBy,omitempty" metadata:",optional"`
 }
 
 // AddTransporter adds a new transporter to the ledger
-func (pc *PalmOilContract) AddTransporter(ctx contractapi.TransactionContextInterface, id string, name string, nik string, noHP string, numShip int) error {
+func (pc *PalmOilContract) AddTransporter(ctx contractapi.TransactionContextInterface, id string, name string, numShip int) error {
+	err := authorize(ctx, "AddTransporter")
+	if err != nil {
+		return err
+	}
+
+	return addTransporter(ctx, Transporter{ID: id, Name: name, NumShip: numShip})
+}
+
+// AddTransporterFromJSON adds a new transporter to the ledger from a JSON transporter document
+func (pc *PalmOilContract) AddTransporterFromJSON(ctx contractapi.TransactionContextInterface, transporterJSON string) error {
+	err := authorize(ctx, "AddTransporterFromJSON")
+	if err != nil {
+		return err
+	}
+
+	var transporter Transporter
+	err = decodeDocument(transporterObjectType, transporterJSON, &transporter)
+	if err != nil {
+		return err
+	}
+
+	return addTransporter(ctx, transporter)
+}
+
+// addTransporter stores a new transporter, enrolled by the caller
+func addTransporter(ctx contractapi.TransactionContextInterface, transporter Transporter) error {
 	// Check if a transporter with the given ID already exists
-	existingTransporterJSON, err := ctx.GetStub().GetState(id)
+	existingTransporterJSON, err := getEntityState(ctx, transporterObjectType, transporter.ID)
 	if err != nil {
-		return fmt.Errorf("failed to read from world state: %v", err)
+		return err
 	}
 	if existingTransporterJSON != nil {
-		return fmt.Errorf("a transporter with ID %s already exists", id)
+		return fmt.Errorf("a transporter with ID %s already exists", transporter.ID)
+	}
+
+	transporter.EnrolledBy, err = callerEnrollment(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = validateTransporter(ctx, &transporter)
+	if err != nil {
+		return err
 	}
 
-	transporter := Transporter{
-		ID:      id,
-		Name:    name,
-		NIK:     nik,
-		NoHP:    noHP,
-		NumShip: numShip,
+	transporter.NIKHash, err = addPersonalData(ctx, transporterObjectType, transporter.ID, transporter.EnrolledBy)
+	if err != nil {
+		return err
 	}
 
 	transporterJSON, err := json.Marshal(transporter)
@@ -40,14 +73,68 @@ func (pc *PalmOilContract) AddTransporter(ctx contractapi.TransactionContextInte
 		return err
 	}
 
-	return ctx.GetStub().PutState(id, transporterJSON)
+	err = putEntityState(ctx, transporterObjectType, transporter.ID, transporterJSON)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventTransporterAdded, transporterPayload(&transporter))
 }
 
 // UpdateTransporter updates an existing transporter on the ledger
-func (pc *PalmOilContract) UpdateTransporter(ctx contractapi.TransactionContextInterface, id string, name string, nik string, noHP string, numShip int) error {
-	transporterJSON, err := ctx.GetStub().GetState(id)
+func (pc *PalmOilContract) UpdateTransporter(ctx contractapi.TransactionContextInterface, id string, name string, numShip int) error {
+	err := authorize(ctx, "UpdateTransporter")
 	if err != nil {
-		return fmt.Errorf("failed to read from world state: %v", err)
+		return err
+	}
+
+	return updateTransporter(ctx, "UpdateTransporter", id, func(transporter *Transporter) error {
+		transporter.Name = name
+		transporter.NumShip = numShip
+		return nil
+	})
+}
+
+// UpdateTransporterFromJSON replaces every attribute of an existing transporter with those
+// of a JSON transporter document
+func (pc *PalmOilContract) UpdateTransporterFromJSON(ctx contractapi.TransactionContextInterface, transporterJSON string) error {
+	err := authorize(ctx, "UpdateTransporterFromJSON")
+	if err != nil {
+		return err
+	}
+
+	var update Transporter
+	err = decodeDocument(transporterObjectType, transporterJSON, &update)
+	if err != nil {
+		return err
+	}
+
+	return updateTransporter(ctx, "UpdateTransporterFromJSON", update.ID, func(transporter *Transporter) error {
+		transporter.Name = update.Name
+		transporter.NumShip = update.NumShip
+		return nil
+	})
+}
+
+// PatchTransporter changes the attributes of an existing transporter named in a JSON
+// patch document and leaves the others unchanged
+func (pc *PalmOilContract) PatchTransporter(ctx contractapi.TransactionContextInterface, id string, patchJSON string) error {
+	err := authorize(ctx, "PatchTransporter")
+	if err != nil {
+		return err
+	}
+
+	return updateTransporter(ctx, "PatchTransporter", id, func(transporter *Transporter) error {
+		return applyPatch(transporterObjectType, transporter, patchJSON)
+	})
+}
+
+// updateTransporter changes the attributes of an existing transporter with update,
+// after checking the caller may change it
+func updateTransporter(ctx contractapi.TransactionContextInterface, function string, id string, update func(transporter *Transporter) error) error {
+	transporterJSON, err := getEntityState(ctx, transporterObjectType, id)
+	if err != nil {
+		return err
 	}
 	if transporterJSON == nil {
 		return fmt.Errorf("the transporter with ID %s does not exist", id)
@@ -56,25 +143,50 @@ func (pc *PalmOilContract) UpdateTransporter(ctx contractapi.TransactionContextI
 	var transporter Transporter
 	json.Unmarshal(transporterJSON, &transporter)
 
+	err = authorizeOwner(ctx, function, transporter.EnrolledBy)
+	if err != nil {
+		return err
+	}
+
 	// Update the transporter's attributes
-	transporter.Name = name
-	transporter.NIK = nik
-	transporter.NoHP = noHP
-	transporter.NumShip = numShip
+	err = update(&transporter)
+	if err != nil {
+		return err
+	}
+
+	err = validateTransporter(ctx, &transporter)
+	if err != nil {
+		return err
+	}
+
+	transporter.NIKHash, err = updatePersonalData(ctx, transporterObjectType, id, transporter.EnrolledBy, transporter.NIKHash)
+	if err != nil {
+		return err
+	}
 
 	transporterJSON, err = json.Marshal(transporter)
 	if err != nil {
 		return err
 	}
 
-	return ctx.GetStub().PutState(id, transporterJSON)
+	err = putEntityState(ctx, transporterObjectType, id, transporterJSON)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, EventTransporterUpdated, transporterPayload(&transporter))
 }
 
 // QueryTransporterByID retrieves a transporter by its ID from the ledger
 func (pc *PalmOilContract) QueryTransporterByID(ctx contractapi.TransactionContextInterface, id string) (*Transporter, error) {
-	transporterJSON, err := ctx.GetStub().GetState(id)
+	err := authorize(ctx, "QueryTransporterByID")
 	if err != nil {
-		return nil, fmt.Errorf("failed to read from world state: %v", err)
+		return nil, err
+	}
+
+	transporterJSON, err := getEntityState(ctx, transporterObjectType, id)
+	if err != nil {
+		return nil, err
 	}
 	if transporterJSON == nil {
 		return nil, fmt.Errorf("the transporter with ID %s does not exist", id)
@@ -88,24 +200,29 @@ func (pc *PalmOilContract) QueryTransporterByID(ctx contractapi.TransactionConte
 
 // QueryAllTransporters retrieves all transporters from the ledger
 func (pc *PalmOilContract) QueryAllTransporters(ctx contractapi.TransactionContextInterface) ([]*Transporter, error) {
-	resultsIterator, err := ctx.GetStub().GetStateByRange("TRA_", "TRA_zzzzzzzzzz")
+	err := authorize(ctx, "QueryAllTransporters")
 	if err != nil {
 		return nil, err
 	}
-	defer resultsIterator.Close()
 
-	var transporters []*Transporter
-	for resultsIterator.HasNext() {
-		queryResponse, err := resultsIterator.Next()
-		if err != nil {
-			return nil, err
-		}
+	return queryAllEntities[Transporter](ctx, transporterObjectType)
+}
+
+// readTransporter fetches a transporter from the ledger
+func readTransporter(ctx contractapi.TransactionContextInterface, id string) (*Transporter, error) {
+	transporterJSON, err := getEntityState(ctx, transporterObjectType, id)
+	if err != nil {
+		return nil, err
+	}
+	if transporterJSON == nil {
+		return nil, fmt.Errorf("the transporter with ID %s does not exist", id)
+	}
 
-		var transporter Transporter
-		json.Unmarshal(queryResponse.Value, &transporter)
-		transporters = append(transporters, &transporter)
+	var transporter Transporter
+	err = json.Unmarshal(transporterJSON, &transporter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal transporter JSON: %v", err)
 	}
 
-	return transporters, nil
+	return &transporter, nil
 }
-
@@ -0,0 +1,124 @@
+package chaincode
+
+import (
+	"testing"
+
+	"chaincode-if/memledger"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestAddTransporter(t *testing.T) {
+	tests := []struct {
+		name    string
+		caller  *memledger.Identity
+		id      string
+		opts    []memledger.TxOption
+		wantErr string
+	}{
+		{name: "transporter", caller: transporterUser, id: "TRP_002", opts: []memledger.TxOption{personal("1471010101900012")}},
+		{name: "admin", caller: org1Admin, id: "TRP_002", opts: []memledger.TxOption{personal("1471010101900012")}},
+		{name: "duplicate ID", caller: transporterUser, id: "TRP_001", opts: []memledger.TxOption{personal("1471010101900012")}, wantErr: "already exists"},
+		{name: "no personal data", caller: transporterUser, id: "TRP_002", wantErr: "transient data"},
+		{name: "invalid personal data", caller: transporterUser, id: "TRP_002", opts: []memledger.TxOption{memledger.WithTransient(map[string][]byte{personalTransientKey: []byte(`nik`)})}, wantErr: "failed to parse personal transient data"},
+		{name: "farmer role", caller: farmerUser, id: "TRP_002", opts: []memledger.TxOption{personal("1471010101900012")}, wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(t)
+			n.addTransporter(transporterUser, "TRP_001")
+
+			err := n.submit(tt.caller, func(ctx contractapi.TransactionContextInterface) error {
+				return n.contract.AddTransporter(ctx, tt.id, "CV Angkut", 5)
+			}, tt.opts...)
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+
+			transporter := mustEvaluate(n, tt.caller, func(ctx contractapi.TransactionContextInterface) (*Transporter, error) {
+				return n.contract.QueryTransporterByID(ctx, tt.id)
+			})
+			if transporter.NumShip != 5 || transporter.NIKHash != hashNIK(testSalt, "1471010101900012") {
+				t.Errorf("unexpected transporter %+v", transporter)
+			}
+		})
+	}
+}
+
+func TestUpdateTransporter(t *testing.T) {
+	tests := []struct {
+		name    string
+		caller  *memledger.Identity
+		id      string
+		wantErr string
+	}{
+		{name: "enrolling transporter", caller: transporterUser, id: "TRP_001"},
+		{name: "admin", caller: org1Admin, id: "TRP_001"},
+		{name: "missing transporter", caller: transporterUser, id: "TRP_404", wantErr: "does not exist"},
+		{name: "admin of another org", caller: org2Admin, id: "TRP_001", wantErr: "permission denied"},
+		{name: "collector role", caller: collectorUser, id: "TRP_001", wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(t)
+			n.addTransporter(transporterUser, "TRP_001")
+
+			err := n.submit(tt.caller, func(ctx contractapi.TransactionContextInterface) error {
+				return n.contract.UpdateTransporter(ctx, tt.id, "CV Angkut Jaya", 8)
+			})
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+
+			transporter := mustEvaluate(n, tt.caller, func(ctx contractapi.TransactionContextInterface) (*Transporter, error) {
+				return n.contract.QueryTransporterByID(ctx, tt.id)
+			})
+			if transporter.Name != "CV Angkut Jaya" || transporter.NumShip != 8 {
+				t.Errorf("the transporter was not updated: %+v", transporter)
+			}
+		})
+	}
+}
+
+func TestQueryTransporterByID(t *testing.T) {
+	n := newTestNetwork(t)
+	n.addTransporter(transporterUser, "TRP_001")
+
+	tests := []struct {
+		name    string
+		caller  *memledger.Identity
+		id      string
+		wantErr string
+	}{
+		{name: "existing", caller: collectorUser, id: "TRP_001"},
+		{name: "missing", caller: collectorUser, id: "TRP_404", wantErr: "does not exist"},
+		{name: "no role", caller: noRoleUser, id: "TRP_001", wantErr: "permission denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transporter, err := evaluate(n, tt.caller, func(ctx contractapi.TransactionContextInterface) (*Transporter, error) {
+				return n.contract.QueryTransporterByID(ctx, tt.id)
+			})
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr == "" && transporter.ID != tt.id {
+				t.Errorf("expected %s, got %+v", tt.id, transporter)
+			}
+		})
+	}
+}
+
+func TestQueryAllTransporters(t *testing.T) {
+	n := newTestNetwork(t)
+	n.addTransporter(transporterUser, "TRP_001")
+	n.addTransporter(org1Admin, "TRP_002")
+
+	transporters := mustEvaluate(n, farmerUser, n.contract.QueryAllTransporters)
+	if len(transporters) != 2 || transporters[0].ID != "TRP_001" || transporters[1].ID != "TRP_002" {
+		t.Fatalf("expected TRP_001 and TRP_002, got %+v", transporters)
+	}
+}
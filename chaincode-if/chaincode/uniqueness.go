@@ -0,0 +1,211 @@
+package chaincode
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"chaincode-if/validation"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Kinds of identity numbers that are unique across farmers, collectors,
+// processors and transporters
+const (
+	IdentityNIK = "nik"
+	IdentityNIB = "nib"
+)
+
+// identityIndexCollection is the private data collection, shared by every
+// organization of the channel, holding the uniqueness indexes. The public side
+// of the ledger only records hashes of its keys and values.
+const identityIndexCollection = "IdentityIndex"
+
+// identityIndexes are the object types of the uniqueness indexes by kind of
+// identity number. Each index is keyed by the hash of a number and holds the
+// entity it is registered to.
+var identityIndexes = map[string]string{
+	IdentityNIK: "uniqueNIK",
+	IdentityNIB: "uniqueNIB",
+}
+
+// identityFields are the fields reported when an identity number is taken
+var identityFields = map[string]string{
+	IdentityNIK: personalTransientKey + ".nik",
+	IdentityNIB: "nib",
+}
+
+// IdentityOwner is the entity an identity number is registered to
+type IdentityOwner struct {
+	EntityType string `json:"entityType"`
+	EntityID   string `json:"entityId"`
+}
+
+// indexKeyTransientKey is the transient data key holding the secret that keys
+// the NIK uniqueness index. Every organization of the channel passes the same
+// secret, which is never stored on the ledger.
+const indexKeyTransientKey = "indexKey"
+
+// minIndexKeyLength is the shortest identity index key accepted
+const minIndexKeyLength = 32
+
+// identityHash hashes an identity number for its uniqueness index. A number
+// hashes the same for every organization, so duplicates are found across
+// organizations. NIKs follow a known structure and could be recovered from a
+// plain hash by anyone reading the index, so they are hashed with an HMAC
+// keyed by the index key passed as transient data. NIBs are public on the
+// records of collectors and processors and are hashed without a key.
+func identityHash(ctx contractapi.TransactionContextInterface, kind string, number string) (string, error) {
+	if kind != IdentityNIK {
+		sum := sha256.Sum256([]byte(kind + ":" + number))
+		return hex.EncodeToString(sum[:]), nil
+	}
+
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return "", fmt.Errorf("failed to read transient data: %v", err)
+	}
+	key := transientMap[indexKeyTransientKey]
+	if len(key) < minIndexKeyLength {
+		return "", fmt.Errorf("the %s transient data must hold the channel's identity index key of at least %d characters", indexKeyTransientKey, minIndexKeyLength)
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(kind + ":" + number))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// identityIndexKey builds the key of an identity number in its uniqueness index
+func identityIndexKey(ctx contractapi.TransactionContextInterface, kind string, number string) (string, error) {
+	indexType, ok := identityIndexes[kind]
+	if !ok {
+		return "", fmt.Errorf("unknown identity number type %q, expected %s or %s", kind, IdentityNIK, IdentityNIB)
+	}
+
+	hash, err := identityHash(ctx, kind, number)
+	if err != nil {
+		return "", err
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(indexType, []string{hash})
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s index key: %v", kind, err)
+	}
+
+	return key, nil
+}
+
+// getIdentityOwner reads the entity an identity number is registered to, or nil
+func getIdentityOwner(ctx contractapi.TransactionContextInterface, kind string, number string) (*IdentityOwner, error) {
+	key, err := identityIndexKey(ctx, kind, number)
+	if err != nil {
+		return nil, err
+	}
+
+	ownerJSON, err := ctx.GetStub().GetPrivateData(identityIndexCollection, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s index: %v", kind, err)
+	}
+	if ownerJSON == nil {
+		return nil, nil
+	}
+
+	var owner IdentityOwner
+	err = json.Unmarshal(ownerJSON, &owner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s index entry: %v", kind, err)
+	}
+
+	return &owner, nil
+}
+
+// claimIdentity registers an identity number to an entity. It fails with a
+// validation error if the number is registered to another entity. Empty
+// numbers are not registered.
+func claimIdentity(ctx contractapi.TransactionContextInterface, kind string, number string, objectType string, id string) error {
+	if number == "" {
+		return nil
+	}
+
+	owner, err := getIdentityOwner(ctx, kind, number)
+	if err != nil {
+		return err
+	}
+	if owner != nil {
+		if owner.EntityType == objectType && owner.EntityID == id {
+			return nil
+		}
+		v := validation.New(objectType + " " + id)
+		v.Add(identityFields[kind], validation.CodeDuplicate, "is already registered to another entity")
+		return v.Err()
+	}
+
+	key, err := identityIndexKey(ctx, kind, number)
+	if err != nil {
+		return err
+	}
+	ownerJSON, err := json.Marshal(IdentityOwner{EntityType: objectType, EntityID: id})
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutPrivateData(identityIndexCollection, key, ownerJSON)
+}
+
+// releaseIdentity removes an identity number from its index if it is
+// registered to the entity. The entry is purged, so it does not remain in the
+// collection's history.
+func releaseIdentity(ctx contractapi.TransactionContextInterface, kind string, number string, objectType string, id string) error {
+	if number == "" {
+		return nil
+	}
+
+	owner, err := getIdentityOwner(ctx, kind, number)
+	if err != nil {
+		return err
+	}
+	if owner == nil || owner.EntityType != objectType || owner.EntityID != id {
+		return nil
+	}
+
+	key, err := identityIndexKey(ctx, kind, number)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PurgePrivateData(identityIndexCollection, key)
+}
+
+// reindexIdentity moves the index entry of an entity from its previous
+// identity number to its current one
+func reindexIdentity(ctx contractapi.TransactionContextInterface, kind string, previous string, current string, objectType string, id string) error {
+	if previous != current {
+		err := releaseIdentity(ctx, kind, previous, objectType, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return claimIdentity(ctx, kind, current, objectType, id)
+}
+
+// ResolveIdentityNumber retrieves the farmer, collector, processor or
+// transporter a NIK or NIB is registered to. The kind is nik or nib.
+func (pc *PalmOilContract) ResolveIdentityNumber(ctx contractapi.TransactionContextInterface, kind string, number string) (*IdentityOwner, error) {
+	err := authorize(ctx, "ResolveIdentityNumber")
+	if err != nil {
+		return nil, err
+	}
+
+	owner, err := getIdentityOwner(ctx, kind, number)
+	if err != nil {
+		return nil, err
+	}
+	if owner == nil {
+		return nil, fmt.Errorf("no entity is registered with this %s", kind)
+	}
+
+	return owner, nil
+}
@@ -0,0 +1,237 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+
+	"chaincode-if/memledger"
+	"chaincode-if/validation"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestIdentityUniqueness(t *testing.T) {
+	tests := []struct {
+		name      string
+		caller    *memledger.Identity
+		fn        func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error
+		opts      []memledger.TxOption
+		wantField string
+	}{
+		{
+			name:   "farmer NIK",
+			caller: otherFarmerUser,
+			fn: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+				return c.AddFarmer(ctx, "FRM_002", "Joko", "Jambi", `[]`)
+			},
+			opts:      []memledger.TxOption{personal("1471010101900001")},
+			wantField: "personal.nik",
+		},
+		{
+			name:   "NIK of a farmer registered by another org",
+			caller: org2Admin,
+			fn: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+				return c.AddTransporter(ctx, "TRP_002", "CV Angkut", 2)
+			},
+			opts:      []memledger.TxOption{personal("1471010101900001")},
+			wantField: "personal.nik",
+		},
+		{
+			name:   "NIK in new personal data",
+			caller: transporterUser,
+			fn: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+				return c.UpdateTransporter(ctx, "TRP_001", "CV Angkut", 3)
+			},
+			opts:      []memledger.TxOption{personal("1471010101900002")},
+			wantField: "personal.nik",
+		},
+		{
+			name:   "collector NIB",
+			caller: processorUser,
+			fn: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+				return c.AddProcessor(ctx, "PRC_001", "PKS Dumai", "1234567890123", "Dumai", 1000)
+			},
+			opts:      []memledger.TxOption{personal("1471010101900009")},
+			wantField: "nib",
+		},
+		{
+			name:   "NIB of an update",
+			caller: collectorUser,
+			fn: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+				return c.PatchCollector(ctx, "COL_002", `{"nib":"1234567890123"}`)
+			},
+			wantField: "nib",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(t)
+			n.addFarmer(farmerUser, "FRM_001")
+			n.addCollector(collectorUser, "COL_001", "1234567890123")
+			n.addCollector(collectorUser, "COL_002", "1234567890124")
+			n.addTransporter(transporterUser, "TRP_001")
+			blocks := n.ledger.BlockNumber()
+
+			err := n.submit(tt.caller, func(ctx contractapi.TransactionContextInterface) error {
+				return tt.fn(n.contract, ctx)
+			}, tt.opts...)
+
+			validationErr, ok := validation.Parse(err)
+			if !ok {
+				t.Fatalf("expected a validation error, got %v", err)
+			}
+			want := []validation.FieldError{{Field: tt.wantField, Code: validation.CodeDuplicate, Message: "is already registered to another entity"}}
+			if !reflect.DeepEqual(validationErr.Fields, want) {
+				t.Errorf("expected %+v, got %+v", want, validationErr.Fields)
+			}
+			if n.ledger.BlockNumber() != blocks {
+				t.Error("the duplicate was committed")
+			}
+		})
+	}
+}
+
+func TestIdentityReindex(t *testing.T) {
+	n := newTestNetwork(t)
+	n.addFarmer(farmerUser, "FRM_001")
+	n.addCollector(collectorUser, "COL_001", "1234567890123")
+
+	// Updating an entity with its own numbers keeps them registered to it
+	n.mustSubmit(collectorUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.PatchCollector(ctx, "COL_001", `{"name":"KUD Sejahtera"}`)
+	}, personal("1471010101900002"))
+
+	// Changed numbers are released for other entities
+	n.mustSubmit(farmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.UpdateFarmer(ctx, "FRM_001", "Slamet", "Riau", `[]`)
+	}, personal("1471010101900077"))
+	n.mustSubmit(collectorUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.PatchCollector(ctx, "COL_001", `{"nib":"1234567890124"}`)
+	})
+	n.mustSubmit(otherFarmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.AddFarmer(ctx, "FRM_002", "Joko", "Jambi", `[]`)
+	}, personal("1471010101900001"))
+	n.addProcessor(processorUser, "PRC_001", "1234567890123")
+
+	resolve := func(kind string, number string) IdentityOwner {
+		t.Helper()
+		owner := mustEvaluate(n, org1Admin, func(ctx contractapi.TransactionContextInterface) (*IdentityOwner, error) {
+			return n.contract.ResolveIdentityNumber(ctx, kind, number)
+		}, indexKey)
+		return *owner
+	}
+	if owner := resolve(IdentityNIK, "1471010101900077"); owner != (IdentityOwner{EntityType: farmerObjectType, EntityID: "FRM_001"}) {
+		t.Errorf("expected the new NIK to resolve to FRM_001, got %+v", owner)
+	}
+	if owner := resolve(IdentityNIK, "1471010101900001"); owner != (IdentityOwner{EntityType: farmerObjectType, EntityID: "FRM_002"}) {
+		t.Errorf("expected the released NIK to resolve to FRM_002, got %+v", owner)
+	}
+	if owner := resolve(IdentityNIB, "1234567890123"); owner != (IdentityOwner{EntityType: processorObjectType, EntityID: "PRC_001"}) {
+		t.Errorf("expected the released NIB to resolve to PRC_001, got %+v", owner)
+	}
+}
+
+func TestPurgeReleasesNIK(t *testing.T) {
+	n := newTestNetwork(t)
+	n.addFarmer(farmerUser, "FRM_001")
+	n.mustSubmit(farmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := n.contract.PurgePersonalData(ctx, "FRM_001")
+		return err
+	}, indexKey)
+
+	_, err := evaluate(n, org1Admin, func(ctx contractapi.TransactionContextInterface) (*IdentityOwner, error) {
+		return n.contract.ResolveIdentityNumber(ctx, IdentityNIK, "1471010101900001")
+	}, indexKey)
+	checkError(t, err, "no entity is registered with this nik")
+
+	n.mustSubmit(otherFarmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.AddFarmer(ctx, "FRM_002", "Joko", "Jambi", `[]`)
+	}, personal("1471010101900001"))
+}
+
+func TestResolveIdentityNumber(t *testing.T) {
+	n := newTestNetwork(t)
+	n.addFarmer(farmerUser, "FRM_001")
+	n.addCollector(collectorUser, "COL_001", "1234567890123")
+
+	tests := []struct {
+		name    string
+		caller  *memledger.Identity
+		kind    string
+		number  string
+		opts    []memledger.TxOption
+		want    IdentityOwner
+		wantErr string
+	}{
+		{name: "NIK", caller: org1Admin, kind: IdentityNIK, number: "1471010101900001", want: IdentityOwner{EntityType: farmerObjectType, EntityID: "FRM_001"}},
+		{name: "NIK of a collector", caller: org1Admin, kind: IdentityNIK, number: "1471010101900002", want: IdentityOwner{EntityType: collectorObjectType, EntityID: "COL_001"}},
+		{name: "NIB", caller: org2Admin, kind: IdentityNIB, number: "1234567890123", want: IdentityOwner{EntityType: collectorObjectType, EntityID: "COL_001"}},
+		{name: "unregistered number", caller: org1Admin, kind: IdentityNIK, number: "1471010101900099", wantErr: "no entity is registered with this nik"},
+		{name: "NIK looked up as NIB", caller: org1Admin, kind: IdentityNIB, number: "1471010101900001", wantErr: "no entity is registered with this nib"},
+		{name: "unknown kind", caller: org1Admin, kind: "npwp", number: "1234567890123", wantErr: `unknown identity number type "npwp"`},
+		{name: "farmer role", caller: farmerUser, kind: IdentityNIK, number: "1471010101900001", wantErr: "permission denied"},
+		{name: "NIK without index key", caller: org1Admin, kind: IdentityNIK, number: "1471010101900001", opts: []memledger.TxOption{memledger.WithTransient(nil)}, wantErr: "identity index key of at least 32 characters"},
+		{name: "NIK with another index key", caller: org1Admin, kind: IdentityNIK, number: "1471010101900001", opts: []memledger.TxOption{memledger.WithTransient(map[string][]byte{indexKeyTransientKey: []byte("another-identity-index-key-012345")})}, wantErr: "no entity is registered with this nik"},
+		{name: "NIB without index key", caller: org1Admin, kind: IdentityNIB, number: "1234567890123", opts: []memledger.TxOption{memledger.WithTransient(nil)}, want: IdentityOwner{EntityType: collectorObjectType, EntityID: "COL_001"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owner, err := evaluate(n, tt.caller, func(ctx contractapi.TransactionContextInterface) (*IdentityOwner, error) {
+				return n.contract.ResolveIdentityNumber(ctx, tt.kind, tt.number)
+			}, append([]memledger.TxOption{indexKey}, tt.opts...)...)
+			checkError(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+			if *owner != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, *owner)
+			}
+		})
+	}
+}
+
+func TestIdentityIndexIsPrivate(t *testing.T) {
+	n := newTestNetwork(t)
+	n.addFarmer(farmerUser, "FRM_001")
+	n.addCollector(collectorUser, "COL_001", "1234567890123")
+
+	for _, kv := range n.ledger.WorldState() {
+		if strings.Contains(kv.Key, identityIndexes[IdentityNIK]) || strings.Contains(kv.Key, identityIndexes[IdentityNIB]) {
+			t.Errorf("expected no index entry in world state, found %q", kv.Key)
+		}
+	}
+	if entries := n.ledger.PrivateData(identityIndexCollection); len(entries) != 3 {
+		t.Fatalf("expected 2 NIKs and 1 NIB in the index collection, got %d entries", len(entries))
+	}
+
+	n.mustSubmit(farmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := n.contract.PurgePersonalData(ctx, "FRM_001")
+		return err
+	}, indexKey)
+	if entries := n.ledger.PrivateData(identityIndexCollection); len(entries) != 2 {
+		t.Errorf("expected the purged NIK to leave the index collection, got %d entries", len(entries))
+	}
+}
+
+func TestIdentityIndexIsKeyed(t *testing.T) {
+	n := newTestNetwork(t)
+	n.addFarmer(farmerUser, "FRM_001")
+
+	// Without the index key, a NIK can not be found by hashing candidates
+	sum := sha256.Sum256([]byte(IdentityNIK + ":1471010101900001"))
+	for _, kv := range n.ledger.PrivateData(identityIndexCollection) {
+		if strings.Contains(kv.Key, hex.EncodeToString(sum[:])) {
+			t.Errorf("expected the NIK index to be keyed, found the plain hash in %q", kv.Key)
+		}
+	}
+
+	err := n.submit(otherFarmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.AddFarmer(ctx, "FRM_002", "Joko", "Jambi", `[]`)
+	}, memledger.WithTransient(map[string][]byte{personalTransientKey: []byte(`{"nik":"1471010101900002","salt":"0123456789abcdef"}`)}))
+	checkError(t, err, "identity index key")
+}
@@ -0,0 +1,122 @@
+package chaincode
+
+import (
+	"fmt"
+	"time"
+
+	"chaincode-if/validation"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// minPlantedYear is the earliest year a farm can have been planted
+const minPlantedYear = 1900
+
+// validateFarmer checks the personal data passed with a farmer transaction
+func validateFarmer(ctx contractapi.TransactionContextInterface, farmer *Farmer) error {
+	v := validation.New(farmerObjectType + " " + farmer.ID)
+	err := validatePersonalData(ctx, v)
+	if err != nil {
+		return err
+	}
+
+	return v.Err()
+}
+
+// validateFarm checks the planted year, area and capacity of a farm
+func validateFarm(ctx contractapi.TransactionContextInterface, farm *Farm) error {
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to read transaction timestamp: %v", err)
+	}
+	year := time.Unix(timestamp.GetSeconds(), 0).UTC().Year()
+
+	v := validation.New(farmObjectType + " " + farm.ID)
+	v.Range("plantedYear", float64(farm.PlantedYear), minPlantedYear, float64(year))
+	v.Min("area", farm.Area, 0)
+	v.Min("capacity", farm.Capacity, 0)
+
+	return v.Err()
+}
+
+// validatePartnership checks the partner type and dates of a partnership
+func validatePartnership(partnership *Partnership) error {
+	v := validation.New(partnershipObjectType + " " + partnership.ID)
+	v.Check("partnerType", partnership.PartnerType == PartnerFarmer || partnership.PartnerType == PartnerFarm, validation.CodeFormat, "must be farmer or farm")
+	v.Date("startDate", partnership.StartDate)
+	if partnership.EndDate != "" {
+		v.Date("endDate", partnership.EndDate)
+		v.Check("endDate", partnership.EndDate >= partnership.StartDate, validation.CodeRange, "must not be before startDate")
+	}
+
+	return v.Err()
+}
+
+// validateCommodity checks the quantity and harvest date of a harvested
+// commodity
+func validateCommodity(commodity *Commodity) error {
+	v := validation.New(commodityObjectType + " " + commodity.ID)
+	v.Positive("quantity", commodity.Quantity)
+	v.Date("dateHarvested", commodity.DateHarvested)
+
+	return v.Err()
+}
+
+// validateCollector checks the NIB and capacity of a collector and the
+// personal data passed with the transaction
+func validateCollector(ctx contractapi.TransactionContextInterface, collector *Collector) error {
+	v := validation.New(collectorObjectType + " " + collector.ID)
+	v.NIB("nib", collector.NIB)
+	v.Min("capacity", collector.Capacity, 0)
+	err := validatePersonalData(ctx, v)
+	if err != nil {
+		return err
+	}
+
+	return v.Err()
+}
+
+// validateProcessor checks the NIB and capacity of a processor and the
+// personal data passed with the transaction
+func validateProcessor(ctx contractapi.TransactionContextInterface, processor *Processor) error {
+	v := validation.New(processorObjectType + " " + processor.ID)
+	v.NIB("nib", processor.NIB)
+	v.Min("capacity", processor.Capacity, 0)
+	err := validatePersonalData(ctx, v)
+	if err != nil {
+		return err
+	}
+
+	return v.Err()
+}
+
+// validateTransporter checks the number of ships of a transporter and the
+// personal data passed with the transaction
+func validateTransporter(ctx contractapi.TransactionContextInterface, transporter *Transporter) error {
+	v := validation.New(transporterObjectType + " " + transporter.ID)
+	v.Min("numShip", float64(transporter.NumShip), 0)
+	err := validatePersonalData(ctx, v)
+	if err != nil {
+		return err
+	}
+
+	return v.Err()
+}
+
+// validatePersonalData checks the NIK, phone number and email of the personal
+// data passed as transient data, if any
+func validatePersonalData(ctx contractapi.TransactionContextInterface, v *validation.Validator) error {
+	personal, err := readTransientPersonalData(ctx)
+	if err != nil {
+		return err
+	}
+	if personal == nil {
+		return nil
+	}
+
+	v.NIK(personalTransientKey+".nik", personal.NIK)
+	v.Phone(personalTransientKey+".noHP", personal.NoHP)
+	v.Email(personalTransientKey+".email", personal.Email)
+
+	return nil
+}
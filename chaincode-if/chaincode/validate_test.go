@@ -0,0 +1,169 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"chaincode-if/memledger"
+	"chaincode-if/validation"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// personalWith passes personal data as transient data
+func personalWith(personal PersonalData) memledger.TxOption {
+	personal.Salt = testSalt
+	personalJSON, _ := json.Marshal(personal)
+	return memledger.WithTransient(map[string][]byte{personalTransientKey: personalJSON, indexKeyTransientKey: []byte(testIndexKey)})
+}
+
+func TestValidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		caller     *memledger.Identity
+		fn         func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error
+		opts       []memledger.TxOption
+		wantRecord string
+		wantFields []string
+	}{
+		{
+			name:   "farmer NIK and contact",
+			caller: farmerUser,
+			fn: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+				return c.AddFarmer(ctx, "FRM_002", "Slamet", "Riau", `[]`)
+			},
+			opts:       []memledger.TxOption{personalWith(PersonalData{NIK: "147101010190", NoHP: "0812345678", Email: "slamet@"})},
+			wantRecord: "farmer FRM_002",
+			wantFields: []string{"personal.nik", "personal.noHP", "personal.email"},
+		},
+		{
+			name:   "farmer birth date",
+			caller: farmerUser,
+			fn: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+				return c.AddFarmerFromJSON(ctx, `{"id":"FRM_002"}`)
+			},
+			opts:       []memledger.TxOption{personalWith(PersonalData{NIK: "1471013502900001"})},
+			wantRecord: "farmer FRM_002",
+			wantFields: []string{"personal.nik"},
+		},
+		{
+			name:   "farmer update",
+			caller: farmerUser,
+			fn: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+				return c.PatchFarmer(ctx, "FRM_001", `{"name":"Slamet"}`)
+			},
+			opts:       []memledger.TxOption{personalWith(PersonalData{NIK: "1471010101900001", Email: "slamet.example.com"})},
+			wantRecord: "farmer FRM_001",
+			wantFields: []string{"personal.email"},
+		},
+		{
+			name:   "farm ranges",
+			caller: farmerUser,
+			fn: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+				return c.AddFarm(ctx, "FARM_002", "FRM_001", 2030, "Tenera", -2.5, "Kampar", "", -1, "SHM", "RSPO")
+			},
+			wantRecord: "farm FARM_002",
+			wantFields: []string{"plantedYear", "area", "capacity"},
+		},
+		{
+			name:   "farm patch",
+			caller: farmerUser,
+			fn: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+				return c.PatchFarm(ctx, "FARM_001", `{"plantedYear":1850}`)
+			},
+			wantRecord: "farm FARM_001",
+			wantFields: []string{"plantedYear"},
+		},
+		{
+			name:   "collector",
+			caller: collectorUser,
+			fn: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+				return c.AddCollector(ctx, "COL_002", "KUD", "12345", "Siak", -250, `[]`)
+			},
+			opts:       []memledger.TxOption{personal("1471010101900009")},
+			wantRecord: "collector COL_002",
+			wantFields: []string{"nib", "capacity"},
+		},
+		{
+			name:   "collector update",
+			caller: collectorUser,
+			fn: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+				return c.UpdateCollector(ctx, "COL_001", "KUD", "NIB-0000000001", "Siak", 250, `[]`)
+			},
+			wantRecord: "collector COL_001",
+			wantFields: []string{"nib"},
+		},
+		{
+			name:   "processor",
+			caller: processorUser,
+			fn: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+				return c.AddProcessorFromJSON(ctx, `{"id":"PRC_002","nib":"12345678901234","capacity":-60}`)
+			},
+			opts:       []memledger.TxOption{personal("1471010101900009")},
+			wantRecord: "processor PRC_002",
+			wantFields: []string{"nib", "capacity"},
+		},
+		{
+			name:   "transporter",
+			caller: transporterUser,
+			fn: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+				return c.UpdateTransporter(ctx, "TRP_001", "CV Angkut", -3)
+			},
+			opts:       []memledger.TxOption{personal("0071010101900004")},
+			wantRecord: "transporter TRP_001",
+			wantFields: []string{"numShip", "personal.nik"},
+		},
+		{
+			name:   "harvest",
+			caller: org1Admin,
+			fn: func(c *PalmOilContract, ctx contractapi.TransactionContextInterface) error {
+				return c.Harvest(ctx, "COM_001", "FARM_001", "FFB", 100, "10/01/2024", "TR_001", "Budi", "Kampar")
+			},
+			wantRecord: "commodity COM_001",
+			wantFields: []string{"dateHarvested"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(t)
+			n.addFarmer(farmerUser, "FRM_001")
+			n.addFarm(farmerUser, "FARM_001", "FRM_001")
+			n.addCollector(collectorUser, "COL_001", "1234567890123")
+			n.addTransporter(transporterUser, "TRP_001")
+			blocks := n.ledger.BlockNumber()
+
+			err := n.submit(tt.caller, func(ctx contractapi.TransactionContextInterface) error {
+				return tt.fn(n.contract, ctx)
+			}, tt.opts...)
+
+			validationErr, ok := validation.Parse(err)
+			if !ok {
+				t.Fatalf("expected a validation error, got %v", err)
+			}
+			var fields []string
+			for _, field := range validationErr.Fields {
+				fields = append(fields, field.Field)
+			}
+			if validationErr.Record != tt.wantRecord || !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("expected invalid %v of %s, got %v of %s", tt.wantFields, tt.wantRecord, fields, validationErr.Record)
+			}
+			if n.ledger.BlockNumber() != blocks {
+				t.Error("the invalid transaction was committed")
+			}
+		})
+	}
+}
+
+func TestAuthorizationBeforeValidation(t *testing.T) {
+	n := newTestNetwork(t)
+	n.addFarmer(farmerUser, "FRM_001")
+	n.addFarm(farmerUser, "FARM_001", "FRM_001")
+
+	// Callers who may not change a record learn nothing about its validity
+	err := n.submit(otherFarmerUser, func(ctx contractapi.TransactionContextInterface) error {
+		return n.contract.PatchFarm(ctx, "FARM_001", `{"area":-1}`)
+	})
+	checkError(t, err, "permission denied")
+}
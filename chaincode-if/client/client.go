@@ -0,0 +1,280 @@
+// Package client is a typed Go client of the palmoil chaincode. Client has one
+// method per contract function, taking and returning the chaincode's own
+// structs. Gateway implements it against a Fabric network and InProcess
+// against the contract running on an in-memory ledger.
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"chaincode-if/chaincode"
+)
+
+// personalTransientKey is the transient data key the contract reads personal data from
+const personalTransientKey = "personal"
+
+// indexKeyTransientKey is the transient data key the contract reads the
+// identity index key from
+const indexKeyTransientKey = "indexKey"
+
+// saltLength is the number of random bytes in a generated salt
+const saltLength = 16
+
+// Step identifies the person in charge of a traceability step and where it happened
+type Step struct {
+	PIC      string `json:"pic"`
+	Location string `json:"location"`
+}
+
+// HarvestRequest records a commodity harvested from a farm
+type HarvestRequest struct {
+	CommodityID    string  `json:"commodityId"`
+	FarmID         string  `json:"farmId"`
+	Name           string  `json:"name"`
+	Quantity       float64 `json:"quantity"`
+	DateHarvested  string  `json:"dateHarvested"`
+	TraceabilityID string  `json:"traceabilityId"`
+}
+
+// ProcessRequest records a processed commodity made from delivered materials
+type ProcessRequest struct {
+	ProcessedID string   `json:"processedId"`
+	Processor   string   `json:"processor"`
+	Quantity    float64  `json:"quantity"`
+	Materials   []string `json:"materials"`
+	BatchNumber string   `json:"batchNumber"`
+	Quality     string   `json:"quality"`
+}
+
+// RecallRequest recalls everything that originates from a farm, commodity or batch
+type RecallRequest struct {
+	RecallID   string `json:"recallId"`
+	OriginType string `json:"originType"`
+	OriginID   string `json:"originId"`
+	Reason     string `json:"reason"`
+	PIC        string `json:"pic"`
+}
+
+// Patch names the attributes of an entity to change, by their JSON names, and
+// their new values
+type Patch map[string]interface{}
+
+// Client calls the functions of the palmoil contract. Personal data is passed
+// as transient data and never reaches the world state. A nil personal data
+// leaves the stored personal data unchanged on updates; an empty salt is
+// replaced by a random one. Calls that register, release or resolve a NIK also
+// pass the channel's identity index key, set with WithIndexKey on Gateway and
+// InProcess.
+type Client interface {
+	SetMSPRoles(ctx context.Context, mspID string, roles []string) error
+	QueryMSPRoles(ctx context.Context, mspID string) (*chaincode.MSPRoles, error)
+
+	AddFarmer(ctx context.Context, farmer *chaincode.Farmer, personal *chaincode.PersonalData) error
+	UpdateFarmer(ctx context.Context, farmer *chaincode.Farmer, personal *chaincode.PersonalData) error
+	PatchFarmer(ctx context.Context, id string, patch Patch, personal *chaincode.PersonalData) error
+	AddFarmerFromJSON(ctx context.Context, document json.RawMessage, personal *chaincode.PersonalData) error
+	UpdateFarmerFromJSON(ctx context.Context, document json.RawMessage, personal *chaincode.PersonalData) error
+	QueryFarmerByID(ctx context.Context, id string) (*chaincode.Farmer, error)
+	QueryAllFarmers(ctx context.Context) ([]*chaincode.Farmer, error)
+	AddFarm(ctx context.Context, farm *chaincode.Farm) error
+	UpdateFarm(ctx context.Context, farm *chaincode.Farm) error
+	PatchFarm(ctx context.Context, id string, patch Patch) error
+	AddFarmFromJSON(ctx context.Context, document json.RawMessage) error
+	UpdateFarmFromJSON(ctx context.Context, document json.RawMessage) error
+	QueryFarmByID(ctx context.Context, id string) (*chaincode.Farm, error)
+	QueryAllFarms(ctx context.Context) ([]*chaincode.Farm, error)
+	// TransferFarmOwnership proposes to hand a farm over to another farmer,
+	// who becomes its owner with AcceptFarmOwnership
+	TransferFarmOwnership(ctx context.Context, farmID string, newOwner string) error
+	AcceptFarmOwnership(ctx context.Context, farmID string) error
+	RejectFarmOwnership(ctx context.Context, farmID string) error
+	CancelFarmOwnershipTransfer(ctx context.Context, farmID string) error
+	QueryFarmTransfer(ctx context.Context, farmID string) (*chaincode.FarmTransfer, error)
+
+	AddCollector(ctx context.Context, collector *chaincode.Collector, personal *chaincode.PersonalData) error
+	UpdateCollector(ctx context.Context, collector *chaincode.Collector, personal *chaincode.PersonalData) error
+	PatchCollector(ctx context.Context, id string, patch Patch, personal *chaincode.PersonalData) error
+	AddCollectorFromJSON(ctx context.Context, document json.RawMessage, personal *chaincode.PersonalData) error
+	UpdateCollectorFromJSON(ctx context.Context, document json.RawMessage, personal *chaincode.PersonalData) error
+	QueryCollectorByID(ctx context.Context, id string) (*chaincode.Collector, error)
+	QueryAllCollectors(ctx context.Context) ([]*chaincode.Collector, error)
+	// ProposePartnership proposes the partnership between a collector and a
+	// farmer or farm described by the ID, collector, partner and dates of
+	// partnership; the other side consents with AcceptPartnership
+	ProposePartnership(ctx context.Context, partnership *chaincode.Partnership) error
+	AcceptPartnership(ctx context.Context, id string) error
+	RejectPartnership(ctx context.Context, id string) error
+	EndPartnership(ctx context.Context, id string) error
+	QueryPartnership(ctx context.Context, id string) (*chaincode.Partnership, error)
+	QueryPartnershipsByCollector(ctx context.Context, collectorID string) ([]*chaincode.Partnership, error)
+	QueryPartnershipsByPartner(ctx context.Context, partnerType string, partnerID string) ([]*chaincode.Partnership, error)
+
+	AddProcessor(ctx context.Context, processor *chaincode.Processor, personal *chaincode.PersonalData) error
+	UpdateProcessor(ctx context.Context, processor *chaincode.Processor, personal *chaincode.PersonalData) error
+	PatchProcessor(ctx context.Context, id string, patch Patch, personal *chaincode.PersonalData) error
+	AddProcessorFromJSON(ctx context.Context, document json.RawMessage, personal *chaincode.PersonalData) error
+	UpdateProcessorFromJSON(ctx context.Context, document json.RawMessage, personal *chaincode.PersonalData) error
+	QueryProcessorByID(ctx context.Context, id string) (*chaincode.Processor, error)
+	QueryAllProcessors(ctx context.Context) ([]*chaincode.Processor, error)
+
+	AddTransporter(ctx context.Context, transporter *chaincode.Transporter, personal *chaincode.PersonalData) error
+	UpdateTransporter(ctx context.Context, transporter *chaincode.Transporter, personal *chaincode.PersonalData) error
+	PatchTransporter(ctx context.Context, id string, patch Patch, personal *chaincode.PersonalData) error
+	AddTransporterFromJSON(ctx context.Context, document json.RawMessage, personal *chaincode.PersonalData) error
+	UpdateTransporterFromJSON(ctx context.Context, document json.RawMessage, personal *chaincode.PersonalData) error
+	QueryTransporterByID(ctx context.Context, id string) (*chaincode.Transporter, error)
+	QueryAllTransporters(ctx context.Context) ([]*chaincode.Transporter, error)
+
+	Harvest(ctx context.Context, harvest HarvestRequest, step Step) error
+	Collect(ctx context.Context, commodityID string, collectorID string, step Step) error
+	Transport(ctx context.Context, commodityID string, transporterID string, step Step) error
+	Transported(ctx context.Context, commodityID string, transporterID string, step Step) error
+	HoldCommodity(ctx context.Context, commodityID string, step Step) error
+	ReleaseCommodity(ctx context.Context, commodityID string, step Step) error
+	RejectCommodity(ctx context.Context, commodityID string, step Step) error
+	Process(ctx context.Context, process ProcessRequest, step Step) error
+
+	QueryCommodityByID(ctx context.Context, commodityID string) (*chaincode.Commodity, error)
+	QueryAllCommodities(ctx context.Context) ([]*chaincode.Commodity, error)
+	QueryProcessedCommodityByID(ctx context.Context, processedID string) (*chaincode.ProcessedCommodity, error)
+	QueryAllProcessedCommodities(ctx context.Context) ([]*chaincode.ProcessedCommodity, error)
+
+	RecallBatch(ctx context.Context, recall RecallRequest) error
+	QueryRecallByID(ctx context.Context, recallID string) (*chaincode.Recall, error)
+
+	SetExtractionRateRange(ctx context.Context, rateRange chaincode.ExtractionRateRange) error
+	QueryExtractionRateRange(ctx context.Context) (*chaincode.ExtractionRateRange, error)
+
+	TraceProcessedCommodity(ctx context.Context, processedID string) (*chaincode.BatchLineage, error)
+	TraceFarmForward(ctx context.Context, farmID string) (*chaincode.ForwardTrace, error)
+	TraceCommodityForward(ctx context.Context, commodityID string) (*chaincode.ForwardTrace, error)
+
+	GetFarmerHistory(ctx context.Context, id string) ([]*chaincode.HistoryEntry, error)
+	GetFarmHistory(ctx context.Context, id string) ([]*chaincode.HistoryEntry, error)
+	GetCollectorHistory(ctx context.Context, id string) ([]*chaincode.HistoryEntry, error)
+	GetProcessorHistory(ctx context.Context, id string) ([]*chaincode.HistoryEntry, error)
+	GetTransporterHistory(ctx context.Context, id string) ([]*chaincode.HistoryEntry, error)
+	GetCommodityHistory(ctx context.Context, id string) ([]*chaincode.HistoryEntry, error)
+	GetEntityAsOf(ctx context.Context, entityType string, id string, asOf time.Time) (*chaincode.HistoryEntry, error)
+
+	QueryFarmersWithPagination(ctx context.Context, pageSize int32, bookmark string) (*chaincode.FarmerPage, error)
+	QueryFarmsWithPagination(ctx context.Context, pageSize int32, bookmark string) (*chaincode.FarmPage, error)
+	QueryCollectorsWithPagination(ctx context.Context, pageSize int32, bookmark string) (*chaincode.CollectorPage, error)
+	QueryProcessorsWithPagination(ctx context.Context, pageSize int32, bookmark string) (*chaincode.ProcessorPage, error)
+	QueryTransportersWithPagination(ctx context.Context, pageSize int32, bookmark string) (*chaincode.TransporterPage, error)
+	QueryCommoditiesWithPagination(ctx context.Context, pageSize int32, bookmark string) (*chaincode.CommodityPage, error)
+	QueryProcessedCommoditiesWithPagination(ctx context.Context, pageSize int32, bookmark string) (*chaincode.ProcessedCommodityPage, error)
+
+	QueryFarmersByNIK(ctx context.Context, nik string) ([]*chaincode.Farmer, error)
+	QueryCollectorsByNIB(ctx context.Context, nib string) ([]*chaincode.Collector, error)
+	QueryProcessorsByNIB(ctx context.Context, nib string) ([]*chaincode.Processor, error)
+	// ResolveIdentityNumber finds the entity a NIK or NIB is registered to,
+	// with kind chaincode.IdentityNIK or chaincode.IdentityNIB
+	ResolveIdentityNumber(ctx context.Context, kind string, number string) (*chaincode.IdentityOwner, error)
+	QueryFarmsByAttributes(ctx context.Context, legality string, certificate string, seedVarieties string) ([]*chaincode.Farm, error)
+	QueryCommoditiesByState(ctx context.Context, state chaincode.CommodityState) ([]*chaincode.Commodity, error)
+	QueryCommoditiesByStateWithPagination(ctx context.Context, state chaincode.CommodityState, pageSize int32, bookmark string) (*chaincode.CommodityPage, error)
+	QueryCommoditiesByHarvestDate(ctx context.Context, from string, to string) ([]*chaincode.Commodity, error)
+
+	QueryFarmerDetails(ctx context.Context, id string) (*chaincode.FarmerDetails, error)
+	QueryCollectorDetails(ctx context.Context, id string) (*chaincode.CollectorDetails, error)
+	QueryProcessorDetails(ctx context.Context, id string) (*chaincode.ProcessorDetails, error)
+	QueryTransporterDetails(ctx context.Context, id string) (*chaincode.TransporterDetails, error)
+	PurgePersonalData(ctx context.Context, entityID string) (*chaincode.ErasureRecord, error)
+	QueryErasureRecordByID(ctx context.Context, id string) (*chaincode.ErasureRecord, error)
+	QueryAllErasureRecords(ctx context.Context) ([]*chaincode.ErasureRecord, error)
+
+	MigrateTraceability(ctx context.Context) (int, error)
+	MigrateEntityKeys(ctx context.Context) (*chaincode.KeyMigration, error)
+	// MigratePersonalData moves legacy personal data into private data, hashing
+	// NIKs with salt, which is generated when empty
+	MigratePersonalData(ctx context.Context, salt string) (*chaincode.KeyMigration, error)
+}
+
+// personalTransient returns the transient data carrying personal data and the
+// identity index key, or nil when there is no personal data
+func personalTransient(personal *chaincode.PersonalData, indexKey string) (map[string][]byte, error) {
+	if personal == nil {
+		return nil, nil
+	}
+
+	withSalt := *personal
+	if withSalt.Salt == "" {
+		salt, err := newSalt()
+		if err != nil {
+			return nil, err
+		}
+		withSalt.Salt = salt
+	}
+
+	personalJSON, err := json.Marshal(withSalt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode personal data: %v", err)
+	}
+
+	transient := indexKeyTransient(indexKey)
+	transient[personalTransientKey] = personalJSON
+	return transient, nil
+}
+
+// indexKeyTransient returns the transient data carrying the identity index key
+func indexKeyTransient(indexKey string) map[string][]byte {
+	return map[string][]byte{indexKeyTransientKey: []byte(indexKey)}
+}
+
+// saltTransient returns the transient data carrying a migration salt
+func saltTransient(salt string) (map[string][]byte, error) {
+	if salt == "" {
+		var err error
+		salt, err = newSalt()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return map[string][]byte{"salt": []byte(salt)}, nil
+}
+
+// newSalt returns a random hex-encoded salt
+func newSalt() (string, error) {
+	salt := make([]byte, saltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate salt: %v", err)
+	}
+
+	return hex.EncodeToString(salt), nil
+}
+
+// jsonList encodes a list argument of the contract, which takes lists as JSON
+func jsonList(values []string) string {
+	if values == nil {
+		values = []string{}
+	}
+	valuesJSON, _ := json.Marshal(values)
+	return string(valuesJSON)
+}
+
+// patchArg encodes a patch document
+func patchArg(patch Patch) (string, error) {
+	patchJSON, err := json.Marshal(patch)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode patch: %v", err)
+	}
+	return string(patchJSON), nil
+}
+
+// timestampArg formats a time the way the contract parses timestamps
+func timestampArg(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+var (
+	_ Client = (*Gateway)(nil)
+	_ Client = (*InProcess)(nil)
+)
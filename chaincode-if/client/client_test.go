@@ -0,0 +1,465 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"chaincode-if/chaincode"
+	"chaincode-if/memledger"
+	"chaincode-if/validation"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+var (
+	admin       = memledger.MustNewIdentity("Org1MSP", "admin", map[string]string{"role": "admin"})
+	farmer      = memledger.MustNewIdentity("Org1MSP", "farmer", map[string]string{"role": "farmer"})
+	collector   = memledger.MustNewIdentity("Org1MSP", "collector", map[string]string{"role": "collector"})
+	transporter = memledger.MustNewIdentity("Org1MSP", "transporter", map[string]string{"role": "transporter"})
+	processor   = memledger.MustNewIdentity("Org1MSP", "processor", map[string]string{"role": "processor"})
+)
+
+// testIndexKey is the identity index key of the test channel
+const testIndexKey = "client-test-identity-index-key-0123"
+
+// ledgerTransactor sends transactions with string arguments through the
+// contractapi dispatcher, the way a peer does
+type ledgerTransactor struct {
+	ledger   *memledger.Ledger
+	cc       shim.Chaincode
+	identity *memledger.Identity
+}
+
+func (t *ledgerTransactor) Submit(ctx context.Context, name string, transient map[string][]byte, args ...string) ([]byte, error) {
+	res := t.ledger.Invoke(t.cc, t.identity, append([]string{name}, args...), memledger.WithTransient(transient))
+	if res.Status >= shim.ERRORTHRESHOLD {
+		return nil, errors.New(res.Message)
+	}
+	return res.Payload, nil
+}
+
+func (t *ledgerTransactor) Evaluate(ctx context.Context, name string, transient map[string][]byte, args ...string) ([]byte, error) {
+	res := t.ledger.Query(t.cc, t.identity, append([]string{name}, args...), memledger.WithTransient(transient))
+	if res.Status >= shim.ERRORTHRESHOLD {
+		return nil, errors.New(res.Message)
+	}
+	return res.Payload, nil
+}
+
+// implementations returns, for each Client implementation, a function that
+// opens a client of one shared ledger as an identity
+func implementations(t *testing.T) map[string]func() func(id *memledger.Identity) Client {
+	t.Helper()
+	return map[string]func() func(id *memledger.Identity) Client{
+		"in-process": func() func(id *memledger.Identity) Client {
+			c := NewInProcess(memledger.New(), admin).WithIndexKey(testIndexKey)
+			return func(id *memledger.Identity) Client { return c.As(id) }
+		},
+		"transactor": func() func(id *memledger.Identity) Client {
+			cc, err := contractapi.NewChaincode(chaincode.NewPalmOilContract())
+			if err != nil {
+				t.Fatal(err)
+			}
+			ledger := memledger.New()
+			return func(id *memledger.Identity) Client {
+				return NewTransactorClient(&ledgerTransactor{ledger: ledger, cc: cc, identity: id}).WithIndexKey(testIndexKey)
+			}
+		},
+	}
+}
+
+// must fails the test on an error
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// seed registers a farm, a collector partnered with its farmer, a transporter
+// and a processor and delivers two harvests to the processor
+func seed(t *testing.T, as func(id *memledger.Identity) Client) {
+	t.Helper()
+	ctx := context.Background()
+
+	must(t, as(farmer).AddFarmer(ctx, &chaincode.Farmer{ID: "FRM_001", Name: "Slamet", Address: "Kampar"}, &chaincode.PersonalData{NIK: "1471010101900001"}))
+	must(t, as(farmer).AddFarm(ctx, &chaincode.Farm{ID: "FARM_001", Owner: "FRM_001", PlantedYear: 2010, SeedVarieties: "Tenera", Area: 2.5, Address: "Kampar", Capacity: 25, Legality: "SHM", Certificate: "RSPO"}))
+	must(t, as(collector).AddCollector(ctx, &chaincode.Collector{ID: "COL_001", Name: "KUD Makmur", NIB: "1234567890123", Address: "Siak", Capacity: 250}, &chaincode.PersonalData{NIK: "1471010101900002"}))
+	must(t, as(collector).ProposePartnership(ctx, &chaincode.Partnership{ID: "PRT_001", CollectorID: "COL_001", PartnerType: chaincode.PartnerFarmer, PartnerID: "FRM_001", StartDate: "2024-01-01"}))
+	must(t, as(farmer).AcceptPartnership(ctx, "PRT_001"))
+	must(t, as(transporter).AddTransporter(ctx, &chaincode.Transporter{ID: "TRP_001", Name: "CV Angkut", NumShip: 3}, &chaincode.PersonalData{NIK: "1471010101900004"}))
+	must(t, as(processor).AddProcessor(ctx, &chaincode.Processor{ID: "PRC_001", Name: "PKS Dumai", NIB: "1234567890124", Address: "Dumai", Capacity: 60}, &chaincode.PersonalData{NIK: "1471010101900003"}))
+
+	for _, id := range []string{"COM_001", "COM_002"} {
+		must(t, as(farmer).Harvest(ctx, HarvestRequest{CommodityID: id, FarmID: "FARM_001", Name: "FFB", Quantity: 100, DateHarvested: "2024-01-10", TraceabilityID: "TR_" + id}, Step{PIC: "Budi", Location: "Kampar"}))
+		must(t, as(collector).Collect(ctx, id, "COL_001", Step{PIC: "Sari", Location: "Pekanbaru"}))
+		must(t, as(transporter).Transport(ctx, id, "TRP_001", Step{PIC: "Agus", Location: "Pekanbaru"}))
+		must(t, as(transporter).Transported(ctx, id, "TRP_001", Step{PIC: "Agus", Location: "Dumai"}))
+	}
+}
+
+func TestSupplyChain(t *testing.T) {
+	for name, open := range implementations(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			as := open()
+			seed(t, as)
+
+			must(t, as(processor).Process(ctx, ProcessRequest{ProcessedID: "PCD_001", Processor: "PRC_001", Quantity: 45.5, Materials: []string{"COM_001", "COM_002"}, BatchNumber: "B-001", Quality: "A"}, Step{PIC: "Rina", Location: "Dumai"}))
+
+			lineage, err := as(admin).TraceProcessedCommodity(ctx, "PCD_001")
+			must(t, err)
+			if lineage.Batch.Quantity != 45.5 || len(lineage.Materials) != 2 || lineage.Materials[0].Farm.ID != "FARM_001" || lineage.Processor.ID != "PRC_001" {
+				t.Errorf("unexpected lineage %+v", lineage)
+			}
+
+			farm, err := as(farmer).QueryFarmByID(ctx, "FARM_001")
+			must(t, err)
+			if farm.Area != 2.5 || farm.PlantedYear != 2010 {
+				t.Errorf("unexpected farm %+v", farm)
+			}
+
+			commodities, err := as(collector).QueryCommoditiesByState(ctx, chaincode.StateProcessed)
+			must(t, err)
+			if len(commodities) != 2 {
+				t.Errorf("expected 2 processed commodities, got %d", len(commodities))
+			}
+
+			page, err := as(collector).QueryCommoditiesWithPagination(ctx, 1, "")
+			must(t, err)
+			if len(page.Records) != 1 || page.Records[0].ID != "COM_001" || page.Bookmark == "" {
+				t.Errorf("unexpected first page %+v", page)
+			}
+			page, err = as(collector).QueryCommoditiesWithPagination(ctx, 1, page.Bookmark)
+			must(t, err)
+			if len(page.Records) != 1 || page.Records[0].ID != "COM_002" {
+				t.Errorf("unexpected second page %+v", page)
+			}
+
+			forward, err := as(admin).TraceFarmForward(ctx, "FARM_001")
+			must(t, err)
+			if len(forward.Batches) != 1 || forward.Batches[0].ID != "PCD_001" {
+				t.Errorf("unexpected forward trace %+v", forward)
+			}
+		})
+	}
+}
+
+func TestPersonalData(t *testing.T) {
+	for name, open := range implementations(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			as := open()
+			seed(t, as)
+
+			details, err := as(farmer).QueryFarmerDetails(ctx, "FRM_001")
+			must(t, err)
+			if details.PersonalData.NIK != "1471010101900001" || len(details.PersonalData.Salt) != 2*saltLength {
+				t.Fatalf("expected a generated salt, got %+v", details.PersonalData)
+			}
+
+			// Updating without personal data keeps the stored personal data
+			must(t, as(farmer).UpdateFarmer(ctx, &chaincode.Farmer{ID: "FRM_001", Name: "Slamet Riyadi", Address: "Kampar", Farm: []string{"FARM_001"}}, nil))
+			updated, err := as(farmer).QueryFarmerDetails(ctx, "FRM_001")
+			must(t, err)
+			if updated.Farmer.Name != "Slamet Riyadi" || *updated.PersonalData != *details.PersonalData {
+				t.Errorf("unexpected details after update %+v", updated)
+			}
+
+			farmers, err := as(admin).QueryFarmersByNIK(ctx, "1471010101900001")
+			must(t, err)
+			if len(farmers) != 1 || farmers[0].ID != "FRM_001" {
+				t.Errorf("expected FRM_001 by NIK, got %+v", farmers)
+			}
+
+			owner, err := as(admin).ResolveIdentityNumber(ctx, chaincode.IdentityNIB, "1234567890124")
+			must(t, err)
+			if *owner != (chaincode.IdentityOwner{EntityType: "processor", EntityID: "PRC_001"}) {
+				t.Errorf("expected PRC_001 by NIB, got %+v", owner)
+			}
+			err = as(transporter).AddTransporter(ctx, &chaincode.Transporter{ID: "TRP_002", Name: "CV Sawit"}, &chaincode.PersonalData{NIK: "1471010101900001"})
+			if validationErr, ok := validation.Parse(err); !ok || validationErr.Fields[0].Code != validation.CodeDuplicate {
+				t.Errorf("expected a duplicate NIK error, got %v", err)
+			}
+
+			record, err := as(farmer).PurgePersonalData(ctx, "FRM_001")
+			must(t, err)
+			if record.EntityID != "FRM_001" || record.EntityType != "farmer" {
+				t.Errorf("unexpected erasure record %+v", record)
+			}
+			_, err = as(farmer).QueryFarmerDetails(ctx, "FRM_001")
+			if err == nil || !strings.Contains(err.Error(), "no personal data is stored") {
+				t.Errorf("expected the personal data to be purged, got %v", err)
+			}
+		})
+	}
+}
+
+func TestAdministration(t *testing.T) {
+	for name, open := range implementations(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			as := open()
+
+			must(t, as(admin).SetMSPRoles(ctx, "Org1MSP", []string{"admin", "farmer"}))
+			roles, err := as(farmer).QueryMSPRoles(ctx, "Org1MSP")
+			must(t, err)
+			if !reflect.DeepEqual(roles.Roles, []string{"admin", "farmer"}) {
+				t.Errorf("unexpected roles %+v", roles)
+			}
+
+			must(t, as(admin).SetExtractionRateRange(ctx, chaincode.ExtractionRateRange{Min: 0.2, Max: 0.25}))
+			rateRange, err := as(farmer).QueryExtractionRateRange(ctx)
+			must(t, err)
+			if *rateRange != (chaincode.ExtractionRateRange{Min: 0.2, Max: 0.25}) {
+				t.Errorf("unexpected extraction rate range %+v", rateRange)
+			}
+
+			migrated, err := as(admin).MigrateTraceability(ctx)
+			must(t, err)
+			migration, err := as(admin).MigratePersonalData(ctx, "")
+			must(t, err)
+			if migrated != 0 || migration.Migrated != 0 {
+				t.Errorf("expected nothing to migrate, got %d and %+v", migrated, migration)
+			}
+		})
+	}
+}
+
+func TestPatch(t *testing.T) {
+	for name, open := range implementations(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			as := open()
+			seed(t, as)
+
+			must(t, as(farmer).PatchFarm(ctx, "FARM_001", Patch{"certificate": "ISPO", "area": 3.25}))
+			farm, err := as(farmer).QueryFarmByID(ctx, "FARM_001")
+			must(t, err)
+			if farm.Certificate != "ISPO" || farm.Area != 3.25 || farm.Legality != "SHM" {
+				t.Errorf("unexpected farm after patch %+v", farm)
+			}
+
+			must(t, as(collector).PatchCollector(ctx, "COL_001", Patch{"address": "Pekanbaru"}, &chaincode.PersonalData{NIK: "1471010101900022"}))
+			details, err := as(collector).QueryCollectorDetails(ctx, "COL_001")
+			must(t, err)
+			if !reflect.DeepEqual(details.Collector.Partner, []string{"FRM_001"}) || details.Collector.Address != "Pekanbaru" || details.Collector.Name != "KUD Makmur" || details.PersonalData.NIK != "1471010101900022" {
+				t.Errorf("unexpected collector after patch %+v", details)
+			}
+
+			err = as(farmer).PatchFarmer(ctx, "FRM_001", Patch{"nikHash": ""}, nil)
+			if err == nil || !strings.Contains(err.Error(), "must not change nikHash") {
+				t.Errorf("expected a managed field error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFromJSON(t *testing.T) {
+	for name, open := range implementations(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			as := open()
+			seed(t, as)
+
+			must(t, as(farmer).AddFarmerFromJSON(ctx, json.RawMessage(`{"id": "FRM_002", "name": "Joko", "address": "Jambi", "farm": []}`), &chaincode.PersonalData{NIK: "1471010101900005"}))
+			must(t, as(farmer).AddFarmFromJSON(ctx, json.RawMessage(`{"id": "FARM_002", "owner": "FRM_002", "plantedYear": 2015, "seedVarieties": "Tenera", "area": 1.5, "address": "Jambi", "capacity": 10, "legality": "SHM", "certificate": "ISPO"}`)))
+			farm, err := as(farmer).QueryFarmByID(ctx, "FARM_002")
+			must(t, err)
+			if farm.Owner != "FRM_002" || farm.Area != 1.5 {
+				t.Errorf("unexpected farm %+v", farm)
+			}
+
+			must(t, as(transporter).UpdateTransporterFromJSON(ctx, json.RawMessage(`{"id": "TRP_001", "name": "CV Angkut Jaya", "numShip": 4}`), nil))
+			updated, err := as(transporter).QueryTransporterByID(ctx, "TRP_001")
+			must(t, err)
+			if updated.Name != "CV Angkut Jaya" || updated.NumShip != 4 {
+				t.Errorf("unexpected transporter %+v", updated)
+			}
+
+			err = as(farmer).UpdateFarmerFromJSON(ctx, json.RawMessage(`{"id": "FRM_001", "name": "Slamet", "address": "Kampar", "farm": ["FARM_001"], "nikHash": ""}`), nil)
+			if err == nil || !strings.Contains(err.Error(), "nikHash") {
+				t.Errorf("expected a managed field error, got %v", err)
+			}
+		})
+	}
+}
+
+// TestClientCoversContract checks that every transaction of the contract has
+// a Client method of the same name and that the Client has no other methods
+func TestClientCoversContract(t *testing.T) {
+	inherited := reflect.TypeOf(&contractapi.Contract{})
+	transactions := map[string]bool{}
+	contract := reflect.TypeOf(&chaincode.PalmOilContract{})
+	for i := 0; i < contract.NumMethod(); i++ {
+		name := contract.Method(i).Name
+		if _, ok := inherited.MethodByName(name); !ok {
+			transactions[name] = true
+		}
+	}
+
+	client := reflect.TypeOf((*Client)(nil)).Elem()
+	for i := 0; i < client.NumMethod(); i++ {
+		name := client.Method(i).Name
+		if !transactions[name] {
+			t.Errorf("the Client method %s is not a transaction of the contract", name)
+		}
+		delete(transactions, name)
+	}
+	for name := range transactions {
+		t.Errorf("the transaction %s has no Client method", name)
+	}
+}
+
+func TestFarmTransfer(t *testing.T) {
+	for name, open := range implementations(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			as := open()
+			seed(t, as)
+			must(t, as(admin).AddFarmer(ctx, &chaincode.Farmer{ID: "FRM_002", Name: "Joko", Address: "Jambi"}, &chaincode.PersonalData{NIK: "1471010101900005"}))
+
+			must(t, as(farmer).TransferFarmOwnership(ctx, "FARM_001", "FRM_002"))
+			must(t, as(farmer).CancelFarmOwnershipTransfer(ctx, "FARM_001"))
+			must(t, as(farmer).TransferFarmOwnership(ctx, "FARM_001", "FRM_002"))
+			transfer, err := as(collector).QueryFarmTransfer(ctx, "FARM_001")
+			must(t, err)
+			if transfer.Status != chaincode.TransferPending || transfer.From != "FRM_001" || transfer.To != "FRM_002" {
+				t.Errorf("unexpected transfer %+v", transfer)
+			}
+
+			must(t, as(admin).AcceptFarmOwnership(ctx, "FARM_001"))
+			farm, err := as(farmer).QueryFarmByID(ctx, "FARM_001")
+			must(t, err)
+			if farm.Owner != "FRM_002" {
+				t.Errorf("expected FRM_002 to own the farm, got %s", farm.Owner)
+			}
+			farmer2, err := as(admin).QueryFarmerByID(ctx, "FRM_002")
+			must(t, err)
+			if !reflect.DeepEqual(farmer2.Farm, []string{"FARM_001"}) {
+				t.Errorf("expected FRM_002 to list FARM_001, got %v", farmer2.Farm)
+			}
+
+			err = as(admin).RejectFarmOwnership(ctx, "FARM_001")
+			if err == nil || !strings.Contains(err.Error(), "has no pending transfer") {
+				t.Errorf("expected a no pending transfer error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestPartnership(t *testing.T) {
+	for name, open := range implementations(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			as := open()
+			seed(t, as)
+
+			must(t, as(farmer).ProposePartnership(ctx, &chaincode.Partnership{ID: "PRT_002", CollectorID: "COL_001", PartnerType: chaincode.PartnerFarm, PartnerID: "FARM_001", StartDate: "2024-01-01", EndDate: "2025-12-31"}))
+			partnership, err := as(collector).QueryPartnership(ctx, "PRT_002")
+			must(t, err)
+			if partnership.Status != chaincode.PartnershipProposed || partnership.PartnerConsent == nil || partnership.CollectorConsent != nil {
+				t.Errorf("unexpected proposed partnership %+v", partnership)
+			}
+			must(t, as(collector).RejectPartnership(ctx, "PRT_002"))
+
+			partnerships, err := as(admin).QueryPartnershipsByCollector(ctx, "COL_001")
+			must(t, err)
+			if len(partnerships) != 2 || partnerships[0].ID != "PRT_002" || partnerships[0].Status != chaincode.PartnershipRejected {
+				t.Errorf("unexpected partnerships of COL_001 %+v", partnerships)
+			}
+
+			must(t, as(farmer).EndPartnership(ctx, "PRT_001"))
+			partnerships, err = as(admin).QueryPartnershipsByPartner(ctx, chaincode.PartnerFarmer, "FRM_001")
+			must(t, err)
+			if len(partnerships) != 1 || partnerships[0].Status != chaincode.PartnershipEnded {
+				t.Errorf("unexpected partnerships of FRM_001 %+v", partnerships)
+			}
+			collectorRecord, err := as(admin).QueryCollectorByID(ctx, "COL_001")
+			must(t, err)
+			if len(collectorRecord.Partner) != 0 {
+				t.Errorf("expected COL_001 to have no partners, got %v", collectorRecord.Partner)
+			}
+
+			must(t, as(farmer).Harvest(ctx, HarvestRequest{CommodityID: "COM_003", FarmID: "FARM_001", Name: "FFB", Quantity: 50, DateHarvested: "2024-01-12", TraceabilityID: "TR_COM_003"}, Step{PIC: "Budi", Location: "Kampar"}))
+			err = as(collector).Collect(ctx, "COM_003", "COL_001", Step{PIC: "Sari", Location: "Pekanbaru"})
+			if err == nil || !strings.Contains(err.Error(), "is not an active partner") {
+				t.Errorf("expected a partnership error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for name, open := range implementations(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			as := open()
+			seed(t, as)
+
+			_, err := as(farmer).QueryFarmerByID(ctx, "FRM_404")
+			if err == nil || !strings.Contains(err.Error(), "does not exist") {
+				t.Errorf("expected a missing farmer error, got %v", err)
+			}
+
+			err = as(farmer).AddFarmer(ctx, &chaincode.Farmer{ID: "FRM_001", Name: "Slamet"}, &chaincode.PersonalData{NIK: "1471010101900001"})
+			if err == nil || !strings.Contains(err.Error(), "already exists") {
+				t.Errorf("expected a duplicate farmer error, got %v", err)
+			}
+
+			err = as(farmer).AddFarmer(ctx, &chaincode.Farmer{ID: "FRM_002", Name: "Sri"}, &chaincode.PersonalData{NIK: "1471010101900002", NoHP: "0812"})
+			if invalid, ok := validation.Parse(err); !ok || invalid.Fields[0].Field != "personal.noHP" {
+				t.Errorf("expected an invalid phone number, got %v", err)
+			}
+
+			err = as(transporter).Harvest(ctx, HarvestRequest{CommodityID: "COM_003", FarmID: "FARM_001", Quantity: 10}, Step{})
+			if err == nil || !strings.Contains(err.Error(), "permission denied") {
+				t.Errorf("expected a permission error, got %v", err)
+			}
+
+			err = as(admin).RecallBatch(ctx, RecallRequest{RecallID: "RCL_001", OriginType: chaincode.OriginFarm, OriginID: "FARM_001", Reason: "illegal clearing", PIC: "Dewi"})
+			must(t, err)
+			err = as(processor).Process(ctx, ProcessRequest{ProcessedID: "PCD_001", Processor: "PRC_001", Quantity: 45, Materials: []string{"COM_001", "COM_002"}}, Step{})
+			if err == nil || !strings.Contains(err.Error(), "recalled") {
+				t.Errorf("expected recalled materials to be refused, got %v", err)
+			}
+		})
+	}
+}
+
+func TestGetEntityAsOf(t *testing.T) {
+	for name, open := range implementations(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			as := open()
+			seed(t, as)
+
+			asOf := time.Date(2024, 1, 1, 0, 0, 0, 500000000, time.UTC)
+			entry, err := as(admin).GetEntityAsOf(ctx, "farmer", "FRM_001", asOf)
+			must(t, err)
+			if !strings.Contains(entry.Value, `"FRM_001"`) {
+				t.Errorf("unexpected history entry %+v", entry)
+			}
+		})
+	}
+}
+
+func TestContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c := NewInProcess(memledger.New(), admin)
+	err := c.SetExtractionRateRange(ctx, chaincode.ExtractionRateRange{Min: 0.2, Max: 0.25})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if c.Ledger().BlockNumber() != 0 {
+		t.Fatal("a canceled transaction was committed")
+	}
+}
@@ -0,0 +1,593 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"chaincode-if/chaincode"
+
+	gwclient "github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// Transactor submits and evaluates contract transactions with string arguments
+type Transactor interface {
+	// Submit endorses and commits a transaction and returns its result
+	Submit(ctx context.Context, name string, transient map[string][]byte, args ...string) ([]byte, error)
+	// Evaluate runs a transaction on one peer without committing it
+	Evaluate(ctx context.Context, name string, transient map[string][]byte, args ...string) ([]byte, error)
+}
+
+// Gateway is a Client that encodes calls as the contract's string arguments
+// and sends them through a Transactor
+type Gateway struct {
+	transactor Transactor
+	indexKey   string
+}
+
+// NewGateway returns a Client of a contract on a Fabric network. Transactions
+// carrying personal data are endorsed by the peers of mspID only, so that the
+// data is not sent to the peers of other organizations.
+func NewGateway(contract *gwclient.Contract, mspID string) *Gateway {
+	return &Gateway{transactor: &gatewayTransactor{contract: contract, mspID: mspID}}
+}
+
+// NewTransactorClient returns a Client that sends its calls through a Transactor
+func NewTransactorClient(transactor Transactor) *Gateway {
+	return &Gateway{transactor: transactor}
+}
+
+// WithIndexKey returns a Client that passes the channel's identity index key
+// with the calls that register, release or resolve a NIK
+func (g *Gateway) WithIndexKey(key string) *Gateway {
+	return &Gateway{transactor: g.transactor, indexKey: key}
+}
+
+// gatewayTransactor sends transactions through the Fabric Gateway
+type gatewayTransactor struct {
+	contract *gwclient.Contract
+	mspID    string
+}
+
+func (t *gatewayTransactor) Submit(ctx context.Context, name string, transient map[string][]byte, args ...string) ([]byte, error) {
+	opts := []gwclient.ProposalOption{gwclient.WithArguments(args...)}
+	if transient != nil {
+		opts = append(opts, gwclient.WithTransient(transient), gwclient.WithEndorsingOrganizations(t.mspID))
+	}
+
+	proposal, err := t.contract.NewProposal(name, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s proposal: %v", name, err)
+	}
+	transaction, err := proposal.EndorseWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to endorse %s: %w", name, err)
+	}
+	commit, err := transaction.SubmitWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to submit %s: %w", name, err)
+	}
+	status, err := commit.StatusWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the commit status of %s: %w", name, err)
+	}
+	if !status.Successful {
+		return nil, fmt.Errorf("transaction %s of %s failed to commit with status %v", status.TransactionID, name, status.Code)
+	}
+
+	return transaction.Result(), nil
+}
+
+func (t *gatewayTransactor) Evaluate(ctx context.Context, name string, transient map[string][]byte, args ...string) ([]byte, error) {
+	opts := []gwclient.ProposalOption{gwclient.WithArguments(args...)}
+	if transient != nil {
+		opts = append(opts, gwclient.WithTransient(transient))
+	}
+
+	proposal, err := t.contract.NewProposal(name, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s proposal: %v", name, err)
+	}
+	result, err := proposal.EvaluateWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate %s: %w", name, err)
+	}
+
+	return result, nil
+}
+
+// submit submits a transaction that returns nothing
+func (g *Gateway) submit(ctx context.Context, transient map[string][]byte, name string, args ...string) error {
+	_, err := g.transactor.Submit(ctx, name, transient, args...)
+	return err
+}
+
+// submitPersonal submits a transaction that carries personal data
+func (g *Gateway) submitPersonal(ctx context.Context, personal *chaincode.PersonalData, name string, args ...string) error {
+	transient, err := personalTransient(personal, g.indexKey)
+	if err != nil {
+		return err
+	}
+	return g.submit(ctx, transient, name, args...)
+}
+
+// submitResult submits a transaction and decodes its JSON result
+func submitResult[T any](ctx context.Context, g *Gateway, transient map[string][]byte, name string, args ...string) (T, error) {
+	var result T
+	resultJSON, err := g.transactor.Submit(ctx, name, transient, args...)
+	if err != nil {
+		return result, err
+	}
+	if len(resultJSON) == 0 {
+		return result, nil
+	}
+	err = json.Unmarshal(resultJSON, &result)
+	if err != nil {
+		return result, fmt.Errorf("failed to decode the result of %s: %v", name, err)
+	}
+	return result, nil
+}
+
+// evaluate evaluates a transaction and decodes its JSON result
+func evaluate[T any](ctx context.Context, g *Gateway, name string, args ...string) (T, error) {
+	return evaluateTransient[T](ctx, g, nil, name, args...)
+}
+
+// evaluateTransient evaluates a transaction carrying transient data and
+// decodes its JSON result
+func evaluateTransient[T any](ctx context.Context, g *Gateway, transient map[string][]byte, name string, args ...string) (T, error) {
+	var result T
+	resultJSON, err := g.transactor.Evaluate(ctx, name, transient, args...)
+	if err != nil {
+		return result, err
+	}
+	if len(resultJSON) == 0 {
+		return result, nil
+	}
+	err = json.Unmarshal(resultJSON, &result)
+	if err != nil {
+		return result, fmt.Errorf("failed to decode the result of %s: %v", name, err)
+	}
+	return result, nil
+}
+
+// formatFloat formats a number argument
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// pageArgs formats the arguments of a paginated query
+func pageArgs(pageSize int32, bookmark string) []string {
+	return []string{strconv.FormatInt(int64(pageSize), 10), bookmark}
+}
+
+func (g *Gateway) SetMSPRoles(ctx context.Context, mspID string, roles []string) error {
+	return g.submit(ctx, nil, "SetMSPRoles", mspID, jsonList(roles))
+}
+
+func (g *Gateway) QueryMSPRoles(ctx context.Context, mspID string) (*chaincode.MSPRoles, error) {
+	return evaluate[*chaincode.MSPRoles](ctx, g, "QueryMSPRoles", mspID)
+}
+
+func (g *Gateway) AddFarmer(ctx context.Context, farmer *chaincode.Farmer, personal *chaincode.PersonalData) error {
+	return g.submitPersonal(ctx, personal, "AddFarmer", farmer.ID, farmer.Name, farmer.Address, jsonList(farmer.Farm))
+}
+
+func (g *Gateway) UpdateFarmer(ctx context.Context, farmer *chaincode.Farmer, personal *chaincode.PersonalData) error {
+	return g.submitPersonal(ctx, personal, "UpdateFarmer", farmer.ID, farmer.Name, farmer.Address, jsonList(farmer.Farm))
+}
+
+func (g *Gateway) PatchFarmer(ctx context.Context, id string, patch Patch, personal *chaincode.PersonalData) error {
+	patchJSON, err := patchArg(patch)
+	if err != nil {
+		return err
+	}
+	return g.submitPersonal(ctx, personal, "PatchFarmer", id, patchJSON)
+}
+
+func (g *Gateway) AddFarmerFromJSON(ctx context.Context, document json.RawMessage, personal *chaincode.PersonalData) error {
+	return g.submitPersonal(ctx, personal, "AddFarmerFromJSON", string(document))
+}
+
+func (g *Gateway) UpdateFarmerFromJSON(ctx context.Context, document json.RawMessage, personal *chaincode.PersonalData) error {
+	return g.submitPersonal(ctx, personal, "UpdateFarmerFromJSON", string(document))
+}
+
+func (g *Gateway) QueryFarmerByID(ctx context.Context, id string) (*chaincode.Farmer, error) {
+	return evaluate[*chaincode.Farmer](ctx, g, "QueryFarmerByID", id)
+}
+
+func (g *Gateway) QueryAllFarmers(ctx context.Context) ([]*chaincode.Farmer, error) {
+	return evaluate[[]*chaincode.Farmer](ctx, g, "QueryAllFarmers")
+}
+
+// farmArgs formats the arguments of AddFarm and UpdateFarm
+func farmArgs(farm *chaincode.Farm) []string {
+	return []string{farm.ID, farm.Owner, strconv.Itoa(farm.PlantedYear), farm.SeedVarieties, formatFloat(farm.Area), farm.Address, farm.Coordinate, formatFloat(farm.Capacity), farm.Legality, farm.Certificate}
+}
+
+func (g *Gateway) AddFarm(ctx context.Context, farm *chaincode.Farm) error {
+	return g.submit(ctx, nil, "AddFarm", farmArgs(farm)...)
+}
+
+func (g *Gateway) UpdateFarm(ctx context.Context, farm *chaincode.Farm) error {
+	return g.submit(ctx, nil, "UpdateFarm", farmArgs(farm)...)
+}
+
+func (g *Gateway) PatchFarm(ctx context.Context, id string, patch Patch) error {
+	patchJSON, err := patchArg(patch)
+	if err != nil {
+		return err
+	}
+	return g.submit(ctx, nil, "PatchFarm", id, patchJSON)
+}
+
+func (g *Gateway) AddFarmFromJSON(ctx context.Context, document json.RawMessage) error {
+	return g.submit(ctx, nil, "AddFarmFromJSON", string(document))
+}
+
+func (g *Gateway) UpdateFarmFromJSON(ctx context.Context, document json.RawMessage) error {
+	return g.submit(ctx, nil, "UpdateFarmFromJSON", string(document))
+}
+
+func (g *Gateway) QueryFarmByID(ctx context.Context, id string) (*chaincode.Farm, error) {
+	return evaluate[*chaincode.Farm](ctx, g, "QueryFarmByID", id)
+}
+
+func (g *Gateway) QueryAllFarms(ctx context.Context) ([]*chaincode.Farm, error) {
+	return evaluate[[]*chaincode.Farm](ctx, g, "QueryAllFarms")
+}
+
+func (g *Gateway) TransferFarmOwnership(ctx context.Context, farmID string, newOwner string) error {
+	return g.submit(ctx, nil, "TransferFarmOwnership", farmID, newOwner)
+}
+
+func (g *Gateway) AcceptFarmOwnership(ctx context.Context, farmID string) error {
+	return g.submit(ctx, nil, "AcceptFarmOwnership", farmID)
+}
+
+func (g *Gateway) RejectFarmOwnership(ctx context.Context, farmID string) error {
+	return g.submit(ctx, nil, "RejectFarmOwnership", farmID)
+}
+
+func (g *Gateway) CancelFarmOwnershipTransfer(ctx context.Context, farmID string) error {
+	return g.submit(ctx, nil, "CancelFarmOwnershipTransfer", farmID)
+}
+
+func (g *Gateway) QueryFarmTransfer(ctx context.Context, farmID string) (*chaincode.FarmTransfer, error) {
+	return evaluate[*chaincode.FarmTransfer](ctx, g, "QueryFarmTransfer", farmID)
+}
+
+func (g *Gateway) AddCollector(ctx context.Context, collector *chaincode.Collector, personal *chaincode.PersonalData) error {
+	return g.submitPersonal(ctx, personal, "AddCollector", collector.ID, collector.Name, collector.NIB, collector.Address, formatFloat(collector.Capacity), jsonList(collector.Partner))
+}
+
+func (g *Gateway) UpdateCollector(ctx context.Context, collector *chaincode.Collector, personal *chaincode.PersonalData) error {
+	return g.submitPersonal(ctx, personal, "UpdateCollector", collector.ID, collector.Name, collector.NIB, collector.Address, formatFloat(collector.Capacity), jsonList(collector.Partner))
+}
+
+func (g *Gateway) PatchCollector(ctx context.Context, id string, patch Patch, personal *chaincode.PersonalData) error {
+	patchJSON, err := patchArg(patch)
+	if err != nil {
+		return err
+	}
+	return g.submitPersonal(ctx, personal, "PatchCollector", id, patchJSON)
+}
+
+func (g *Gateway) AddCollectorFromJSON(ctx context.Context, document json.RawMessage, personal *chaincode.PersonalData) error {
+	return g.submitPersonal(ctx, personal, "AddCollectorFromJSON", string(document))
+}
+
+func (g *Gateway) UpdateCollectorFromJSON(ctx context.Context, document json.RawMessage, personal *chaincode.PersonalData) error {
+	return g.submitPersonal(ctx, personal, "UpdateCollectorFromJSON", string(document))
+}
+
+func (g *Gateway) QueryCollectorByID(ctx context.Context, id string) (*chaincode.Collector, error) {
+	return evaluate[*chaincode.Collector](ctx, g, "QueryCollectorByID", id)
+}
+
+func (g *Gateway) QueryAllCollectors(ctx context.Context) ([]*chaincode.Collector, error) {
+	return evaluate[[]*chaincode.Collector](ctx, g, "QueryAllCollectors")
+}
+
+func (g *Gateway) ProposePartnership(ctx context.Context, partnership *chaincode.Partnership) error {
+	return g.submit(ctx, nil, "ProposePartnership", partnership.ID, partnership.CollectorID, partnership.PartnerType, partnership.PartnerID, partnership.StartDate, partnership.EndDate)
+}
+
+func (g *Gateway) AcceptPartnership(ctx context.Context, id string) error {
+	return g.submit(ctx, nil, "AcceptPartnership", id)
+}
+
+func (g *Gateway) RejectPartnership(ctx context.Context, id string) error {
+	return g.submit(ctx, nil, "RejectPartnership", id)
+}
+
+func (g *Gateway) EndPartnership(ctx context.Context, id string) error {
+	return g.submit(ctx, nil, "EndPartnership", id)
+}
+
+func (g *Gateway) QueryPartnership(ctx context.Context, id string) (*chaincode.Partnership, error) {
+	return evaluate[*chaincode.Partnership](ctx, g, "QueryPartnership", id)
+}
+
+func (g *Gateway) QueryPartnershipsByCollector(ctx context.Context, collectorID string) ([]*chaincode.Partnership, error) {
+	return evaluate[[]*chaincode.Partnership](ctx, g, "QueryPartnershipsByCollector", collectorID)
+}
+
+func (g *Gateway) QueryPartnershipsByPartner(ctx context.Context, partnerType string, partnerID string) ([]*chaincode.Partnership, error) {
+	return evaluate[[]*chaincode.Partnership](ctx, g, "QueryPartnershipsByPartner", partnerType, partnerID)
+}
+
+func (g *Gateway) AddProcessor(ctx context.Context, processor *chaincode.Processor, personal *chaincode.PersonalData) error {
+	return g.submitPersonal(ctx, personal, "AddProcessor", processor.ID, processor.Name, processor.NIB, processor.Address, formatFloat(processor.Capacity))
+}
+
+func (g *Gateway) UpdateProcessor(ctx context.Context, processor *chaincode.Processor, personal *chaincode.PersonalData) error {
+	return g.submitPersonal(ctx, personal, "UpdateProcessor", processor.ID, processor.Name, processor.NIB, processor.Address, formatFloat(processor.Capacity))
+}
+
+func (g *Gateway) PatchProcessor(ctx context.Context, id string, patch Patch, personal *chaincode.PersonalData) error {
+	patchJSON, err := patchArg(patch)
+	if err != nil {
+		return err
+	}
+	return g.submitPersonal(ctx, personal, "PatchProcessor", id, patchJSON)
+}
+
+func (g *Gateway) AddProcessorFromJSON(ctx context.Context, document json.RawMessage, personal *chaincode.PersonalData) error {
+	return g.submitPersonal(ctx, personal, "AddProcessorFromJSON", string(document))
+}
+
+func (g *Gateway) UpdateProcessorFromJSON(ctx context.Context, document json.RawMessage, personal *chaincode.PersonalData) error {
+	return g.submitPersonal(ctx, personal, "UpdateProcessorFromJSON", string(document))
+}
+
+func (g *Gateway) QueryProcessorByID(ctx context.Context, id string) (*chaincode.Processor, error) {
+	return evaluate[*chaincode.Processor](ctx, g, "QueryProcessorByID", id)
+}
+
+func (g *Gateway) QueryAllProcessors(ctx context.Context) ([]*chaincode.Processor, error) {
+	return evaluate[[]*chaincode.Processor](ctx, g, "QueryAllProcessors")
+}
+
+func (g *Gateway) AddTransporter(ctx context.Context, transporter *chaincode.Transporter, personal *chaincode.PersonalData) error {
+	return g.submitPersonal(ctx, personal, "AddTransporter", transporter.ID, transporter.Name, strconv.Itoa(transporter.NumShip))
+}
+
+func (g *Gateway) UpdateTransporter(ctx context.Context, transporter *chaincode.Transporter, personal *chaincode.PersonalData) error {
+	return g.submitPersonal(ctx, personal, "UpdateTransporter", transporter.ID, transporter.Name, strconv.Itoa(transporter.NumShip))
+}
+
+func (g *Gateway) PatchTransporter(ctx context.Context, id string, patch Patch, personal *chaincode.PersonalData) error {
+	patchJSON, err := patchArg(patch)
+	if err != nil {
+		return err
+	}
+	return g.submitPersonal(ctx, personal, "PatchTransporter", id, patchJSON)
+}
+
+func (g *Gateway) AddTransporterFromJSON(ctx context.Context, document json.RawMessage, personal *chaincode.PersonalData) error {
+	return g.submitPersonal(ctx, personal, "AddTransporterFromJSON", string(document))
+}
+
+func (g *Gateway) UpdateTransporterFromJSON(ctx context.Context, document json.RawMessage, personal *chaincode.PersonalData) error {
+	return g.submitPersonal(ctx, personal, "UpdateTransporterFromJSON", string(document))
+}
+
+func (g *Gateway) QueryTransporterByID(ctx context.Context, id string) (*chaincode.Transporter, error) {
+	return evaluate[*chaincode.Transporter](ctx, g, "QueryTransporterByID", id)
+}
+
+func (g *Gateway) QueryAllTransporters(ctx context.Context) ([]*chaincode.Transporter, error) {
+	return evaluate[[]*chaincode.Transporter](ctx, g, "QueryAllTransporters")
+}
+
+func (g *Gateway) Harvest(ctx context.Context, harvest HarvestRequest, step Step) error {
+	return g.submit(ctx, nil, "Harvest", harvest.CommodityID, harvest.FarmID, harvest.Name, formatFloat(harvest.Quantity), harvest.DateHarvested, harvest.TraceabilityID, step.PIC, step.Location)
+}
+
+func (g *Gateway) Collect(ctx context.Context, commodityID string, collectorID string, step Step) error {
+	return g.submit(ctx, nil, "Collect", commodityID, collectorID, step.PIC, step.Location)
+}
+
+func (g *Gateway) Transport(ctx context.Context, commodityID string, transporterID string, step Step) error {
+	return g.submit(ctx, nil, "Transport", commodityID, transporterID, step.PIC, step.Location)
+}
+
+func (g *Gateway) Transported(ctx context.Context, commodityID string, transporterID string, step Step) error {
+	return g.submit(ctx, nil, "Transported", commodityID, transporterID, step.PIC, step.Location)
+}
+
+func (g *Gateway) HoldCommodity(ctx context.Context, commodityID string, step Step) error {
+	return g.submit(ctx, nil, "HoldCommodity", commodityID, step.PIC, step.Location)
+}
+
+func (g *Gateway) ReleaseCommodity(ctx context.Context, commodityID string, step Step) error {
+	return g.submit(ctx, nil, "ReleaseCommodity", commodityID, step.PIC, step.Location)
+}
+
+func (g *Gateway) RejectCommodity(ctx context.Context, commodityID string, step Step) error {
+	return g.submit(ctx, nil, "RejectCommodity", commodityID, step.PIC, step.Location)
+}
+
+func (g *Gateway) Process(ctx context.Context, process ProcessRequest, step Step) error {
+	return g.submit(ctx, nil, "Process", process.ProcessedID, process.Processor, formatFloat(process.Quantity), jsonList(process.Materials), process.BatchNumber, process.Quality, step.PIC, step.Location)
+}
+
+func (g *Gateway) QueryCommodityByID(ctx context.Context, commodityID string) (*chaincode.Commodity, error) {
+	return evaluate[*chaincode.Commodity](ctx, g, "QueryCommodityByID", commodityID)
+}
+
+func (g *Gateway) QueryAllCommodities(ctx context.Context) ([]*chaincode.Commodity, error) {
+	return evaluate[[]*chaincode.Commodity](ctx, g, "QueryAllCommodities")
+}
+
+func (g *Gateway) QueryProcessedCommodityByID(ctx context.Context, processedID string) (*chaincode.ProcessedCommodity, error) {
+	return evaluate[*chaincode.ProcessedCommodity](ctx, g, "QueryProcessedCommodityByID", processedID)
+}
+
+func (g *Gateway) QueryAllProcessedCommodities(ctx context.Context) ([]*chaincode.ProcessedCommodity, error) {
+	return evaluate[[]*chaincode.ProcessedCommodity](ctx, g, "QueryAllProcessedCommodities")
+}
+
+func (g *Gateway) RecallBatch(ctx context.Context, recall RecallRequest) error {
+	return g.submit(ctx, nil, "RecallBatch", recall.RecallID, recall.OriginType, recall.OriginID, recall.Reason, recall.PIC)
+}
+
+func (g *Gateway) QueryRecallByID(ctx context.Context, recallID string) (*chaincode.Recall, error) {
+	return evaluate[*chaincode.Recall](ctx, g, "QueryRecallByID", recallID)
+}
+
+func (g *Gateway) SetExtractionRateRange(ctx context.Context, rateRange chaincode.ExtractionRateRange) error {
+	return g.submit(ctx, nil, "SetExtractionRateRange", formatFloat(rateRange.Min), formatFloat(rateRange.Max))
+}
+
+func (g *Gateway) QueryExtractionRateRange(ctx context.Context) (*chaincode.ExtractionRateRange, error) {
+	return evaluate[*chaincode.ExtractionRateRange](ctx, g, "QueryExtractionRateRange")
+}
+
+func (g *Gateway) TraceProcessedCommodity(ctx context.Context, processedID string) (*chaincode.BatchLineage, error) {
+	return evaluate[*chaincode.BatchLineage](ctx, g, "TraceProcessedCommodity", processedID)
+}
+
+func (g *Gateway) TraceFarmForward(ctx context.Context, farmID string) (*chaincode.ForwardTrace, error) {
+	return evaluate[*chaincode.ForwardTrace](ctx, g, "TraceFarmForward", farmID)
+}
+
+func (g *Gateway) TraceCommodityForward(ctx context.Context, commodityID string) (*chaincode.ForwardTrace, error) {
+	return evaluate[*chaincode.ForwardTrace](ctx, g, "TraceCommodityForward", commodityID)
+}
+
+func (g *Gateway) GetFarmerHistory(ctx context.Context, id string) ([]*chaincode.HistoryEntry, error) {
+	return evaluate[[]*chaincode.HistoryEntry](ctx, g, "GetFarmerHistory", id)
+}
+
+func (g *Gateway) GetFarmHistory(ctx context.Context, id string) ([]*chaincode.HistoryEntry, error) {
+	return evaluate[[]*chaincode.HistoryEntry](ctx, g, "GetFarmHistory", id)
+}
+
+func (g *Gateway) GetCollectorHistory(ctx context.Context, id string) ([]*chaincode.HistoryEntry, error) {
+	return evaluate[[]*chaincode.HistoryEntry](ctx, g, "GetCollectorHistory", id)
+}
+
+func (g *Gateway) GetProcessorHistory(ctx context.Context, id string) ([]*chaincode.HistoryEntry, error) {
+	return evaluate[[]*chaincode.HistoryEntry](ctx, g, "GetProcessorHistory", id)
+}
+
+func (g *Gateway) GetTransporterHistory(ctx context.Context, id string) ([]*chaincode.HistoryEntry, error) {
+	return evaluate[[]*chaincode.HistoryEntry](ctx, g, "GetTransporterHistory", id)
+}
+
+func (g *Gateway) GetCommodityHistory(ctx context.Context, id string) ([]*chaincode.HistoryEntry, error) {
+	return evaluate[[]*chaincode.HistoryEntry](ctx, g, "GetCommodityHistory", id)
+}
+
+func (g *Gateway) GetEntityAsOf(ctx context.Context, entityType string, id string, asOf time.Time) (*chaincode.HistoryEntry, error) {
+	return evaluate[*chaincode.HistoryEntry](ctx, g, "GetEntityAsOf", entityType, id, timestampArg(asOf))
+}
+
+func (g *Gateway) QueryFarmersWithPagination(ctx context.Context, pageSize int32, bookmark string) (*chaincode.FarmerPage, error) {
+	return evaluate[*chaincode.FarmerPage](ctx, g, "QueryFarmersWithPagination", pageArgs(pageSize, bookmark)...)
+}
+
+func (g *Gateway) QueryFarmsWithPagination(ctx context.Context, pageSize int32, bookmark string) (*chaincode.FarmPage, error) {
+	return evaluate[*chaincode.FarmPage](ctx, g, "QueryFarmsWithPagination", pageArgs(pageSize, bookmark)...)
+}
+
+func (g *Gateway) QueryCollectorsWithPagination(ctx context.Context, pageSize int32, bookmark string) (*chaincode.CollectorPage, error) {
+	return evaluate[*chaincode.CollectorPage](ctx, g, "QueryCollectorsWithPagination", pageArgs(pageSize, bookmark)...)
+}
+
+func (g *Gateway) QueryProcessorsWithPagination(ctx context.Context, pageSize int32, bookmark string) (*chaincode.ProcessorPage, error) {
+	return evaluate[*chaincode.ProcessorPage](ctx, g, "QueryProcessorsWithPagination", pageArgs(pageSize, bookmark)...)
+}
+
+func (g *Gateway) QueryTransportersWithPagination(ctx context.Context, pageSize int32, bookmark string) (*chaincode.TransporterPage, error) {
+	return evaluate[*chaincode.TransporterPage](ctx, g, "QueryTransportersWithPagination", pageArgs(pageSize, bookmark)...)
+}
+
+func (g *Gateway) QueryCommoditiesWithPagination(ctx context.Context, pageSize int32, bookmark string) (*chaincode.CommodityPage, error) {
+	return evaluate[*chaincode.CommodityPage](ctx, g, "QueryCommoditiesWithPagination", pageArgs(pageSize, bookmark)...)
+}
+
+func (g *Gateway) QueryProcessedCommoditiesWithPagination(ctx context.Context, pageSize int32, bookmark string) (*chaincode.ProcessedCommodityPage, error) {
+	return evaluate[*chaincode.ProcessedCommodityPage](ctx, g, "QueryProcessedCommoditiesWithPagination", pageArgs(pageSize, bookmark)...)
+}
+
+func (g *Gateway) QueryFarmersByNIK(ctx context.Context, nik string) ([]*chaincode.Farmer, error) {
+	return evaluate[[]*chaincode.Farmer](ctx, g, "QueryFarmersByNIK", nik)
+}
+
+func (g *Gateway) QueryCollectorsByNIB(ctx context.Context, nib string) ([]*chaincode.Collector, error) {
+	return evaluate[[]*chaincode.Collector](ctx, g, "QueryCollectorsByNIB", nib)
+}
+
+func (g *Gateway) QueryProcessorsByNIB(ctx context.Context, nib string) ([]*chaincode.Processor, error) {
+	return evaluate[[]*chaincode.Processor](ctx, g, "QueryProcessorsByNIB", nib)
+}
+
+func (g *Gateway) ResolveIdentityNumber(ctx context.Context, kind string, number string) (*chaincode.IdentityOwner, error) {
+	return evaluateTransient[*chaincode.IdentityOwner](ctx, g, indexKeyTransient(g.indexKey), "ResolveIdentityNumber", kind, number)
+}
+
+func (g *Gateway) QueryFarmsByAttributes(ctx context.Context, legality string, certificate string, seedVarieties string) ([]*chaincode.Farm, error) {
+	return evaluate[[]*chaincode.Farm](ctx, g, "QueryFarmsByAttributes", legality, certificate, seedVarieties)
+}
+
+func (g *Gateway) QueryCommoditiesByState(ctx context.Context, state chaincode.CommodityState) ([]*chaincode.Commodity, error) {
+	return evaluate[[]*chaincode.Commodity](ctx, g, "QueryCommoditiesByState", string(state))
+}
+
+func (g *Gateway) QueryCommoditiesByStateWithPagination(ctx context.Context, state chaincode.CommodityState, pageSize int32, bookmark string) (*chaincode.CommodityPage, error) {
+	return evaluate[*chaincode.CommodityPage](ctx, g, "QueryCommoditiesByStateWithPagination", append([]string{string(state)}, pageArgs(pageSize, bookmark)...)...)
+}
+
+func (g *Gateway) QueryCommoditiesByHarvestDate(ctx context.Context, from string, to string) ([]*chaincode.Commodity, error) {
+	return evaluate[[]*chaincode.Commodity](ctx, g, "QueryCommoditiesByHarvestDate", from, to)
+}
+
+func (g *Gateway) QueryFarmerDetails(ctx context.Context, id string) (*chaincode.FarmerDetails, error) {
+	return evaluate[*chaincode.FarmerDetails](ctx, g, "QueryFarmerDetails", id)
+}
+
+func (g *Gateway) QueryCollectorDetails(ctx context.Context, id string) (*chaincode.CollectorDetails, error) {
+	return evaluate[*chaincode.CollectorDetails](ctx, g, "QueryCollectorDetails", id)
+}
+
+func (g *Gateway) QueryProcessorDetails(ctx context.Context, id string) (*chaincode.ProcessorDetails, error) {
+	return evaluate[*chaincode.ProcessorDetails](ctx, g, "QueryProcessorDetails", id)
+}
+
+func (g *Gateway) QueryTransporterDetails(ctx context.Context, id string) (*chaincode.TransporterDetails, error) {
+	return evaluate[*chaincode.TransporterDetails](ctx, g, "QueryTransporterDetails", id)
+}
+
+func (g *Gateway) PurgePersonalData(ctx context.Context, entityID string) (*chaincode.ErasureRecord, error) {
+	return submitResult[*chaincode.ErasureRecord](ctx, g, indexKeyTransient(g.indexKey), "PurgePersonalData", entityID)
+}
+
+func (g *Gateway) QueryErasureRecordByID(ctx context.Context, id string) (*chaincode.ErasureRecord, error) {
+	return evaluate[*chaincode.ErasureRecord](ctx, g, "QueryErasureRecordByID", id)
+}
+
+func (g *Gateway) QueryAllErasureRecords(ctx context.Context) ([]*chaincode.ErasureRecord, error) {
+	return evaluate[[]*chaincode.ErasureRecord](ctx, g, "QueryAllErasureRecords")
+}
+
+func (g *Gateway) MigrateTraceability(ctx context.Context) (int, error) {
+	return submitResult[int](ctx, g, nil, "MigrateTraceability")
+}
+
+func (g *Gateway) MigrateEntityKeys(ctx context.Context) (*chaincode.KeyMigration, error) {
+	return submitResult[*chaincode.KeyMigration](ctx, g, nil, "MigrateEntityKeys")
+}
+
+func (g *Gateway) MigratePersonalData(ctx context.Context, salt string) (*chaincode.KeyMigration, error) {
+	transient, err := saltTransient(salt)
+	if err != nil {
+		return nil, err
+	}
+	return submitResult[*chaincode.KeyMigration](ctx, g, transient, "MigratePersonalData")
+}
@@ -0,0 +1,691 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"chaincode-if/chaincode"
+	"chaincode-if/memledger"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// InProcess is a Client that calls the contract directly, as one identity,
+// on an in-memory ledger. Submitted transactions are committed when the
+// contract returns no error; evaluated ones never are.
+type InProcess struct {
+	ledger   *memledger.Ledger
+	identity *memledger.Identity
+	contract *chaincode.PalmOilContract
+	indexKey string
+}
+
+// NewInProcess returns a Client that calls the contract on a ledger as an identity
+func NewInProcess(ledger *memledger.Ledger, identity *memledger.Identity) *InProcess {
+	return &InProcess{ledger: ledger, identity: identity, contract: &chaincode.PalmOilContract{}}
+}
+
+// As returns a Client that calls the contract on the same ledger as another identity
+func (c *InProcess) As(identity *memledger.Identity) *InProcess {
+	return &InProcess{ledger: c.ledger, identity: identity, contract: c.contract, indexKey: c.indexKey}
+}
+
+// WithIndexKey returns a Client that passes the channel's identity index key
+// with the calls that register, release or resolve a NIK
+func (c *InProcess) WithIndexKey(key string) *InProcess {
+	return &InProcess{ledger: c.ledger, identity: c.identity, contract: c.contract, indexKey: key}
+}
+
+// Ledger returns the ledger the contract runs on
+func (c *InProcess) Ledger() *memledger.Ledger {
+	return c.ledger
+}
+
+// submit runs a transaction and commits it if it succeeds
+func (c *InProcess) submit(ctx context.Context, transient map[string][]byte, fn func(tx contractapi.TransactionContextInterface) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.ledger.Transact(c.identity, fn, memledger.WithTransient(transient))
+}
+
+// submitPersonal runs a transaction that carries personal data
+func (c *InProcess) submitPersonal(ctx context.Context, personal *chaincode.PersonalData, fn func(tx contractapi.TransactionContextInterface) error) error {
+	transient, err := personalTransient(personal, c.indexKey)
+	if err != nil {
+		return err
+	}
+	return c.submit(ctx, transient, fn)
+}
+
+// submitInProcess runs a transaction that returns a result and commits it if it succeeds
+func submitInProcess[T any](ctx context.Context, c *InProcess, transient map[string][]byte, fn func(tx contractapi.TransactionContextInterface) (T, error)) (T, error) {
+	var result T
+	err := c.submit(ctx, transient, func(tx contractapi.TransactionContextInterface) error {
+		var err error
+		result, err = fn(tx)
+		return err
+	})
+	return result, err
+}
+
+// evaluateInProcess runs a transaction without committing it
+func evaluateInProcess[T any](ctx context.Context, c *InProcess, fn func(tx contractapi.TransactionContextInterface) (T, error)) (T, error) {
+	return evaluateInProcessTransient(ctx, c, nil, fn)
+}
+
+// evaluateInProcessTransient runs a transaction carrying transient data
+// without committing it
+func evaluateInProcessTransient[T any](ctx context.Context, c *InProcess, transient map[string][]byte, fn func(tx contractapi.TransactionContextInterface) (T, error)) (T, error) {
+	var result T
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+	err := c.ledger.Evaluate(c.identity, func(tx contractapi.TransactionContextInterface) error {
+		var err error
+		result, err = fn(tx)
+		return err
+	}, memledger.WithTransient(transient))
+	return result, err
+}
+
+func (c *InProcess) SetMSPRoles(ctx context.Context, mspID string, roles []string) error {
+	return c.submit(ctx, nil, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.SetMSPRoles(tx, mspID, jsonList(roles))
+	})
+}
+
+func (c *InProcess) QueryMSPRoles(ctx context.Context, mspID string) (*chaincode.MSPRoles, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) (*chaincode.MSPRoles, error) {
+		return c.contract.QueryMSPRoles(tx, mspID)
+	})
+}
+
+func (c *InProcess) AddFarmer(ctx context.Context, farmer *chaincode.Farmer, personal *chaincode.PersonalData) error {
+	return c.submitPersonal(ctx, personal, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.AddFarmer(tx, farmer.ID, farmer.Name, farmer.Address, jsonList(farmer.Farm))
+	})
+}
+
+func (c *InProcess) UpdateFarmer(ctx context.Context, farmer *chaincode.Farmer, personal *chaincode.PersonalData) error {
+	return c.submitPersonal(ctx, personal, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.UpdateFarmer(tx, farmer.ID, farmer.Name, farmer.Address, jsonList(farmer.Farm))
+	})
+}
+
+func (c *InProcess) PatchFarmer(ctx context.Context, id string, patch Patch, personal *chaincode.PersonalData) error {
+	patchJSON, err := patchArg(patch)
+	if err != nil {
+		return err
+	}
+	return c.submitPersonal(ctx, personal, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.PatchFarmer(tx, id, patchJSON)
+	})
+}
+
+func (c *InProcess) AddFarmerFromJSON(ctx context.Context, document json.RawMessage, personal *chaincode.PersonalData) error {
+	return c.submitPersonal(ctx, personal, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.AddFarmerFromJSON(tx, string(document))
+	})
+}
+
+func (c *InProcess) UpdateFarmerFromJSON(ctx context.Context, document json.RawMessage, personal *chaincode.PersonalData) error {
+	return c.submitPersonal(ctx, personal, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.UpdateFarmerFromJSON(tx, string(document))
+	})
+}
+
+func (c *InProcess) QueryFarmerByID(ctx context.Context, id string) (*chaincode.Farmer, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) (*chaincode.Farmer, error) {
+		return c.contract.QueryFarmerByID(tx, id)
+	})
+}
+
+func (c *InProcess) QueryAllFarmers(ctx context.Context) ([]*chaincode.Farmer, error) {
+	return evaluateInProcess(ctx, c, c.contract.QueryAllFarmers)
+}
+
+func (c *InProcess) AddFarm(ctx context.Context, farm *chaincode.Farm) error {
+	return c.submit(ctx, nil, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.AddFarm(tx, farm.ID, farm.Owner, farm.PlantedYear, farm.SeedVarieties, farm.Area, farm.Address, farm.Coordinate, farm.Capacity, farm.Legality, farm.Certificate)
+	})
+}
+
+func (c *InProcess) UpdateFarm(ctx context.Context, farm *chaincode.Farm) error {
+	return c.submit(ctx, nil, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.UpdateFarm(tx, farm.ID, farm.Owner, farm.PlantedYear, farm.SeedVarieties, farm.Area, farm.Address, farm.Coordinate, farm.Capacity, farm.Legality, farm.Certificate)
+	})
+}
+
+func (c *InProcess) PatchFarm(ctx context.Context, id string, patch Patch) error {
+	patchJSON, err := patchArg(patch)
+	if err != nil {
+		return err
+	}
+	return c.submit(ctx, nil, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.PatchFarm(tx, id, patchJSON)
+	})
+}
+
+func (c *InProcess) AddFarmFromJSON(ctx context.Context, document json.RawMessage) error {
+	return c.submit(ctx, nil, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.AddFarmFromJSON(tx, string(document))
+	})
+}
+
+func (c *InProcess) UpdateFarmFromJSON(ctx context.Context, document json.RawMessage) error {
+	return c.submit(ctx, nil, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.UpdateFarmFromJSON(tx, string(document))
+	})
+}
+
+func (c *InProcess) QueryFarmByID(ctx context.Context, id string) (*chaincode.Farm, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) (*chaincode.Farm, error) {
+		return c.contract.QueryFarmByID(tx, id)
+	})
+}
+
+func (c *InProcess) QueryAllFarms(ctx context.Context) ([]*chaincode.Farm, error) {
+	return evaluateInProcess(ctx, c, c.contract.QueryAllFarms)
+}
+
+func (c *InProcess) TransferFarmOwnership(ctx context.Context, farmID string, newOwner string) error {
+	return c.submit(ctx, nil, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.TransferFarmOwnership(tx, farmID, newOwner)
+	})
+}
+
+func (c *InProcess) AcceptFarmOwnership(ctx context.Context, farmID string) error {
+	return c.submit(ctx, nil, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.AcceptFarmOwnership(tx, farmID)
+	})
+}
+
+func (c *InProcess) RejectFarmOwnership(ctx context.Context, farmID string) error {
+	return c.submit(ctx, nil, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.RejectFarmOwnership(tx, farmID)
+	})
+}
+
+func (c *InProcess) CancelFarmOwnershipTransfer(ctx context.Context, farmID string) error {
+	return c.submit(ctx, nil, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.CancelFarmOwnershipTransfer(tx, farmID)
+	})
+}
+
+func (c *InProcess) QueryFarmTransfer(ctx context.Context, farmID string) (*chaincode.FarmTransfer, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) (*chaincode.FarmTransfer, error) {
+		return c.contract.QueryFarmTransfer(tx, farmID)
+	})
+}
+
+func (c *InProcess) AddCollector(ctx context.Context, collector *chaincode.Collector, personal *chaincode.PersonalData) error {
+	return c.submitPersonal(ctx, personal, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.AddCollector(tx, collector.ID, collector.Name, collector.NIB, collector.Address, collector.Capacity, jsonList(collector.Partner))
+	})
+}
+
+func (c *InProcess) UpdateCollector(ctx context.Context, collector *chaincode.Collector, personal *chaincode.PersonalData) error {
+	return c.submitPersonal(ctx, personal, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.UpdateCollector(tx, collector.ID, collector.Name, collector.NIB, collector.Address, collector.Capacity, jsonList(collector.Partner))
+	})
+}
+
+func (c *InProcess) PatchCollector(ctx context.Context, id string, patch Patch, personal *chaincode.PersonalData) error {
+	patchJSON, err := patchArg(patch)
+	if err != nil {
+		return err
+	}
+	return c.submitPersonal(ctx, personal, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.PatchCollector(tx, id, patchJSON)
+	})
+}
+
+func (c *InProcess) AddCollectorFromJSON(ctx context.Context, document json.RawMessage, personal *chaincode.PersonalData) error {
+	return c.submitPersonal(ctx, personal, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.AddCollectorFromJSON(tx, string(document))
+	})
+}
+
+func (c *InProcess) UpdateCollectorFromJSON(ctx context.Context, document json.RawMessage, personal *chaincode.PersonalData) error {
+	return c.submitPersonal(ctx, personal, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.UpdateCollectorFromJSON(tx, string(document))
+	})
+}
+
+func (c *InProcess) QueryCollectorByID(ctx context.Context, id string) (*chaincode.Collector, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) (*chaincode.Collector, error) {
+		return c.contract.QueryCollectorByID(tx, id)
+	})
+}
+
+func (c *InProcess) QueryAllCollectors(ctx context.Context) ([]*chaincode.Collector, error) {
+	return evaluateInProcess(ctx, c, c.contract.QueryAllCollectors)
+}
+
+func (c *InProcess) ProposePartnership(ctx context.Context, partnership *chaincode.Partnership) error {
+	return c.submit(ctx, nil, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.ProposePartnership(tx, partnership.ID, partnership.CollectorID, partnership.PartnerType, partnership.PartnerID, partnership.StartDate, partnership.EndDate)
+	})
+}
+
+func (c *InProcess) AcceptPartnership(ctx context.Context, id string) error {
+	return c.submit(ctx, nil, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.AcceptPartnership(tx, id)
+	})
+}
+
+func (c *InProcess) RejectPartnership(ctx context.Context, id string) error {
+	return c.submit(ctx, nil, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.RejectPartnership(tx, id)
+	})
+}
+
+func (c *InProcess) EndPartnership(ctx context.Context, id string) error {
+	return c.submit(ctx, nil, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.EndPartnership(tx, id)
+	})
+}
+
+func (c *InProcess) QueryPartnership(ctx context.Context, id string) (*chaincode.Partnership, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) (*chaincode.Partnership, error) {
+		return c.contract.QueryPartnership(tx, id)
+	})
+}
+
+func (c *InProcess) QueryPartnershipsByCollector(ctx context.Context, collectorID string) ([]*chaincode.Partnership, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) ([]*chaincode.Partnership, error) {
+		return c.contract.QueryPartnershipsByCollector(tx, collectorID)
+	})
+}
+
+func (c *InProcess) QueryPartnershipsByPartner(ctx context.Context, partnerType string, partnerID string) ([]*chaincode.Partnership, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) ([]*chaincode.Partnership, error) {
+		return c.contract.QueryPartnershipsByPartner(tx, partnerType, partnerID)
+	})
+}
+
+func (c *InProcess) AddProcessor(ctx context.Context, processor *chaincode.Processor, personal *chaincode.PersonalData) error {
+	return c.submitPersonal(ctx, personal, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.AddProcessor(tx, processor.ID, processor.Name, processor.NIB, processor.Address, processor.Capacity)
+	})
+}
+
+func (c *InProcess) UpdateProcessor(ctx context.Context, processor *chaincode.Processor, personal *chaincode.PersonalData) error {
+	return c.submitPersonal(ctx, personal, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.UpdateProcessor(tx, processor.ID, processor.Name, processor.NIB, processor.Address, processor.Capacity)
+	})
+}
+
+func (c *InProcess) PatchProcessor(ctx context.Context, id string, patch Patch, personal *chaincode.PersonalData) error {
+	patchJSON, err := patchArg(patch)
+	if err != nil {
+		return err
+	}
+	return c.submitPersonal(ctx, personal, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.PatchProcessor(tx, id, patchJSON)
+	})
+}
+
+func (c *InProcess) AddProcessorFromJSON(ctx context.Context, document json.RawMessage, personal *chaincode.PersonalData) error {
+	return c.submitPersonal(ctx, personal, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.AddProcessorFromJSON(tx, string(document))
+	})
+}
+
+func (c *InProcess) UpdateProcessorFromJSON(ctx context.Context, document json.RawMessage, personal *chaincode.PersonalData) error {
+	return c.submitPersonal(ctx, personal, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.UpdateProcessorFromJSON(tx, string(document))
+	})
+}
+
+func (c *InProcess) QueryProcessorByID(ctx context.Context, id string) (*chaincode.Processor, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) (*chaincode.Processor, error) {
+		return c.contract.QueryProcessorByID(tx, id)
+	})
+}
+
+func (c *InProcess) QueryAllProcessors(ctx context.Context) ([]*chaincode.Processor, error) {
+	return evaluateInProcess(ctx, c, c.contract.QueryAllProcessors)
+}
+
+func (c *InProcess) AddTransporter(ctx context.Context, transporter *chaincode.Transporter, personal *chaincode.PersonalData) error {
+	return c.submitPersonal(ctx, personal, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.AddTransporter(tx, transporter.ID, transporter.Name, transporter.NumShip)
+	})
+}
+
+func (c *InProcess) UpdateTransporter(ctx context.Context, transporter *chaincode.Transporter, personal *chaincode.PersonalData) error {
+	return c.submitPersonal(ctx, personal, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.UpdateTransporter(tx, transporter.ID, transporter.Name, transporter.NumShip)
+	})
+}
+
+func (c *InProcess) PatchTransporter(ctx context.Context, id string, patch Patch, personal *chaincode.PersonalData) error {
+	patchJSON, err := patchArg(patch)
+	if err != nil {
+		return err
+	}
+	return c.submitPersonal(ctx, personal, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.PatchTransporter(tx, id, patchJSON)
+	})
+}
+
+func (c *InProcess) AddTransporterFromJSON(ctx context.Context, document json.RawMessage, personal *chaincode.PersonalData) error {
+	return c.submitPersonal(ctx, personal, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.AddTransporterFromJSON(tx, string(document))
+	})
+}
+
+func (c *InProcess) UpdateTransporterFromJSON(ctx context.Context, document json.RawMessage, personal *chaincode.PersonalData) error {
+	return c.submitPersonal(ctx, personal, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.UpdateTransporterFromJSON(tx, string(document))
+	})
+}
+
+func (c *InProcess) QueryTransporterByID(ctx context.Context, id string) (*chaincode.Transporter, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) (*chaincode.Transporter, error) {
+		return c.contract.QueryTransporterByID(tx, id)
+	})
+}
+
+func (c *InProcess) QueryAllTransporters(ctx context.Context) ([]*chaincode.Transporter, error) {
+	return evaluateInProcess(ctx, c, c.contract.QueryAllTransporters)
+}
+
+func (c *InProcess) Harvest(ctx context.Context, harvest HarvestRequest, step Step) error {
+	return c.submit(ctx, nil, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.Harvest(tx, harvest.CommodityID, harvest.FarmID, harvest.Name, harvest.Quantity, harvest.DateHarvested, harvest.TraceabilityID, step.PIC, step.Location)
+	})
+}
+
+func (c *InProcess) Collect(ctx context.Context, commodityID string, collectorID string, step Step) error {
+	return c.submit(ctx, nil, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.Collect(tx, commodityID, collectorID, step.PIC, step.Location)
+	})
+}
+
+func (c *InProcess) Transport(ctx context.Context, commodityID string, transporterID string, step Step) error {
+	return c.submit(ctx, nil, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.Transport(tx, commodityID, transporterID, step.PIC, step.Location)
+	})
+}
+
+func (c *InProcess) Transported(ctx context.Context, commodityID string, transporterID string, step Step) error {
+	return c.submit(ctx, nil, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.Transported(tx, commodityID, transporterID, step.PIC, step.Location)
+	})
+}
+
+func (c *InProcess) HoldCommodity(ctx context.Context, commodityID string, step Step) error {
+	return c.submit(ctx, nil, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.HoldCommodity(tx, commodityID, step.PIC, step.Location)
+	})
+}
+
+func (c *InProcess) ReleaseCommodity(ctx context.Context, commodityID string, step Step) error {
+	return c.submit(ctx, nil, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.ReleaseCommodity(tx, commodityID, step.PIC, step.Location)
+	})
+}
+
+func (c *InProcess) RejectCommodity(ctx context.Context, commodityID string, step Step) error {
+	return c.submit(ctx, nil, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.RejectCommodity(tx, commodityID, step.PIC, step.Location)
+	})
+}
+
+func (c *InProcess) Process(ctx context.Context, process ProcessRequest, step Step) error {
+	return c.submit(ctx, nil, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.Process(tx, process.ProcessedID, process.Processor, process.Quantity, jsonList(process.Materials), process.BatchNumber, process.Quality, step.PIC, step.Location)
+	})
+}
+
+func (c *InProcess) QueryCommodityByID(ctx context.Context, commodityID string) (*chaincode.Commodity, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) (*chaincode.Commodity, error) {
+		return c.contract.QueryCommodityByID(tx, commodityID)
+	})
+}
+
+func (c *InProcess) QueryAllCommodities(ctx context.Context) ([]*chaincode.Commodity, error) {
+	return evaluateInProcess(ctx, c, c.contract.QueryAllCommodities)
+}
+
+func (c *InProcess) QueryProcessedCommodityByID(ctx context.Context, processedID string) (*chaincode.ProcessedCommodity, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) (*chaincode.ProcessedCommodity, error) {
+		return c.contract.QueryProcessedCommodityByID(tx, processedID)
+	})
+}
+
+func (c *InProcess) QueryAllProcessedCommodities(ctx context.Context) ([]*chaincode.ProcessedCommodity, error) {
+	return evaluateInProcess(ctx, c, c.contract.QueryAllProcessedCommodities)
+}
+
+func (c *InProcess) RecallBatch(ctx context.Context, recall RecallRequest) error {
+	return c.submit(ctx, nil, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.RecallBatch(tx, recall.RecallID, recall.OriginType, recall.OriginID, recall.Reason, recall.PIC)
+	})
+}
+
+func (c *InProcess) QueryRecallByID(ctx context.Context, recallID string) (*chaincode.Recall, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) (*chaincode.Recall, error) {
+		return c.contract.QueryRecallByID(tx, recallID)
+	})
+}
+
+func (c *InProcess) SetExtractionRateRange(ctx context.Context, rateRange chaincode.ExtractionRateRange) error {
+	return c.submit(ctx, nil, func(tx contractapi.TransactionContextInterface) error {
+		return c.contract.SetExtractionRateRange(tx, rateRange.Min, rateRange.Max)
+	})
+}
+
+func (c *InProcess) QueryExtractionRateRange(ctx context.Context) (*chaincode.ExtractionRateRange, error) {
+	return evaluateInProcess(ctx, c, c.contract.QueryExtractionRateRange)
+}
+
+func (c *InProcess) TraceProcessedCommodity(ctx context.Context, processedID string) (*chaincode.BatchLineage, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) (*chaincode.BatchLineage, error) {
+		return c.contract.TraceProcessedCommodity(tx, processedID)
+	})
+}
+
+func (c *InProcess) TraceFarmForward(ctx context.Context, farmID string) (*chaincode.ForwardTrace, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) (*chaincode.ForwardTrace, error) {
+		return c.contract.TraceFarmForward(tx, farmID)
+	})
+}
+
+func (c *InProcess) TraceCommodityForward(ctx context.Context, commodityID string) (*chaincode.ForwardTrace, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) (*chaincode.ForwardTrace, error) {
+		return c.contract.TraceCommodityForward(tx, commodityID)
+	})
+}
+
+func (c *InProcess) GetFarmerHistory(ctx context.Context, id string) ([]*chaincode.HistoryEntry, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) ([]*chaincode.HistoryEntry, error) {
+		return c.contract.GetFarmerHistory(tx, id)
+	})
+}
+
+func (c *InProcess) GetFarmHistory(ctx context.Context, id string) ([]*chaincode.HistoryEntry, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) ([]*chaincode.HistoryEntry, error) {
+		return c.contract.GetFarmHistory(tx, id)
+	})
+}
+
+func (c *InProcess) GetCollectorHistory(ctx context.Context, id string) ([]*chaincode.HistoryEntry, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) ([]*chaincode.HistoryEntry, error) {
+		return c.contract.GetCollectorHistory(tx, id)
+	})
+}
+
+func (c *InProcess) GetProcessorHistory(ctx context.Context, id string) ([]*chaincode.HistoryEntry, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) ([]*chaincode.HistoryEntry, error) {
+		return c.contract.GetProcessorHistory(tx, id)
+	})
+}
+
+func (c *InProcess) GetTransporterHistory(ctx context.Context, id string) ([]*chaincode.HistoryEntry, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) ([]*chaincode.HistoryEntry, error) {
+		return c.contract.GetTransporterHistory(tx, id)
+	})
+}
+
+func (c *InProcess) GetCommodityHistory(ctx context.Context, id string) ([]*chaincode.HistoryEntry, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) ([]*chaincode.HistoryEntry, error) {
+		return c.contract.GetCommodityHistory(tx, id)
+	})
+}
+
+func (c *InProcess) GetEntityAsOf(ctx context.Context, entityType string, id string, asOf time.Time) (*chaincode.HistoryEntry, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) (*chaincode.HistoryEntry, error) {
+		return c.contract.GetEntityAsOf(tx, entityType, id, timestampArg(asOf))
+	})
+}
+
+func (c *InProcess) QueryFarmersWithPagination(ctx context.Context, pageSize int32, bookmark string) (*chaincode.FarmerPage, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) (*chaincode.FarmerPage, error) {
+		return c.contract.QueryFarmersWithPagination(tx, pageSize, bookmark)
+	})
+}
+
+func (c *InProcess) QueryFarmsWithPagination(ctx context.Context, pageSize int32, bookmark string) (*chaincode.FarmPage, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) (*chaincode.FarmPage, error) {
+		return c.contract.QueryFarmsWithPagination(tx, pageSize, bookmark)
+	})
+}
+
+func (c *InProcess) QueryCollectorsWithPagination(ctx context.Context, pageSize int32, bookmark string) (*chaincode.CollectorPage, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) (*chaincode.CollectorPage, error) {
+		return c.contract.QueryCollectorsWithPagination(tx, pageSize, bookmark)
+	})
+}
+
+func (c *InProcess) QueryProcessorsWithPagination(ctx context.Context, pageSize int32, bookmark string) (*chaincode.ProcessorPage, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) (*chaincode.ProcessorPage, error) {
+		return c.contract.QueryProcessorsWithPagination(tx, pageSize, bookmark)
+	})
+}
+
+func (c *InProcess) QueryTransportersWithPagination(ctx context.Context, pageSize int32, bookmark string) (*chaincode.TransporterPage, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) (*chaincode.TransporterPage, error) {
+		return c.contract.QueryTransportersWithPagination(tx, pageSize, bookmark)
+	})
+}
+
+func (c *InProcess) QueryCommoditiesWithPagination(ctx context.Context, pageSize int32, bookmark string) (*chaincode.CommodityPage, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) (*chaincode.CommodityPage, error) {
+		return c.contract.QueryCommoditiesWithPagination(tx, pageSize, bookmark)
+	})
+}
+
+func (c *InProcess) QueryProcessedCommoditiesWithPagination(ctx context.Context, pageSize int32, bookmark string) (*chaincode.ProcessedCommodityPage, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) (*chaincode.ProcessedCommodityPage, error) {
+		return c.contract.QueryProcessedCommoditiesWithPagination(tx, pageSize, bookmark)
+	})
+}
+
+func (c *InProcess) QueryFarmersByNIK(ctx context.Context, nik string) ([]*chaincode.Farmer, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) ([]*chaincode.Farmer, error) {
+		return c.contract.QueryFarmersByNIK(tx, nik)
+	})
+}
+
+func (c *InProcess) QueryCollectorsByNIB(ctx context.Context, nib string) ([]*chaincode.Collector, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) ([]*chaincode.Collector, error) {
+		return c.contract.QueryCollectorsByNIB(tx, nib)
+	})
+}
+
+func (c *InProcess) QueryProcessorsByNIB(ctx context.Context, nib string) ([]*chaincode.Processor, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) ([]*chaincode.Processor, error) {
+		return c.contract.QueryProcessorsByNIB(tx, nib)
+	})
+}
+
+func (c *InProcess) ResolveIdentityNumber(ctx context.Context, kind string, number string) (*chaincode.IdentityOwner, error) {
+	return evaluateInProcessTransient(ctx, c, indexKeyTransient(c.indexKey), func(tx contractapi.TransactionContextInterface) (*chaincode.IdentityOwner, error) {
+		return c.contract.ResolveIdentityNumber(tx, kind, number)
+	})
+}
+
+func (c *InProcess) QueryFarmsByAttributes(ctx context.Context, legality string, certificate string, seedVarieties string) ([]*chaincode.Farm, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) ([]*chaincode.Farm, error) {
+		return c.contract.QueryFarmsByAttributes(tx, legality, certificate, seedVarieties)
+	})
+}
+
+func (c *InProcess) QueryCommoditiesByState(ctx context.Context, state chaincode.CommodityState) ([]*chaincode.Commodity, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) ([]*chaincode.Commodity, error) {
+		return c.contract.QueryCommoditiesByState(tx, string(state))
+	})
+}
+
+func (c *InProcess) QueryCommoditiesByStateWithPagination(ctx context.Context, state chaincode.CommodityState, pageSize int32, bookmark string) (*chaincode.CommodityPage, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) (*chaincode.CommodityPage, error) {
+		return c.contract.QueryCommoditiesByStateWithPagination(tx, string(state), pageSize, bookmark)
+	})
+}
+
+func (c *InProcess) QueryCommoditiesByHarvestDate(ctx context.Context, from string, to string) ([]*chaincode.Commodity, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) ([]*chaincode.Commodity, error) {
+		return c.contract.QueryCommoditiesByHarvestDate(tx, from, to)
+	})
+}
+
+func (c *InProcess) QueryFarmerDetails(ctx context.Context, id string) (*chaincode.FarmerDetails, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) (*chaincode.FarmerDetails, error) {
+		return c.contract.QueryFarmerDetails(tx, id)
+	})
+}
+
+func (c *InProcess) QueryCollectorDetails(ctx context.Context, id string) (*chaincode.CollectorDetails, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) (*chaincode.CollectorDetails, error) {
+		return c.contract.QueryCollectorDetails(tx, id)
+	})
+}
+
+func (c *InProcess) QueryProcessorDetails(ctx context.Context, id string) (*chaincode.ProcessorDetails, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) (*chaincode.ProcessorDetails, error) {
+		return c.contract.QueryProcessorDetails(tx, id)
+	})
+}
+
+func (c *InProcess) QueryTransporterDetails(ctx context.Context, id string) (*chaincode.TransporterDetails, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) (*chaincode.TransporterDetails, error) {
+		return c.contract.QueryTransporterDetails(tx, id)
+	})
+}
+
+func (c *InProcess) PurgePersonalData(ctx context.Context, entityID string) (*chaincode.ErasureRecord, error) {
+	return submitInProcess(ctx, c, indexKeyTransient(c.indexKey), func(tx contractapi.TransactionContextInterface) (*chaincode.ErasureRecord, error) {
+		return c.contract.PurgePersonalData(tx, entityID)
+	})
+}
+
+func (c *InProcess) QueryErasureRecordByID(ctx context.Context, id string) (*chaincode.ErasureRecord, error) {
+	return evaluateInProcess(ctx, c, func(tx contractapi.TransactionContextInterface) (*chaincode.ErasureRecord, error) {
+		return c.contract.QueryErasureRecordByID(tx, id)
+	})
+}
+
+func (c *InProcess) QueryAllErasureRecords(ctx context.Context) ([]*chaincode.ErasureRecord, error) {
+	return evaluateInProcess(ctx, c, c.contract.QueryAllErasureRecords)
+}
+
+func (c *InProcess) MigrateTraceability(ctx context.Context) (int, error) {
+	return submitInProcess(ctx, c, nil, c.contract.MigrateTraceability)
+}
+
+func (c *InProcess) MigrateEntityKeys(ctx context.Context) (*chaincode.KeyMigration, error) {
+	return submitInProcess(ctx, c, nil, c.contract.MigrateEntityKeys)
+}
+
+func (c *InProcess) MigratePersonalData(ctx context.Context, salt string) (*chaincode.KeyMigration, error) {
+	transient, err := saltTransient(salt)
+	if err != nil {
+		return nil, err
+	}
+	return submitInProcess(ctx, c, transient, c.contract.MigratePersonalData)
+}
@@ -0,0 +1,87 @@
+// Command palmoil-listener projects the palmoil chaincode events into a SQLite
+// read model. It reads events from a peer through the Fabric Gateway, or from
+// a file of recorded events, and resumes from the read model's checkpoint.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"chaincode-if/gateway"
+	"chaincode-if/listener"
+)
+
+func main() {
+	logger := log.New(os.Stderr, "palmoil-listener: ", log.LstdFlags)
+
+	// run returns instead of exiting, so its deferred calls close the read
+	// model, the record file and the connection first
+	err := run(logger)
+	if err != nil {
+		logger.Fatal(err)
+	}
+}
+
+// run parses the flags and applies events until the source is exhausted, the
+// process is interrupted or an error occurs
+func run(logger *log.Logger) error {
+	dbPath := flag.String("db", "palmoil.db", "SQLite read model database")
+	replayPath := flag.String("replay", "", "replay events from this file instead of a peer")
+	recordPath := flag.String("record", "", "append the events received to this file")
+	startBlock := flag.Uint64("start-block", 0, "block to start at when the read model has no checkpoint")
+	channel := flag.String("channel", "mychannel", "channel name")
+	chaincodeName := flag.String("chaincode", "palmoil", "chaincode name")
+
+	var cfg gateway.Config
+	flag.StringVar(&cfg.PeerEndpoint, "peer", "localhost:7051", "peer gateway endpoint")
+	flag.StringVar(&cfg.PeerHostOverride, "peer-host", "peer0.org1.example.com", "peer TLS host name")
+	flag.StringVar(&cfg.TLSCertPath, "tls-cert", "", "peer TLS CA certificate")
+	flag.StringVar(&cfg.MSPID, "msp-id", "Org1MSP", "client MSP ID")
+	flag.StringVar(&cfg.CertPath, "cert", "", "client certificate")
+	flag.StringVar(&cfg.KeyPath, "key", "", "client private key")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	model, err := listener.OpenReadModel(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer model.Close()
+
+	var source listener.EventSource
+	if *replayPath != "" {
+		source, err = listener.ReadReplayFile(*replayPath)
+		if err != nil {
+			return err
+		}
+	} else {
+		connection, err := gateway.Connect(cfg)
+		if err != nil {
+			return err
+		}
+		defer connection.Close()
+		source = listener.NewGatewaySource(connection.Gateway.GetNetwork(*channel), *chaincodeName)
+	}
+
+	if *recordPath != "" {
+		recordFile, err := os.OpenFile(*recordPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		defer recordFile.Close()
+		source = listener.NewRecordingSource(source, recordFile)
+	}
+
+	err = listener.New(source, model, *startBlock, logger).Run(ctx)
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
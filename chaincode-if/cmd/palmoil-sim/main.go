@@ -0,0 +1,133 @@
+// Command palmoil-sim runs a supply-chain scenario against the palmoil
+// chaincode on an in-memory ledger and prints the result of every step, the
+// resulting world state, the events and the lineage of every processed
+// commodity. With -golden it compares the result with a recorded one instead,
+// which checks a chaincode upgrade against scenarios before deployment.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"chaincode-if/scenario"
+)
+
+func main() {
+	format := flag.String("format", "text", "output format, text or json")
+	goldenPath := flag.String("golden", "", "compare the JSON result with this file")
+	update := flag.Bool("update", false, "write the JSON result to the -golden file instead of comparing")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: palmoil-sim [flags] scenario.yaml\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	logger := log.New(os.Stderr, "palmoil-sim: ", 0)
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	s, err := scenario.Load(flag.Arg(0))
+	if err != nil {
+		logger.Fatal(err)
+	}
+	result, err := scenario.Run(s)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		logger.Fatal(err)
+	}
+	resultJSON = append(resultJSON, '\n')
+
+	switch {
+	case *goldenPath != "" && *update:
+		err = os.WriteFile(*goldenPath, resultJSON, 0o644)
+		if err != nil {
+			logger.Fatal(err)
+		}
+	case *goldenPath != "":
+		golden, err := os.ReadFile(*goldenPath)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		if line, ok := firstDifference(golden, resultJSON); !ok {
+			logger.Fatalf("the result differs from %s at line %d", *goldenPath, line)
+		}
+	case *format == "json":
+		os.Stdout.Write(resultJSON)
+	case *format == "text":
+		printText(os.Stdout, result)
+	default:
+		logger.Fatalf("unknown format %q", *format)
+	}
+
+	if failed := result.Failed(); len(failed) > 0 {
+		for _, step := range failed {
+			logger.Printf("step %s %s failed: %s", step.TxID, step.Function, step.Message)
+		}
+		os.Exit(1)
+	}
+}
+
+// firstDifference compares two results line by line and returns the first
+// line that differs
+func firstDifference(want []byte, got []byte) (int, bool) {
+	wantLines := bytes.Split(want, []byte("\n"))
+	gotLines := bytes.Split(got, []byte("\n"))
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		if i >= len(wantLines) || i >= len(gotLines) || !bytes.Equal(wantLines[i], gotLines[i]) {
+			return i + 1, false
+		}
+	}
+	return 0, true
+}
+
+// printText prints a result for reading
+func printText(w io.Writer, result *scenario.Result) {
+	fmt.Fprintf(w, "Scenario: %s\n\nSteps:\n", result.Scenario)
+	for _, step := range result.Steps {
+		outcome := "ok"
+		if step.Message != "" {
+			outcome = step.Message
+		}
+		if !step.Passed {
+			outcome = "FAILED: " + outcome
+		}
+		fmt.Fprintf(w, "  %s %-12s %-28s %s\n", step.TxID, step.As, step.Function, outcome)
+	}
+
+	fmt.Fprintf(w, "\nWorld state:\n")
+	for _, entry := range result.WorldState {
+		key := entry.Key
+		if entry.ObjectType != "" {
+			key = entry.ObjectType + " " + strings.Join(entry.Attributes, " ")
+		}
+		value := string(entry.Value)
+		if entry.Value == nil {
+			value = fmt.Sprintf("%x", entry.Raw)
+		}
+		fmt.Fprintf(w, "  %s = %s\n", key, value)
+	}
+
+	fmt.Fprintf(w, "\nEvents:\n")
+	for _, event := range result.Events {
+		fmt.Fprintf(w, "  block %d %s %s\n", event.BlockNumber, event.TxID, event.Name)
+	}
+
+	fmt.Fprintf(w, "\nLineage:\n")
+	for _, lineage := range result.Lineage {
+		var indented bytes.Buffer
+		json.Indent(&indented, lineage, "  ", "  ")
+		fmt.Fprintf(w, "  %s\n", indented.String())
+	}
+}
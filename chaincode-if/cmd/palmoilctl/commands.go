@@ -0,0 +1,584 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"chaincode-if/chaincode"
+	"chaincode-if/client"
+)
+
+// exportPageSize is the page size export fetches records with
+const exportPageSize = 100
+
+// command is an action on a resource. It returns the value to print, or nil.
+type command struct {
+	usage string
+	run   func(ctx context.Context, e *env, args []string) (interface{}, error)
+}
+
+// commands maps resources to their actions
+var commands = map[string]map[string]command{
+	"farmer": withAction(entityCommands(
+		func(f *chaincode.Farmer) string { return f.ID },
+		client.Client.AddFarmer,
+		client.Client.UpdateFarmer,
+		client.Client.PatchFarmer,
+		client.Client.QueryFarmerByID,
+		client.Client.QueryAllFarmers,
+		client.Client.GetFarmerHistory,
+	), "details", idCommand(client.Client.QueryFarmerDetails)),
+	"farm": entityCommands(
+		func(f *chaincode.Farm) string { return f.ID },
+		withoutPersonalData(client.Client.AddFarm),
+		withoutPersonalData(client.Client.UpdateFarm),
+		patchWithoutPersonalData(client.Client.PatchFarm),
+		client.Client.QueryFarmByID,
+		client.Client.QueryAllFarms,
+		client.Client.GetFarmHistory,
+	),
+	"transfer": {
+		"propose": {usage: "FARM_ID NEW_OWNER", run: proposeFarmTransfer},
+		"accept":  transferCommand(client.Client.AcceptFarmOwnership),
+		"reject":  transferCommand(client.Client.RejectFarmOwnership),
+		"cancel":  transferCommand(client.Client.CancelFarmOwnershipTransfer),
+		"get":     idCommand(client.Client.QueryFarmTransfer),
+	},
+	"collector": withAction(entityCommands(
+		func(c *chaincode.Collector) string { return c.ID },
+		client.Client.AddCollector,
+		client.Client.UpdateCollector,
+		client.Client.PatchCollector,
+		client.Client.QueryCollectorByID,
+		client.Client.QueryAllCollectors,
+		client.Client.GetCollectorHistory,
+	), "details", idCommand(client.Client.QueryCollectorDetails)),
+	"partnership": {
+		"propose": {usage: "--from FILE", run: proposePartnership},
+		"accept":  partnershipCommand(client.Client.AcceptPartnership),
+		"reject":  partnershipCommand(client.Client.RejectPartnership),
+		"end":     partnershipCommand(client.Client.EndPartnership),
+		"get":     idCommand(client.Client.QueryPartnership),
+		"list":    {usage: "--collector ID | --farmer ID | --farm ID", run: listPartnerships},
+	},
+	"processor": withAction(entityCommands(
+		func(p *chaincode.Processor) string { return p.ID },
+		client.Client.AddProcessor,
+		client.Client.UpdateProcessor,
+		client.Client.PatchProcessor,
+		client.Client.QueryProcessorByID,
+		client.Client.QueryAllProcessors,
+		client.Client.GetProcessorHistory,
+	), "details", idCommand(client.Client.QueryProcessorDetails)),
+	"transporter": withAction(entityCommands(
+		func(t *chaincode.Transporter) string { return t.ID },
+		client.Client.AddTransporter,
+		client.Client.UpdateTransporter,
+		client.Client.PatchTransporter,
+		client.Client.QueryTransporterByID,
+		client.Client.QueryAllTransporters,
+		client.Client.GetTransporterHistory,
+	), "details", idCommand(client.Client.QueryTransporterDetails)),
+	"commodity": {
+		"harvest":     {usage: "--from FILE", run: harvest},
+		"collect":     actorStepCommand("collector", client.Client.Collect),
+		"transport":   actorStepCommand("transporter", client.Client.Transport),
+		"transported": actorStepCommand("transporter", client.Client.Transported),
+		"hold":        stepCommand(client.Client.HoldCommodity),
+		"release":     stepCommand(client.Client.ReleaseCommodity),
+		"reject":      stepCommand(client.Client.RejectCommodity),
+		"get":         idCommand(client.Client.QueryCommodityByID),
+		"list":        {usage: "[--state STATE]", run: listCommodities},
+		"history":     idCommand(client.Client.GetCommodityHistory),
+	},
+	"batch": {
+		"process": {usage: "--from FILE", run: process},
+		"get":     idCommand(client.Client.QueryProcessedCommodityByID),
+		"list":    listCommand(client.Client.QueryAllProcessedCommodities),
+	},
+	"recall": {
+		"create": {usage: "--from FILE", run: recall},
+		"get":    idCommand(client.Client.QueryRecallByID),
+	},
+	"trace": {
+		"batch":     idCommand(client.Client.TraceProcessedCommodity),
+		"farm":      idCommand(client.Client.TraceFarmForward),
+		"commodity": idCommand(client.Client.TraceCommodityForward),
+	},
+	"identity": {
+		"resolve": {usage: "nik|nib NUMBER", run: resolveIdentity},
+	},
+	"export": {
+		"all":          exportCommand(exportAll),
+		"farmers":      exportCommand(exportRecords(client.Client.QueryFarmersWithPagination)),
+		"farms":        exportCommand(exportRecords(client.Client.QueryFarmsWithPagination)),
+		"collectors":   exportCommand(exportRecords(client.Client.QueryCollectorsWithPagination)),
+		"processors":   exportCommand(exportRecords(client.Client.QueryProcessorsWithPagination)),
+		"transporters": exportCommand(exportRecords(client.Client.QueryTransportersWithPagination)),
+		"commodities":  exportCommand(exportRecords(client.Client.QueryCommoditiesWithPagination)),
+		"batches":      exportCommand(exportRecords(client.Client.QueryProcessedCommoditiesWithPagination)),
+	},
+}
+
+// parseArgs parses the flags of an action, which may come before or after its
+// positional arguments, and checks the number of positional arguments
+func parseArgs(flags *flag.FlagSet, args []string, positional ...string) ([]string, error) {
+	flags.SetOutput(io.Discard)
+
+	var values []string
+	for {
+		err := flags.Parse(args)
+		if err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			break
+		}
+		values = append(values, args[0])
+		args = args[1:]
+	}
+
+	if len(values) != len(positional) {
+		return nil, fmt.Errorf("expected arguments %v, got %d", positional, len(values))
+	}
+	return values, nil
+}
+
+// readFrom decodes the JSON file of a --from flag into every value, or stdin
+// when the path is "-"
+func readFrom(e *env, path string, values ...interface{}) error {
+	if path == "" {
+		return fmt.Errorf("--from is required")
+	}
+
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(e.stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	for _, value := range values {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		err = decoder.Decode(value)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// personalFile is the personal data of an entity file, kept out of the
+// entity's public record
+type personalFile struct {
+	Personal *chaincode.PersonalData `json:"personal,omitempty"`
+}
+
+// withoutPersonalData adapts a call of an entity without personal data
+func withoutPersonalData[T any](fn func(client.Client, context.Context, *T) error) func(client.Client, context.Context, *T, *chaincode.PersonalData) error {
+	return func(c client.Client, ctx context.Context, entity *T, _ *chaincode.PersonalData) error {
+		return fn(c, ctx, entity)
+	}
+}
+
+// patchWithoutPersonalData adapts a patch of an entity without personal data
+func patchWithoutPersonalData(fn func(client.Client, context.Context, string, client.Patch) error) func(client.Client, context.Context, string, client.Patch, *chaincode.PersonalData) error {
+	return func(c client.Client, ctx context.Context, id string, patch client.Patch, _ *chaincode.PersonalData) error {
+		return fn(c, ctx, id, patch)
+	}
+}
+
+// entityCommands returns the actions on a registered entity. Entity files
+// hold the entity's JSON record and its personal data under "personal".
+func entityCommands[T any](
+	idOf func(*T) string,
+	add func(client.Client, context.Context, *T, *chaincode.PersonalData) error,
+	update func(client.Client, context.Context, *T, *chaincode.PersonalData) error,
+	patch func(client.Client, context.Context, string, client.Patch, *chaincode.PersonalData) error,
+	get func(client.Client, context.Context, string) (*T, error),
+	list func(client.Client, context.Context) ([]*T, error),
+	history func(client.Client, context.Context, string) ([]*chaincode.HistoryEntry, error),
+) map[string]command {
+	// write adds or updates an entity and returns its stored record
+	write := func(fn func(client.Client, context.Context, *T, *chaincode.PersonalData) error) command {
+		return command{usage: "--from FILE", run: func(ctx context.Context, e *env, args []string) (interface{}, error) {
+			flags := flag.NewFlagSet("", flag.ContinueOnError)
+			from := flags.String("from", "", "JSON file of the entity, or - for stdin")
+			if _, err := parseArgs(flags, args); err != nil {
+				return nil, err
+			}
+
+			var entity T
+			var personal personalFile
+			if err := readFrom(e, *from, &entity, &personal); err != nil {
+				return nil, err
+			}
+			if err := fn(e.client, ctx, &entity, personal.Personal); err != nil {
+				return nil, err
+			}
+			return get(e.client, ctx, idOf(&entity))
+		}}
+	}
+
+	return map[string]command{
+		"add":    write(add),
+		"update": write(update),
+		"patch": {usage: "ID --from FILE", run: func(ctx context.Context, e *env, args []string) (interface{}, error) {
+			flags := flag.NewFlagSet("", flag.ContinueOnError)
+			from := flags.String("from", "", "JSON file of the attributes to change, or - for stdin")
+			values, err := parseArgs(flags, args, "ID")
+			if err != nil {
+				return nil, err
+			}
+
+			var changes client.Patch
+			var personal personalFile
+			if err := readFrom(e, *from, &changes, &personal); err != nil {
+				return nil, err
+			}
+			delete(changes, "personal")
+			if err := patch(e.client, ctx, values[0], changes, personal.Personal); err != nil {
+				return nil, err
+			}
+			return get(e.client, ctx, values[0])
+		}},
+		"get":     idCommand(get),
+		"list":    listCommand(list),
+		"history": idCommand(history),
+	}
+}
+
+// withAction adds an action to the actions of a resource
+func withAction(actions map[string]command, name string, cmd command) map[string]command {
+	actions[name] = cmd
+	return actions
+}
+
+// idCommand returns an action that takes the ID of a record
+func idCommand[T any](fn func(client.Client, context.Context, string) (T, error)) command {
+	return command{usage: "ID", run: func(ctx context.Context, e *env, args []string) (interface{}, error) {
+		values, err := parseArgs(flag.NewFlagSet("", flag.ContinueOnError), args, "ID")
+		if err != nil {
+			return nil, err
+		}
+		return fn(e.client, ctx, values[0])
+	}}
+}
+
+// resolveIdentity finds the entity a NIK or NIB is registered to
+func resolveIdentity(ctx context.Context, e *env, args []string) (interface{}, error) {
+	values, err := parseArgs(flag.NewFlagSet("", flag.ContinueOnError), args, "KIND", "NUMBER")
+	if err != nil {
+		return nil, err
+	}
+	return e.client.ResolveIdentityNumber(ctx, values[0], values[1])
+}
+
+// proposeFarmTransfer proposes to hand a farm over to another farmer
+func proposeFarmTransfer(ctx context.Context, e *env, args []string) (interface{}, error) {
+	values, err := parseArgs(flag.NewFlagSet("", flag.ContinueOnError), args, "FARM_ID", "NEW_OWNER")
+	if err != nil {
+		return nil, err
+	}
+	if err := e.client.TransferFarmOwnership(ctx, values[0], values[1]); err != nil {
+		return nil, err
+	}
+	return e.client.QueryFarmTransfer(ctx, values[0])
+}
+
+// transferCommand returns an action that closes the pending transfer of a
+// farm and returns the transfer
+func transferCommand(fn func(client.Client, context.Context, string) error) command {
+	return command{usage: "FARM_ID", run: func(ctx context.Context, e *env, args []string) (interface{}, error) {
+		values, err := parseArgs(flag.NewFlagSet("", flag.ContinueOnError), args, "FARM_ID")
+		if err != nil {
+			return nil, err
+		}
+		if err := fn(e.client, ctx, values[0]); err != nil {
+			return nil, err
+		}
+		return e.client.QueryFarmTransfer(ctx, values[0])
+	}}
+}
+
+// proposePartnership proposes a partnership from a file holding the
+// partnership's ID, collector, partner and dates
+func proposePartnership(ctx context.Context, e *env, args []string) (interface{}, error) {
+	flags := flag.NewFlagSet("", flag.ContinueOnError)
+	from := flags.String("from", "", "JSON file of the partnership, or - for stdin")
+	if _, err := parseArgs(flags, args); err != nil {
+		return nil, err
+	}
+
+	var partnership chaincode.Partnership
+	if err := readFrom(e, *from, &partnership); err != nil {
+		return nil, err
+	}
+	if err := e.client.ProposePartnership(ctx, &partnership); err != nil {
+		return nil, err
+	}
+	return e.client.QueryPartnership(ctx, partnership.ID)
+}
+
+// partnershipCommand returns an action that answers or ends a partnership
+// and returns the partnership
+func partnershipCommand(fn func(client.Client, context.Context, string) error) command {
+	return command{usage: "ID", run: func(ctx context.Context, e *env, args []string) (interface{}, error) {
+		values, err := parseArgs(flag.NewFlagSet("", flag.ContinueOnError), args, "ID")
+		if err != nil {
+			return nil, err
+		}
+		if err := fn(e.client, ctx, values[0]); err != nil {
+			return nil, err
+		}
+		return e.client.QueryPartnership(ctx, values[0])
+	}}
+}
+
+// listPartnerships lists the partnerships of a collector, farmer or farm
+func listPartnerships(ctx context.Context, e *env, args []string) (interface{}, error) {
+	flags := flag.NewFlagSet("", flag.ContinueOnError)
+	collectorID := flags.String("collector", "", "list the partnerships of this collector")
+	farmerID := flags.String("farmer", "", "list the partnerships of this farmer")
+	farmID := flags.String("farm", "", "list the partnerships of this farm")
+	if _, err := parseArgs(flags, args); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case *collectorID != "" && *farmerID == "" && *farmID == "":
+		return e.client.QueryPartnershipsByCollector(ctx, *collectorID)
+	case *farmerID != "" && *collectorID == "" && *farmID == "":
+		return e.client.QueryPartnershipsByPartner(ctx, chaincode.PartnerFarmer, *farmerID)
+	case *farmID != "" && *collectorID == "" && *farmerID == "":
+		return e.client.QueryPartnershipsByPartner(ctx, chaincode.PartnerFarm, *farmID)
+	}
+	return nil, fmt.Errorf("exactly one of --collector, --farmer or --farm is required")
+}
+
+// listCommand returns an action that lists records
+func listCommand[T any](fn func(client.Client, context.Context) (T, error)) command {
+	return command{run: func(ctx context.Context, e *env, args []string) (interface{}, error) {
+		if _, err := parseArgs(flag.NewFlagSet("", flag.ContinueOnError), args); err != nil {
+			return nil, err
+		}
+		return fn(e.client, ctx)
+	}}
+}
+
+// stepCommand returns an action that records a traceability step of a
+// commodity and returns the commodity
+func stepCommand(fn func(client.Client, context.Context, string, client.Step) error) command {
+	return command{usage: "ID --pic PIC --location LOCATION", run: func(ctx context.Context, e *env, args []string) (interface{}, error) {
+		flags := flag.NewFlagSet("", flag.ContinueOnError)
+		var step client.Step
+		flags.StringVar(&step.PIC, "pic", "", "person in charge")
+		flags.StringVar(&step.Location, "location", "", "location of the step")
+		values, err := parseArgs(flags, args, "ID")
+		if err != nil {
+			return nil, err
+		}
+
+		if err := fn(e.client, ctx, values[0], step); err != nil {
+			return nil, err
+		}
+		return e.client.QueryCommodityByID(ctx, values[0])
+	}}
+}
+
+// actorStepCommand returns an action that records a traceability step of a
+// commodity on behalf of an actor, such as the collector collecting it, and
+// returns the commodity
+func actorStepCommand(actor string, fn func(client.Client, context.Context, string, string, client.Step) error) command {
+	usage := fmt.Sprintf("ID --%s %s_ID --pic PIC --location LOCATION", actor, strings.ToUpper(actor))
+	return command{usage: usage, run: func(ctx context.Context, e *env, args []string) (interface{}, error) {
+		flags := flag.NewFlagSet("", flag.ContinueOnError)
+		actorID := flags.String(actor, "", "ID of the "+actor)
+		var step client.Step
+		flags.StringVar(&step.PIC, "pic", "", "person in charge")
+		flags.StringVar(&step.Location, "location", "", "location of the step")
+		values, err := parseArgs(flags, args, "ID")
+		if err != nil {
+			return nil, err
+		}
+
+		if err := fn(e.client, ctx, values[0], *actorID, step); err != nil {
+			return nil, err
+		}
+		return e.client.QueryCommodityByID(ctx, values[0])
+	}}
+}
+
+// harvest records a harvest from a file holding the harvest and its step
+func harvest(ctx context.Context, e *env, args []string) (interface{}, error) {
+	flags := flag.NewFlagSet("", flag.ContinueOnError)
+	from := flags.String("from", "", "JSON file of the harvest, or - for stdin")
+	if _, err := parseArgs(flags, args); err != nil {
+		return nil, err
+	}
+
+	var request client.HarvestRequest
+	var step client.Step
+	if err := readFrom(e, *from, &request, &step); err != nil {
+		return nil, err
+	}
+	if err := e.client.Harvest(ctx, request, step); err != nil {
+		return nil, err
+	}
+	return e.client.QueryCommodityByID(ctx, request.CommodityID)
+}
+
+// process records a processed commodity from a file holding the batch and its step
+func process(ctx context.Context, e *env, args []string) (interface{}, error) {
+	flags := flag.NewFlagSet("", flag.ContinueOnError)
+	from := flags.String("from", "", "JSON file of the batch, or - for stdin")
+	if _, err := parseArgs(flags, args); err != nil {
+		return nil, err
+	}
+
+	var request client.ProcessRequest
+	var step client.Step
+	if err := readFrom(e, *from, &request, &step); err != nil {
+		return nil, err
+	}
+	if err := e.client.Process(ctx, request, step); err != nil {
+		return nil, err
+	}
+	return e.client.QueryProcessedCommodityByID(ctx, request.ProcessedID)
+}
+
+// recall recalls an origin from a file holding the recall
+func recall(ctx context.Context, e *env, args []string) (interface{}, error) {
+	flags := flag.NewFlagSet("", flag.ContinueOnError)
+	from := flags.String("from", "", "JSON file of the recall, or - for stdin")
+	if _, err := parseArgs(flags, args); err != nil {
+		return nil, err
+	}
+
+	var request client.RecallRequest
+	if err := readFrom(e, *from, &request); err != nil {
+		return nil, err
+	}
+	if err := e.client.RecallBatch(ctx, request); err != nil {
+		return nil, err
+	}
+	return e.client.QueryRecallByID(ctx, request.RecallID)
+}
+
+// listCommodities lists every commodity, or those in a lifecycle state
+func listCommodities(ctx context.Context, e *env, args []string) (interface{}, error) {
+	flags := flag.NewFlagSet("", flag.ContinueOnError)
+	state := flags.String("state", "", "only list commodities in this state")
+	if _, err := parseArgs(flags, args); err != nil {
+		return nil, err
+	}
+
+	if *state != "" {
+		return e.client.QueryCommoditiesByState(ctx, chaincode.CommodityState(*state))
+	}
+	return e.client.QueryAllCommodities(ctx)
+}
+
+// exportCommand returns an action that writes records as JSON to a file or
+// stdout, whatever the output format
+func exportCommand(export func(ctx context.Context, c client.Client) (interface{}, error)) command {
+	return command{usage: "[--file FILE]", run: func(ctx context.Context, e *env, args []string) (interface{}, error) {
+		flags := flag.NewFlagSet("", flag.ContinueOnError)
+		path := flags.String("file", "", "write to this file instead of stdout")
+		if _, err := parseArgs(flags, args); err != nil {
+			return nil, err
+		}
+
+		records, err := export(ctx, e.client)
+		if err != nil {
+			return nil, err
+		}
+
+		out := e.stdout
+		if *path != "" {
+			file, err := os.Create(*path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create %s: %v", *path, err)
+			}
+			defer file.Close()
+			out = file
+		}
+		return nil, writeJSON(out, records)
+	}}
+}
+
+// exportRecords returns an export of every record of a paginated query
+func exportRecords[P any](query func(client.Client, context.Context, int32, string) (P, error)) func(context.Context, client.Client) (interface{}, error) {
+	return func(ctx context.Context, c client.Client) (interface{}, error) {
+		var records []json.RawMessage
+		bookmark := ""
+		for {
+			p, err := query(c, ctx, exportPageSize, bookmark)
+			if err != nil {
+				return nil, err
+			}
+
+			// Every page type has the same records and bookmark fields
+			var fields struct {
+				Records  []json.RawMessage `json:"records"`
+				Bookmark string            `json:"bookmark"`
+			}
+			pageJSON, err := json.Marshal(p)
+			if err != nil {
+				return nil, err
+			}
+			if err := json.Unmarshal(pageJSON, &fields); err != nil {
+				return nil, err
+			}
+
+			records = append(records, fields.Records...)
+			if fields.Bookmark == "" || len(fields.Records) == 0 {
+				break
+			}
+			bookmark = fields.Bookmark
+		}
+
+		if records == nil {
+			records = []json.RawMessage{}
+		}
+		return records, nil
+	}
+}
+
+// exportAll exports the records of every entity type
+func exportAll(ctx context.Context, c client.Client) (interface{}, error) {
+	exports := []struct {
+		name   string
+		export func(context.Context, client.Client) (interface{}, error)
+	}{
+		{"farmers", exportRecords(client.Client.QueryFarmersWithPagination)},
+		{"farms", exportRecords(client.Client.QueryFarmsWithPagination)},
+		{"collectors", exportRecords(client.Client.QueryCollectorsWithPagination)},
+		{"processors", exportRecords(client.Client.QueryProcessorsWithPagination)},
+		{"transporters", exportRecords(client.Client.QueryTransportersWithPagination)},
+		{"commodities", exportRecords(client.Client.QueryCommoditiesWithPagination)},
+		{"batches", exportRecords(client.Client.QueryProcessedCommoditiesWithPagination)},
+	}
+
+	all := make(map[string]interface{}, len(exports))
+	for _, export := range exports {
+		records, err := export.export(ctx, c)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export %s: %v", export.name, err)
+		}
+		all[export.name] = records
+	}
+
+	return all, nil
+}
@@ -0,0 +1,190 @@
+// Command palmoilctl calls the palmoil contract for operators: it registers
+// and updates actors, records traceability steps, runs lineage queries and
+// exports the ledger's records. It talks to a peer through the Fabric
+// Gateway, or with -dry-run to the contract on an in-memory ledger, which can
+// be seeded from a palmoil-sim scenario.
+//
+// Usage:
+//
+//	palmoilctl [flags] <resource> <action> [action flags] [arguments]
+//	palmoilctl farmer add --from farmer.json
+//	palmoilctl trace batch PCD_001
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	"chaincode-if/client"
+	"chaincode-if/gateway"
+	"chaincode-if/memledger"
+	"chaincode-if/scenario"
+)
+
+// env is what a command runs with
+type env struct {
+	client client.Client
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	os.Exit(run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs palmoilctl with its arguments and returns the exit code
+func run(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("palmoilctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	output := flags.String("o", "table", "output format, table or json")
+	dryRun := flags.Bool("dry-run", false, "call the contract on an in-memory ledger instead of a peer")
+	seedPath := flags.String("seed", "", "with -dry-run, seed the ledger by running this scenario")
+	role := flags.String("role", "admin", "with -dry-run, the role attribute of the caller")
+	channel := flags.String("channel", "mychannel", "channel name")
+	chaincodeName := flags.String("chaincode", "palmoil", "chaincode name")
+	indexKey := flags.String("index-key", os.Getenv("PALMOIL_INDEX_KEY"), "identity index key passed with NIK registrations, purges and lookups; with -dry-run, defaults to the seed's")
+
+	var cfg gateway.Config
+	flags.StringVar(&cfg.PeerEndpoint, "peer", "localhost:7051", "peer gateway endpoint")
+	flags.StringVar(&cfg.PeerHostOverride, "peer-host", "peer0.org1.example.com", "peer TLS host name")
+	flags.StringVar(&cfg.TLSCertPath, "tls-cert", "", "peer TLS CA certificate")
+	flags.StringVar(&cfg.MSPID, "msp-id", "Org1MSP", "client MSP ID")
+	flags.StringVar(&cfg.CertPath, "cert", "", "client certificate")
+	flags.StringVar(&cfg.KeyPath, "key", "", "client private key")
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: palmoilctl [flags] <resource> <action> [action flags] [arguments]\n\ncommands:\n")
+		for _, usage := range commandUsages() {
+			fmt.Fprintf(stderr, "  %s\n", usage)
+		}
+		fmt.Fprintf(stderr, "\nflags:\n")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *output != "table" && *output != "json" {
+		fmt.Fprintf(stderr, "palmoilctl: unknown output format %q\n", *output)
+		return 2
+	}
+
+	cmd, cmdArgs, err := findCommand(flags.Args())
+	if err != nil {
+		fmt.Fprintf(stderr, "palmoilctl: %v\n", err)
+		flags.Usage()
+		return 2
+	}
+
+	e := &env{stdin: stdin, stdout: stdout}
+	if *dryRun {
+		e.client, err = dryRunClient(cfg.MSPID, *role, *seedPath, *indexKey)
+		if err != nil {
+			fmt.Fprintf(stderr, "palmoilctl: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(stderr, "palmoilctl: dry run, changes are discarded\n")
+	} else {
+		connection, err := gateway.Connect(cfg)
+		if err != nil {
+			fmt.Fprintf(stderr, "palmoilctl: %v\n", err)
+			return 1
+		}
+		defer connection.Close()
+		contract := connection.Gateway.GetNetwork(*channel).GetContract(*chaincodeName)
+		e.client = client.NewGateway(contract, cfg.MSPID).WithIndexKey(*indexKey)
+	}
+
+	result, err := cmd.run(ctx, e, cmdArgs)
+	if err != nil {
+		fmt.Fprintf(stderr, "palmoilctl: %v\n", err)
+		return 1
+	}
+	if result == nil {
+		return 0
+	}
+
+	if *output == "json" {
+		err = writeJSON(stdout, result)
+	} else {
+		err = writeTable(stdout, result)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "palmoilctl: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// dryRunClient returns a client of the contract on an in-memory ledger,
+// seeded by a scenario when seedPath is set
+func dryRunClient(mspID string, role string, seedPath string, indexKey string) (client.Client, error) {
+	ledger := memledger.New()
+	if seedPath != "" {
+		s, err := scenario.Load(seedPath)
+		if err != nil {
+			return nil, err
+		}
+		if seedKey, ok := s.Transient["indexKey"].(string); ok && indexKey == "" {
+			indexKey = seedKey
+		}
+		steps, err := scenario.Replay(ledger, s)
+		if err != nil {
+			return nil, err
+		}
+		for _, step := range steps {
+			if !step.Passed {
+				return nil, fmt.Errorf("seed step %s %s failed: %s", step.TxID, step.Function, step.Message)
+			}
+		}
+	}
+
+	identity, err := memledger.NewIdentity(mspID, "palmoilctl", map[string]string{"role": role})
+	if err != nil {
+		return nil, err
+	}
+
+	return client.NewInProcess(ledger, identity).WithIndexKey(indexKey), nil
+}
+
+// findCommand looks up the command named by the first two arguments
+func findCommand(args []string) (*command, []string, error) {
+	if len(args) < 1 {
+		return nil, nil, fmt.Errorf("no command given")
+	}
+	actions, ok := commands[args[0]]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown resource %q", args[0])
+	}
+	if len(args) < 2 {
+		return nil, nil, fmt.Errorf("no action given for %s", args[0])
+	}
+	cmd, ok := actions[args[1]]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown action %q for %s", args[1], args[0])
+	}
+
+	return &cmd, args[2:], nil
+}
+
+// commandUsages returns the usage line of every command, sorted
+func commandUsages() []string {
+	var usages []string
+	for resource, actions := range commands {
+		for action, cmd := range actions {
+			usages = append(usages, strings.TrimSpace(resource+" "+action+" "+cmd.usage))
+		}
+	}
+	sort.Strings(usages)
+	return usages
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"chaincode-if/chaincode"
+)
+
+// seed is the scenario every dry run in these tests starts from
+const seed = "../../scenarios/supply-chain.yaml"
+
+// ctl runs palmoilctl on a ledger seeded with the supply chain scenario and
+// returns its exit code and output, with the columns of each line separated
+// by a single space
+func ctl(t *testing.T, stdin string, args ...string) (int, []string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	args = append([]string{"-dry-run", "-seed", seed}, args...)
+	code := run(context.Background(), args, strings.NewReader(stdin), &stdout, &stderr)
+
+	var lines []string
+	for _, line := range strings.Split(strings.TrimRight(stdout.String(), "\n"), "\n") {
+		lines = append(lines, strings.Join(strings.Fields(line), " "))
+	}
+	return code, lines, stderr.String()
+}
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		positional []string
+		values     []string
+		state      string
+		wantErr    string
+	}{
+		{"positional only", []string{"COM_001"}, []string{"ID"}, []string{"COM_001"}, "", ""},
+		{"flag first", []string{"--state", "processed", "COM_001"}, []string{"ID"}, []string{"COM_001"}, "processed", ""},
+		{"flag last", []string{"COM_001", "--state", "processed"}, []string{"ID"}, []string{"COM_001"}, "processed", ""},
+		{"flag between", []string{"PRC_001", "-state=held", "TRP_001"}, []string{"ID", "TO"}, []string{"PRC_001", "TRP_001"}, "held", ""},
+		{"missing positional", []string{}, []string{"ID"}, nil, "", "expected arguments [ID], got 0"},
+		{"extra positional", []string{"COM_001", "COM_002"}, []string{"ID"}, nil, "", "expected arguments [ID], got 2"},
+		{"unknown flag", []string{"COM_001", "--status", "held"}, []string{"ID"}, nil, "", "flag provided but not defined: -status"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags := flag.NewFlagSet("", flag.ContinueOnError)
+			state := flags.String("state", "", "")
+			values, err := parseArgs(flags, tt.args, tt.positional...)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("expected error %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(values, ",") != strings.Join(tt.values, ",") {
+				t.Errorf("expected arguments %v, got %v", tt.values, values)
+			}
+			if *state != tt.state {
+				t.Errorf("expected state %q, got %q", tt.state, *state)
+			}
+		})
+	}
+}
+
+func TestFindCommand(t *testing.T) {
+	for resource, actions := range commands {
+		for action := range actions {
+			cmd, args, err := findCommand([]string{resource, action, "ID"})
+			if err != nil {
+				t.Errorf("%s %s: %v", resource, action, err)
+				continue
+			}
+			if cmd.usage != commands[resource][action].usage || len(args) != 1 || args[0] != "ID" {
+				t.Errorf("%s %s: expected its command with arguments [ID], got %q with %v", resource, action, cmd.usage, args)
+			}
+		}
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"no command", nil, "no command given"},
+		{"unknown resource", []string{"harvest"}, `unknown resource "harvest"`},
+		{"no action", []string{"commodity"}, "no action given for commodity"},
+		{"unknown action", []string{"commodity", "delete"}, `unknown action "delete" for commodity`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := findCommand(tt.args)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	farmer := `{"id": "FRM_002", "name": "Joko", "address": "Jambi", "farm": [],
+		"personal": {"nik": "1471010101900005", "noHP": "+6281200000005", "email": "joko@example.com", "salt": "test-salt-farmer-02"}}`
+
+	tests := []struct {
+		name      string
+		args      []string
+		stdin     string
+		wantCode  int
+		wantLines []string
+		wantErr   string
+	}{
+		{
+			name:      "record as fields",
+			args:      []string{"farmer", "get", "FRM_001"},
+			wantLines: []string{"FIELD VALUE", "id FRM_001", "name Slamet", "address Kampar", "farm FARM_001"},
+		},
+		{
+			name: "list as rows",
+			args: []string{"commodity", "list", "--state", "processed"},
+			wantLines: []string{
+				"ID NAME QUANTITY DATEHARVESTED FARMID FARMERID COLLECTORID TRANSPORTERID STATE HELDFROM PROCESSEDINTO RECALLID",
+				"COM_001 FFB 100 2024-03-01 FARM_001 FRM_001 COL_001 TRP_001 processed PCD_001",
+				"COM_002 FFB 120 2024-03-01 FARM_001 FRM_001 COL_001 TRP_001 processed PCD_001",
+			},
+		},
+		{
+			name:      "batch trace with its lineage",
+			args:      []string{"trace", "batch", "PCD_001"},
+			wantLines: []string{"id PCD_001", "material COM_001,COM_002", "COMMODITY QUANTITY FARM FARMER", "COM_001 100 FARM_001 FRM_001", "COM_002 120 FARM_001 FRM_001"},
+		},
+		{
+			name:  "add from stdin",
+			args:  []string{"-role", "farmer", "farmer", "add", "--from", "-"},
+			stdin: farmer,
+		},
+		{
+			name:     "missing --from",
+			args:     []string{"-role", "farmer", "farmer", "add"},
+			wantCode: 1,
+			wantErr:  "palmoilctl: --from is required",
+		},
+		{
+			name:     "missing argument",
+			args:     []string{"commodity", "get"},
+			wantCode: 1,
+			wantErr:  "palmoilctl: expected arguments [ID], got 0",
+		},
+		{
+			name:     "contract error",
+			args:     []string{"commodity", "get", "COM_404"},
+			wantCode: 1,
+			wantErr:  "palmoilctl: the commodity with ID COM_404 does not exist",
+		},
+		{
+			name:     "no command",
+			args:     nil,
+			wantCode: 2,
+			wantErr:  "palmoilctl: no command given",
+		},
+		{
+			name:     "unknown action",
+			args:     []string{"commodity", "delete", "COM_001"},
+			wantCode: 2,
+			wantErr:  `palmoilctl: unknown action "delete" for commodity`,
+		},
+		{
+			name:     "unknown output format",
+			args:     []string{"-o", "xml", "commodity", "get", "COM_001"},
+			wantCode: 2,
+			wantErr:  `palmoilctl: unknown output format "xml"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, lines, stderr := ctl(t, tt.stdin, tt.args...)
+			if code != tt.wantCode {
+				t.Fatalf("expected exit code %d, got %d: %s", tt.wantCode, code, stderr)
+			}
+			if tt.wantErr != "" && !strings.Contains(stderr, tt.wantErr+"\n") {
+				t.Errorf("expected %q on stderr, got %q", tt.wantErr, stderr)
+			}
+			for _, want := range tt.wantLines {
+				if !containsLine(lines, want) {
+					t.Errorf("expected the line %q, got %q", want, lines)
+				}
+			}
+		})
+	}
+}
+
+func TestRunJSON(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	args := []string{"-dry-run", "-seed", seed, "-o", "json", "batch", "get", "PCD_001"}
+	if code := run(context.Background(), args, strings.NewReader(""), &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if !strings.HasPrefix(stdout.String(), "{\n  \"id\": \"PCD_001\",\n") {
+		t.Errorf("expected indented JSON, got %s", stdout.String())
+	}
+
+	var batch chaincode.ProcessedCommodity
+	if err := json.Unmarshal(stdout.Bytes(), &batch); err != nil {
+		t.Fatal(err)
+	}
+	if batch.ID != "PCD_001" || strings.Join(batch.Material, ",") != "COM_001,COM_002" {
+		t.Errorf("expected PCD_001 made from COM_001 and COM_002, got %s from %v", batch.ID, batch.Material)
+	}
+}
+
+func TestRunExportToFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "farms.json")
+	code, lines, stderr := ctl(t, "", "export", "farms", "--file", path)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr)
+	}
+	if len(lines) != 1 || lines[0] != "" {
+		t.Errorf("expected nothing on stdout, got %q", lines)
+	}
+
+	exported, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !json.Valid(exported) || !bytes.Contains(exported, []byte(`"FARM_001"`)) {
+		t.Errorf("expected the farms as JSON, got %s", exported)
+	}
+}
+
+func containsLine(lines []string, want string) bool {
+	for _, line := range lines {
+		if line == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+
+	"chaincode-if/chaincode"
+)
+
+// writeJSON writes a result as indented JSON
+func writeJSON(out io.Writer, result interface{}) error {
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode result: %v", err)
+	}
+
+	_, err = fmt.Fprintf(out, "%s\n", resultJSON)
+	return err
+}
+
+// writeTable writes a result as aligned columns: a list as one row per
+// record, and a single record as one row per field
+func writeTable(out io.Writer, result interface{}) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+
+	switch result := result.(type) {
+	case *chaincode.BatchLineage:
+		writeLineage(w, result)
+	case *chaincode.ForwardTrace:
+		writeForwardTrace(w, result)
+	default:
+		writeValue(w, reflect.ValueOf(result))
+	}
+
+	return w.Flush()
+}
+
+// writeLineage writes a batch followed by the origin of each of its materials
+func writeLineage(w io.Writer, lineage *chaincode.BatchLineage) {
+	writeValue(w, reflect.ValueOf(lineage.Batch))
+	fmt.Fprintf(w, "\nCOMMODITY\tQUANTITY\tFARM\tFARMER\n")
+	for _, material := range lineage.Materials {
+		farmID, farmerID := "-", "-"
+		if material.Farm != nil {
+			farmID = material.Farm.ID
+		}
+		if material.Farmer != nil {
+			farmerID = material.Farmer.ID
+		}
+		fmt.Fprintf(w, "%s\t%v\t%s\t%s\n", material.Commodity.ID, material.Commodity.Quantity, farmID, farmerID)
+	}
+}
+
+// writeForwardTrace writes the commodities and batches that came from an origin
+func writeForwardTrace(w io.Writer, trace *chaincode.ForwardTrace) {
+	fmt.Fprintf(w, "%s %s\n\n", trace.OriginType, trace.OriginID)
+	writeValue(w, reflect.ValueOf(trace.Commodities))
+	fmt.Fprintln(w)
+	writeValue(w, reflect.ValueOf(trace.Batches))
+}
+
+// writeValue writes a list of records, a record or a scalar
+func writeValue(w io.Writer, value reflect.Value) {
+	value = indirect(value)
+	if !value.IsValid() {
+		fmt.Fprintln(w, "not found")
+		return
+	}
+
+	switch {
+	case value.Kind() == reflect.Slice && value.Type().Elem().Kind() != reflect.Uint8:
+		writeRows(w, value)
+	case value.Kind() == reflect.Struct:
+		fmt.Fprintf(w, "FIELD\tVALUE\n")
+		for _, field := range fields(value.Type()) {
+			fmt.Fprintf(w, "%s\t%s\n", field.name, formatField(value.Field(field.index)))
+		}
+	default:
+		fmt.Fprintln(w, formatField(value))
+	}
+}
+
+// writeRows writes a list as one row per element, with a column per scalar
+// field when the elements are records
+func writeRows(w io.Writer, list reflect.Value) {
+	elemType := list.Type().Elem()
+	for elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+
+	if elemType.Kind() != reflect.Struct {
+		for i := 0; i < list.Len(); i++ {
+			fmt.Fprintln(w, formatField(list.Index(i)))
+		}
+		return
+	}
+
+	var columns []field
+	var names []string
+	for _, field := range fields(elemType) {
+		if isScalar(field.typ) {
+			columns = append(columns, field)
+			names = append(names, strings.ToUpper(field.name))
+		}
+	}
+
+	fmt.Fprintln(w, strings.Join(names, "\t"))
+	for i := 0; i < list.Len(); i++ {
+		elem := indirect(list.Index(i))
+		if !elem.IsValid() {
+			continue
+		}
+		cells := make([]string, len(columns))
+		for j, column := range columns {
+			cells[j] = formatField(elem.Field(column.index))
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+}
+
+// field is an exported field of a record, named after its JSON key
+type field struct {
+	name  string
+	index int
+	typ   reflect.Type
+}
+
+// fields returns the fields of a record that are encoded to JSON
+func fields(t reflect.Type) []field {
+	var result []field
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		if !structField.IsExported() {
+			continue
+		}
+		name := strings.Split(structField.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = structField.Name
+		}
+		result = append(result, field{name: name, index: i, typ: structField.Type})
+	}
+	return result
+}
+
+// isScalar reports whether a field fits in a table cell
+func isScalar(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.String
+	}
+	return false
+}
+
+// formatField formats a field for a table cell. Lists of strings are joined
+// with commas and records are written as compact JSON.
+func formatField(value reflect.Value) string {
+	value = indirect(value)
+	if !value.IsValid() {
+		return "-"
+	}
+
+	if value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.String {
+		items := make([]string, value.Len())
+		for i := range items {
+			items[i] = value.Index(i).String()
+		}
+		return strings.Join(items, ",")
+	}
+	if isScalar(value.Type()) {
+		return fmt.Sprint(value.Interface())
+	}
+
+	valueJSON, err := json.Marshal(value.Interface())
+	if err != nil {
+		return fmt.Sprint(value.Interface())
+	}
+	return string(valueJSON)
+}
+
+// indirect follows pointers and interfaces, returning an invalid value for nil
+func indirect(value reflect.Value) reflect.Value {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return reflect.Value{}
+		}
+		value = value.Elem()
+	}
+	return value
+}
@@ -0,0 +1,107 @@
+// Package gateway connects off-chain Go programs to a Fabric peer through the
+// Fabric Gateway service.
+package gateway
+
+import (
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-gateway/pkg/identity"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// Config locates the peer and the client identity used to connect to it
+type Config struct {
+	// PeerEndpoint is the host:port of the peer's gateway service
+	PeerEndpoint string
+	// PeerHostOverride is the TLS server name of the peer, when it differs
+	// from the endpoint host
+	PeerHostOverride string
+	// TLSCertPath is the PEM file of the peer's TLS CA certificate
+	TLSCertPath string
+	// MSPID is the MSP of the client identity
+	MSPID string
+	// CertPath and KeyPath are the PEM files of the client's enrollment
+	// certificate and private key
+	CertPath string
+	KeyPath  string
+}
+
+// Connection is an open gateway connection
+type Connection struct {
+	Gateway *client.Gateway
+	conn    *grpc.ClientConn
+}
+
+// Connect opens a gateway connection to the configured peer
+func Connect(cfg Config) (*Connection, error) {
+	tlsCertPEM, err := os.ReadFile(cfg.TLSCertPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TLS certificate: %v", err)
+	}
+	tlsCert, err := identity.CertificateFromPEM(tlsCertPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse TLS certificate: %v", err)
+	}
+	certPool := x509.NewCertPool()
+	certPool.AddCert(tlsCert)
+
+	conn, err := grpc.Dial(cfg.PeerEndpoint, grpc.WithTransportCredentials(credentials.NewClientTLSFromCert(certPool, cfg.PeerHostOverride)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial %s: %v", cfg.PeerEndpoint, err)
+	}
+
+	id, sign, err := loadIdentity(cfg)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	gw, err := client.Connect(id, client.WithSign(sign), client.WithClientConnection(conn))
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to connect to gateway: %v", err)
+	}
+
+	return &Connection{Gateway: gw, conn: conn}, nil
+}
+
+// Close closes the gateway and its gRPC connection
+func (c *Connection) Close() error {
+	c.Gateway.Close()
+	return c.conn.Close()
+}
+
+// loadIdentity reads the client certificate and private key
+func loadIdentity(cfg Config) (*identity.X509Identity, identity.Sign, error) {
+	certPEM, err := os.ReadFile(cfg.CertPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read client certificate: %v", err)
+	}
+	cert, err := identity.CertificateFromPEM(certPEM)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse client certificate: %v", err)
+	}
+	id, err := identity.NewX509Identity(cfg.MSPID, cert)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyPEM, err := os.ReadFile(cfg.KeyPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read private key: %v", err)
+	}
+	key, err := identity.PrivateKeyFromPEM(keyPEM)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse private key: %v", err)
+	}
+	sign, err := identity.NewPrivateKeySign(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return id, sign, nil
+}
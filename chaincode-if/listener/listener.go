@@ -0,0 +1,72 @@
+package listener
+
+import (
+	"context"
+	"log"
+)
+
+// Listener reads events from a source and applies them to a read model,
+// resuming after the read model's checkpoint
+type Listener struct {
+	source     EventSource
+	model      *ReadModel
+	startBlock uint64
+	logger     *log.Logger
+}
+
+// New returns a listener that starts at startBlock when the read model has no
+// checkpoint yet. A nil logger discards progress messages.
+func New(source EventSource, model *ReadModel, startBlock uint64, logger *log.Logger) *Listener {
+	return &Listener{source: source, model: model, startBlock: startBlock, logger: logger}
+}
+
+// Run applies events until the source is exhausted or the context is done. It
+// returns an error if the source fails, such as when the connection to the
+// peer is lost.
+func (l *Listener) Run(ctx context.Context) error {
+	checkpoint, err := l.model.Checkpoint(ctx)
+	if err != nil {
+		return err
+	}
+
+	startBlock := l.startBlock
+	if checkpoint != nil {
+		startBlock = checkpoint.BlockNumber
+	}
+	l.logf("reading events from block %d", startBlock)
+
+	events, err := l.source.Events(ctx, startBlock)
+	if err != nil {
+		return err
+	}
+
+	// The checkpoint block is read again; skip its events up to and
+	// including the checkpoint transaction
+	skipping := checkpoint != nil
+	for event := range events {
+		if skipping && event.BlockNumber == checkpoint.BlockNumber {
+			if event.TransactionID == checkpoint.TransactionID {
+				skipping = false
+			}
+			continue
+		}
+		skipping = false
+
+		err = l.model.Apply(ctx, event)
+		if err != nil {
+			return err
+		}
+		l.logf("applied %s from block %d transaction %s", event.EventName, event.BlockNumber, event.TransactionID)
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return l.source.Err()
+}
+
+func (l *Listener) logf(format string, args ...interface{}) {
+	if l.logger != nil {
+		l.logger.Printf(format, args...)
+	}
+}
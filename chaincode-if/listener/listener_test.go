@@ -0,0 +1,189 @@
+package listener
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"chaincode-if/memledger"
+	"chaincode-if/scenario"
+)
+
+// recordEvents runs a scenario on an in-memory ledger and returns the events
+// it committed, as a peer would deliver them
+func recordEvents(t *testing.T, path string) []*Event {
+	t.Helper()
+	s, err := scenario.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ledger := memledger.New()
+	steps, err := scenario.Replay(ledger, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, step := range steps {
+		if !step.Passed {
+			t.Fatalf("step %d %s failed: %s", i+1, step.Function, step.Message)
+		}
+	}
+
+	var events []*Event
+	for _, event := range ledger.Events() {
+		events = append(events, &Event{
+			BlockNumber:   event.BlockNumber,
+			TransactionID: event.TransactionID,
+			ChaincodeName: "palmoil",
+			EventName:     event.EventName,
+			Payload:       event.Payload,
+		})
+	}
+	return events
+}
+
+// openTestModel opens an empty read model that is closed when the test ends
+func openTestModel(t *testing.T) *ReadModel {
+	t.Helper()
+	model, err := OpenReadModel(filepath.Join(t.TempDir(), "palmoil.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { model.Close() })
+	return model
+}
+
+// run runs a listener over events and returns the number of events it applied
+func run(t *testing.T, model *ReadModel, events []*Event) int {
+	t.Helper()
+	var logs bytes.Buffer
+	err := New(NewReplaySource(events), model, 0, log.New(&logs, "", 0)).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Count(logs.String(), "applied ")
+}
+
+// queryString returns the single value a query selects, as a string
+func queryString(t *testing.T, db *sql.DB, query string, args ...interface{}) string {
+	t.Helper()
+	var value string
+	err := db.QueryRow(query, args...).Scan(&value)
+	if err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return value
+}
+
+func TestRunResumesFromCheckpoint(t *testing.T) {
+	// Two transactions per block, so the listener resumes in the middle of
+	// the checkpoint block
+	var events []*Event
+	for i, event := range recordEvents(t, "testdata/every-event.yaml") {
+		paired := *event
+		paired.BlockNumber = uint64(i/2 + 1)
+		events = append(events, &paired)
+	}
+	split := 11
+
+	model := openTestModel(t)
+	if applied := run(t, model, events[:split]); applied != split {
+		t.Fatalf("expected %d events applied, got %d", split, applied)
+	}
+	checkpoint, err := model.Checkpoint(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint == nil || checkpoint.TransactionID != events[split-1].TransactionID {
+		t.Fatalf("expected the checkpoint at transaction %s, got %+v", events[split-1].TransactionID, checkpoint)
+	}
+
+	if applied := run(t, model, events); applied != len(events)-split {
+		t.Errorf("expected %d events applied after restarting, got %d", len(events)-split, applied)
+	}
+	if applied := run(t, model, events); applied != 0 {
+		t.Errorf("expected no events applied once up to date, got %d", applied)
+	}
+
+	// The resumed read model matches one built in a single run
+	whole := openTestModel(t)
+	run(t, whole, events)
+	for _, query := range []string{
+		`SELECT COUNT(*) FROM events`,
+		`SELECT COUNT(*) FROM trace_events`,
+		`SELECT group_concat(id || ':' || status, ',') FROM (SELECT id, status FROM partnerships ORDER BY id)`,
+		`SELECT group_concat(id || ':' || state, ',') FROM (SELECT id, state FROM commodities ORDER BY id)`,
+		`SELECT group_concat(id || ':' || farms, ',') FROM (SELECT id, farms FROM farmers ORDER BY id)`,
+	} {
+		resumed := queryString(t, model.DB(), query)
+		expected := queryString(t, whole.DB(), query)
+		if resumed != expected {
+			t.Errorf("%s: expected %s, got %s", query, expected, resumed)
+		}
+	}
+}
+
+func TestRecordingSourceReplays(t *testing.T) {
+	events := recordEvents(t, "testdata/every-event.yaml")
+
+	var recorded bytes.Buffer
+	source := NewRecordingSource(NewReplaySource(events), &recorded)
+	err := New(source, openTestModel(t), 0, nil).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replayed, err := ReadEvents(&recorded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(replayed, events) {
+		t.Errorf("expected the recording to replay the %d events, got %d", len(events), len(replayed))
+	}
+}
+
+// failingSource delivers its events and then fails, like a gateway whose
+// connection to the peer is lost
+type failingSource struct {
+	events []*Event
+	err    error
+}
+
+func (s *failingSource) Events(ctx context.Context, startBlock uint64) (<-chan *Event, error) {
+	events := make(chan *Event, len(s.events))
+	for _, event := range s.events {
+		events <- event
+	}
+	close(events)
+	return events, nil
+}
+
+func (s *failingSource) Err() error {
+	return s.err
+}
+
+func TestRunFailsWithSource(t *testing.T) {
+	events := recordEvents(t, "testdata/every-event.yaml")
+	lost := errors.New("connection lost")
+	model := openTestModel(t)
+
+	err := New(&failingSource{events: events[:3], err: lost}, model, 0, nil).Run(context.Background())
+	if !errors.Is(err, lost) {
+		t.Fatalf("expected the failure of the source, got %v", err)
+	}
+
+	// The events delivered before the failure are kept
+	checkpoint, err := model.Checkpoint(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint == nil || checkpoint.TransactionID != events[2].TransactionID {
+		t.Errorf("expected the checkpoint at transaction %s, got %+v", events[2].TransactionID, checkpoint)
+	}
+}
@@ -0,0 +1,414 @@
+package listener
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"chaincode-if/chaincode"
+)
+
+// project decodes the envelope of an event, logs it and updates the tables
+// its payload affects. Events without a table, such as configuration and
+// migration events, are only logged.
+func project(ctx context.Context, tx *sql.Tx, event *Event) error {
+	var envelope chaincode.Event
+	err := json.Unmarshal(event.Payload, &envelope)
+	if err != nil {
+		return fmt.Errorf("failed to decode event envelope: %v", err)
+	}
+
+	// Recalls were announced with the bare Recall as payload before events
+	// were wrapped in an envelope
+	if envelope.Name == "" && event.EventName == chaincode.EventBatchRecalled {
+		var recall chaincode.Recall
+		err = json.Unmarshal(event.Payload, &recall)
+		if err != nil {
+			return err
+		}
+		envelope = chaincode.Event{
+			Name:      chaincode.EventBatchRecalled,
+			TxID:      recall.TxID,
+			Timestamp: recall.Timestamp,
+			MSPID:     recall.MSPID,
+			Payload:   event.Payload,
+		}
+	}
+
+	if envelope.Name != event.EventName {
+		return fmt.Errorf("envelope name %q does not match event name %q", envelope.Name, event.EventName)
+	}
+	if envelope.SchemaVersion > chaincode.EventSchemaVersion {
+		return fmt.Errorf("unsupported schema version %d, expected at most %d", envelope.SchemaVersion, chaincode.EventSchemaVersion)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT OR IGNORE INTO events (transaction_id, block_number, name, schema_version, timestamp, msp_id, payload)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		event.TransactionID, event.BlockNumber, envelope.Name, envelope.SchemaVersion, envelope.Timestamp, envelope.MSPID, string(envelope.Payload))
+	if err != nil {
+		return err
+	}
+
+	switch envelope.Name {
+	case chaincode.EventFarmerAdded, chaincode.EventFarmerUpdated:
+		var farmer chaincode.FarmerPayload
+		if err := json.Unmarshal(envelope.Payload, &farmer); err != nil {
+			return err
+		}
+		return projectFarmer(ctx, tx, &farmer, envelope.Timestamp)
+
+	case chaincode.EventFarmAdded, chaincode.EventFarmUpdated:
+		var farm chaincode.FarmPayload
+		if err := json.Unmarshal(envelope.Payload, &farm); err != nil {
+			return err
+		}
+		return projectFarm(ctx, tx, &farm, envelope.Timestamp)
+
+	case chaincode.EventFarmOwnershipTransferred:
+		var transfer chaincode.FarmTransfer
+		if err := json.Unmarshal(envelope.Payload, &transfer); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE farms SET owner = ?, updated_at = ? WHERE id = ?`, transfer.To, envelope.Timestamp, transfer.FarmID)
+		if err != nil {
+			return err
+		}
+		err = endPartnerships(ctx, tx, transfer.EndedPartnerships, envelope.Timestamp)
+		if err != nil {
+			return err
+		}
+		return linkFarm(ctx, tx, transfer.FarmID, transfer.To, envelope.Timestamp)
+
+	case chaincode.EventCollectorAdded, chaincode.EventCollectorUpdated:
+		var collector chaincode.CollectorPayload
+		if err := json.Unmarshal(envelope.Payload, &collector); err != nil {
+			return err
+		}
+		return projectCollector(ctx, tx, &collector, envelope.Timestamp)
+
+	case chaincode.EventPartnershipProposed, chaincode.EventPartnershipAccepted,
+		chaincode.EventPartnershipRejected, chaincode.EventPartnershipEnded:
+		var partnership chaincode.PartnershipPayload
+		if err := json.Unmarshal(envelope.Payload, &partnership); err != nil {
+			return err
+		}
+		return projectPartnership(ctx, tx, &partnership, envelope.Timestamp)
+
+	case chaincode.EventProcessorAdded, chaincode.EventProcessorUpdated:
+		var processor chaincode.ProcessorPayload
+		if err := json.Unmarshal(envelope.Payload, &processor); err != nil {
+			return err
+		}
+		return projectProcessor(ctx, tx, &processor, envelope.Timestamp)
+
+	case chaincode.EventTransporterAdded, chaincode.EventTransporterUpdated:
+		var transporter chaincode.TransporterPayload
+		if err := json.Unmarshal(envelope.Payload, &transporter); err != nil {
+			return err
+		}
+		return projectTransporter(ctx, tx, &transporter, envelope.Timestamp)
+
+	case chaincode.EventCommodityHarvested, chaincode.EventCommodityCollected, chaincode.EventCommodityInTransport,
+		chaincode.EventCommodityDelivered, chaincode.EventCommodityHeld, chaincode.EventCommodityReleased,
+		chaincode.EventCommodityRejected:
+		var commodity chaincode.CommodityPayload
+		if err := json.Unmarshal(envelope.Payload, &commodity); err != nil {
+			return err
+		}
+		return projectCommodity(ctx, tx, &commodity, event.TransactionID)
+
+	case chaincode.EventCommodityProcessed:
+		var batch chaincode.BatchPayload
+		if err := json.Unmarshal(envelope.Payload, &batch); err != nil {
+			return err
+		}
+		return projectBatch(ctx, tx, &batch, event.TransactionID)
+
+	case chaincode.EventBatchRecalled:
+		var recall chaincode.Recall
+		if err := json.Unmarshal(envelope.Payload, &recall); err != nil {
+			return err
+		}
+		return projectRecall(ctx, tx, &recall, event.TransactionID)
+
+	case chaincode.EventPersonalDataPurged:
+		var erasure chaincode.ErasurePayload
+		if err := json.Unmarshal(envelope.Payload, &erasure); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `INSERT OR IGNORE INTO erasures (id, entity_type, entity_id, timestamp) VALUES (?, ?, ?, ?)`,
+			erasure.ID, erasure.EntityType, erasure.EntityID, envelope.Timestamp)
+		return err
+	}
+
+	return nil
+}
+
+// jsonList encodes a list for a TEXT column, never as null
+func jsonList(values []string) string {
+	if values == nil {
+		values = []string{}
+	}
+	listJSON, _ := json.Marshal(values)
+	return string(listJSON)
+}
+
+func projectFarmer(ctx context.Context, tx *sql.Tx, farmer *chaincode.FarmerPayload, timestamp string) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO farmers (id, farms, msp_id, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET farms = excluded.farms, msp_id = excluded.msp_id, updated_at = excluded.updated_at`,
+		farmer.ID, jsonList(farmer.Farms), farmer.MSPID, timestamp)
+	if err != nil {
+		return err
+	}
+
+	// Farmers become the owner of the unowned farms they list
+	for _, farmID := range farmer.Farms {
+		_, err = tx.ExecContext(ctx, `UPDATE farms SET owner = ?, updated_at = ? WHERE id = ? AND owner = ''`, farmer.ID, timestamp, farmID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func projectFarm(ctx context.Context, tx *sql.Tx, farm *chaincode.FarmPayload, timestamp string) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO farms (id, owner, planted_year, seed_varieties, area, capacity, legality, certificate, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET owner = excluded.owner, planted_year = excluded.planted_year,
+			seed_varieties = excluded.seed_varieties, area = excluded.area, capacity = excluded.capacity,
+			legality = excluded.legality, certificate = excluded.certificate, updated_at = excluded.updated_at`,
+		farm.ID, farm.Owner, farm.PlantedYear, farm.SeedVarieties, farm.Area, farm.Capacity, farm.Legality,
+		farm.Certificate, timestamp)
+	if err != nil {
+		return err
+	}
+
+	err = endPartnerships(ctx, tx, farm.EndedPartnerships, timestamp)
+	if err != nil {
+		return err
+	}
+
+	return linkFarm(ctx, tx, farm.ID, farm.Owner, timestamp)
+}
+
+// linkFarm moves a farm to the farm list of its owner. The chaincode updates
+// the farm lists in the same transaction as the farm, but only announces the
+// farm.
+func linkFarm(ctx context.Context, tx *sql.Tx, farmID string, owner string, timestamp string) error {
+	farmIDJSON, _ := json.Marshal(farmID)
+	rows, err := tx.QueryContext(ctx, `SELECT id, farms FROM farmers WHERE id = ? OR farms LIKE ?`, owner, "%"+string(farmIDJSON)+"%")
+	if err != nil {
+		return err
+	}
+
+	lists := map[string][]string{}
+	for rows.Next() {
+		var id, farmsJSON string
+		if err := rows.Scan(&id, &farmsJSON); err != nil {
+			rows.Close()
+			return err
+		}
+		var farms []string
+		if err := json.Unmarshal([]byte(farmsJSON), &farms); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to decode farms of farmer %s: %v", id, err)
+		}
+		lists[id] = farms
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, farms := range lists {
+		updated := []string{}
+		listed := false
+		for _, listedID := range farms {
+			if listedID != farmID || id == owner {
+				updated = append(updated, listedID)
+			}
+			listed = listed || listedID == farmID
+		}
+		if id == owner && !listed {
+			updated = append(updated, farmID)
+		}
+		if len(updated) == len(farms) {
+			continue
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE farmers SET farms = ?, updated_at = ? WHERE id = ?`, jsonList(updated), timestamp, id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func projectCollector(ctx context.Context, tx *sql.Tx, collector *chaincode.CollectorPayload, timestamp string) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO collectors (id, nib, capacity, partners, msp_id, updated_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET nib = excluded.nib, capacity = excluded.capacity, partners = excluded.partners,
+			msp_id = excluded.msp_id, updated_at = excluded.updated_at`,
+		collector.ID, collector.NIB, collector.Capacity, jsonList(collector.Partners), collector.MSPID, timestamp)
+	return err
+}
+
+// projectPartnership records a partnership and relists the partners of its
+// collector, which the chaincode updates in the same transaction without
+// announcing the collector
+func projectPartnership(ctx context.Context, tx *sql.Tx, partnership *chaincode.PartnershipPayload, timestamp string) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO partnerships (id, collector_id, partner_type, partner_id, start_date, end_date, status, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET status = excluded.status, updated_at = excluded.updated_at`,
+		partnership.ID, partnership.CollectorID, partnership.PartnerType, partnership.PartnerID, partnership.StartDate,
+		partnership.EndDate, string(partnership.Status), timestamp)
+	if err != nil {
+		return err
+	}
+
+	return relistPartners(ctx, tx, partnership.CollectorID, timestamp)
+}
+
+// endPartnerships ends the partnerships a change of farm owner ended and
+// relists the partners of their collectors
+func endPartnerships(ctx context.Context, tx *sql.Tx, ids []string, timestamp string) error {
+	for _, id := range ids {
+		var collectorID string
+		err := tx.QueryRowContext(ctx, `SELECT collector_id FROM partnerships WHERE id = ?`, id).Scan(&collectorID)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE partnerships SET status = ?, updated_at = ? WHERE id = ?`, string(chaincode.PartnershipEnded), timestamp, id)
+		if err != nil {
+			return err
+		}
+		err = relistPartners(ctx, tx, collectorID, timestamp)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// relistPartners sets the partners of a collector to its active partners
+func relistPartners(ctx context.Context, tx *sql.Tx, collectorID string, timestamp string) error {
+	rows, err := tx.QueryContext(ctx, `SELECT partner_id FROM partnerships WHERE collector_id = ? AND status = ?
+		GROUP BY partner_type, partner_id ORDER BY partner_type, partner_id`, collectorID, string(chaincode.PartnershipActive))
+	if err != nil {
+		return err
+	}
+	partners := []string{}
+	for rows.Next() {
+		var partnerID string
+		if err := rows.Scan(&partnerID); err != nil {
+			rows.Close()
+			return err
+		}
+		partners = append(partners, partnerID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE collectors SET partners = ?, updated_at = ? WHERE id = ?`, jsonList(partners), timestamp, collectorID)
+	return err
+}
+
+func projectProcessor(ctx context.Context, tx *sql.Tx, processor *chaincode.ProcessorPayload, timestamp string) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO processors (id, nib, capacity, msp_id, updated_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET nib = excluded.nib, capacity = excluded.capacity, msp_id = excluded.msp_id,
+			updated_at = excluded.updated_at`,
+		processor.ID, processor.NIB, processor.Capacity, processor.MSPID, timestamp)
+	return err
+}
+
+func projectTransporter(ctx context.Context, tx *sql.Tx, transporter *chaincode.TransporterPayload, timestamp string) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO transporters (id, num_ship, msp_id, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET num_ship = excluded.num_ship, msp_id = excluded.msp_id, updated_at = excluded.updated_at`,
+		transporter.ID, transporter.NumShip, transporter.MSPID, timestamp)
+	return err
+}
+
+func projectCommodity(ctx context.Context, tx *sql.Tx, commodity *chaincode.CommodityPayload, txID string) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO commodities (id, name, quantity, date_harvested, farm_id, farmer_id, collector_id, transporter_id, state, held_from, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET name = excluded.name, quantity = excluded.quantity,
+			date_harvested = excluded.date_harvested, farm_id = excluded.farm_id, farmer_id = excluded.farmer_id,
+			collector_id = excluded.collector_id, transporter_id = excluded.transporter_id, state = excluded.state,
+			held_from = excluded.held_from, updated_at = excluded.updated_at`,
+		commodity.ID, commodity.Name, commodity.Quantity, commodity.DateHarvested, commodity.FarmID, commodity.FarmerID,
+		commodity.CollectorID, commodity.TransporterID, string(commodity.State), string(commodity.HeldFrom), commodity.Step.Timestamp)
+	if err != nil {
+		return err
+	}
+
+	return insertTraceEvent(ctx, tx, txID, commodity.ID, commodity.Step)
+}
+
+func projectBatch(ctx context.Context, tx *sql.Tx, batch *chaincode.BatchPayload, txID string) error {
+	_, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO batches (id, processor, quantity, input_quantity, extraction_rate, materials, batch_number, quality, status, processed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		batch.ID, batch.Processor, batch.Quantity, batch.InputQuantity, batch.ExtractionRate, jsonList(batch.Materials),
+		batch.BatchNumber, batch.Quality, string(batch.Status), batch.Step.Timestamp)
+	if err != nil {
+		return err
+	}
+
+	for _, materialID := range batch.Materials {
+		_, err = tx.ExecContext(ctx, `UPDATE commodities SET state = ?, processed_into = ?, updated_at = ? WHERE id = ?`,
+			string(chaincode.StateProcessed), batch.ID, batch.Step.Timestamp, materialID)
+		if err != nil {
+			return err
+		}
+		err = insertTraceEvent(ctx, tx, txID, materialID, batch.Step)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func projectRecall(ctx context.Context, tx *sql.Tx, recall *chaincode.Recall, txID string) error {
+	_, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO recalls (id, origin_type, origin_id, reason, commodities, batches, timestamp)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		recall.ID, recall.OriginType, recall.OriginID, recall.Reason, jsonList(recall.Commodities), jsonList(recall.Batches), recall.Timestamp)
+	if err != nil {
+		return err
+	}
+
+	step := chaincode.StepPayload{Status: string(chaincode.StateRecalled), Timestamp: recall.Timestamp, TxID: txID, MSPID: recall.MSPID}
+	for _, commodityID := range recall.Commodities {
+		_, err = tx.ExecContext(ctx, `UPDATE commodities SET state = ?, held_from = '', recall_id = ?, updated_at = ? WHERE id = ?`,
+			string(chaincode.StateRecalled), recall.ID, recall.Timestamp, commodityID)
+		if err != nil {
+			return err
+		}
+		err = insertTraceEvent(ctx, tx, txID, commodityID, step)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, batchID := range recall.Batches {
+		_, err = tx.ExecContext(ctx, `UPDATE batches SET status = ?, recall_id = ? WHERE id = ?`,
+			string(chaincode.BatchRecalled), recall.ID, batchID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func insertTraceEvent(ctx context.Context, tx *sql.Tx, txID string, commodityID string, step chaincode.StepPayload) error {
+	_, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO trace_events (transaction_id, commodity_id, status, location, timestamp, msp_id)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		txID, commodityID, step.Status, step.Location, step.Timestamp, step.MSPID)
+	return err
+}
@@ -0,0 +1,133 @@
+package listener
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"chaincode-if/chaincode"
+)
+
+// eventNames lists every event the chaincode emits
+var eventNames = []string{
+	chaincode.EventFarmerAdded, chaincode.EventFarmerUpdated, chaincode.EventFarmAdded, chaincode.EventFarmUpdated,
+	chaincode.EventFarmTransferProposed, chaincode.EventFarmOwnershipTransferred, chaincode.EventFarmTransferRejected,
+	chaincode.EventFarmTransferCancelled, chaincode.EventPartnershipProposed, chaincode.EventPartnershipAccepted,
+	chaincode.EventPartnershipRejected, chaincode.EventPartnershipEnded, chaincode.EventCollectorAdded,
+	chaincode.EventCollectorUpdated, chaincode.EventProcessorAdded, chaincode.EventProcessorUpdated,
+	chaincode.EventTransporterAdded, chaincode.EventTransporterUpdated, chaincode.EventCommodityHarvested,
+	chaincode.EventCommodityCollected, chaincode.EventCommodityInTransport, chaincode.EventCommodityDelivered,
+	chaincode.EventCommodityHeld, chaincode.EventCommodityReleased, chaincode.EventCommodityRejected,
+	chaincode.EventCommodityProcessed, chaincode.EventBatchRecalled, chaincode.EventExtractionRateSet,
+	chaincode.EventMSPRolesSet, chaincode.EventPersonalDataPurged, chaincode.EventTraceabilityMigrated,
+	chaincode.EventEntityKeysMigrated, chaincode.EventPersonalDataMigrated,
+}
+
+// readModelRows are queries on the read model of testdata/every-event.yaml
+// and the values they select
+var readModelRows = []struct {
+	name  string
+	query string
+	want  string
+}{
+	{"farm owner", `SELECT owner FROM farms WHERE id = 'FARM_001'`, "FRM_002"},
+	{"farm update", `SELECT capacity FROM farms WHERE id = 'FARM_001'`, "30"},
+	{"previous owner's farms", `SELECT farms FROM farmers WHERE id = 'FRM_001'`, `[]`},
+	{"new owner's farms", `SELECT farms FROM farmers WHERE id = 'FRM_002'`, `["FARM_001"]`},
+	{"collector update", `SELECT capacity FROM collectors WHERE id = 'COL_001'`, "300"},
+	{"collector partners", `SELECT partners FROM collectors WHERE id = 'COL_001'`, `[]`},
+	{"rejected partnership", `SELECT status FROM partnerships WHERE id = 'PRT_001'`, "rejected"},
+	{"ended partnership", `SELECT status FROM partnerships WHERE id = 'PRT_002'`, "ended"},
+	{"partnership ended by transfer", `SELECT status FROM partnerships WHERE id = 'PRT_003'`, "ended"},
+	{"processor update", `SELECT capacity FROM processors WHERE id = 'PRC_001'`, "90"},
+	{"transporter update", `SELECT num_ship FROM transporters WHERE id = 'TRP_001'`, "4"},
+	{"collected by", `SELECT collector_id FROM commodities WHERE id = 'COM_001'`, "COL_001"},
+	{"transported by", `SELECT transporter_id FROM commodities WHERE id = 'COM_001'`, "TRP_001"},
+	{"processed into", `SELECT processed_into FROM commodities WHERE id = 'COM_001'`, "PCD_001"},
+	{"trace of processed commodity", `SELECT group_concat(status, ',') FROM (SELECT status FROM trace_events WHERE commodity_id = 'COM_001' ORDER BY timestamp)`,
+		"harvested,on hold,harvested,collected,in transport,delivered,processed,recalled"},
+	{"rejected commodity", `SELECT group_concat(status, ',') FROM (SELECT status FROM trace_events WHERE commodity_id = 'COM_002' ORDER BY timestamp)`,
+		"harvested,rejected"},
+	{"recalled commodity", `SELECT state || ' ' || recall_id FROM commodities WHERE id = 'COM_001'`, "recalled RCL_001"},
+	{"recalled batch", `SELECT status || ' ' || recall_id FROM batches WHERE id = 'PCD_001'`, "recalled RCL_001"},
+	{"recall", `SELECT origin_type || ' ' || origin_id || ' ' || commodities FROM recalls WHERE id = 'RCL_001'`, `farm FARM_001 ["COM_001"]`},
+	{"erasure", `SELECT entity_type || ' ' || entity_id FROM erasures`, "farmer FRM_001"},
+}
+
+func TestProjectEveryEvent(t *testing.T) {
+	events := recordEvents(t, "testdata/every-event.yaml")
+	model := openTestModel(t)
+	if applied := run(t, model, events); applied != len(events) {
+		t.Fatalf("expected %d events applied, got %d", len(events), applied)
+	}
+
+	for _, name := range eventNames {
+		var count int
+		err := model.DB().QueryRow(`SELECT COUNT(*) FROM events WHERE name = ?`, name).Scan(&count)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count == 0 {
+			t.Errorf("no %s event was projected", name)
+		}
+	}
+
+	for _, row := range readModelRows {
+		t.Run(row.name, func(t *testing.T) {
+			if got := queryString(t, model.DB(), row.query); got != row.want {
+				t.Errorf("expected %s, got %s", row.want, got)
+			}
+		})
+	}
+}
+
+func TestProjectLegacyRecall(t *testing.T) {
+	// Recalls were once announced with the bare Recall as payload, without
+	// the event envelope
+	events := recordEvents(t, "testdata/every-event.yaml")
+	var recall chaincode.Recall
+	for i, event := range events {
+		if event.EventName != chaincode.EventBatchRecalled {
+			continue
+		}
+		var envelope chaincode.Event
+		if err := json.Unmarshal(event.Payload, &envelope); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(envelope.Payload, &recall); err != nil {
+			t.Fatal(err)
+		}
+		legacy := *event
+		legacy.Payload = envelope.Payload
+		events[i] = &legacy
+	}
+	if recall.ID == "" {
+		t.Fatal("the scenario recalls nothing")
+	}
+
+	model := openTestModel(t)
+	run(t, model, events)
+
+	logged := queryString(t, model.DB(), `SELECT name || ' ' || schema_version || ' ' || msp_id || ' ' || timestamp FROM events WHERE transaction_id = ?`, recall.TxID)
+	if want := chaincode.EventBatchRecalled + " 0 " + recall.MSPID + " " + recall.Timestamp; logged != want {
+		t.Errorf("expected the recall logged as %q, got %q", want, logged)
+	}
+	for _, row := range readModelRows {
+		t.Run(row.name, func(t *testing.T) {
+			if got := queryString(t, model.DB(), row.query); got != row.want {
+				t.Errorf("expected %s, got %s", row.want, got)
+			}
+		})
+	}
+}
+
+func TestProjectRejectsMismatchedEnvelope(t *testing.T) {
+	events := recordEvents(t, "testdata/every-event.yaml")
+	mislabelled := *events[0]
+	mislabelled.EventName = chaincode.EventFarmUpdated
+
+	err := openTestModel(t).Apply(context.Background(), &mislabelled)
+	if err == nil {
+		t.Error("expected an error for an envelope of another event")
+	}
+}
@@ -0,0 +1,290 @@
+package listener
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	// Registers the pure Go "sqlite" database/sql driver
+	_ "modernc.org/sqlite"
+)
+
+// schema creates the read model tables. Entities hold their latest projected
+// version; trace_events holds every commodity step; events logs every event
+// received, including those not projected into a table.
+const schema = `
+CREATE TABLE IF NOT EXISTS checkpoint (
+	id             INTEGER PRIMARY KEY CHECK (id = 1),
+	block_number   INTEGER NOT NULL,
+	transaction_id TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS events (
+	transaction_id TEXT PRIMARY KEY,
+	block_number   INTEGER NOT NULL,
+	name           TEXT NOT NULL,
+	schema_version INTEGER NOT NULL,
+	timestamp      TEXT NOT NULL,
+	msp_id         TEXT NOT NULL,
+	payload        TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS farmers (
+	id         TEXT PRIMARY KEY,
+	farms      TEXT NOT NULL,
+	msp_id     TEXT NOT NULL,
+	updated_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS farms (
+	id             TEXT PRIMARY KEY,
+	owner          TEXT NOT NULL,
+	planted_year   INTEGER NOT NULL,
+	seed_varieties TEXT NOT NULL,
+	area           REAL NOT NULL,
+	capacity       REAL NOT NULL,
+	legality       TEXT NOT NULL,
+	certificate    TEXT NOT NULL,
+	updated_at     TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS collectors (
+	id         TEXT PRIMARY KEY,
+	nib        TEXT NOT NULL,
+	capacity   REAL NOT NULL,
+	partners   TEXT NOT NULL,
+	msp_id     TEXT NOT NULL,
+	updated_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS partnerships (
+	id           TEXT PRIMARY KEY,
+	collector_id TEXT NOT NULL,
+	partner_type TEXT NOT NULL,
+	partner_id   TEXT NOT NULL,
+	start_date   TEXT NOT NULL,
+	end_date     TEXT NOT NULL,
+	status       TEXT NOT NULL,
+	updated_at   TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS partnerships_collector ON partnerships (collector_id);
+CREATE INDEX IF NOT EXISTS partnerships_partner ON partnerships (partner_type, partner_id);
+
+CREATE TABLE IF NOT EXISTS processors (
+	id         TEXT PRIMARY KEY,
+	nib        TEXT NOT NULL,
+	capacity   REAL NOT NULL,
+	msp_id     TEXT NOT NULL,
+	updated_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS transporters (
+	id         TEXT PRIMARY KEY,
+	num_ship   INTEGER NOT NULL,
+	msp_id     TEXT NOT NULL,
+	updated_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS commodities (
+	id             TEXT PRIMARY KEY,
+	name           TEXT NOT NULL,
+	quantity       REAL NOT NULL,
+	date_harvested TEXT NOT NULL,
+	farm_id        TEXT NOT NULL,
+	farmer_id      TEXT NOT NULL,
+	collector_id   TEXT NOT NULL DEFAULT '',
+	transporter_id TEXT NOT NULL DEFAULT '',
+	state          TEXT NOT NULL,
+	held_from      TEXT NOT NULL DEFAULT '',
+	processed_into TEXT NOT NULL DEFAULT '',
+	recall_id      TEXT NOT NULL DEFAULT '',
+	updated_at     TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS commodities_farm ON commodities (farm_id);
+CREATE INDEX IF NOT EXISTS commodities_state ON commodities (state);
+
+CREATE TABLE IF NOT EXISTS trace_events (
+	transaction_id TEXT NOT NULL,
+	commodity_id   TEXT NOT NULL,
+	status         TEXT NOT NULL,
+	location       TEXT NOT NULL,
+	timestamp      TEXT NOT NULL,
+	msp_id         TEXT NOT NULL,
+	PRIMARY KEY (transaction_id, commodity_id)
+);
+CREATE INDEX IF NOT EXISTS trace_events_commodity ON trace_events (commodity_id, timestamp);
+
+CREATE TABLE IF NOT EXISTS batches (
+	id              TEXT PRIMARY KEY,
+	processor       TEXT NOT NULL,
+	quantity        REAL NOT NULL,
+	input_quantity  REAL NOT NULL,
+	extraction_rate REAL NOT NULL,
+	materials       TEXT NOT NULL,
+	batch_number    TEXT NOT NULL,
+	quality         TEXT NOT NULL,
+	status          TEXT NOT NULL,
+	recall_id       TEXT NOT NULL DEFAULT '',
+	processed_at    TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS recalls (
+	id          TEXT PRIMARY KEY,
+	origin_type TEXT NOT NULL,
+	origin_id   TEXT NOT NULL,
+	reason      TEXT NOT NULL,
+	commodities TEXT NOT NULL,
+	batches     TEXT NOT NULL,
+	timestamp   TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS erasures (
+	id          TEXT PRIMARY KEY,
+	entity_type TEXT NOT NULL,
+	entity_id   TEXT NOT NULL,
+	timestamp   TEXT NOT NULL
+);
+`
+
+// Checkpoint is the last event applied to the read model
+type Checkpoint struct {
+	BlockNumber   uint64
+	TransactionID string
+}
+
+// ReadModel is the SQLite database the events are projected into
+type ReadModel struct {
+	db *sql.DB
+}
+
+// OpenReadModel opens or creates the read model database at path. Use
+// ":memory:" for a throwaway database.
+func OpenReadModel(path string) (*ReadModel, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	// A single connection keeps an in-memory database alive and serializes writes
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(schema)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create read model schema: %v", err)
+	}
+
+	err = upgradeSchema(db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to upgrade read model schema: %v", err)
+	}
+
+	return &ReadModel{db: db}, nil
+}
+
+// addedColumns are columns added to a table after its first version, with
+// their definitions. CREATE TABLE IF NOT EXISTS leaves an existing table as
+// it is, so they are added to it here.
+var addedColumns = []struct{ table, column, definition string }{
+	{"commodities", "collector_id", "TEXT NOT NULL DEFAULT ''"},
+	{"commodities", "transporter_id", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// droppedColumns are columns of earlier schemas that are no longer projected.
+// Farm events stopped carrying the farm's address and coordinate, so the
+// values earlier versions stored are removed.
+var droppedColumns = []struct{ table, column string }{
+	{"farms", "address"},
+	{"farms", "coordinate"},
+}
+
+// upgradeSchema brings a database created by an earlier version to the
+// current schema
+func upgradeSchema(db *sql.DB) error {
+	for _, added := range addedColumns {
+		exists, err := hasColumn(db, added.table, added.column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", added.table, added.column, added.definition))
+		if err != nil {
+			return err
+		}
+	}
+	for _, dropped := range droppedColumns {
+		exists, err := hasColumn(db, dropped.table, dropped.column)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", dropped.table, dropped.column))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasColumn reports whether a table has a column
+func hasColumn(db *sql.DB, table string, column string) (bool, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// DB returns the underlying database for reporting queries
+func (m *ReadModel) DB() *sql.DB {
+	return m.db
+}
+
+// Close closes the database
+func (m *ReadModel) Close() error {
+	return m.db.Close()
+}
+
+// Checkpoint returns the last event applied, or nil if none has been
+func (m *ReadModel) Checkpoint(ctx context.Context) (*Checkpoint, error) {
+	var checkpoint Checkpoint
+	err := m.db.QueryRowContext(ctx, "SELECT block_number, transaction_id FROM checkpoint WHERE id = 1").
+		Scan(&checkpoint.BlockNumber, &checkpoint.TransactionID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %v", err)
+	}
+
+	return &checkpoint, nil
+}
+
+// Apply projects an event and moves the checkpoint past it in one database
+// transaction, so every event is applied exactly once
+func (m *ReadModel) Apply(ctx context.Context, event *Event) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = project(ctx, tx, event)
+	if err != nil {
+		return fmt.Errorf("failed to project %s event of transaction %s: %v", event.EventName, event.TransactionID, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO checkpoint (id, block_number, transaction_id) VALUES (1, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET block_number = excluded.block_number, transaction_id = excluded.transaction_id`,
+		event.BlockNumber, event.TransactionID)
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoint: %v", err)
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,225 @@
+// Package listener projects the palmoil chaincode events into a SQLite read
+// model for dashboards and reporting.
+package listener
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// Event is a chaincode event together with the block and transaction that
+// emitted it
+type Event struct {
+	BlockNumber   uint64          `json:"blockNumber"`
+	TransactionID string          `json:"transactionId"`
+	ChaincodeName string          `json:"chaincodeName"`
+	EventName     string          `json:"eventName"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// EventSource delivers chaincode events in ledger order, starting at a block.
+// The channel is closed when the source is exhausted, when the context is done
+// or when the source fails. Once the channel is closed, Err returns the
+// failure, or nil if the source was exhausted or the context is done.
+type EventSource interface {
+	Events(ctx context.Context, startBlock uint64) (<-chan *Event, error)
+	Err() error
+}
+
+// GatewaySource reads the events of a chaincode from a peer through the
+// Fabric Gateway. Its channel stays open, delivering new events as they are
+// committed, until the context is done or the connection fails.
+type GatewaySource struct {
+	network   *client.Network
+	chaincode string
+	err       error
+}
+
+// NewGatewaySource returns a source for the events of a chaincode on a channel
+func NewGatewaySource(network *client.Network, chaincode string) *GatewaySource {
+	return &GatewaySource{network: network, chaincode: chaincode}
+}
+
+// Events replays the chaincode events from startBlock and then follows new blocks
+func (s *GatewaySource) Events(ctx context.Context, startBlock uint64) (<-chan *Event, error) {
+	gatewayEvents, err := s.network.ChaincodeEvents(ctx, s.chaincode, client.WithStartBlock(startBlock))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chaincode events: %v", err)
+	}
+
+	events := make(chan *Event)
+	go func() {
+		defer close(events)
+		defer func() {
+			// The gateway closes its channel only when the context is done or
+			// the connection fails
+			if ctx.Err() == nil {
+				s.err = errors.New("the chaincode event stream closed; the connection to the peer failed")
+			}
+		}()
+		for gatewayEvent := range gatewayEvents {
+			event := &Event{
+				BlockNumber:   gatewayEvent.BlockNumber,
+				TransactionID: gatewayEvent.TransactionID,
+				ChaincodeName: gatewayEvent.ChaincodeName,
+				EventName:     gatewayEvent.EventName,
+				Payload:       gatewayEvent.Payload,
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// Err returns the failure that closed the channel of the last Events call
+func (s *GatewaySource) Err() error {
+	return s.err
+}
+
+// ReplaySource delivers a fixed list of events, such as events recorded to a
+// file, so the listener can run without a Fabric network
+type ReplaySource struct {
+	events []*Event
+}
+
+// NewReplaySource returns a source delivering the given events, which must be
+// in ledger order
+func NewReplaySource(events []*Event) *ReplaySource {
+	return &ReplaySource{events: events}
+}
+
+// ReadReplayFile reads a replay source from a file holding one JSON event per line
+func ReadReplayFile(path string) (*ReplaySource, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	events, err := ReadEvents(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	return NewReplaySource(events), nil
+}
+
+// ReadEvents decodes events written one JSON object per line
+func ReadEvents(r io.Reader) ([]*Event, error) {
+	var events []*Event
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var event Event
+		err := json.Unmarshal(scanner.Bytes(), &event)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		events = append(events, &event)
+	}
+
+	return events, scanner.Err()
+}
+
+// WriteEvent appends an event to w as one JSON line, in the format read by
+// ReadEvents
+func WriteEvent(w io.Writer, event *Event) error {
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(eventJSON, '\n'))
+	return err
+}
+
+// Events delivers the events from startBlock onwards and then closes the channel
+func (s *ReplaySource) Events(ctx context.Context, startBlock uint64) (<-chan *Event, error) {
+	events := make(chan *Event)
+	go func() {
+		defer close(events)
+		for _, event := range s.events {
+			if event.BlockNumber < startBlock {
+				continue
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// Err returns nil, since replaying a list of events cannot fail
+func (s *ReplaySource) Err() error {
+	return nil
+}
+
+// RecordingSource passes the events of another source through while writing
+// each one to w, producing a file ReadReplayFile can replay
+type RecordingSource struct {
+	source EventSource
+	w      io.Writer
+	err    error
+}
+
+// NewRecordingSource returns a source recording the events of source to w
+func NewRecordingSource(source EventSource, w io.Writer) *RecordingSource {
+	return &RecordingSource{source: source, w: w}
+}
+
+// Events delivers the events of the wrapped source, recording each one first.
+// The channel is closed early if an event cannot be recorded.
+func (s *RecordingSource) Events(ctx context.Context, startBlock uint64) (<-chan *Event, error) {
+	sourceEvents, err := s.source.Events(ctx, startBlock)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan *Event)
+	go func() {
+		defer close(events)
+		for event := range sourceEvents {
+			err := WriteEvent(s.w, event)
+			if err != nil {
+				s.err = fmt.Errorf("failed to record the event of transaction %s: %v", event.TransactionID, err)
+				return
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// Err returns the failure to record an event, or else the failure of the
+// wrapped source
+func (s *RecordingSource) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.source.Err()
+}
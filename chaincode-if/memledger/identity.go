@@ -0,0 +1,145 @@
+package memledger
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/msp"
+)
+
+// attributeOID is the certificate extension Fabric CA stores attributes in
+var attributeOID = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 1}
+
+// Identity is an enrolled client of an MSP
+type Identity struct {
+	MSPID       string
+	Name        string
+	Attributes  map[string]string
+	Certificate *x509.Certificate
+	creator     []byte
+}
+
+// certificateAuthority issues the certificates of one MSP
+type certificateAuthority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+var (
+	authoritiesMu sync.Mutex
+	authorities   = make(map[string]*certificateAuthority)
+	serialNumber  int64
+)
+
+// NewIdentity enrolls an identity with a common name and certificate
+// attributes, such as a role, in an MSP. Identities of the same MSP share a
+// certificate authority for the life of the process.
+func NewIdentity(mspID string, name string, attributes map[string]string) (*Identity, error) {
+	ca, err := authority(mspID)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: nextSerialNumber(),
+		Subject:      pkix.Name{CommonName: name, OrganizationalUnit: []string{"client"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if len(attributes) > 0 {
+		attrsJSON, err := json.Marshal(map[string]interface{}{"attrs": attributes})
+		if err != nil {
+			return nil, err
+		}
+		template.ExtraExtensions = []pkix.Extension{{Id: attributeOID, Value: attrsJSON}}
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue certificate for %s: %v", name, err)
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return nil, err
+	}
+
+	creator, err := proto.Marshal(&msp.SerializedIdentity{
+		Mspid:   mspID,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Identity{MSPID: mspID, Name: name, Attributes: attributes, Certificate: cert, creator: creator}, nil
+}
+
+// MustNewIdentity is like NewIdentity but panics on error, for tests and fixtures
+func MustNewIdentity(mspID string, name string, attributes map[string]string) *Identity {
+	id, err := NewIdentity(mspID, name, attributes)
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+// authority returns the certificate authority of an MSP, creating it on first use
+func authority(mspID string) (*certificateAuthority, error) {
+	authoritiesMu.Lock()
+	defer authoritiesMu.Unlock()
+
+	if ca, ok := authorities[mspID]; ok {
+		return ca, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serialNumber++
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serialNumber),
+		Subject:               pkix.Name{CommonName: "ca." + mspID, Organization: []string{mspID}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(10 * 365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate authority for %s: %v", mspID, err)
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return nil, err
+	}
+
+	ca := &certificateAuthority{cert: cert, key: key}
+	authorities[mspID] = ca
+	return ca, nil
+}
+
+func nextSerialNumber() *big.Int {
+	authoritiesMu.Lock()
+	defer authoritiesMu.Unlock()
+	serialNumber++
+	return big.NewInt(serialNumber)
+}
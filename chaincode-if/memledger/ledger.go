@@ -0,0 +1,306 @@
+// Package memledger is an in-memory Fabric ledger for running chaincode
+// without a network. It implements the shim stub and contractapi transaction
+// context interfaces with the semantics of a peer: a transaction reads the
+// committed state only, its writes are applied when it commits, and every
+// committed transaction is a block of its own with history and events.
+//
+// Rich queries support the subset of CouchDB Mango selectors used by the
+// palmoil chaincode: field equality and the $eq, $ne, $gt, $gte, $lt, $lte
+// and $in operators.
+//
+// Transactions must not run concurrently: the endorsing peer's MSP ID is
+// published through the CORE_PEER_LOCALMSPID environment variable, like on a
+// real peer, which is process-wide.
+package memledger
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// peerMSPIDEnv is the environment variable the shim reads the peer's MSP ID from
+const peerMSPIDEnv = "CORE_PEER_LOCALMSPID"
+
+// DefaultChannel is the channel ID reported by stubs
+const DefaultChannel = "mychannel"
+
+// Event is a chaincode event emitted by a committed transaction
+type Event struct {
+	BlockNumber   uint64
+	TransactionID string
+	EventName     string
+	Payload       []byte
+}
+
+// KV is a key and its committed value
+type KV struct {
+	Key   string
+	Value []byte
+}
+
+// Ledger is the committed state of a channel
+type Ledger struct {
+	mu          sync.Mutex
+	state       map[string][]byte
+	private     map[string]map[string][]byte
+	history     map[string][]*queryresult.KeyModification
+	events      []Event
+	blockNumber uint64
+	txCount     uint64
+	clock       time.Time
+	tick        time.Duration
+}
+
+// New returns an empty ledger whose clock starts at 2024-01-01T00:00:00Z and
+// advances one second per transaction
+func New() *Ledger {
+	return &Ledger{
+		state:   make(map[string][]byte),
+		private: make(map[string]map[string][]byte),
+		history: make(map[string][]*queryresult.KeyModification),
+		clock:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		tick:    time.Second,
+	}
+}
+
+// SetClock sets the timestamp of the next transaction and the interval
+// between transactions
+func (l *Ledger) SetClock(next time.Time, tick time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.clock = next
+	l.tick = tick
+}
+
+// TxOption configures a transaction
+type TxOption func(*Stub)
+
+// WithTransient passes transient data to the transaction
+func WithTransient(transient map[string][]byte) TxOption {
+	return func(s *Stub) {
+		s.transient = transient
+	}
+}
+
+// WithPeerMSPID sets the MSP of the endorsing peer. By default the peer
+// belongs to the MSP of the submitting identity.
+func WithPeerMSPID(mspID string) TxOption {
+	return func(s *Stub) {
+		s.peerMSPID = mspID
+	}
+}
+
+// WithArgs sets the function name and arguments of the transaction
+func WithArgs(args ...string) TxOption {
+	return func(s *Stub) {
+		s.args = make([][]byte, len(args))
+		for i, arg := range args {
+			s.args[i] = []byte(arg)
+		}
+	}
+}
+
+// NewStub starts a transaction submitted by an identity. Its writes are
+// applied by Commit.
+func (l *Ledger) NewStub(id *Identity, opts ...TxOption) *Stub {
+	l.mu.Lock()
+	l.txCount++
+	txID := fmt.Sprintf("tx%06d", l.txCount)
+	timestamp := l.clock
+	l.clock = l.clock.Add(l.tick)
+	l.mu.Unlock()
+
+	stub := &Stub{
+		ledger:       l,
+		txID:         txID,
+		timestamp:    timestamppb.New(timestamp),
+		creator:      id.creator,
+		peerMSPID:    id.MSPID,
+		transient:    map[string][]byte{},
+		writes:       make(map[string]*write),
+		privateWrite: make(map[string]map[string]*write),
+	}
+	for _, opt := range opts {
+		opt(stub)
+	}
+
+	return stub
+}
+
+// NewContext returns a transaction context for a stub, with the client
+// identity taken from the stub's creator
+func NewContext(stub *Stub) (*contractapi.TransactionContext, error) {
+	clientIdentity, err := cid.New(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := new(contractapi.TransactionContext)
+	ctx.SetStub(stub)
+	ctx.SetClientIdentity(clientIdentity)
+
+	return ctx, nil
+}
+
+// Transact runs fn as a transaction of an identity and commits it if fn
+// returns no error
+func (l *Ledger) Transact(id *Identity, fn func(ctx contractapi.TransactionContextInterface) error, opts ...TxOption) error {
+	stub := l.NewStub(id, opts...)
+	ctx, err := NewContext(stub)
+	if err != nil {
+		return err
+	}
+
+	stub.publishPeer()
+	err = fn(ctx)
+	if err != nil {
+		return err
+	}
+
+	stub.Commit()
+	return nil
+}
+
+// Evaluate runs fn as a transaction of an identity without committing it, like
+// a query evaluated on a single peer
+func (l *Ledger) Evaluate(id *Identity, fn func(ctx contractapi.TransactionContextInterface) error, opts ...TxOption) error {
+	stub := l.NewStub(id, opts...)
+	ctx, err := NewContext(stub)
+	if err != nil {
+		return err
+	}
+
+	stub.publishPeer()
+	return fn(ctx)
+}
+
+// Invoke runs a chaincode transaction with string arguments, the first being
+// the function name, and commits it if the chaincode returns a success status
+func (l *Ledger) Invoke(cc shim.Chaincode, id *Identity, args []string, opts ...TxOption) *peer.Response {
+	stub := l.NewStub(id, append(opts, WithArgs(args...))...)
+
+	stub.publishPeer()
+	response := cc.Invoke(stub)
+	if response.Status < shim.ERRORTHRESHOLD {
+		stub.Commit()
+	}
+
+	return &response
+}
+
+// Query runs a chaincode transaction like Invoke without committing it, like
+// a transaction evaluated on a single peer
+func (l *Ledger) Query(cc shim.Chaincode, id *Identity, args []string, opts ...TxOption) *peer.Response {
+	stub := l.NewStub(id, append(opts, WithArgs(args...))...)
+
+	stub.publishPeer()
+	response := cc.Invoke(stub)
+
+	return &response
+}
+
+// publishPeer makes the stub's peer MSP visible to the shim
+func (s *Stub) publishPeer() {
+	os.Setenv(peerMSPIDEnv, s.peerMSPID)
+}
+
+// Commit applies the writes of a transaction to the ledger as a new block
+func (s *Stub) Commit() {
+	l := s.ledger
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.blockNumber++
+	for key, w := range s.writes {
+		if w.delete {
+			delete(l.state, key)
+		} else {
+			l.state[key] = w.value
+		}
+		l.history[key] = append(l.history[key], &queryresult.KeyModification{
+			TxId:      s.txID,
+			Value:     w.value,
+			Timestamp: s.timestamp,
+			IsDelete:  w.delete,
+		})
+	}
+
+	for collection, writes := range s.privateWrite {
+		data := l.private[collection]
+		if data == nil {
+			data = make(map[string][]byte)
+			l.private[collection] = data
+		}
+		for key, w := range writes {
+			if w.delete {
+				delete(data, key)
+			} else {
+				data[key] = w.value
+			}
+		}
+	}
+
+	if s.event != nil {
+		l.events = append(l.events, Event{
+			BlockNumber:   l.blockNumber,
+			TransactionID: s.txID,
+			EventName:     s.event.EventName,
+			Payload:       s.event.Payload,
+		})
+	}
+}
+
+// Events returns the events of every committed transaction, in order
+func (l *Ledger) Events() []Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Event(nil), l.events...)
+}
+
+// WorldState returns the committed world state sorted by key
+func (l *Ledger) WorldState() []KV {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return sortedKVs(l.state)
+}
+
+// PrivateData returns the committed contents of a private data collection
+// sorted by key
+func (l *Ledger) PrivateData(collection string) []KV {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return sortedKVs(l.private[collection])
+}
+
+// LastTxID returns the ID of the last transaction started, committed or not
+func (l *Ledger) LastTxID() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return fmt.Sprintf("tx%06d", l.txCount)
+}
+
+// BlockNumber returns the number of the last committed block
+func (l *Ledger) BlockNumber() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.blockNumber
+}
+
+func sortedKVs(data map[string][]byte) []KV {
+	kvs := make([]KV, 0, len(data))
+	for key, value := range data {
+		kvs = append(kvs, KV{Key: key, Value: value})
+	}
+	sort.Slice(kvs, func(i, j int) bool { return kvs[i].Key < kvs[j].Key })
+	return kvs
+}
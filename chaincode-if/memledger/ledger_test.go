@@ -0,0 +1,290 @@
+package memledger
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+var testUser = MustNewIdentity("Org1MSP", "user1", map[string]string{"role": "farmer"})
+
+// keys drains a state iterator into its keys
+func keys(t *testing.T, it shim.StateQueryIteratorInterface) []string {
+	t.Helper()
+	defer it.Close()
+	result := []string{}
+	for it.HasNext() {
+		kv, err := it.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		result = append(result, kv.Key)
+	}
+	return result
+}
+
+// put commits a transaction that writes every key with its value
+func put(t *testing.T, l *Ledger, kvs ...string) {
+	t.Helper()
+	err := l.Transact(testUser, func(ctx contractapi.TransactionContextInterface) error {
+		for i := 0; i < len(kvs); i += 2 {
+			if err := ctx.GetStub().PutState(kvs[i], []byte(kvs[i+1])); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTransact(t *testing.T) {
+	l := New()
+
+	err := l.Transact(testUser, func(ctx contractapi.TransactionContextInterface) error {
+		stub := ctx.GetStub()
+		stub.PutState("a", []byte("1"))
+		stub.SetEvent("first", []byte("1"))
+		stub.SetEvent("second", []byte("2"))
+
+		// Reads see the committed state only
+		value, _ := stub.GetState("a")
+		if value != nil {
+			t.Errorf("read an uncommitted write %q", value)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = l.Transact(testUser, func(ctx contractapi.TransactionContextInterface) error {
+		ctx.GetStub().PutState("b", []byte("2"))
+		return errors.New("endorsement failed")
+	})
+	if err == nil {
+		t.Fatal("expected the failing transaction to return its error")
+	}
+
+	err = l.Evaluate(testUser, func(ctx contractapi.TransactionContextInterface) error {
+		return ctx.GetStub().PutState("c", []byte("3"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if state := l.WorldState(); len(state) != 1 || state[0].Key != "a" {
+		t.Errorf("expected only the committed write, got %+v", state)
+	}
+	if l.BlockNumber() != 1 {
+		t.Errorf("expected one block, got %d", l.BlockNumber())
+	}
+	events := l.Events()
+	if len(events) != 1 || events[0].EventName != "second" || events[0].TransactionID != "tx000001" || events[0].BlockNumber != 1 {
+		t.Errorf("expected the last event of the first transaction, got %+v", events)
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	l := New()
+	err := l.Evaluate(testUser, func(ctx contractapi.TransactionContextInterface) error {
+		mspID, err := ctx.GetClientIdentity().GetMSPID()
+		if err != nil || mspID != "Org1MSP" {
+			t.Errorf("expected Org1MSP, got %q (%v)", mspID, err)
+		}
+		role, found, err := ctx.GetClientIdentity().GetAttributeValue("role")
+		if err != nil || !found || role != "farmer" {
+			t.Errorf("expected the farmer role, got %q (%v)", role, err)
+		}
+		peerMSPID, err := shim.GetMSPID()
+		if err != nil || peerMSPID != "Org2MSP" {
+			t.Errorf("expected the peer of Org2MSP, got %q (%v)", peerMSPID, err)
+		}
+		return nil
+	}, WithPeerMSPID("Org2MSP"))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompositeKeys(t *testing.T) {
+	l := New()
+	stub := l.NewStub(testUser)
+	farm1, _ := stub.CreateCompositeKey("farm", []string{"FARM_001"})
+	farm2, _ := stub.CreateCompositeKey("farm", []string{"FARM_002"})
+	farmer, _ := stub.CreateCompositeKey("farmer", []string{"FRM_001"})
+	put(t, l, farm2, "{}", farm1, "{}", farmer, "{}", "simple", "{}")
+
+	stub = l.NewStub(testUser)
+	it, err := stub.GetStateByPartialCompositeKey("farm", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := keys(t, it); !reflect.DeepEqual(got, []string{farm1, farm2}) {
+		t.Errorf("expected the farms in key order, got %q", got)
+	}
+
+	objectType, attributes, err := stub.SplitCompositeKey(farm1)
+	if err != nil || objectType != "farm" || !reflect.DeepEqual(attributes, []string{"FARM_001"}) {
+		t.Errorf("unexpected split %q %q (%v)", objectType, attributes, err)
+	}
+
+	// Range queries do not return composite keys
+	it, err = stub.GetStateByRange("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := keys(t, it); !reflect.DeepEqual(got, []string{"simple"}) {
+		t.Errorf("expected only the simple key, got %q", got)
+	}
+}
+
+func TestPagination(t *testing.T) {
+	l := New()
+	put(t, l, "k1", "{}", "k2", "{}", "k3", "{}", "k4", "{}", "k5", "{}")
+	stub := l.NewStub(testUser)
+
+	var pages [][]string
+	bookmark := ""
+	for {
+		it, metadata, err := stub.GetStateByRangeWithPagination("", "", 2, bookmark)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, keys(t, it))
+		bookmark = metadata.Bookmark
+		if bookmark == "" {
+			break
+		}
+	}
+
+	want := [][]string{{"k1", "k2"}, {"k3", "k4"}, {"k5"}}
+	if !reflect.DeepEqual(pages, want) {
+		t.Errorf("expected pages %q, got %q", want, pages)
+	}
+
+	_, _, err := stub.GetStateByRangeWithPagination("", "", 0, "")
+	if err == nil || !strings.Contains(err.Error(), "page size must be positive") {
+		t.Errorf("expected a page size error, got %v", err)
+	}
+}
+
+func TestQueryResult(t *testing.T) {
+	l := New()
+	put(t, l,
+		"c1", `{"docType":"commodity","quantity":50,"state":"HARVESTED","farm":{"id":"FARM_001"}}`,
+		"c2", `{"docType":"commodity","quantity":100,"state":"COLLECTED","farm":{"id":"FARM_002"}}`,
+		"c3", `{"docType":"commodity","quantity":150,"state":"HARVESTED","farm":{"id":"FARM_001"}}`,
+		"f1", `{"docType":"farm","quantity":100}`,
+		"raw", `not json`,
+	)
+	stub := l.NewStub(testUser)
+
+	tests := []struct {
+		name     string
+		query    string
+		wantKeys []string
+		wantErr  string
+	}{
+		{name: "equality", query: `{"selector":{"docType":"commodity","state":"HARVESTED"}}`, wantKeys: []string{"c1", "c3"}},
+		{name: "nested field", query: `{"selector":{"farm.id":"FARM_002"}}`, wantKeys: []string{"c2"}},
+		{name: "$ne", query: `{"selector":{"docType":"commodity","state":{"$ne":"HARVESTED"}}}`, wantKeys: []string{"c2"}},
+		{name: "$in", query: `{"selector":{"state":{"$in":["COLLECTED","HARVESTED"]}}}`, wantKeys: []string{"c1", "c2", "c3"}},
+		{name: "range", query: `{"selector":{"quantity":{"$gt":50,"$lte":150}}}`, wantKeys: []string{"c2", "c3", "f1"}},
+		{name: "no match", query: `{"selector":{"docType":"recall"}}`, wantKeys: []string{}},
+		{name: "sort is ignored", query: `{"selector":{"docType":"farm"},"sort":[{"quantity":"desc"}]}`, wantKeys: []string{"f1"}},
+		{name: "unsupported operator", query: `{"selector":{"state":{"$regex":"^H"}}}`, wantErr: "unsupported operator $regex"},
+		{name: "no selector", query: `{}`, wantErr: "no selector"},
+		{name: "invalid JSON", query: `{`, wantErr: "invalid query"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			it, err := stub.GetQueryResult(tt.query)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := keys(t, it); !reflect.DeepEqual(got, tt.wantKeys) {
+				t.Errorf("expected %q, got %q", tt.wantKeys, got)
+			}
+		})
+	}
+}
+
+func TestHistory(t *testing.T) {
+	l := New()
+	put(t, l, "a", "1")
+	put(t, l, "a", "2")
+	err := l.Transact(testUser, func(ctx contractapi.TransactionContextInterface) error {
+		return ctx.GetStub().DelState("a")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	it, err := l.NewStub(testUser).GetHistoryForKey("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+
+	var values []string
+	var deleted []bool
+	for it.HasNext() {
+		modification, err := it.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		values = append(values, string(modification.Value))
+		deleted = append(deleted, modification.IsDelete)
+	}
+	if !reflect.DeepEqual(values, []string{"1", "2", ""}) || !reflect.DeepEqual(deleted, []bool{false, false, true}) {
+		t.Errorf("unexpected history %q %v", values, deleted)
+	}
+}
+
+func TestPrivateData(t *testing.T) {
+	l := New()
+	err := l.Transact(testUser, func(ctx contractapi.TransactionContextInterface) error {
+		transient, _ := ctx.GetStub().GetTransient()
+		return ctx.GetStub().PutPrivateData("Org1MSPPersonalData", "FRM_001", transient["nik"])
+	}, WithTransient(map[string][]byte{"nik": []byte("1471010101900001")}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(l.WorldState()) != 0 {
+		t.Errorf("private data leaked into the world state: %+v", l.WorldState())
+	}
+	collection := l.PrivateData("Org1MSPPersonalData")
+	if len(collection) != 1 || string(collection[0].Value) != "1471010101900001" {
+		t.Fatalf("unexpected collection %+v", collection)
+	}
+
+	stub := l.NewStub(testUser)
+	hash, _ := stub.GetPrivateDataHash("Org1MSPPersonalData", "FRM_001")
+	if len(hash) != 32 {
+		t.Errorf("expected a SHA-256 hash, got %x", hash)
+	}
+
+	err = l.Transact(testUser, func(ctx contractapi.TransactionContextInterface) error {
+		return ctx.GetStub().PurgePrivateData("Org1MSPPersonalData", "FRM_001")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.PrivateData("Org1MSPPersonalData")) != 0 {
+		t.Error("the private data was not purged")
+	}
+}
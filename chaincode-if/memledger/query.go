@@ -0,0 +1,154 @@
+package memledger
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// mangoQuery is the part of a CouchDB query the ledger evaluates. use_index,
+// sort and fields are accepted and ignored; results are sorted by key.
+type mangoQuery struct {
+	Selector map[string]interface{} `json:"selector"`
+}
+
+// queryKVs returns the entries of data, sorted by key, whose JSON value
+// matches the selector of a Mango query
+func (s *Stub) queryKVs(data map[string][]byte, query string) ([]KV, error) {
+	var q mangoQuery
+	err := json.Unmarshal([]byte(query), &q)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query: %v", err)
+	}
+	if q.Selector == nil {
+		return nil, fmt.Errorf("invalid query: no selector")
+	}
+
+	s.ledger.mu.Lock()
+	kvs := sortedKVs(data)
+	s.ledger.mu.Unlock()
+
+	var matches []KV
+	for _, kv := range kvs {
+		var doc map[string]interface{}
+		if json.Unmarshal(kv.Value, &doc) != nil {
+			continue
+		}
+
+		ok, err := matchSelector(doc, q.Selector)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matches = append(matches, kv)
+		}
+	}
+
+	return matches, nil
+}
+
+// matchSelector reports whether a document matches every field condition of
+// a selector. Dotted field names select nested fields.
+func matchSelector(doc map[string]interface{}, selector map[string]interface{}) (bool, error) {
+	for field, condition := range selector {
+		value, present := lookupField(doc, field)
+
+		operators, isOperators := condition.(map[string]interface{})
+		if !isOperators {
+			operators = map[string]interface{}{"$eq": condition}
+		}
+
+		for operator, operand := range operators {
+			ok, err := matchOperator(operator, value, present, operand)
+			if err != nil {
+				return false, err
+			}
+			if !ok {
+				return false, nil
+			}
+		}
+	}
+
+	return true, nil
+}
+
+func lookupField(doc map[string]interface{}, field string) (interface{}, bool) {
+	var current interface{} = doc
+	for _, name := range strings.Split(field, ".") {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = object[name]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func matchOperator(operator string, value interface{}, present bool, operand interface{}) (bool, error) {
+	switch operator {
+	case "$eq":
+		return present && reflect.DeepEqual(value, operand), nil
+	case "$ne":
+		return present && !reflect.DeepEqual(value, operand), nil
+	case "$in":
+		candidates, ok := operand.([]interface{})
+		if !ok {
+			return false, fmt.Errorf("invalid query: $in needs an array")
+		}
+		for _, candidate := range candidates {
+			if present && reflect.DeepEqual(value, candidate) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "$gt", "$gte", "$lt", "$lte":
+		if !present {
+			return false, nil
+		}
+		cmp, ok := compare(value, operand)
+		if !ok {
+			return false, nil
+		}
+		switch operator {
+		case "$gt":
+			return cmp > 0, nil
+		case "$gte":
+			return cmp >= 0, nil
+		case "$lt":
+			return cmp < 0, nil
+		default:
+			return cmp <= 0, nil
+		}
+	}
+
+	return false, fmt.Errorf("invalid query: unsupported operator %s", operator)
+}
+
+// compare orders two strings or two numbers
+func compare(a, b interface{}) (int, bool) {
+	switch a := a.(type) {
+	case string:
+		b, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(a, b), true
+	case float64:
+		b, ok := b.(float64)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case a < b:
+			return -1, true
+		case a > b:
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
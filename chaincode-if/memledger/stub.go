@@ -0,0 +1,453 @@
+package memledger
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// compositeKeyNamespace prefixes every composite key
+const compositeKeyNamespace = "\x00"
+
+// maxUnicodeRune ends the key range of a partial composite key
+const maxUnicodeRune = utf8.MaxRune
+
+// errNotSupported is returned by the stub functions the ledger does not model
+var errNotSupported = errors.New("not supported by the in-memory ledger")
+
+// write is a buffered write of a transaction
+type write struct {
+	value  []byte
+	delete bool
+}
+
+// Stub is one transaction against a Ledger. It implements
+// shim.ChaincodeStubInterface.
+type Stub struct {
+	ledger       *Ledger
+	txID         string
+	timestamp    *timestamp.Timestamp
+	creator      []byte
+	peerMSPID    string
+	transient    map[string][]byte
+	args         [][]byte
+	writes       map[string]*write
+	privateWrite map[string]map[string]*write
+	event        *peer.ChaincodeEvent
+}
+
+var _ shim.ChaincodeStubInterface = (*Stub)(nil)
+
+// GetArgs returns the function name and arguments of the transaction
+func (s *Stub) GetArgs() [][]byte {
+	return s.args
+}
+
+// GetStringArgs returns the function name and arguments as strings
+func (s *Stub) GetStringArgs() []string {
+	args := make([]string, len(s.args))
+	for i, arg := range s.args {
+		args[i] = string(arg)
+	}
+	return args
+}
+
+// GetFunctionAndParameters splits the arguments into function name and parameters
+func (s *Stub) GetFunctionAndParameters() (string, []string) {
+	args := s.GetStringArgs()
+	if len(args) == 0 {
+		return "", []string{}
+	}
+	return args[0], args[1:]
+}
+
+// GetArgsSlice returns the arguments concatenated
+func (s *Stub) GetArgsSlice() ([]byte, error) {
+	var slice []byte
+	for _, arg := range s.args {
+		slice = append(slice, arg...)
+	}
+	return slice, nil
+}
+
+// GetTxID returns the transaction ID
+func (s *Stub) GetTxID() string {
+	return s.txID
+}
+
+// GetChannelID returns DefaultChannel
+func (s *Stub) GetChannelID() string {
+	return DefaultChannel
+}
+
+// InvokeChaincode is not supported
+func (s *Stub) InvokeChaincode(chaincodeName string, args [][]byte, channel string) peer.Response {
+	return shim.Error(fmt.Sprintf("invoking chaincode %s is %v", chaincodeName, errNotSupported))
+}
+
+// GetState returns the committed value of a key, or nil
+func (s *Stub) GetState(key string) ([]byte, error) {
+	s.ledger.mu.Lock()
+	defer s.ledger.mu.Unlock()
+	return s.ledger.state[key], nil
+}
+
+// PutState buffers a write of a key
+func (s *Stub) PutState(key string, value []byte) error {
+	if key == "" {
+		return errors.New("key must not be an empty string")
+	}
+	if value == nil {
+		value = []byte{}
+	}
+	s.writes[key] = &write{value: value}
+	return nil
+}
+
+// DelState buffers the deletion of a key
+func (s *Stub) DelState(key string) error {
+	s.writes[key] = &write{delete: true}
+	return nil
+}
+
+// SetStateValidationParameter is not supported
+func (s *Stub) SetStateValidationParameter(key string, ep []byte) error {
+	return errNotSupported
+}
+
+// GetStateValidationParameter is not supported
+func (s *Stub) GetStateValidationParameter(key string) ([]byte, error) {
+	return nil, errNotSupported
+}
+
+// GetStateByRange iterates the committed simple keys in [startKey, endKey).
+// Empty keys leave the range open.
+func (s *Stub) GetStateByRange(startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	if startKey == "" {
+		startKey = "\x01"
+	}
+	return s.rangeIterator(startKey, endKey, "")
+}
+
+// GetStateByRangeWithPagination iterates one page of a key range
+func (s *Stub) GetStateByRangeWithPagination(startKey, endKey string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	if startKey == "" {
+		startKey = "\x01"
+	}
+	kvs := s.keyRange(startKey, endKey, "")
+	return paginate(kvs, pageSize, bookmark)
+}
+
+// GetStateByPartialCompositeKey iterates the committed composite keys with
+// the given prefix
+func (s *Stub) GetStateByPartialCompositeKey(objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	startKey, endKey, err := partialCompositeKeyRange(objectType, keys)
+	if err != nil {
+		return nil, err
+	}
+	return s.rangeIterator(startKey, endKey, "")
+}
+
+// GetStateByPartialCompositeKeyWithPagination iterates one page of the
+// composite keys with the given prefix
+func (s *Stub) GetStateByPartialCompositeKeyWithPagination(objectType string, keys []string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	startKey, endKey, err := partialCompositeKeyRange(objectType, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	return paginate(s.keyRange(startKey, endKey, ""), pageSize, bookmark)
+}
+
+// CreateCompositeKey combines an object type and attributes into a key
+func (s *Stub) CreateCompositeKey(objectType string, attributes []string) (string, error) {
+	return shim.CreateCompositeKey(objectType, attributes)
+}
+
+// SplitCompositeKey splits a composite key into its object type and attributes
+func (s *Stub) SplitCompositeKey(compositeKey string) (string, []string, error) {
+	if !strings.HasPrefix(compositeKey, compositeKeyNamespace) {
+		return "", nil, fmt.Errorf("%q is not a composite key", compositeKey)
+	}
+
+	parts := strings.Split(compositeKey[1:], "\x00")
+	if len(parts) < 2 {
+		return "", nil, fmt.Errorf("%q is not a composite key", compositeKey)
+	}
+
+	return parts[0], parts[1 : len(parts)-1], nil
+}
+
+// GetQueryResult runs a Mango query over the committed state
+func (s *Stub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	kvs, err := s.queryKVs(s.ledger.state, query)
+	if err != nil {
+		return nil, err
+	}
+	return &stateIterator{kvs: kvs}, nil
+}
+
+// GetQueryResultWithPagination runs a Mango query and iterates one page of the result
+func (s *Stub) GetQueryResultWithPagination(query string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	kvs, err := s.queryKVs(s.ledger.state, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	return paginate(kvs, pageSize, bookmark)
+}
+
+// GetHistoryForKey iterates the committed versions of a key, oldest first
+func (s *Stub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	s.ledger.mu.Lock()
+	defer s.ledger.mu.Unlock()
+	history := append([]*queryresult.KeyModification(nil), s.ledger.history[key]...)
+	return &historyIterator{modifications: history}, nil
+}
+
+// GetPrivateData returns the committed value of a key in a collection, or nil
+func (s *Stub) GetPrivateData(collection, key string) ([]byte, error) {
+	s.ledger.mu.Lock()
+	defer s.ledger.mu.Unlock()
+	return s.ledger.private[collection][key], nil
+}
+
+// GetPrivateDataHash returns the SHA-256 hash of the committed value of a
+// key in a collection, or nil
+func (s *Stub) GetPrivateDataHash(collection, key string) ([]byte, error) {
+	value, _ := s.GetPrivateData(collection, key)
+	if value == nil {
+		return nil, nil
+	}
+	sum := sha256.Sum256(value)
+	return sum[:], nil
+}
+
+// PutPrivateData buffers a write of a key in a collection
+func (s *Stub) PutPrivateData(collection string, key string, value []byte) error {
+	if collection == "" {
+		return errors.New("collection must not be an empty string")
+	}
+	if key == "" {
+		return errors.New("key must not be an empty string")
+	}
+	s.collectionWrites(collection)[key] = &write{value: value}
+	return nil
+}
+
+// DelPrivateData buffers the deletion of a key in a collection
+func (s *Stub) DelPrivateData(collection, key string) error {
+	s.collectionWrites(collection)[key] = &write{delete: true}
+	return nil
+}
+
+// PurgePrivateData buffers the deletion of a key in a collection. The ledger
+// keeps no private data history, so a purge is a delete.
+func (s *Stub) PurgePrivateData(collection, key string) error {
+	return s.DelPrivateData(collection, key)
+}
+
+// SetPrivateDataValidationParameter is not supported
+func (s *Stub) SetPrivateDataValidationParameter(collection, key string, ep []byte) error {
+	return errNotSupported
+}
+
+// GetPrivateDataValidationParameter is not supported
+func (s *Stub) GetPrivateDataValidationParameter(collection, key string) ([]byte, error) {
+	return nil, errNotSupported
+}
+
+// GetPrivateDataByRange iterates the committed simple keys of a collection in [startKey, endKey)
+func (s *Stub) GetPrivateDataByRange(collection, startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	if startKey == "" {
+		startKey = "\x01"
+	}
+	return s.rangeIterator(startKey, endKey, collection)
+}
+
+// GetPrivateDataByPartialCompositeKey iterates the committed composite keys of
+// a collection with the given prefix
+func (s *Stub) GetPrivateDataByPartialCompositeKey(collection, objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	startKey, endKey, err := partialCompositeKeyRange(objectType, keys)
+	if err != nil {
+		return nil, err
+	}
+	return s.rangeIterator(startKey, endKey, collection)
+}
+
+// GetPrivateDataQueryResult runs a Mango query over the committed contents of a collection
+func (s *Stub) GetPrivateDataQueryResult(collection, query string) (shim.StateQueryIteratorInterface, error) {
+	s.ledger.mu.Lock()
+	data := s.ledger.private[collection]
+	s.ledger.mu.Unlock()
+
+	kvs, err := s.queryKVs(data, query)
+	if err != nil {
+		return nil, err
+	}
+	return &stateIterator{kvs: kvs}, nil
+}
+
+// GetCreator returns the serialized identity of the submitter
+func (s *Stub) GetCreator() ([]byte, error) {
+	return s.creator, nil
+}
+
+// GetTransient returns the transient data of the transaction
+func (s *Stub) GetTransient() (map[string][]byte, error) {
+	return s.transient, nil
+}
+
+// GetBinding is not supported
+func (s *Stub) GetBinding() ([]byte, error) {
+	return nil, errNotSupported
+}
+
+// GetDecorations returns no decorations
+func (s *Stub) GetDecorations() map[string][]byte {
+	return map[string][]byte{}
+}
+
+// GetSignedProposal is not supported
+func (s *Stub) GetSignedProposal() (*peer.SignedProposal, error) {
+	return nil, errNotSupported
+}
+
+// GetTxTimestamp returns the transaction timestamp from the ledger clock
+func (s *Stub) GetTxTimestamp() (*timestamp.Timestamp, error) {
+	return s.timestamp, nil
+}
+
+// SetEvent sets the event of the transaction, replacing any earlier one
+func (s *Stub) SetEvent(name string, payload []byte) error {
+	if name == "" {
+		return errors.New("event name can not be empty string")
+	}
+	s.event = &peer.ChaincodeEvent{EventName: name, Payload: payload}
+	return nil
+}
+
+// Event returns the event set by the transaction, or nil
+func (s *Stub) Event() *peer.ChaincodeEvent {
+	return s.event
+}
+
+func (s *Stub) collectionWrites(collection string) map[string]*write {
+	writes := s.privateWrite[collection]
+	if writes == nil {
+		writes = make(map[string]*write)
+		s.privateWrite[collection] = writes
+	}
+	return writes
+}
+
+// keyRange returns the committed keys in [startKey, endKey) of the world
+// state, or of a collection if one is named, sorted by key
+func (s *Stub) keyRange(startKey, endKey, collection string) []KV {
+	s.ledger.mu.Lock()
+	defer s.ledger.mu.Unlock()
+
+	data := s.ledger.state
+	if collection != "" {
+		data = s.ledger.private[collection]
+	}
+
+	var kvs []KV
+	for _, kv := range sortedKVs(data) {
+		if kv.Key < startKey || (endKey != "" && kv.Key >= endKey) {
+			continue
+		}
+		kvs = append(kvs, kv)
+	}
+	return kvs
+}
+
+func (s *Stub) rangeIterator(startKey, endKey, collection string) (shim.StateQueryIteratorInterface, error) {
+	return &stateIterator{kvs: s.keyRange(startKey, endKey, collection)}, nil
+}
+
+// partialCompositeKeyRange returns the key range holding the composite keys
+// that start with an object type and attributes
+func partialCompositeKeyRange(objectType string, attributes []string) (string, string, error) {
+	prefix, err := shim.CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return "", "", err
+	}
+	return prefix, prefix + string(maxUnicodeRune), nil
+}
+
+// paginate returns the page of kvs that starts at the bookmark key. The
+// returned bookmark is the key of the first record of the next page, or
+// empty on the last page.
+func paginate(kvs []KV, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	if pageSize <= 0 {
+		return nil, nil, fmt.Errorf("page size must be positive, got %d", pageSize)
+	}
+
+	start := 0
+	if bookmark != "" {
+		start = sort.Search(len(kvs), func(i int) bool { return kvs[i].Key >= bookmark })
+	}
+	end := start + int(pageSize)
+	next := ""
+	if end < len(kvs) {
+		next = kvs[end].Key
+	} else {
+		end = len(kvs)
+	}
+
+	page := kvs[start:end]
+	return &stateIterator{kvs: page}, &peer.QueryResponseMetadata{FetchedRecordsCount: int32(len(page)), Bookmark: next}, nil
+}
+
+// stateIterator iterates a fixed list of key-values
+type stateIterator struct {
+	kvs []KV
+	pos int
+}
+
+func (it *stateIterator) HasNext() bool {
+	return it.pos < len(it.kvs)
+}
+
+func (it *stateIterator) Next() (*queryresult.KV, error) {
+	if !it.HasNext() {
+		return nil, errors.New("no more results")
+	}
+	kv := it.kvs[it.pos]
+	it.pos++
+	return &queryresult.KV{Namespace: "", Key: kv.Key, Value: kv.Value}, nil
+}
+
+func (it *stateIterator) Close() error {
+	return nil
+}
+
+// historyIterator iterates the versions of a key
+type historyIterator struct {
+	modifications []*queryresult.KeyModification
+	pos           int
+}
+
+func (it *historyIterator) HasNext() bool {
+	return it.pos < len(it.modifications)
+}
+
+func (it *historyIterator) Next() (*queryresult.KeyModification, error) {
+	if !it.HasNext() {
+		return nil, errors.New("no more results")
+	}
+	modification := it.modifications[it.pos]
+	it.pos++
+	return modification, nil
+}
+
+func (it *historyIterator) Close() error {
+	return nil
+}
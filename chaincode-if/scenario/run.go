@@ -0,0 +1,305 @@
+package scenario
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"chaincode-if/chaincode"
+	"chaincode-if/memledger"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// compositeKeyNamespace starts every composite key
+const compositeKeyNamespace = "\x00"
+
+// Result is the outcome of a scenario: the result of every step and the
+// ledger they left behind
+type Result struct {
+	Scenario   string       `json:"scenario"`
+	Steps      []StepResult `json:"steps"`
+	WorldState []StateEntry `json:"worldState"`
+	Events     []Event      `json:"events"`
+	// Lineage holds the backward lineage of every processed commodity
+	Lineage []json.RawMessage `json:"lineage"`
+}
+
+// StepResult is the outcome of one step
+type StepResult struct {
+	Name     string          `json:"name,omitempty"`
+	As       string          `json:"as"`
+	Function string          `json:"function"`
+	TxID     string          `json:"txId"`
+	Status   int32           `json:"status"`
+	Message  string          `json:"message,omitempty"`
+	Payload  json.RawMessage `json:"payload,omitempty"`
+	// Passed reports whether the step succeeded or failed as expected
+	Passed bool `json:"passed"`
+}
+
+// StateEntry is a world state key and its value. Composite keys are split
+// into their object type and attributes.
+type StateEntry struct {
+	Key        string          `json:"key,omitempty"`
+	ObjectType string          `json:"objectType,omitempty"`
+	Attributes []string        `json:"attributes,omitempty"`
+	Value      json.RawMessage `json:"value,omitempty"`
+	// Raw holds values that are not JSON, such as index entries
+	Raw []byte `json:"raw,omitempty"`
+}
+
+// Event is a chaincode event of a committed transaction
+type Event struct {
+	BlockNumber uint64          `json:"blockNumber"`
+	TxID        string          `json:"txId"`
+	Name        string          `json:"name"`
+	Payload     json.RawMessage `json:"payload"`
+}
+
+// Failed returns the steps that did not pass
+func (r *Result) Failed() []StepResult {
+	var failed []StepResult
+	for _, step := range r.Steps {
+		if !step.Passed {
+			failed = append(failed, step)
+		}
+	}
+	return failed
+}
+
+// Run runs the steps of a scenario in order on an empty ledger. A step that
+// does not pass does not stop the scenario.
+func Run(s *Scenario) (*Result, error) {
+	cc, err := contractapi.NewChaincode(chaincode.NewPalmOilContract())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chaincode: %v", err)
+	}
+
+	ledger := memledger.New()
+	steps, identities, err := replay(ledger, cc, s)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Scenario: s.Name, Steps: steps}
+	result.WorldState = worldState(ledger)
+	result.Events = events(ledger)
+
+	auditor := identities[s.Auditor]
+	if auditor == nil {
+		auditor, err = memledger.NewIdentity("Org1MSP", "palmoil-sim-auditor", map[string]string{"role": "admin"})
+		if err != nil {
+			return nil, fmt.Errorf("failed to enroll auditor: %v", err)
+		}
+	}
+	result.Lineage, err = lineage(ledger, cc, auditor)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Replay runs the steps of a scenario in order on a ledger, such as to seed
+// it with state, and returns their results
+func Replay(ledger *memledger.Ledger, s *Scenario) ([]StepResult, error) {
+	cc, err := contractapi.NewChaincode(chaincode.NewPalmOilContract())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chaincode: %v", err)
+	}
+
+	steps, _, err := replay(ledger, cc, s)
+	return steps, err
+}
+
+// replay runs the steps of a scenario and returns their results and the
+// identities it enrolled
+func replay(ledger *memledger.Ledger, cc shim.Chaincode, s *Scenario) ([]StepResult, map[string]*memledger.Identity, error) {
+	err := setClock(ledger, s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	identities, err := enroll(s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	steps := []StepResult{}
+	for i, step := range s.Steps {
+		stepResult, err := runStep(ledger, cc, identities[step.As], s.Transient, step)
+		if err != nil {
+			return nil, nil, fmt.Errorf("step %d: %v", i+1, err)
+		}
+		steps = append(steps, *stepResult)
+	}
+
+	return steps, identities, nil
+}
+
+// setClock starts the ledger clock at the scenario's start time and tick
+func setClock(ledger *memledger.Ledger, s *Scenario) error {
+	if s.Start == "" && s.Tick == "" {
+		return nil
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if s.Start != "" {
+		var err error
+		start, err = time.Parse(time.RFC3339, s.Start)
+		if err != nil {
+			return fmt.Errorf("invalid start: %v", err)
+		}
+	}
+	tick := time.Second
+	if s.Tick != "" {
+		var err error
+		tick, err = time.ParseDuration(s.Tick)
+		if err != nil {
+			return fmt.Errorf("invalid tick: %v", err)
+		}
+	}
+
+	ledger.SetClock(start, tick)
+	return nil
+}
+
+// enroll creates the identities of a scenario
+func enroll(s *Scenario) (map[string]*memledger.Identity, error) {
+	identities := make(map[string]*memledger.Identity, len(s.Identities))
+	for name, spec := range s.Identities {
+		id, err := memledger.NewIdentity(spec.MSPID, name, spec.Attributes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to enroll %s: %v", name, err)
+		}
+		identities[name] = id
+	}
+
+	return identities, nil
+}
+
+// runStep submits or evaluates the transaction of a step, passing the
+// scenario's transient values along with the step's own
+func runStep(ledger *memledger.Ledger, cc shim.Chaincode, id *memledger.Identity, scenarioTransient map[string]interface{}, step Step) (*StepResult, error) {
+	args := []string{step.Function}
+	for i, arg := range step.Args {
+		argValue, err := argString(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid argument %d: %v", i+1, err)
+		}
+		args = append(args, argValue)
+	}
+
+	transient := make(map[string][]byte, len(scenarioTransient)+len(step.Transient))
+	for _, values := range []map[string]interface{}{scenarioTransient, step.Transient} {
+		for key, value := range values {
+			transientValue, err := argString(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid transient value %s: %v", key, err)
+			}
+			transient[key] = []byte(transientValue)
+		}
+	}
+
+	opts := []memledger.TxOption{memledger.WithTransient(transient)}
+	if step.Peer != "" {
+		opts = append(opts, memledger.WithPeerMSPID(step.Peer))
+	}
+
+	transact := ledger.Invoke
+	if step.Evaluate {
+		transact = ledger.Query
+	}
+	res := transact(cc, id, args, opts...)
+
+	result := &StepResult{
+		Name:     step.Name,
+		As:       step.As,
+		Function: step.Function,
+		TxID:     ledger.LastTxID(),
+		Status:   res.Status,
+		Message:  res.Message,
+	}
+	if json.Valid(res.Payload) {
+		result.Payload = res.Payload
+	}
+
+	failed := res.Status >= shim.ERRORTHRESHOLD
+	if step.ExpectError == "" {
+		result.Passed = !failed
+	} else {
+		result.Passed = failed && strings.Contains(res.Message, step.ExpectError)
+	}
+
+	return result, nil
+}
+
+// worldState returns the committed world state with composite keys split
+func worldState(ledger *memledger.Ledger) []StateEntry {
+	entries := []StateEntry{}
+	for _, kv := range ledger.WorldState() {
+		var entry StateEntry
+		if strings.HasPrefix(kv.Key, compositeKeyNamespace) {
+			parts := strings.Split(strings.TrimSuffix(kv.Key[1:], "\x00"), "\x00")
+			entry.ObjectType = parts[0]
+			entry.Attributes = parts[1:]
+		} else {
+			entry.Key = kv.Key
+		}
+
+		if json.Valid(kv.Value) {
+			entry.Value = kv.Value
+		} else {
+			entry.Raw = kv.Value
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// events returns the events of the ledger
+func events(ledger *memledger.Ledger) []Event {
+	result := []Event{}
+	for _, event := range ledger.Events() {
+		e := Event{BlockNumber: event.BlockNumber, TxID: event.TransactionID, Name: event.EventName, Payload: event.Payload}
+		if !json.Valid(e.Payload) {
+			e.Payload, _ = json.Marshal(string(event.Payload))
+		}
+		result = append(result, e)
+	}
+
+	return result
+}
+
+// lineage traces every processed commodity on the ledger as the auditor
+func lineage(ledger *memledger.Ledger, cc shim.Chaincode, auditor *memledger.Identity) ([]json.RawMessage, error) {
+	res := ledger.Query(cc, auditor, []string{"QueryAllProcessedCommodities"})
+	if res.Status >= shim.ERRORTHRESHOLD {
+		return nil, fmt.Errorf("failed to query processed commodities: %s", res.Message)
+	}
+
+	var batches []struct {
+		ID string `json:"id"`
+	}
+	err := json.Unmarshal(res.Payload, &batches)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse processed commodities: %v", err)
+	}
+	sort.Slice(batches, func(i, j int) bool { return batches[i].ID < batches[j].ID })
+
+	result := []json.RawMessage{}
+	for _, batch := range batches {
+		res := ledger.Query(cc, auditor, []string{"TraceProcessedCommodity", batch.ID})
+		if res.Status >= shim.ERRORTHRESHOLD {
+			return nil, fmt.Errorf("failed to trace processed commodity %s: %s", batch.ID, res.Message)
+		}
+		result = append(result, res.Payload)
+	}
+
+	return result, nil
+}
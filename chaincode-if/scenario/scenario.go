@@ -0,0 +1,168 @@
+// Package scenario runs scripted supply-chain transactions against the
+// palmoil chaincode on an in-memory ledger. Scenarios are written in YAML or
+// JSON and produce a deterministic result, so a result recorded with one
+// version of the chaincode can be compared with the result of another.
+package scenario
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Scenario is a script of transactions submitted by named identities
+type Scenario struct {
+	Name string `json:"name"`
+	// Start is the RFC 3339 timestamp of the first transaction
+	Start string `json:"start,omitempty"`
+	// Tick is the interval between transactions, such as "1h"
+	Tick       string                  `json:"tick,omitempty"`
+	Identities map[string]IdentitySpec `json:"identities"`
+	// Auditor names the identity that traces lineage after the steps. By
+	// default an admin of Org1MSP is used.
+	Auditor string `json:"auditor,omitempty"`
+	// Transient values are passed with every step, such as the identity index
+	// key under "indexKey". The transient values of a step take precedence.
+	Transient map[string]interface{} `json:"transient,omitempty"`
+	Steps     []Step                 `json:"steps"`
+}
+
+// IdentitySpec is an enrolled client of an MSP and its certificate attributes
+type IdentitySpec struct {
+	MSPID      string            `json:"mspId"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// Step is one transaction of a scenario
+type Step struct {
+	Name     string `json:"name,omitempty"`
+	As       string `json:"as"`
+	Function string `json:"function"`
+	// Args are passed as strings. Lists and objects are passed as JSON.
+	Args []interface{} `json:"args,omitempty"`
+	// Transient values are passed as strings. Lists and objects are passed as JSON.
+	Transient map[string]interface{} `json:"transient,omitempty"`
+	// Peer is the MSP of the endorsing peer, by default the MSP of As
+	Peer string `json:"peer,omitempty"`
+	// Evaluate runs the transaction without committing it
+	Evaluate bool `json:"evaluate,omitempty"`
+	// ExpectError is a substring of the error the transaction must fail with
+	ExpectError string `json:"expectError,omitempty"`
+}
+
+// Load reads a scenario from a YAML or JSON file
+func Load(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario: %v", err)
+	}
+
+	return Parse(data)
+}
+
+// Parse parses a YAML or JSON scenario
+func Parse(data []byte) (*Scenario, error) {
+	var document interface{}
+	err := yaml.Unmarshal(data, &document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse scenario: %v", err)
+	}
+
+	// YAML is decoded through JSON so that both formats share the json tags
+	documentJSON, err := json.Marshal(jsonCompatible(document))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse scenario: %v", err)
+	}
+
+	var s Scenario
+	decoder := json.NewDecoder(bytes.NewReader(documentJSON))
+	decoder.UseNumber()
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse scenario: %v", err)
+	}
+
+	err = s.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// validate checks that every step names a function and a known identity
+func (s *Scenario) validate() error {
+	if s.Start != "" {
+		if _, err := time.Parse(time.RFC3339, s.Start); err != nil {
+			return fmt.Errorf("invalid start: %v", err)
+		}
+	}
+	if s.Tick != "" {
+		if _, err := time.ParseDuration(s.Tick); err != nil {
+			return fmt.Errorf("invalid tick: %v", err)
+		}
+	}
+	for name, spec := range s.Identities {
+		if spec.MSPID == "" {
+			return fmt.Errorf("identity %s has no mspId", name)
+		}
+	}
+	if _, ok := s.Identities[s.Auditor]; s.Auditor != "" && !ok {
+		return fmt.Errorf("auditor %s is not a declared identity", s.Auditor)
+	}
+
+	for i, step := range s.Steps {
+		if step.Function == "" {
+			return fmt.Errorf("step %d has no function", i+1)
+		}
+		if _, ok := s.Identities[step.As]; !ok {
+			return fmt.Errorf("step %d is submitted as %q, which is not a declared identity", i+1, step.As)
+		}
+	}
+
+	return nil
+}
+
+// jsonCompatible converts the maps decoded from YAML, which may have
+// non-string keys, into maps that encoding/json accepts
+func jsonCompatible(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			result[fmt.Sprint(k)] = jsonCompatible(v)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(value))
+		for i, v := range value {
+			result[i] = jsonCompatible(v)
+		}
+		return result
+	}
+
+	return value
+}
+
+// argString converts a scenario value into the string passed to the chaincode
+func argString(value interface{}) (string, error) {
+	switch value := value.(type) {
+	case string:
+		return value, nil
+	case json.Number:
+		return value.String(), nil
+	case nil:
+		return "", nil
+	}
+
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(valueJSON), nil
+}
@@ -0,0 +1,141 @@
+package scenario
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	identities := "identities:\n  farmer: {mspId: Org1MSP, attributes: {role: farmer}}\n"
+
+	tests := []struct {
+		name     string
+		document string
+		wantArgs []string
+		wantErr  string
+	}{
+		{name: "YAML", document: identities + "steps:\n  - {as: farmer, function: AddFarmer, args: [FRM_001, Slamet, 2.5, [FARM_001], {a: 1}, true]}\n", wantArgs: []string{"FRM_001", "Slamet", "2.5", `["FARM_001"]`, `{"a":1}`, "true"}},
+		{name: "JSON", document: `{"identities":{"farmer":{"mspId":"Org1MSP"}},"steps":[{"as":"farmer","function":"QueryAllFarms"}]}`, wantArgs: []string{}},
+		{name: "unknown identity", document: identities + "steps:\n  - {as: collector, function: Collect}\n", wantErr: `step 1 is submitted as "collector"`},
+		{name: "no function", document: identities + "steps:\n  - {as: farmer}\n", wantErr: "step 1 has no function"},
+		{name: "no MSP", document: "identities:\n  farmer: {}\n", wantErr: "identity farmer has no mspId"},
+		{name: "unknown auditor", document: identities + "auditor: admin\n", wantErr: "auditor admin is not a declared identity"},
+		{name: "invalid tick", document: identities + "tick: hourly\n", wantErr: "invalid tick"},
+		{name: "unknown field", document: identities + "step: []\n", wantErr: `unknown field "step"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse([]byte(tt.document))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			args := []string{}
+			for _, arg := range s.Steps[0].Args {
+				argValue, err := argString(arg)
+				if err != nil {
+					t.Fatal(err)
+				}
+				args = append(args, argValue)
+			}
+			if strings.Join(args, "|") != strings.Join(tt.wantArgs, "|") {
+				t.Errorf("expected arguments %q, got %q", tt.wantArgs, args)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	s, err := Parse([]byte(`
+identities:
+  farmer: {mspId: Org1MSP, attributes: {role: farmer}}
+  auditor: {mspId: Org1MSP, attributes: {role: admin}}
+auditor: auditor
+transient:
+  indexKey: scenario-test-identity-index-key-01
+steps:
+  - as: farmer
+    function: AddFarmer
+    args: [FRM_001, Slamet, Kampar, []]
+    transient:
+      personal: {nik: "1471010101900001", salt: scenario-salt-0001}
+  - {as: farmer, function: QueryFarmerByID, args: [FRM_001], evaluate: true}
+  - {as: farmer, function: AddFarmer, args: [FRM_002, Sri, Kampar, []], expectError: must be passed as personal transient data}
+  - {as: farmer, function: QueryFarmerByID, args: [FRM_404], evaluate: true}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Run(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	passed := []bool{}
+	for _, step := range result.Steps {
+		passed = append(passed, step.Passed)
+	}
+	if len(passed) != 4 || !passed[0] || !passed[1] || !passed[2] || passed[3] {
+		t.Errorf("expected only the last step to fail, got %+v", result.Steps)
+	}
+	if len(result.Failed()) != 1 || result.Failed()[0].TxID != "tx000004" {
+		t.Errorf("unexpected failed steps %+v", result.Failed())
+	}
+
+	var farmer struct {
+		ID string `json:"id"`
+	}
+	json.Unmarshal(result.Steps[1].Payload, &farmer)
+	if farmer.ID != "FRM_001" {
+		t.Errorf("expected the query to return FRM_001, got %s", result.Steps[1].Payload)
+	}
+
+	// Evaluated steps and failures leave no block behind
+	// The uniqueness index entry of the farmer's NIK is private data
+	if len(result.Events) != 1 || result.Events[0].Name != "FarmerAdded" || len(result.WorldState) != 1 {
+		t.Fatalf("expected one committed transaction, got %+v and %+v", result.Events, result.WorldState)
+	}
+	if result.WorldState[0].ObjectType != "farmer" || result.WorldState[0].Attributes[0] != "FRM_001" {
+		t.Errorf("unexpected world state %+v", result.WorldState)
+	}
+}
+
+// TestSupplyChainScenario replays the example scenario and compares it with
+// its recorded result. Regenerate the recording after an intended change with
+// go run ./cmd/palmoil-sim -golden scenarios/supply-chain.golden.json -update scenarios/supply-chain.yaml
+func TestSupplyChainScenario(t *testing.T) {
+	s, err := Load("../scenarios/supply-chain.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := Run(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed := result.Failed(); len(failed) > 0 {
+		t.Fatalf("steps failed: %+v", failed)
+	}
+
+	got, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile("../scenarios/supply-chain.golden.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(bytes.TrimSpace(want), got) {
+		t.Error("the result differs from scenarios/supply-chain.golden.json")
+	}
+}
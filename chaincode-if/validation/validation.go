@@ -0,0 +1,273 @@
+// Package validation checks the identity, contact, date and numeric fields of the
+// palmoil records. A Validator collects every invalid field of a record and
+// reports them together as an *Error, which lists the field, a code and a
+// message for each.
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Codes of the field errors
+const (
+	CodeRequired  = "required"
+	CodeFormat    = "format"
+	CodeRange     = "range"
+	CodeDuplicate = "duplicate"
+)
+
+// provinceCodes are the two-digit province codes a NIK starts with
+var provinceCodes = map[string]bool{
+	"11": true, "12": true, "13": true, "14": true, "15": true, "16": true, "17": true, "18": true, "19": true,
+	"21": true,
+	"31": true, "32": true, "33": true, "34": true, "35": true, "36": true,
+	"51": true, "52": true, "53": true,
+	"61": true, "62": true, "63": true, "64": true, "65": true,
+	"71": true, "72": true, "73": true, "74": true, "75": true, "76": true,
+	"81": true, "82": true,
+	"91": true, "92": true, "93": true, "94": true, "95": true, "96": true,
+}
+
+// daysInMonth is the most days of each month, allowing 29 February since a
+// NIK only holds the last two digits of the birth year
+var daysInMonth = [13]int{0, 31, 29, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31}
+
+var (
+	digitsPattern = regexp.MustCompile(`^[0-9]+$`)
+	e164Pattern   = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+
+	// errorPattern finds the message of an Error within a longer message
+	errorPattern = regexp.MustCompile(`invalid ([^:\[\]]+): (\[\{)`)
+)
+
+// FieldError describes why the value of one field is invalid. Values are left
+// out, since they may be personal data.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *FieldError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + " " + e.Message
+}
+
+// Error is returned when one or more fields of a record are invalid. Its
+// message is "invalid <record>: " followed by the fields as a JSON array, so
+// clients that only see the message can recover the fields with Parse.
+type Error struct {
+	Record string
+	Fields []FieldError
+}
+
+func (e *Error) Error() string {
+	fieldsJSON, _ := json.Marshal(e.Fields)
+	return fmt.Sprintf("invalid %s: %s", e.Record, fieldsJSON)
+}
+
+// Parse returns the validation error in err, reading it back from the message
+// when err came through a peer or gateway and lost its type
+func Parse(err error) (*Error, bool) {
+	if err == nil {
+		return nil, false
+	}
+	var validationErr *Error
+	if errors.As(err, &validationErr) {
+		return validationErr, true
+	}
+
+	message := err.Error()
+	for _, match := range errorPattern.FindAllStringSubmatchIndex(message, -1) {
+		var fields []FieldError
+		decoder := json.NewDecoder(strings.NewReader(message[match[4]:]))
+		if decoder.Decode(&fields) == nil && len(fields) > 0 {
+			return &Error{Record: message[match[2]:match[3]], Fields: fields}, true
+		}
+	}
+
+	return nil, false
+}
+
+// Validator collects the invalid fields of a record
+type Validator struct {
+	record string
+	fields []FieldError
+}
+
+// New returns a validator of a record, named in errors such as "farmer FRM_001"
+func New(record string) *Validator {
+	return &Validator{record: record}
+}
+
+// Add records an invalid field
+func (v *Validator) Add(field string, code string, message string) {
+	v.fields = append(v.fields, FieldError{Field: field, Code: code, Message: message})
+}
+
+// Check records an invalid field unless ok
+func (v *Validator) Check(field string, ok bool, code string, message string) {
+	if !ok {
+		v.Add(field, code, message)
+	}
+}
+
+// check records the error of a field check
+func (v *Validator) check(field string, err error) {
+	var fieldErr *FieldError
+	if errors.As(err, &fieldErr) {
+		v.Add(field, fieldErr.Code, fieldErr.Message)
+	}
+}
+
+// NIK checks a required NIK
+func (v *Validator) NIK(field string, value string) {
+	v.check(field, NIK(value))
+}
+
+// NIB checks a NIB, which may be empty
+func (v *Validator) NIB(field string, value string) {
+	if value != "" {
+		v.check(field, NIB(value))
+	}
+}
+
+// Phone checks a phone number, which may be empty
+func (v *Validator) Phone(field string, value string) {
+	if value != "" {
+		v.check(field, Phone(value))
+	}
+}
+
+// Email checks an email address, which may be empty
+func (v *Validator) Email(field string, value string) {
+	if value != "" {
+		v.check(field, Email(value))
+	}
+}
+
+// Date checks a required calendar date
+func (v *Validator) Date(field string, value string) {
+	v.check(field, Date(value))
+}
+
+// Range checks that a number is within [min, max]
+func (v *Validator) Range(field string, value float64, min float64, max float64) {
+	v.check(field, Range(value, min, max))
+}
+
+// Min checks that a number is finite and at least min
+func (v *Validator) Min(field string, value float64, min float64) {
+	v.check(field, Range(value, min, math.MaxFloat64))
+}
+
+// Positive checks that a number is finite and greater than 0
+func (v *Validator) Positive(field string, value float64) {
+	v.check(field, Positive(value))
+}
+
+// Err returns the invalid fields as an *Error, or nil if there are none
+func (v *Validator) Err() error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+	return &Error{Record: v.record, Fields: v.fields}
+}
+
+// NIK checks an Indonesian population identity number: 16 digits holding the
+// province, regency and district codes, the birth date, with 40 added to the
+// day for women, and a non-zero serial number
+func NIK(value string) error {
+	if value == "" {
+		return &FieldError{Code: CodeRequired, Message: "is required"}
+	}
+	if len(value) != 16 || !digitsPattern.MatchString(value) {
+		return &FieldError{Code: CodeFormat, Message: "must have 16 digits"}
+	}
+	if !provinceCodes[value[0:2]] {
+		return &FieldError{Code: CodeFormat, Message: "does not start with a province code"}
+	}
+	if value[2:4] == "00" || value[4:6] == "00" {
+		return &FieldError{Code: CodeFormat, Message: "does not hold a regency and district code"}
+	}
+
+	day, _ := strconv.Atoi(value[6:8])
+	month, _ := strconv.Atoi(value[8:10])
+	if day > 40 {
+		day -= 40
+	}
+	if month < 1 || month > 12 || day < 1 || day > daysInMonth[month] {
+		return &FieldError{Code: CodeFormat, Message: "does not hold a valid birth date"}
+	}
+	if value[12:16] == "0000" {
+		return &FieldError{Code: CodeFormat, Message: "does not hold a serial number"}
+	}
+
+	return nil
+}
+
+// NIB checks a business identification number of 13 digits
+func NIB(value string) error {
+	if len(value) != 13 || !digitsPattern.MatchString(value) {
+		return &FieldError{Code: CodeFormat, Message: "must have 13 digits"}
+	}
+	return nil
+}
+
+// Phone checks a phone number in E.164 format, such as +6281234567890
+func Phone(value string) error {
+	if !e164Pattern.MatchString(value) {
+		return &FieldError{Code: CodeFormat, Message: "must be an E.164 phone number such as +6281234567890"}
+	}
+	return nil
+}
+
+// Email checks the syntax of an email address, without a display name
+func Email(value string) error {
+	address, err := mail.ParseAddress(value)
+	if err != nil || address.Name != "" || address.Address != value || !strings.Contains(value[strings.LastIndex(value, "@"):], ".") {
+		return &FieldError{Code: CodeFormat, Message: "is not a valid email address"}
+	}
+	return nil
+}
+
+// Date checks a calendar date in YYYY-MM-DD format, such as 2024-03-01
+func Date(value string) error {
+	if value == "" {
+		return &FieldError{Code: CodeRequired, Message: "is required"}
+	}
+	if _, err := time.Parse(time.DateOnly, value); err != nil {
+		return &FieldError{Code: CodeFormat, Message: "must be a date such as 2024-03-01"}
+	}
+	return nil
+}
+
+// Range checks that a number is within [min, max]
+func Range(value float64, min float64, max float64) error {
+	if math.IsNaN(value) || value < min || value > max {
+		if max == math.MaxFloat64 {
+			return &FieldError{Code: CodeRange, Message: fmt.Sprintf("must be at least %v", min)}
+		}
+		return &FieldError{Code: CodeRange, Message: fmt.Sprintf("must be between %v and %v", min, max)}
+	}
+	return nil
+}
+
+// Positive checks that a number is finite and greater than 0, such as a
+// quantity
+func Positive(value float64) error {
+	if math.IsNaN(value) || math.IsInf(value, 0) || value <= 0 {
+		return &FieldError{Code: CodeRange, Message: "must be greater than 0"}
+	}
+	return nil
+}
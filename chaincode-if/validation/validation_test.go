@@ -0,0 +1,159 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestNIK(t *testing.T) {
+	tests := []struct {
+		name     string
+		nik      string
+		wantCode string
+	}{
+		{name: "man", nik: "1471010101900001"},
+		{name: "woman", nik: "3201024512850003"},
+		{name: "29 February", nik: "3171012902000001"},
+		{name: "new Papua province", nik: "9601011502950001"},
+		{name: "empty", nik: "", wantCode: CodeRequired},
+		{name: "15 digits", nik: "147101010190000", wantCode: CodeFormat},
+		{name: "17 digits", nik: "14710101019000011", wantCode: CodeFormat},
+		{name: "letters", nik: "14710101019000AB", wantCode: CodeFormat},
+		{name: "unknown province", nik: "2071010101900001", wantCode: CodeFormat},
+		{name: "no regency", nik: "1400010101900001", wantCode: CodeFormat},
+		{name: "no district", nik: "1471000101900001", wantCode: CodeFormat},
+		{name: "day zero", nik: "1471010001900001", wantCode: CodeFormat},
+		{name: "day 32", nik: "1471013201900001", wantCode: CodeFormat},
+		{name: "day 40", nik: "1471014001900001", wantCode: CodeFormat},
+		{name: "woman day 72", nik: "1471017201900001", wantCode: CodeFormat},
+		{name: "31 April", nik: "1471013104900001", wantCode: CodeFormat},
+		{name: "month 13", nik: "1471010113900001", wantCode: CodeFormat},
+		{name: "no serial", nik: "1471010101900000", wantCode: CodeFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkCode(t, NIK(tt.nik), tt.wantCode)
+		})
+	}
+}
+
+func TestFormats(t *testing.T) {
+	tests := []struct {
+		name     string
+		check    func(string) error
+		value    string
+		wantCode string
+	}{
+		{name: "NIB", check: NIB, value: "1234567890123"},
+		{name: "short NIB", check: NIB, value: "123456789012", wantCode: CodeFormat},
+		{name: "NIB with letters", check: NIB, value: "12345678901AB", wantCode: CodeFormat},
+		{name: "phone", check: Phone, value: "+6281234567890"},
+		{name: "local phone", check: Phone, value: "081234567890", wantCode: CodeFormat},
+		{name: "phone with spaces", check: Phone, value: "+62 812 3456 7890", wantCode: CodeFormat},
+		{name: "phone of 16 digits", check: Phone, value: "+6281234567890123", wantCode: CodeFormat},
+		{name: "phone with country code 0", check: Phone, value: "+0812345678", wantCode: CodeFormat},
+		{name: "email", check: Email, value: "slamet@example.co.id"},
+		{name: "email without domain", check: Email, value: "slamet@", wantCode: CodeFormat},
+		{name: "email without top-level domain", check: Email, value: "slamet@localhost", wantCode: CodeFormat},
+		{name: "email with display name", check: Email, value: "Slamet <slamet@example.com>", wantCode: CodeFormat},
+		{name: "email with spaces", check: Email, value: "sla met@example.com", wantCode: CodeFormat},
+		{name: "date", check: Date, value: "2024-02-29"},
+		{name: "empty date", check: Date, value: "", wantCode: CodeRequired},
+		{name: "date of a day that does not exist", check: Date, value: "2023-02-29", wantCode: CodeFormat},
+		{name: "date with time", check: Date, value: "2024-03-01T10:00:00Z", wantCode: CodeFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkCode(t, tt.check(tt.value), tt.wantCode)
+		})
+	}
+}
+
+func TestRange(t *testing.T) {
+	checkCode(t, Range(5, 0, 10), "")
+	checkCode(t, Range(0, 0, 10), "")
+	checkCode(t, Range(-0.5, 0, 10), CodeRange)
+	checkCode(t, Range(10.5, 0, 10), CodeRange)
+	checkCode(t, Range(math.NaN(), 0, 10), CodeRange)
+	checkCode(t, Range(math.Inf(1), 0, math.MaxFloat64), CodeRange)
+}
+
+func TestPositive(t *testing.T) {
+	checkCode(t, Positive(0.5), "")
+	checkCode(t, Positive(0), CodeRange)
+	checkCode(t, Positive(-1), CodeRange)
+	checkCode(t, Positive(math.NaN()), CodeRange)
+	checkCode(t, Positive(math.Inf(1)), CodeRange)
+}
+
+func TestValidator(t *testing.T) {
+	v := New("collector COL_001")
+	v.NIB("nib", "")
+	v.Phone("noHP", "")
+	v.Email("email", "")
+	if err := v.Err(); err != nil {
+		t.Fatalf("expected empty optional fields to pass, got %v", err)
+	}
+
+	v.NIK("personal.nik", "123")
+	v.NIB("nib", "123")
+	v.Min("capacity", -1, 0)
+	v.Check("name", false, CodeRequired, "is required")
+
+	var validationErr *Error
+	if !errors.As(v.Err(), &validationErr) {
+		t.Fatalf("expected an *Error, got %v", v.Err())
+	}
+	want := []FieldError{
+		{Field: "personal.nik", Code: CodeFormat, Message: "must have 16 digits"},
+		{Field: "nib", Code: CodeFormat, Message: "must have 13 digits"},
+		{Field: "capacity", Code: CodeRange, Message: "must be at least 0"},
+		{Field: "name", Code: CodeRequired, Message: "is required"},
+	}
+	if validationErr.Record != "collector COL_001" || !reflect.DeepEqual(validationErr.Fields, want) {
+		t.Errorf("unexpected error %+v", validationErr)
+	}
+}
+
+func TestParse(t *testing.T) {
+	original := &Error{Record: "farmer FRM_001", Fields: []FieldError{{Field: "personal.nik", Code: CodeFormat, Message: "must have 16 digits"}}}
+
+	parsed, ok := Parse(fmt.Errorf("wrapped: %w", original))
+	if !ok || parsed != original {
+		t.Errorf("expected the wrapped error, got %+v", parsed)
+	}
+
+	// Errors from a gateway only carry the message
+	gatewayErr := errors.New("rpc error: code = Aborted desc = failed to endorse transaction, see attached details for more info: chaincode response 500, " + original.Error() + " (peer0.org1)")
+	parsed, ok = Parse(gatewayErr)
+	if !ok || !reflect.DeepEqual(parsed, original) {
+		t.Errorf("expected %+v from the message, got %+v", original, parsed)
+	}
+
+	for _, err := range []error{nil, errors.New("the farmer with ID FRM_404 does not exist"), errors.New("invalid argument: [1]")} {
+		if parsed, ok := Parse(err); ok {
+			t.Errorf("expected no validation error in %v, got %+v", err, parsed)
+		}
+	}
+}
+
+// checkCode fails the test unless err is a field error with wantCode, or is
+// nil if wantCode is empty
+func checkCode(t *testing.T, err error, wantCode string) {
+	t.Helper()
+	if wantCode == "" {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Code != wantCode {
+		t.Fatalf("expected a %s error, got %v", wantCode, err)
+	}
+}
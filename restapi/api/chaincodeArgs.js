@@ -0,0 +1,12 @@
+// Chaincode arguments are strings. Documents, patches and lists may be sent as
+// JSON values in the request body and are encoded here, so the request for
+// AddFarmerFromJSON can carry the farmer as an object and AddFarmer its farms
+// as an array.
+function toChaincodeArgs(args = []) {
+    if (!Array.isArray(args)) {
+        throw new Error('args must be an array');
+    }
+    return args.map(arg => typeof arg === 'string' ? arg : JSON.stringify(arg));
+}
+
+module.exports = { toChaincodeArgs };
@@ -0,0 +1,23 @@
+// Validation errors of the chaincode read "invalid <record>: " followed by the
+// invalid fields as a JSON array, somewhere within the gateway's error message
+const validationPattern = /invalid ([^:\[\]]+): (\[\{.*\}\])/;
+
+// parseValidationError returns the record and invalid fields of a chaincode
+// validation error, or null if the message holds none
+function parseValidationError(message) {
+    const match = validationPattern.exec(message || '');
+    if (!match) {
+        return null;
+    }
+    // The pattern may run past the array, so shorten it until it parses
+    for (let end = match[2].length; end > 0; end = match[2].lastIndexOf(']', end - 1)) {
+        try {
+            return { record: match[1], fields: JSON.parse(match[2].slice(0, end)) };
+        } catch (e) {
+            // keep shortening
+        }
+    }
+    return null;
+}
+
+module.exports = { parseValidationError };
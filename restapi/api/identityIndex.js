@@ -0,0 +1,16 @@
+// The contract keys its NIK uniqueness index with a secret shared by every
+// organization of the channel. The server reads it from IDENTITY_INDEX_KEY
+// and adds it to the transient data of the transactions that register,
+// release or resolve a NIK, so REST clients never handle it.
+const INDEX_KEY_FUNCTIONS = ['PurgePersonalData', 'ResolveIdentityNumber'];
+
+function withIndexKey(func, transient) {
+    const indexKey = process.env.IDENTITY_INDEX_KEY;
+    const needsKey = INDEX_KEY_FUNCTIONS.includes(func) || (transient && transient.personal);
+    if (!indexKey || !needsKey) {
+        return transient;
+    }
+    return Object.assign({}, transient, { indexKey });
+}
+
+module.exports = { withIndexKey };
@@ -0,0 +1,20 @@
+// Personal data is kept in the private data collection of the organization
+// that enrolled the entity, <MSP>PersonalData, which only that organization's
+// peers hold. The transactions below read, write or purge it, so they must
+// run on the caller's own peers: any other peer finds nothing, or endorses a
+// different result.
+const PERSONAL_DATA_FUNCTIONS = [
+    'QueryFarmerDetails',
+    'QueryCollectorDetails',
+    'QueryProcessorDetails',
+    'QueryTransporterDetails',
+    'QueryFarmersByNIK',
+    'PurgePersonalData',
+    'MigratePersonalData',
+];
+
+function usesOwnCollection(func, transient) {
+    return PERSONAL_DATA_FUNCTIONS.includes(func) || Boolean(transient && transient.personal);
+}
+
+module.exports = { usesOwnCollection };
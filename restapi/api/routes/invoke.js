@@ -2,6 +2,10 @@ const express = require('express');
 const router = express.Router();
 const { Gateway, Wallets } = require('fabric-network');
 const path = require('path');
+const { toChaincodeArgs } = require('../chaincodeArgs');
+const { parseValidationError } = require('../errors');
+const { withIndexKey } = require('../identityIndex');
+const { usesOwnCollection } = require('../personalData');
 const { buildCCPOrg1, buildCCPOrg2, buildWallet, prettyJSONString } = require('../../../../test-application/javascript/AppUtil.js');
 
 const CONFIG = {
@@ -15,7 +19,7 @@ const CONFIG = {
     }
 };
 
-async function invokeChaincode(org, user, func, args = []) {
+async function invokeChaincode(org, user, func, args = [], transient) {
     const ccp = org === 'Org1' ? buildCCPOrg1() : buildCCPOrg2();
     const walletPath = path.join(__dirname, CONFIG.walletPaths[org]);
     const wallet = await buildWallet(Wallets, walletPath);
@@ -31,7 +35,19 @@ async function invokeChaincode(org, user, func, args = []) {
         const network = await gateway.getNetwork(CONFIG.channel);
         const contract = network.getContract(CONFIG.chaincode);
 
-        const result = await contract.submitTransaction(func, ...args);
+        const transaction = contract.createTransaction(func);
+        if (transient) {
+            const transientData = {};
+            for (const [key, value] of Object.entries(transient)) {
+                transientData[key] = Buffer.from(typeof value === 'string' ? value : JSON.stringify(value));
+            }
+            transaction.setTransient(transientData);
+        }
+        // Transient data and personal data stay with the caller's own peers
+        if (transient || usesOwnCollection(func)) {
+            transaction.setEndorsingOrganizations(`${org}MSP`);
+        }
+        await transaction.submit(...args);
         return { success: true, message: "Transaction executed successfully" };
 
     } finally {
@@ -40,38 +56,23 @@ async function invokeChaincode(org, user, func, args = []) {
 }
 
 router.post('/', async (req, res, next) => {
-    const { org, user, func, args } = req.body;
-
-    // Check if the function being called is 'AddFarmer' or 'UpdateFarmer' and handle the farms attribute
-    if ((func === 'AddFarmer' || func === 'UpdateFarmer') && args && args.length > 6) {
-        try {
-            args[6] = JSON.stringify(args[6]);
-        } catch (e) {
-            return res.status(400).json({ error: "Failed to stringify farms attribute." });
-        }
-    }
+    const { org, user, func, args, transient } = req.body;
 
-    // Check if the function being called is 'AddCollector' or 'UpdateCollector' and handle the farms attribute
-    if ((func === 'AddCollector' || func === 'UpdateCollector') && args && args.length > 8) {
-        try {
-            args[8] = JSON.stringify(args[8]);
-        } catch (e) {
-            return res.status(400).json({ error: "Failed to stringify farms attribute." });
-        }
-    }
-
-    if ((func === 'Process' || func === 'Process') && args && args.length > 5) {
-        try {
-            args[3] = JSON.stringify(args[3]);
-        } catch (e) {
-            return res.status(400).json({ error: "Failed to stringify farms attribute." });
-        }
+    let chaincodeArgs;
+    try {
+        chaincodeArgs = toChaincodeArgs(args);
+    } catch (e) {
+        return res.status(400).json({ success: false, error: e.message });
     }
 
     try {
-        const result = await invokeChaincode(org, user, func, args);
+        const result = await invokeChaincode(org, user, func, chaincodeArgs, withIndexKey(func, transient));
         res.json({ result });
     } catch (error) {
+        const invalid = parseValidationError(error.message);
+        if (invalid) {
+            return res.status(400).json({ success: false, error: `invalid ${invalid.record}`, fields: invalid.fields });
+        }
         res.status(500).json({ success: false, error: error.message });
     }
 });
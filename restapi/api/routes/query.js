@@ -1,7 +1,10 @@
 const express = require('express');
 const router = express.Router();
-const { Gateway, Wallets } = require('fabric-network');
+const { DefaultQueryHandlerStrategies, Gateway, Wallets } = require('fabric-network');
 const path = require('path');
+const { toChaincodeArgs } = require('../chaincodeArgs');
+const { withIndexKey } = require('../identityIndex');
+const { usesOwnCollection } = require('../personalData');
 const { buildCCPOrg1, buildCCPOrg2, buildWallet, prettyJSONString } = require('../../../../test-application/javascript/AppUtil.js');
 
 const CONFIG = {
@@ -15,23 +18,41 @@ const CONFIG = {
     }
 };
 
-async function invokeChaincode(org, user, func, args = []) {
+async function invokeChaincode(org, user, func, args = [], transient) {
     const ccp = org === 'Org1' ? buildCCPOrg1() : buildCCPOrg2();
     const walletPath = path.join(__dirname, CONFIG.walletPaths[org]);
     const wallet = await buildWallet(Wallets, walletPath);
     const gateway = new Gateway();
 
     try {
-        await gateway.connect(ccp, {
+        const options = {
             wallet,
             identity: user,
             discovery: { enabled: true, asLocalhost: true }
-        });
+        };
+        // Queries on personal data are evaluated by the caller's own peers
+        // only. The default strategy falls back to other organizations' peers,
+        // which do not hold the caller's collection.
+        if (usesOwnCollection(func, transient)) {
+            options.queryHandlerOptions = { strategy: DefaultQueryHandlerStrategies.MSPID_SCOPE_SINGLE };
+        }
+        await gateway.connect(ccp, options);
 
         const network = await gateway.getNetwork(CONFIG.channel);
         const contract = network.getContract(CONFIG.chaincode);
 
-        const result = await contract.evaluateTransaction(func, ...args);
+        let result;
+        if (transient) {
+            const transientData = {};
+            for (const [key, value] of Object.entries(transient)) {
+                transientData[key] = Buffer.from(typeof value === 'string' ? value : JSON.stringify(value));
+            }
+            result = await contract.createTransaction(func)
+                .setTransient(transientData)
+                .evaluate(...args);
+        } else {
+            result = await contract.evaluateTransaction(func, ...args);
+        }
         return JSON.parse(result.toString());
 
     } finally {
@@ -43,17 +64,15 @@ async function invokeChaincode(org, user, func, args = []) {
 router.get('/', async (req, res, next) => {
     const { org, user, func, args } = req.body;
 
-    // Check if the function being called is 'AddFarmer' and handle the farms attribute
-    if (func === 'AddFarmer' && args && args.length > 6) {
-        try {
-            args[6] = JSON.stringify(args[6]);
-        } catch (e) {
-            return res.status(400).json({ error: "Failed to stringify farms attribute." });
-        }
+    let chaincodeArgs;
+    try {
+        chaincodeArgs = toChaincodeArgs(args);
+    } catch (e) {
+        return res.status(400).json({ error: e.message });
     }
-    
+
     try {
-        const result = await invokeChaincode(org, user, func, args);
+        const result = await invokeChaincode(org, user, func, chaincodeArgs, withIndexKey(func));
         res.json({ result });
     } catch (error) {
         res.status(500).json({ error: error.message });
@@ -9,13 +9,56 @@
 const { Wallets } = require('fabric-network');
 const FabricCAServices = require('fabric-ca-client');
 const path = require('path');
-const { buildCAClient, registerAndEnrollUser } = require('../../test-application/javascript/CAUtil.js');
+const { buildCAClient } = require('../../test-application/javascript/CAUtil.js');
 const { buildCCPOrg1, buildCCPOrg2, buildWallet } = require('../../test-application/javascript/AppUtil.js');
 
 const mspOrg1 = 'Org1MSP';
 const mspOrg2 = 'Org2MSP';
+const adminUserId = 'admin';
+const roles = ['admin', 'farmer', 'collector', 'processor', 'transporter'];
 
-async function connectToOrg1CA (UserID) {
+// The palmoil chaincode authorizes callers by the role attribute of their
+// enrollment certificate, so it is registered with ecert: true
+async function registerAndEnrollUser (caClient, wallet, orgMspId, userId, affiliation, role) {
+	const userIdentity = await wallet.get(userId);
+	if (userIdentity) {
+		console.log(`An identity for the user ${userId} already exists in the wallet`);
+		return;
+	}
+
+	const adminIdentity = await wallet.get(adminUserId);
+	if (!adminIdentity) {
+		console.log('An identity for the admin user does not exist in the wallet');
+		console.log('Enroll the admin user before retrying');
+		return;
+	}
+
+	const provider = wallet.getProviderRegistry().getProvider(adminIdentity.type);
+	const adminUser = await provider.getUserContext(adminIdentity, adminUserId);
+
+	const secret = await caClient.register({
+		affiliation: affiliation,
+		enrollmentID: userId,
+		role: 'client',
+		attrs: [{ name: 'role', value: role, ecert: true }]
+	}, adminUser);
+	const enrollment = await caClient.enroll({
+		enrollmentID: userId,
+		enrollmentSecret: secret
+	});
+	const x509Identity = {
+		credentials: {
+			certificate: enrollment.certificate,
+			privateKey: enrollment.key.toBytes(),
+		},
+		mspId: orgMspId,
+		type: 'X.509',
+	};
+	await wallet.put(userId, x509Identity);
+	console.log(`Successfully registered and enrolled user ${userId} with role ${role} and imported it into the wallet`);
+}
+
+async function connectToOrg1CA (UserID, role) {
 	console.log('\n--> Register and enrolling new user');
 	const ccpOrg1 = buildCCPOrg1();
 	const caOrg1Client = buildCAClient(FabricCAServices, ccpOrg1, 'ca.org1.example.com');
@@ -23,10 +66,10 @@ async function connectToOrg1CA (UserID) {
 	const walletPathOrg1 = path.join(__dirname, 'wallet/org1');
 	const walletOrg1 = await buildWallet(Wallets, walletPathOrg1);
 
-	await registerAndEnrollUser(caOrg1Client, walletOrg1, mspOrg1, UserID, 'org1.department1');
+	await registerAndEnrollUser(caOrg1Client, walletOrg1, mspOrg1, UserID, 'org1.department1', role);
 }
 
-async function connectToOrg2CA (UserID) {
+async function connectToOrg2CA (UserID, role) {
 	console.log('\n--> Register and enrolling new user');
 	const ccpOrg2 = buildCCPOrg2();
 	const caOrg2Client = buildCAClient(FabricCAServices, ccpOrg2, 'ca.org2.example.com');
@@ -34,24 +77,26 @@ async function connectToOrg2CA (UserID) {
 	const walletPathOrg2 = path.join(__dirname, 'wallet/org2');
 	const walletOrg2 = await buildWallet(Wallets, walletPathOrg2);
 
-	await registerAndEnrollUser(caOrg2Client, walletOrg2, mspOrg2, UserID, 'org2.department1');
+	await registerAndEnrollUser(caOrg2Client, walletOrg2, mspOrg2, UserID, 'org2.department1', role);
 }
 async function main () {
-	if (process.argv[2] === undefined && process.argv[3] === undefined) {
-		console.log('Usage: node registerEnrollUser.js org userID');
+	if (process.argv[2] === undefined || process.argv[3] === undefined || !roles.includes(process.argv[4])) {
+		console.log('Usage: node registerEnrollUser.js org userID role');
+		console.log(`Role must be one of ${roles.join(', ')}`);
 		process.exit(1);
 	}
 
 	const org = process.argv[2];
 	const userId = process.argv[3];
+	const role = process.argv[4];
 
 	try {
 		if (org === 'Org1' || org === 'org1') {
-			await connectToOrg1CA(userId);
+			await connectToOrg1CA(userId, role);
 		} else if (org === 'Org2' || org === 'org2') {
-			await connectToOrg2CA(userId);
+			await connectToOrg2CA(userId, role);
 		} else {
-			console.log('Usage: node registerEnrollUser.js org userID');
+			console.log('Usage: node registerEnrollUser.js org userID role');
 			console.log('Org must be Org1 or Org2');
 		}
 	} catch (error) {